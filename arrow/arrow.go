@@ -0,0 +1,332 @@
+package arrow
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+)
+
+const (
+	// defaultBatchSize is the maximum number of rows per record batch unless --batch-size is given
+	defaultBatchSize = 64 << 10
+	// fileMagic starts and ends an Arrow IPC file, streams have no magic number
+	fileMagic = "ARROW1"
+)
+
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	reader, err := newRowReader(cfg)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	SetColumns(table, reader.batches.Schema())
+	for {
+		batch, err := reader.batches.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := AppendRecordBatch(cfg, table, batch); err != nil {
+			return err
+		}
+	}
+}
+
+// batchReader is implemented by the readers of IPC files and streams
+type batchReader interface {
+	Schema() *arrow.Schema
+	// Read returns the next record batch, valid until the next call, or io.EOF
+	Read() (arrow.RecordBatch, error)
+}
+
+// rowReader reads the rows of an IPC file or stream one record batch at a time
+type rowReader struct {
+	batches batchReader
+	close   func()
+	columns []common.Column
+	batch   arrow.RecordBatch
+	row     int // next row of batch
+	nulls   []bool
+}
+
+// NewRowReader returns a reader of the rows of an IPC file or stream
+func NewRowReader(cfg *common.Config) (common.RowReader, error) {
+	return newRowReader(cfg)
+}
+
+// newRowReader opens cfg.InputPath, or cfg.Reader when the file cannot be
+// opened by name, as an IPC file if it starts with the file magic number and
+// as an IPC stream otherwise
+func newRowReader(cfg *common.Config) (*rowReader, error) {
+	var input io.Reader = cfg.Reader
+	closeInput := func() {}
+	if path := cfg.InputPath(); path != "" || cfg.Reader == nil {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		input, closeInput = f, func() { f.Close() }
+	}
+
+	buffered := bufio.NewReader(input)
+	magic, _ := buffered.Peek(len(fileMagic))
+	reader := &rowReader{}
+	if string(magic) == fileMagic {
+		// The file format keeps its schema and batch index in a footer
+		var src ipc.ReadAtSeeker
+		if f, ok := input.(*os.File); ok {
+			src = f
+		} else {
+			data, err := io.ReadAll(buffered)
+			if err != nil {
+				closeInput()
+				return nil, err
+			}
+			src = bytes.NewReader(data)
+		}
+		file, err := ipc.NewFileReader(src, ipc.WithAllocator(memory.DefaultAllocator))
+		if err != nil {
+			closeInput()
+			return nil, fmt.Errorf("invalid Arrow IPC file: %w", err)
+		}
+		reader.batches = file
+		reader.close = func() { file.Close(); closeInput() }
+	} else {
+		stream, err := ipc.NewReader(buffered, ipc.WithAllocator(memory.DefaultAllocator))
+		if err != nil {
+			closeInput()
+			return nil, fmt.Errorf("invalid Arrow IPC stream: %w", err)
+		}
+		reader.batches = stream
+		reader.close = func() { stream.Release(); closeInput() }
+	}
+
+	columns := &common.Table{}
+	SetColumns(columns, reader.batches.Schema())
+	reader.columns = columns.Columns
+	return reader, nil
+}
+
+func (r *rowReader) Columns() []common.Column {
+	return r.columns
+}
+
+func (r *rowReader) Next() ([]string, error) {
+	for r.batch == nil || r.row >= int(r.batch.NumRows()) {
+		batch, err := r.batches.Read()
+		if err == io.EOF {
+			r.batch = nil
+			r.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.batch, r.row = batch, 0
+	}
+	row, nulls := Row(r.batch, r.row)
+	r.row++
+	r.nulls = nulls
+	return row, nil
+}
+
+func (r *rowReader) Nulls() []bool {
+	return r.nulls
+}
+
+// Close closes the input file, it is safe to call twice
+func (r *rowReader) Close() error {
+	r.close()
+	r.close = func() {}
+	return nil
+}
+
+// batchWriter writes record batches to an IPC file or stream
+type batchWriter struct {
+	writer interface {
+		Write(arrow.RecordBatch) error
+		Close() error
+	}
+	file *os.File // cfg.Result, nil when writing to cfg.Writer
+}
+
+// newBatchWriter creates cfg.Result, or writes to cfg.Writer when no file is given
+func newBatchWriter(cfg *common.Config, schema *arrow.Schema) (*batchWriter, error) {
+	options := []ipc.Option{ipc.WithSchema(schema), ipc.WithAllocator(memory.DefaultAllocator)}
+	switch compression := cfg.GetExtensionString("compression", "none"); compression {
+	case "none":
+	case "lz4":
+		options = append(options, ipc.WithLZ4())
+	case "zstd":
+		options = append(options, ipc.WithZstd())
+	default:
+		return nil, fmt.Errorf("unsupported Arrow compression %q, expected none, lz4 or zstd", compression)
+	}
+
+	w := &batchWriter{}
+	out := cfg.Writer
+	if cfg.Result != "" || cfg.Writer == nil {
+		file, err := os.Create(cfg.Result)
+		if err != nil {
+			return nil, err
+		}
+		w.file, out = file, file
+	}
+
+	format := cfg.GetExtensionString("ipc", "")
+	if format == "" && strings.EqualFold(filepath.Ext(cfg.Result), ".arrows") {
+		format = "stream"
+	}
+	if format == "stream" {
+		w.writer = ipc.NewWriter(out, options...)
+		return w, nil
+	}
+	writer, err := ipc.NewFileWriter(out, options...)
+	if err != nil {
+		w.closeFile()
+		return nil, err
+	}
+	w.writer = writer
+	return w, nil
+}
+
+// Close writes the end of the stream or the file footer
+func (w *batchWriter) Close() error {
+	err := w.writer.Close()
+	if closeErr := w.closeFile(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (w *batchWriter) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Close()
+}
+
+func Marshal(cfg *common.Config, table *common.Table) error {
+	schema, err := NewSchema(table)
+	if err != nil {
+		return err
+	}
+	writer, err := newBatchWriter(cfg, schema)
+	if err != nil {
+		return err
+	}
+	batchSize := cfg.GetExtensionInt("batch-size", defaultBatchSize)
+	for from := 0; from < len(table.Rows); from += batchSize {
+		if err := writeBatch(writer, schema, table, from, min(from+batchSize, len(table.Rows))); err != nil {
+			writer.Close()
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// writeBatch writes the rows from, to of table as one record batch
+func writeBatch(writer *batchWriter, schema *arrow.Schema, table *common.Table, from, to int) error {
+	batch, err := NewRecordBatch(memory.DefaultAllocator, schema, table, from, to)
+	if err != nil {
+		return err
+	}
+	defer batch.Release()
+	return writer.writer.Write(batch)
+}
+
+// rowWriter collects rows into record batches and writes each batch when it
+// is full, so the table is never held in memory as a whole
+type rowWriter struct {
+	cfg       *common.Config
+	batchSize int
+	pending   *common.Table // rows of the next batch
+	schema    *arrow.Schema
+	writer    *batchWriter
+}
+
+// NewRowWriter returns a streaming writer with --streaming. Without it the
+// whole table is read first, so that every value counts for the column types.
+func NewRowWriter(cfg *common.Config) (common.RowWriter, error) {
+	if !cfg.GetExtensionBool("streaming", false) {
+		return nil, common.ErrStreamNotSupported
+	}
+	return &rowWriter{
+		cfg:       cfg,
+		batchSize: cfg.GetExtensionInt("batch-size", defaultBatchSize),
+	}, nil
+}
+
+func (w *rowWriter) WriteHeader(columns []common.Column) error {
+	w.pending = &common.Table{}
+	for _, column := range columns {
+		w.pending.Headers = append(w.pending.Headers, column.Name)
+	}
+	w.pending.Columns = columns
+	return nil
+}
+
+func (w *rowWriter) WriteRow(row []string) error {
+	return w.WriteNullRow(row, nil)
+}
+
+func (w *rowWriter) WriteNullRow(row []string, nulls []bool) error {
+	for i, null := range nulls {
+		if null {
+			w.pending.SetNull(len(w.pending.Rows), i)
+		}
+	}
+	w.pending.Rows = append(w.pending.Rows, row)
+	if len(w.pending.Rows) >= w.batchSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// flush writes the pending rows. The first batch fixes the schema of the file.
+func (w *rowWriter) flush() error {
+	if w.writer == nil {
+		schema, err := NewSchema(w.pending)
+		if err != nil {
+			return err
+		}
+		w.schema = schema
+		writer, err := newBatchWriter(w.cfg, w.schema)
+		if err != nil {
+			return err
+		}
+		w.writer = writer
+	}
+	if len(w.pending.Rows) > 0 {
+		if err := writeBatch(w.writer, w.schema, w.pending, 0, len(w.pending.Rows)); err != nil {
+			return fmt.Errorf("%w; the column types come from the first %d rows, convert without --streaming to read all rows first", err, w.batchSize)
+		}
+	}
+	w.pending.Rows, w.pending.Nulls = nil, nil
+	return nil
+}
+
+func (w *rowWriter) Close() error {
+	if w.pending == nil {
+		return nil // WriteHeader was not called
+	}
+	if err := w.flush(); err != nil {
+		if w.writer != nil {
+			w.writer.Close()
+		}
+		return err
+	}
+	return w.writer.Close()
+}
@@ -0,0 +1,217 @@
+package arrow
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTable() *common.Table {
+	table := &common.Table{
+		Headers: []string{"id", "name", "score", "born", "active"},
+		Rows: [][]string{
+			{"1", "alice", "9.5", "2020-01-02", "true"},
+			{"2", "", "", "2021-03-04", "false"},
+			{"3", "NULL", "7", "", "true"},
+		},
+	}
+	table.SetNull(2, 1)
+	return table
+}
+
+func TestMarshalAndUnmarshal(t *testing.T) {
+	expected := [][]string{
+		{"1", "alice", "9.5", "2020-01-02", "true"},
+		{"2", "", "NULL", "2021-03-04", "false"},
+		{"3", "NULL", "7", "NULL", "true"},
+	}
+	for _, name := range []string{"data.arrow", "data.arrows"} {
+		t.Run(name, func(t *testing.T) {
+			result := filepath.Join(t.TempDir(), name)
+			require.NoError(t, Marshal(&common.Config{Result: result}, newTable()))
+
+			got := &common.Table{}
+			require.NoError(t, Unmarshal(&common.Config{File: result}, got))
+			assert.Equal(t, []string{"id", "name", "score", "born", "active"}, got.Headers)
+			assert.Equal(t, expected, got.Rows)
+			assert.True(t, got.IsNull(2, 1))
+			assert.False(t, got.IsNull(1, 1))
+			assert.Equal(t, "int64", got.Columns[0].SourceType)
+			assert.Equal(t, "date32", got.Columns[3].SourceType)
+			assert.Equal(t, common.ColumnTypeBool, got.Columns[4].Type)
+		})
+	}
+}
+
+func TestCellTextFloats(t *testing.T) {
+	mem := memory.NewGoAllocator()
+	float64s := array.NewFloat64Builder(mem)
+	defer float64s.Release()
+	float64s.AppendValues([]float64{12345678901234567, 0.000001, 9.5}, nil)
+	column := float64s.NewArray()
+	defer column.Release()
+	assert.Equal(t, "12345678901234568", CellText(column, 0))
+	assert.Equal(t, "0.000001", CellText(column, 1))
+	assert.Equal(t, "9.5", CellText(column, 2))
+
+	float32s := array.NewFloat32Builder(mem)
+	defer float32s.Release()
+	float32s.Append(1e21)
+	column = float32s.NewArray()
+	defer column.Release()
+	assert.Equal(t, "1000000000000000000000", CellText(column, 0))
+}
+
+func TestMarshalIPCFormat(t *testing.T) {
+	var file, stream bytes.Buffer
+	require.NoError(t, Marshal(&common.Config{Writer: &file}, newTable()))
+	require.NoError(t, Marshal(&common.Config{Writer: &stream, Extension: map[string]string{"ipc": "stream"}}, newTable()))
+	assert.True(t, bytes.HasPrefix(file.Bytes(), []byte(fileMagic)))
+	assert.True(t, bytes.HasPrefix(stream.Bytes(), []byte("\xff\xff\xff\xff")))
+
+	// Both are read from a reader as well as from a file
+	for _, buf := range []*bytes.Buffer{&file, &stream} {
+		got := &common.Table{}
+		require.NoError(t, Unmarshal(&common.Config{Reader: buf}, got))
+		assert.Len(t, got.Rows, 3)
+	}
+}
+
+func TestMarshalOptions(t *testing.T) {
+	rows := make([][]string, 5)
+	for i := range rows {
+		rows[i] = []string{"row", "1"}
+	}
+	table := &common.Table{Headers: []string{"a", "b"}, Rows: rows}
+
+	result := filepath.Join(t.TempDir(), "data.arrow")
+	cfg := &common.Config{Result: result, Extension: map[string]string{"compression": "lz4", "batch-size": "2"}}
+	require.NoError(t, Marshal(cfg, table))
+
+	f, err := os.Open(result)
+	require.NoError(t, err)
+	defer f.Close()
+	reader, err := ipc.NewFileReader(f)
+	require.NoError(t, err)
+	defer reader.Close()
+	assert.Equal(t, 3, reader.NumRecords())
+
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{File: result}, got))
+	assert.Equal(t, rows, got.Rows)
+
+	// A Parquet codec is not silently dropped
+	cfg.Extension = map[string]string{"compression": "snappy"}
+	assert.ErrorContains(t, Marshal(cfg, table), `unsupported Arrow compression "snappy"`)
+}
+
+func TestRowReaderAndWriter(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := &common.Config{Writer: &buf, Extension: map[string]string{"streaming": "true", "batch-size": "2"}}
+	writer, err := NewRowWriter(cfg)
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteHeader([]common.Column{{Name: "a"}, {Name: "b"}}))
+	require.NoError(t, writer.(common.NullRowWriter).WriteNullRow([]string{"1", "NULL"}, []bool{false, true}))
+	require.NoError(t, writer.WriteRow([]string{"2", "x"}))
+	require.NoError(t, writer.WriteRow([]string{"3", "y"}))
+	assert.NotZero(t, buf.Len(), "the first batch is written before Close")
+	require.NoError(t, writer.Close())
+
+	reader, err := NewRowReader(&common.Config{Reader: &buf})
+	require.NoError(t, err)
+	assert.Equal(t, "int64", reader.Columns()[0].SourceType)
+	var rows [][]string
+	var nulls [][]bool
+	for {
+		row, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		rows = append(rows, row)
+		nulls = append(nulls, reader.(common.NullRowReader).Nulls())
+	}
+	assert.Equal(t, [][]string{{"1", "NULL"}, {"2", "x"}, {"3", "y"}}, rows)
+	assert.Equal(t, []bool{false, true}, nulls[0])
+	assert.Equal(t, []bool{false, false}, nulls[1])
+}
+
+func TestRowWriter(t *testing.T) {
+	_, err := NewRowWriter(&common.Config{})
+	assert.ErrorIs(t, err, common.ErrStreamNotSupported)
+
+	// A later batch that does not fit the types of the first one fails
+	cfg := &common.Config{Writer: &bytes.Buffer{}, Extension: map[string]string{"streaming": "true", "batch-size": "1"}}
+	writer, err := NewRowWriter(cfg)
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteHeader([]common.Column{{Name: "a"}}))
+	require.NoError(t, writer.WriteRow([]string{"1"}))
+	err = writer.WriteRow([]string{"x"})
+	assert.ErrorContains(t, err, `value "x" does not fit the int64 type`)
+	assert.ErrorContains(t, err, "--streaming")
+
+	// Without rows the file still has a schema
+	var buf bytes.Buffer
+	cfg = &common.Config{Writer: &buf, Extension: map[string]string{"streaming": "true"}}
+	writer, err = NewRowWriter(cfg)
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteHeader([]common.Column{{Name: "a"}}))
+	require.NoError(t, writer.Close())
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: &buf}, got))
+	assert.Equal(t, []string{"a"}, got.Headers)
+	assert.Empty(t, got.Rows)
+
+	// A conversion failing before the header closes the writer without output
+	buf.Reset()
+	writer, err = NewRowWriter(cfg)
+	require.NoError(t, err)
+	assert.NoError(t, writer.Close())
+	assert.Zero(t, buf.Len())
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	err := Unmarshal(&common.Config{Reader: bytes.NewReader([]byte("a,b\n1,2\n"))}, &common.Table{})
+	assert.ErrorContains(t, err, "invalid Arrow IPC stream")
+
+	err = Unmarshal(&common.Config{Reader: bytes.NewReader([]byte(fileMagic + "\x00\x00"))}, &common.Table{})
+	assert.ErrorContains(t, err, "invalid Arrow IPC file")
+
+	err = Unmarshal(&common.Config{File: "does-not-exist.arrow"}, &common.Table{})
+	assert.True(t, os.IsNotExist(err))
+
+	table := &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1"}}}
+	assert.Error(t, Marshal(&common.Config{Writer: &bytes.Buffer{}}, table))
+}
+
+func TestMarshalRaggedRow(t *testing.T) {
+	// The schema is inferred from every row, so a short row after the first one is an error, not a panic
+	table := &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1", "2"}, {"1"}}}
+	err := Marshal(&common.Config{Writer: &bytes.Buffer{}}, table)
+	assert.EqualError(t, err, "row length 1 does not match header length 2")
+}
+
+func TestFixture(t *testing.T) {
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{File: "../test/mysql.arrow"}, table))
+	assert.Equal(t, []string{"FIELD", "TYPE", "NULL", "KEY", "DEFAULT", "EXTRA"}, table.Headers)
+	assert.Equal(t, []string{"user_id", "smallint(5)", "NO", "PRI", "NULL", "auto_increment"}, table.Rows[0])
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, common.DetectCertain, detect("ARROW1\x00\x00"))
+	assert.Equal(t, common.DetectLikely, detect("\xff\xff\xff\xff\x10\x01"))
+	assert.Equal(t, common.DetectNone, detect("a,b\n"))
+	assert.Error(t, validateBatchSize("0"))
+	assert.NoError(t, validateBatchSize("1000"))
+}
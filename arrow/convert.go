@@ -0,0 +1,293 @@
+package arrow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+)
+
+// The conversions between Arrow record batches and tables are shared with
+// the parquet format, which reads and writes Parquet files through Arrow,
+// and with the avro format for its column types.
+
+// timestampLayouts are the layouts of the date column values written as timestamps
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// ColumnType maps an Arrow type to a column type
+func ColumnType(t arrow.DataType) common.ColumnType {
+	switch id := t.ID(); {
+	case id == arrow.BOOL:
+		return common.ColumnTypeBool
+	case arrow.IsInteger(id):
+		return common.ColumnTypeInt
+	case arrow.IsFloating(id), arrow.IsDecimal(id):
+		return common.ColumnTypeFloat
+	case id == arrow.DATE32, id == arrow.DATE64, id == arrow.TIMESTAMP:
+		return common.ColumnTypeDate
+	}
+	return common.ColumnTypeString
+}
+
+// SetColumns sets the headers and the column schema of table from an Arrow schema
+func SetColumns(table *common.Table, schema *arrow.Schema) {
+	table.TrackNulls()
+	for _, field := range schema.Fields() {
+		table.Headers = append(table.Headers, field.Name)
+		table.Columns = append(table.Columns, common.Column{
+			Name:       field.Name,
+			Type:       ColumnType(field.Type),
+			Nullable:   field.Nullable,
+			SourceType: field.Type.String(),
+		})
+	}
+}
+
+// AppendRecordBatch appends the rows of batch to table, see Row, checking each with cfg.CheckRow
+func AppendRecordBatch(cfg *common.Config, table *common.Table, batch arrow.RecordBatch) error {
+	for r := 0; r < int(batch.NumRows()); r++ {
+		row, nulls := Row(batch, r)
+		if err := cfg.CheckRow(len(table.Rows), row); err != nil {
+			return err
+		}
+		for i, null := range nulls {
+			if null {
+				table.SetNull(len(table.Rows), i)
+			}
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return nil
+}
+
+// Row returns the cells of row r of batch and their NULL flags
+func Row(batch arrow.RecordBatch, r int) ([]string, []bool) {
+	row := make([]string, batch.NumCols())
+	nulls := make([]bool, batch.NumCols())
+	for i, column := range batch.Columns() {
+		if column.IsNull(r) {
+			row[i] = common.DefaultNullString
+			nulls[i] = true
+			continue
+		}
+		row[i] = CellText(column, r)
+	}
+	return row, nulls
+}
+
+// CellText formats value r of column. Binary values are text if they are
+// valid UTF-8, as written by tools that leave out the string type. Floats are
+// written without an exponent, like the other formats do.
+func CellText(column arrow.Array, r int) string {
+	switch c := column.(type) {
+	case *array.Float16:
+		return strconv.FormatFloat(float64(c.Value(r).Float32()), 'f', -1, 32)
+	case *array.Float32:
+		return strconv.FormatFloat(float64(c.Value(r)), 'f', -1, 32)
+	case *array.Float64:
+		return strconv.FormatFloat(c.Value(r), 'f', -1, 64)
+	case *array.Binary:
+		if value := c.Value(r); utf8.Valid(value) {
+			return string(value)
+		}
+	case *array.LargeBinary:
+		if value := c.Value(r); utf8.Valid(value) {
+			return string(value)
+		}
+	}
+	return column.ValueStr(r)
+}
+
+// NewSchema infers the Arrow schema of table. Every column gets the narrowest
+// of int64, float64, bool, date32, timestamp and utf8 that holds all of its
+// values, starting from the type in the table schema. A row that does not
+// have a cell for each header is an error.
+func NewSchema(table *common.Table) (*arrow.Schema, error) {
+	for _, row := range table.Rows {
+		if len(row) != len(table.Headers) {
+			return nil, fmt.Errorf("row length %d does not match header length %d", len(row), len(table.Headers))
+		}
+	}
+	columns := table.Schema()
+	var inferred []common.Column
+	fields := make([]arrow.Field, len(table.Headers))
+	for i, header := range table.Headers {
+		t := columns[i].Type
+		if t == common.ColumnTypeUnknown {
+			// Columns without a type in a partial schema, e.g. of a streamed header
+			if inferred == nil {
+				inferred = common.InferColumns(table)
+			}
+			t = inferred[i].Type
+		}
+		fields[i] = arrow.Field{Name: header, Type: fieldType(table, i, t), Nullable: true}
+	}
+	return arrow.NewSchema(fields, nil), nil
+}
+
+// NewRecordBatch converts the rows from, to of table to a record batch of
+// schema. It fails on a value that does not fit the type of its column.
+func NewRecordBatch(mem memory.Allocator, schema *arrow.Schema, table *common.Table, from, to int) (arrow.RecordBatch, error) {
+	for _, row := range table.Rows[from:to] {
+		if len(row) != len(table.Headers) {
+			return nil, fmt.Errorf("row length %d does not match header length %d", len(row), len(table.Headers))
+		}
+	}
+	columns := make([]arrow.Array, len(schema.Fields()))
+	defer func() {
+		for _, column := range columns {
+			if column != nil {
+				column.Release()
+			}
+		}
+	}()
+	for i, field := range schema.Fields() {
+		column, err := buildColumn(mem, table, i, from, to, field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", field.Name, err)
+		}
+		columns[i] = column
+	}
+	return array.NewRecordBatch(schema, columns, int64(to-from)), nil
+}
+
+// isNull reports whether the cell at r, i is written as null in a column that is not a string column
+func isNull(table *common.Table, r, i int) bool {
+	if table.IsNull(r, i) {
+		return true
+	}
+	cell := strings.TrimSpace(table.Rows[r][i])
+	return cell == "" || common.InferType(cell) == nil
+}
+
+// fieldType infers the Arrow type of column i from its column type,
+// falling back to a wider type when a value does not fit it
+func fieldType(table *common.Table, i int, t common.ColumnType) arrow.DataType {
+	fits := func(parse func(string) bool) bool {
+		for r := range table.Rows {
+			if !isNull(table, r, i) && !parse(strings.TrimSpace(table.Rows[r][i])) {
+				return false
+			}
+		}
+		return true
+	}
+	isInt := func(s string) bool { _, err := strconv.ParseInt(s, 10, 64); return err == nil }
+	isFloat := func(s string) bool { _, err := strconv.ParseFloat(s, 64); return err == nil }
+	isBool := func(s string) bool { _, ok := common.TypedValue(s, common.ColumnTypeBool).(bool); return ok }
+	isDate := func(s string) bool { _, err := time.Parse("2006-01-02", s); return err == nil }
+	isTimestamp := func(s string) bool { _, _, ok := ParseTimestamp(s); return ok }
+
+	switch t {
+	case common.ColumnTypeInt:
+		if fits(isInt) {
+			return arrow.PrimitiveTypes.Int64
+		}
+		if fits(isFloat) {
+			return arrow.PrimitiveTypes.Float64
+		}
+	case common.ColumnTypeFloat:
+		if fits(isFloat) {
+			return arrow.PrimitiveTypes.Float64
+		}
+	case common.ColumnTypeBool:
+		if fits(isBool) {
+			return arrow.FixedWidthTypes.Boolean
+		}
+	case common.ColumnTypeDate:
+		if fits(isDate) {
+			return arrow.FixedWidthTypes.Date32
+		}
+		if fits(isTimestamp) {
+			// Timestamps with an offset are stored in UTC, others as local wall time
+			zone := ""
+			for r := range table.Rows {
+				if _, hasZone, _ := ParseTimestamp(strings.TrimSpace(table.Rows[r][i])); hasZone && !isNull(table, r, i) {
+					zone = "UTC"
+					break
+				}
+			}
+			return &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: zone}
+		}
+	}
+	return arrow.BinaryTypes.String
+}
+
+// ParseTimestamp parses a date and time, reporting whether it has a UTC offset
+func ParseTimestamp(s string) (time.Time, bool, bool) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, strings.HasSuffix(layout, "Z07:00"), true
+		}
+	}
+	return time.Time{}, false, false
+}
+
+// buildColumn converts the rows from, to of column i to an Arrow array of dataType
+func buildColumn(mem memory.Allocator, table *common.Table, i, from, to int, dataType arrow.DataType) (arrow.Array, error) {
+	builder := array.NewBuilder(mem, dataType)
+	defer builder.Release()
+	for r := from; r < to; r++ {
+		cell := strings.TrimSpace(table.Rows[r][i])
+		if dataType.ID() == arrow.STRING {
+			if table.IsNull(r, i) {
+				builder.AppendNull()
+			} else {
+				builder.(*array.StringBuilder).Append(table.Rows[r][i])
+			}
+			continue
+		}
+		if isNull(table, r, i) {
+			builder.AppendNull()
+			continue
+		}
+		var err error
+		switch b := builder.(type) {
+		case *array.Int64Builder:
+			var v int64
+			if v, err = strconv.ParseInt(cell, 10, 64); err == nil {
+				b.Append(v)
+			}
+		case *array.Float64Builder:
+			var v float64
+			if v, err = strconv.ParseFloat(cell, 64); err == nil {
+				b.Append(v)
+			}
+		case *array.BooleanBuilder:
+			if v, ok := common.TypedValue(cell, common.ColumnTypeBool).(bool); ok {
+				b.Append(v)
+			} else {
+				err = fmt.Errorf("not a boolean")
+			}
+		case *array.Date32Builder:
+			var t time.Time
+			if t, err = time.Parse("2006-01-02", cell); err == nil {
+				b.Append(arrow.Date32FromTime(t))
+			}
+		case *array.TimestampBuilder:
+			if t, _, ok := ParseTimestamp(cell); ok {
+				b.Append(arrow.Timestamp(t.UnixMicro()))
+			} else {
+				err = fmt.Errorf("not a timestamp")
+			}
+		default:
+			return nil, fmt.Errorf("unsupported Arrow type %s", dataType)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("value %q does not fit the %s type of the column", table.Rows[r][i], dataType)
+		}
+	}
+	return builder.NewArray(), nil
+}
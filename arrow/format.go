@@ -0,0 +1,49 @@
+package arrow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "arrow",
+		Description: "Apache Arrow IPC file (Feather v2) or stream",
+		Aliases:     []string{"feather"},
+		Extensions:  []string{".arrow", ".feather", ".arrows"},
+		Detect:      detect,
+		Params: []common.FormatParam{
+			{Name: "ipc", DefaultValue: "file", AllowedValues: "file, stream", Description: "IPC format written: file (Feather v2) or stream, the default for .arrows files", Use: common.ParamWrite},
+			{Name: "batch-size", DefaultValue: strconv.Itoa(defaultBatchSize), Validate: validateBatchSize, Description: "Maximum number of rows per record batch", Use: common.ParamWrite},
+			{Name: "compression", DefaultValue: "none", AllowedValues: "none, lz4, zstd", Description: "Compression of the record batch buffers", Use: common.ParamWrite},
+			{Name: "streaming", DefaultValue: "false", AllowedValues: "true, false", Description: "Write each record batch once its rows are read; untyped columns take their types from the first batch", Use: common.ParamWrite},
+		},
+		Unmarshal:    Unmarshal,
+		Marshal:      Marshal,
+		NewRowReader: NewRowReader,
+		NewRowWriter: NewRowWriter,
+	})
+}
+
+// detect scores an Arrow IPC file by its magic number, or the continuation
+// marker that starts each message of an IPC stream
+func detect(sample string) int {
+	switch {
+	case strings.HasPrefix(sample, fileMagic):
+		return common.DetectCertain
+	case strings.HasPrefix(sample, "\xff\xff\xff\xff"):
+		return common.DetectLikely
+	}
+	return common.DetectNone
+}
+
+// validateBatchSize accepts a positive number of rows
+func validateBatchSize(value string) error {
+	if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+		return fmt.Errorf("invalid value %q, expected a positive number of rows", value)
+	}
+	return nil
+}
@@ -3,7 +3,9 @@ package ascii
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/martianzhang/tableconvert/common"
 
@@ -19,236 +21,274 @@ var tableStyleMap = map[string]string{
 }
 
 func Unmarshal(cfg *common.Config, table *common.Table) error {
-	style := cfg.GetExtensionString("style", ASCIIDefaultStyle)
-
-	switch style {
-	case ASCIIDefaultStyle:
-		return boxUnmarshal(cfg, table)
-	default:
-		return omniUnmarshal(cfg, table)
+	reader, err := NewRowReader(cfg)
+	if err != nil {
+		return err
 	}
+	return common.ReadAllRows(cfg, reader, table)
 }
 
-func omniUnmarshal(cfg *common.Config, table *common.Table) error {
-	style := cfg.GetExtensionString("style", "dot")
-	if len(style) != 1 {
-		if v, ok := tableStyleMap[style]; ok {
-			style = v
-		} else {
-			return fmt.Errorf("unknown style: %s", style)
-		}
-	}
-
-	scanner := bufio.NewScanner(cfg.Reader)
-	lineNumber := 0
-	var headers []string
-	var rows [][]string
-	parsingState := "start" // states: start, header, header_separator, data, end
+// isBorderLine checks if a line represents a table border (e.g., "+---+---+")
+func isBorderLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "+") && strings.HasSuffix(trimmed, "+") && strings.Contains(trimmed, "-")
+}
 
-	done := false
-	for scanner.Scan() {
-		lineNumber++
-		line := scanner.Text()
-		trimmedLine := strings.TrimSpace(line)
+// isDataLine checks if a line contains table data (e.g., "| val1 | val2 |")
+func isDataLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "|") && strings.HasSuffix(trimmed, "|")
+}
 
-		if trimmedLine == "" {
-			continue // Skip empty lines
+// borderRune returns the character a style border line is drawn with, e.g. "+"
+// for "+++++", and false if the line is not a run of one character
+func borderRune(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	border, _ := utf8.DecodeRuneInString(trimmed)
+	if utf8.RuneCountInString(trimmed) < 3 || border == '-' || border == '=' {
+		return "", false
+	}
+	for _, c := range trimmed {
+		if c != border {
+			return "", false
 		}
+	}
+	return string(border), true
+}
 
-		// Inline separator line check
-		isSeparator := func() bool {
-			if !strings.HasPrefix(trimmedLine, style) || !strings.HasSuffix(trimmedLine, style) {
-				return false
-			}
-			for _, c := range trimmedLine {
-				if c != rune(style[0]) && c != '+' {
-					return false
-				}
-			}
-			return true
-		}
+// splitAndTrim splits a data/header line by '|' and trims whitespace from each part.
+// It skips empty strings resulting from leading/trailing '|' characters.
+func splitAndTrim(line string) []string {
+	parts := strings.Split(line, "|")
+	if len(parts) < 2 { // Must have at least leading and trailing '|'
+		return []string{}
+	}
+	// Exclude first and last empty strings from leading/trailing '|'
+	relevantParts := parts[1 : len(parts)-1]
+	result := make([]string, len(relevantParts))
+	for i, part := range relevantParts {
+		result[i] = strings.TrimSpace(part)
+	}
+	return result
+}
 
-		// Inline data line check and parsing
-		parseDataLine := func() []string {
-			trimmed := strings.TrimPrefix(strings.TrimSuffix(trimmedLine, style), style)
-			parts := strings.Split(trimmed, style)
-			var cells []string
-			for _, part := range parts {
-				cells = append(cells, strings.TrimSpace(part))
-			}
-			return cells
-		}
+// rowReader parses an ASCII table line by line
+type rowReader struct {
+	scanner      *bufio.Scanner
+	lineNumber   int
+	style        string // border character, empty for the box style
+	autoStyle    bool   // take the style from the first line, no style was given
+	headers      []string
+	columns      []common.Column
+	parsingState string // states: start, header, header_separator, data, end
+	rowCount     int
+}
 
-		switch parsingState {
-		case "start":
-			if isSeparator() {
-				parsingState = "header"
-			}
-		case "header":
-			if strings.HasPrefix(trimmedLine, style) && strings.HasSuffix(trimmedLine, style) {
-				headers = parseDataLine()
-				if len(headers) == 0 {
-					return &common.ParseError{
-						LineNumber: lineNumber,
-						Message:    "failed to parse header line",
-						Line:       line,
-					}
-				}
-				parsingState = "header_separator"
-			}
-		case "header_separator":
-			if isSeparator() {
-				parsingState = "data"
-			}
-		case "data":
-			if isSeparator() {
-				parsingState = "end"
-				done = true
-			} else if strings.HasPrefix(trimmedLine, style) && strings.HasSuffix(trimmedLine, style) {
-				row := parseDataLine()
-				if len(row) != len(headers) {
-					return &common.ParseError{
-						LineNumber: lineNumber,
-						Message:    fmt.Sprintf("row has %d columns, expected %d", len(row), len(headers)),
-						Line:       line,
-					}
-				}
-				rows = append(rows, row)
-			}
-		case "end":
-			done = true
-		}
+// NewRowReader returns a streaming ASCII table reader. Input is read up to the header separator.
+func NewRowReader(cfg *common.Config) (common.RowReader, error) {
+	_, styled := cfg.Extension["style"]
+	r := &rowReader{
+		scanner:      bufio.NewScanner(cfg.Reader),
+		parsingState: "start",
+		autoStyle:    !styled,
+	}
 
-		if done {
-			break
+	style := cfg.GetExtensionString("style", ASCIIDefaultStyle)
+	if style != ASCIIDefaultStyle {
+		if len(style) != 1 {
+			if v, ok := tableStyleMap[style]; ok {
+				style = v
+			} else {
+				return nil, fmt.Errorf("unknown style: %s", style)
+			}
 		}
-	}
-	if err := scanner.Err(); err != nil {
-		return err
+		r.style = style
 	}
 
-	if parsingState != "end" {
-		return fmt.Errorf("incomplete table data")
+	if _, err := r.read(true); err != nil && err != io.EOF {
+		return nil, err
 	}
+	r.columns = common.NewColumns(r.headers)
+	return r, nil
+}
 
-	table.Headers = headers
-	table.Rows = rows
-	return nil
+func (r *rowReader) Columns() []common.Column {
+	return r.columns
 }
 
-func boxUnmarshal(cfg *common.Config, table *common.Table) error {
-	// isBorderLine checks if a line represents a table border (e.g., "+---+---+")
-	isBorderLine := func(line string) bool {
-		trimmed := strings.TrimSpace(line)
-		return strings.HasPrefix(trimmed, "+") && strings.HasSuffix(trimmed, "+") && strings.Contains(trimmed, "-")
-	}
+func (r *rowReader) Next() ([]string, error) {
+	return r.read(false)
+}
 
-	// isDataLine checks if a line contains table data (e.g., "| val1 | val2 |")
-	isDataLine := func(line string) bool {
-		trimmed := strings.TrimSpace(line)
-		return strings.HasPrefix(trimmed, "|") && strings.HasSuffix(trimmed, "|")
-	}
+// read consumes input lines until a data row is parsed, or until the header
+// is complete when untilHeader is set. It returns io.EOF at the end of the table.
+func (r *rowReader) read(untilHeader bool) ([]string, error) {
+	for r.parsingState != "end" && r.scanner.Scan() {
+		r.lineNumber++
+		line := r.scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue // Skip empty lines
+		}
+		if r.autoStyle {
+			// A first line such as "+++++" or "·····" is the border of a styled table
+			r.style, _ = borderRune(line)
+			r.autoStyle = false
+		}
 
-	// splitAndTrim splits a data/header line by '|' and trims whitespace from each part.
-	// It skips empty strings resulting from leading/trailing '|' characters.
-	splitAndTrim := func(line string) []string {
-		parts := strings.Split(line, "|")
-		if len(parts) < 2 { // Must have at least leading and trailing '|'
-			return []string{}
+		var row []string
+		var err error
+		if r.style == "" {
+			row, err = r.parseBoxLine(line)
+		} else {
+			row, err = r.parseStyleLine(line)
 		}
-		// Exclude first and last empty strings from leading/trailing '|'
-		relevantParts := parts[1 : len(parts)-1]
-		result := make([]string, len(relevantParts))
-		for i, part := range relevantParts {
-			result[i] = strings.TrimSpace(part)
+		if err != nil {
+			return nil, err
+		}
+		if row != nil {
+			r.rowCount++
+			return row, nil
+		}
+		if untilHeader && r.parsingState == "data" {
+			return nil, nil
 		}
-		return result
 	}
+	return nil, r.finish()
+}
 
-	scanner := bufio.NewScanner(cfg.Reader)
-	lineNumber := 0
-	var headers []string
-	var rows [][]string
-	parsingState := "start" // Possible states: start, header, header_separator, data, end
-
-	for scanner.Scan() {
-		lineNumber++
-		line := scanner.Text()
-		trimmedLine := strings.TrimSpace(line)
-
-		if trimmedLine == "" {
-			continue // Skip empty lines
+// parseBoxLine handles one line of a box style table
+func (r *rowReader) parseBoxLine(line string) ([]string, error) {
+	switch r.parsingState {
+	case "start":
+		if isBorderLine(line) {
+			r.parsingState = "header" // Found top border, expect header next
+		}
+	case "header":
+		if isDataLine(line) {
+			r.headers = splitAndTrim(line)
+			if len(r.headers) == 0 {
+				return nil, &common.ParseError{LineNumber: r.lineNumber, Message: "failed to parse header line", Line: line}
+			}
+			r.parsingState = "header_separator" // Expect separator line after header
+		} else {
+			return nil, &common.ParseError{LineNumber: r.lineNumber, Message: "expected header data line (| Header |)", Line: line}
+		}
+	case "header_separator":
+		if isBorderLine(line) {
+			r.parsingState = "data" // Found separator, expect data rows next
+		} else {
+			return nil, &common.ParseError{LineNumber: r.lineNumber, Message: "expected header separator line (+--+)", Line: line}
 		}
+	case "data":
+		if isDataLine(line) {
+			// Note: Currently lenient about column count mismatch
+			return splitAndTrim(line), nil
+		} else if isBorderLine(line) {
+			r.parsingState = "end" // Found bottom border
+		} else {
+			return nil, &common.ParseError{LineNumber: r.lineNumber, Message: "expected data line (| Data |) or bottom border line (+--+)", Line: line}
+		}
+	}
+	return nil, nil
+}
 
-		done := false
+// parseStyleLine handles one line of a table drawn with a single border character
+func (r *rowReader) parseStyleLine(line string) ([]string, error) {
+	style := r.style
+	border, _ := utf8.DecodeRuneInString(style)
+	trimmedLine := strings.TrimSpace(line)
 
-		switch parsingState {
-		case "start":
-			if isBorderLine(line) {
-				parsingState = "header" // Found top border, expect header next
-			} else {
-				continue
+	// Separator line check
+	isSeparator := func() bool {
+		if !strings.HasPrefix(trimmedLine, style) || !strings.HasSuffix(trimmedLine, style) {
+			return false
+		}
+		for _, c := range trimmedLine {
+			if c != border && c != '+' {
+				return false
 			}
-		case "header":
-			if isDataLine(line) {
-				headers = splitAndTrim(line)
-				if len(headers) == 0 {
-					return &common.ParseError{LineNumber: lineNumber, Message: "failed to parse header line", Line: line}
+		}
+		return true
+	}
+	isData := strings.HasPrefix(trimmedLine, style) && strings.HasSuffix(trimmedLine, style)
+
+	// Data line parsing
+	parseDataLine := func() []string {
+		trimmed := strings.TrimPrefix(strings.TrimSuffix(trimmedLine, style), style)
+		parts := strings.Split(trimmed, style)
+		var cells []string
+		for _, part := range parts {
+			cells = append(cells, strings.TrimSpace(part))
+		}
+		return cells
+	}
+
+	switch r.parsingState {
+	case "start":
+		if isSeparator() {
+			r.parsingState = "header"
+		}
+	case "header":
+		if isData {
+			r.headers = parseDataLine()
+			if len(r.headers) == 0 {
+				return nil, &common.ParseError{
+					LineNumber: r.lineNumber,
+					Message:    "failed to parse header line",
+					Line:       line,
 				}
-				parsingState = "header_separator" // Expect separator line after header
-			} else {
-				return &common.ParseError{LineNumber: lineNumber, Message: "expected header data line (| Header |)", Line: line}
-			}
-		case "header_separator":
-			if isBorderLine(line) {
-				parsingState = "data" // Found separator, expect data rows next
-			} else {
-				return &common.ParseError{LineNumber: lineNumber, Message: "expected header separator line (+--+)", Line: line}
 			}
-		case "data":
-			if isDataLine(line) {
-				rowData := splitAndTrim(line)
-				if len(rowData) != len(headers) {
-					// Note: Currently lenient about column count mismatch
-					// Could add warning or make this an error
+			r.parsingState = "header_separator"
+		}
+	case "header_separator":
+		if isSeparator() {
+			r.parsingState = "data"
+		}
+	case "data":
+		if isSeparator() {
+			r.parsingState = "end"
+		} else if isData {
+			row := parseDataLine()
+			if len(row) != len(r.headers) {
+				return nil, &common.ParseError{
+					LineNumber: r.lineNumber,
+					Message:    fmt.Sprintf("row has %d columns, expected %d", len(row), len(r.headers)),
+					Line:       line,
 				}
-				rows = append(rows, rowData)
-				// Remain in 'data' state to process more rows
-			} else if isBorderLine(line) {
-				parsingState = "end" // Found bottom border
-				done = true
-			} else {
-				return &common.ParseError{LineNumber: lineNumber, Message: "expected data line (| Data |) or bottom border line (+--+)", Line: line}
 			}
-		case "end":
-			done = true // Parsing complete, break out of the loop
+			return row, nil
 		}
+	}
+	return nil, nil
+}
 
-		if done {
-			break
+// finish validates the parser state at the end of input, returning io.EOF for a complete table
+func (r *rowReader) finish() error {
+	if r.style != "" {
+		if err := r.scanner.Err(); err != nil {
+			return err
+		}
+		if r.parsingState != "end" {
+			return fmt.Errorf("incomplete table data")
 		}
+		return io.EOF
 	}
 
-	if err := scanner.Err(); err != nil {
+	if err := r.scanner.Err(); err != nil {
 		return fmt.Errorf("error reading input: %w", err)
 	}
 
 	// Validate final parsing state
-	if parsingState != "end" {
-		// Handle cases where input ends without proper termination
-		if parsingState == "data" && len(rows) > 0 {
-			// Lenient: Accept missing bottom border if we have data
-		} else if parsingState == "header_separator" && len(headers) > 0 {
-			// Lenient: Accept missing data if we have headers
-		} else {
-			return &common.ParseError{LineNumber: lineNumber, Message: fmt.Sprintf("input ended unexpectedly in state '%s', missing bottom border?", parsingState), Line: ""}
-		}
+	switch {
+	case r.parsingState == "end":
+	case r.parsingState == "data" && r.rowCount > 0:
+		// Lenient: Accept missing bottom border if we have data
+	case r.parsingState == "header_separator" && len(r.headers) > 0:
+		// Lenient: Accept missing data if we have headers
+	default:
+		return &common.ParseError{LineNumber: r.lineNumber, Message: fmt.Sprintf("input ended unexpectedly in state '%s', missing bottom border?", r.parsingState), Line: ""}
 	}
-
-	table.Headers = headers
-	table.Rows = rows
-	return nil
-
+	return io.EOF
 }
 
 func Marshal(cfg *common.Config, table *common.Table) error {
@@ -3,6 +3,7 @@ package ascii
 import (
 	"bytes"
 	"errors"
+	"io"
 	"strings"
 	"testing"
 
@@ -149,7 +150,7 @@ func TestMarshal(t *testing.T) {
 		},
 	}
 
-	args := []string{"--from", "mysql", "--to", "mysql"}
+	args := []string{"--from", "ascii", "--to", "ascii"}
 	cfg, err := common.ParseConfig(args)
 	assert.Nil(t, err)
 
@@ -206,3 +207,73 @@ func TestMarshal_CustomStyleTable(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, expected, buf.String())
 }
+
+func TestRowReader(t *testing.T) {
+	tests := []struct {
+		name  string
+		style string
+		input string
+	}{
+		{
+			name:  "box",
+			style: "box",
+			input: "+---+---+\n| a | b |\n+---+---+\n| 1 | 2 |\n| 3 | 4 |\n+---+---+\n",
+		},
+		{
+			name:  "plus",
+			style: "plus",
+			input: "+++++++++\n+ a + b +\n+++++++++\n+ 1 + 2 +\n+ 3 + 4 +\n+++++++++\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &common.Config{
+				Reader:    strings.NewReader(tt.input),
+				Extension: map[string]string{"style": tt.style},
+			}
+			reader, err := NewRowReader(cfg)
+			assert.NoError(t, err)
+			assert.Equal(t, common.NewColumns([]string{"a", "b"}), reader.Columns())
+
+			var rows [][]string
+			for {
+				row, err := reader.Next()
+				if err == io.EOF {
+					break
+				}
+				assert.NoError(t, err)
+				rows = append(rows, row)
+			}
+			assert.Equal(t, [][]string{{"1", "2"}, {"3", "4"}}, rows)
+		})
+	}
+}
+
+func TestUnmarshalDetectedStyle(t *testing.T) {
+	// Without --style the border character is taken from the first line
+	for style, border := range map[string]string{"plus": "+", "dot": "·", "bubble": "◌"} {
+		var buf bytes.Buffer
+		table := &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1", "2"}}}
+		assert.NoError(t, Marshal(&common.Config{Writer: &buf, Extension: map[string]string{"style": style}}, table), style)
+		assert.True(t, strings.HasPrefix(buf.String(), border), style)
+
+		got := &common.Table{}
+		assert.NoError(t, Unmarshal(&common.Config{Reader: &buf, Extension: map[string]string{}}, got), style)
+		assert.Equal(t, table.Headers, got.Headers, style)
+		assert.Equal(t, table.Rows, got.Rows, style)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	plus := "+++++++++++\n+ a  + b  +\n+++++++++++\n+ 1  + 2  +\n+++++++++++\n"
+	assert.Equal(t, 90, detect(plus))
+	assert.Equal(t, 90, detect(strings.ReplaceAll(plus, "+", "·")))
+
+	// Box tables are the mysql format, the box drawing style does not parse
+	assert.Equal(t, common.DetectNone, detect("+---+---+\n| a | b |\n+---+---+\n"))
+	assert.Equal(t, common.DetectNone, detect("┌───┬───┐\n│ a │ b │\n├───┼───┤\n"))
+	// A rule of one character is not a table
+	assert.Equal(t, common.DetectNone, detect("*****\ntext\n*****\n"))
+	assert.Equal(t, common.DetectNone, detect("+++++\n+ a +\n"))
+}
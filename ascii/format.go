@@ -0,0 +1,49 @@
+package ascii
+
+import (
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:            "ascii",
+		Description:     "ASCII box-drawing table",
+		OutputExtension: ".txt",
+		Detect:          detect,
+		DetectOrder:     10,
+		Params: []common.FormatParam{
+			{Name: "style", DefaultValue: "box", AllowedValues: "box, plus(+), dot(·), bubble(◌)", Description: "Table Style",
+				Validate: validateStyle},
+		},
+		Unmarshal:    Unmarshal,
+		Marshal:      Marshal,
+		NewRowReader: NewRowReader,
+	})
+}
+
+// validateStyle accepts a style name or a single border character
+func validateStyle(value string) error {
+	if len(value) == 1 {
+		return nil
+	}
+	return common.OneOf("box", "plus", "dot", "bubble")(value)
+}
+
+// detect scores a table drawn with one border character, as the plus, dot and
+// bubble styles write it: a border line, a header row and a second border line.
+// The box style is the mysql format and is left to it.
+func detect(sample string) int {
+	lines := common.SampleLines(sample, 3)
+	if len(lines) < 3 {
+		return common.DetectNone
+	}
+	border, ok := borderRune(lines[0])
+	header := strings.TrimSpace(lines[1])
+	if !ok || strings.TrimSpace(lines[2]) != strings.TrimSpace(lines[0]) ||
+		!strings.HasPrefix(header, border) || !strings.HasSuffix(header, border) || len(header) <= 2*len(border) {
+		return common.DetectNone
+	}
+	return 90
+}
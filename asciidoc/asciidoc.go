@@ -0,0 +1,506 @@
+package asciidoc
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+// sectionPattern matches a section title such as "== Users"
+var sectionPattern = regexp.MustCompile(`^(={1,6}|#{1,6})\s+(\S.*)$`)
+
+// specPattern matches the cell specifier before a cell separator, e.g. "2+", ".3+^", "2*" or "a"
+var specPattern = regexp.MustCompile(`(^|\s)(?:(\d+)?(?:\.(\d+))?([+*]))?([<^>])?(\.[<^>])?([adehlmsv])?$`)
+
+// delimiter returns the first character of a table delimiter line such as "|===", 0 for any other line
+func delimiter(line string) byte {
+	if len(line) >= 4 && strings.ContainsRune("|,:", rune(line[0])) && strings.Trim(line[1:], "=") == "" {
+		return line[0]
+	}
+	return 0
+}
+
+// attributes parses a block attribute list such as [cols="1,2",options="header"].
+// Keys are lowercased, "%header" style options are added to "options".
+func attributes(line string) map[string]string {
+	attrs := make(map[string]string)
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+
+	var items []string
+	var item strings.Builder
+	var quote rune
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == ',':
+			items = append(items, item.String())
+			item.Reset()
+			continue
+		}
+		item.WriteRune(r)
+	}
+	items = append(items, item.String())
+
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if key, value, found := strings.Cut(item, "="); found {
+			key = strings.ToLower(strings.TrimSpace(key))
+			if key == "opts" {
+				key = "options"
+			}
+			attrs[key] = strings.Trim(strings.TrimSpace(value), `"'`)
+			continue
+		}
+		// Shorthand options and roles of the first positional attribute, e.g. %header%autowidth
+		for i, part := range strings.Split(item, "%") {
+			if i > 0 && part != "" {
+				attrs["options"] = strings.TrimPrefix(attrs["options"]+","+part, ",")
+			}
+		}
+	}
+	return attrs
+}
+
+// columnCount returns the number of columns given by a cols attribute such as "3", "1,2,1" or "2*,3"
+func columnCount(cols string) int {
+	if n, err := strconv.Atoi(strings.TrimSpace(cols)); err == nil {
+		return n
+	}
+	count := 0
+	for _, spec := range strings.FieldsFunc(cols, func(r rune) bool { return r == ',' || r == ';' }) {
+		if multiplier, _, found := strings.Cut(spec, "*"); found {
+			if n, err := strconv.Atoi(strings.TrimSpace(multiplier)); err == nil {
+				count += n
+				continue
+			}
+		}
+		count++
+	}
+	return count
+}
+
+// parse reads up to limit table blocks of cfg.Reader, all of them when limit is 0.
+// A table is named after its block title, or the closest preceding section title.
+func parse(cfg *common.Config, limit int) ([]*common.Table, error) {
+	if cfg.Reader == nil {
+		return nil, fmt.Errorf("Unmarshal: Reader in Config cannot be nil")
+	}
+	content, err := io.ReadAll(cfg.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading input: %w", err)
+	}
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+
+	var tables []*common.Table
+	section, title := "", ""
+	var attrs map[string]string
+	for i := 0; i < len(lines) && (limit == 0 || len(tables) < limit); i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case line == "" || strings.HasPrefix(line, "//"):
+		case delimiter(line) != 0:
+			end := i + 1
+			for end < len(lines) && strings.TrimSpace(lines[end]) != line {
+				end++
+			}
+			if end == len(lines) {
+				return nil, &common.ParseError{LineNumber: i + 1, Message: fmt.Sprintf("table block is not closed by %q", line), Line: lines[i]}
+			}
+			if attrs == nil {
+				attrs = make(map[string]string)
+			}
+			table, err := parseBlock(cfg, lines[i+1:end], delimiter(line), attrs, i+2)
+			if err != nil {
+				return nil, err
+			}
+			table.Name = section
+			if title != "" {
+				table.Name = title
+			}
+			tables = append(tables, table)
+			section, title, attrs = "", "", nil
+			i = end
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") && !strings.HasPrefix(line, "[["):
+			attrs = attributes(line)
+		case len(line) > 1 && line[0] == '.' && line[1] != '.' && line[1] != ' ':
+			title = line[1:]
+		case sectionPattern.MatchString(line):
+			section = sectionPattern.FindStringSubmatch(line)[2]
+			title, attrs = "", nil
+		default:
+			title, attrs = "", nil
+		}
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("parsing failed: no AsciiDoc table found in input")
+	}
+	return tables, nil
+}
+
+// parseBlock reads the lines between the delimiters of a table block, firstLine being the line number of the first one.
+// The first row is the header row.
+func parseBlock(cfg *common.Config, lines []string, delim byte, attrs map[string]string, firstLine int) (*common.Table, error) {
+	format := map[byte]string{'|': "psv", ',': "csv", ':': "dsv"}[delim]
+	if attrs["format"] != "" {
+		format = strings.ToLower(attrs["format"])
+	}
+
+	var rows [][]common.SpanCell
+	var err error
+	switch format {
+	case "csv", "tsv":
+		rows, err = csvRows(lines, format, attrs["separator"], firstLine)
+	case "dsv":
+		rows = dsvRows(lines, attrs["separator"])
+	case "psv":
+		rows, err = psvRows(cfg, lines, attrs, firstLine)
+	default:
+		return nil, &common.ParseError{LineNumber: firstLine - 1, Message: fmt.Sprintf("unsupported table format %q", format)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if format != "psv" {
+		// psvRows checks each row as it ends
+		for i, row := range rows {
+			if err := cfg.CheckSpanRow(i-1, row); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(rows) == 0 {
+		return nil, &common.ParseError{LineNumber: firstLine - 1, Message: "table block has no cells"}
+	}
+	grid, spans, _, err := common.LayoutSpans(rows)
+	if err != nil {
+		return nil, err
+	}
+	table := &common.Table{}
+	table.SetGrid(grid, spans)
+	return table, nil
+}
+
+// csvRows reads a table with format=csv or format=tsv, one record per row
+func csvRows(lines []string, format, separator string, firstLine int) ([][]common.SpanCell, error) {
+	reader := csv.NewReader(strings.NewReader(strings.Join(lines, "\n")))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	if format == "tsv" {
+		reader.Comma = '\t'
+	}
+	if separator != "" {
+		reader.Comma = []rune(separator)[0]
+	}
+	records, err := reader.ReadAll()
+	if err != nil {
+		var csvErr *csv.ParseError
+		if errors.As(err, &csvErr) {
+			return nil, &common.ParseError{LineNumber: firstLine + csvErr.Line - 1, Message: csvErr.Err.Error()}
+		}
+		return nil, err
+	}
+	var rows [][]common.SpanCell
+	for _, record := range records {
+		row := make([]common.SpanCell, len(record))
+		for i, value := range record {
+			row[i].Value = strings.TrimSpace(value)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// dsvRows reads a table with format=dsv, one line per row with "\:" escaping the separator
+func dsvRows(lines []string, separator string) [][]common.SpanCell {
+	if separator == "" {
+		separator = ":"
+	}
+	var rows [][]common.SpanCell
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var row []common.SpanCell
+		for _, value := range splitUnescaped(line, separator) {
+			row = append(row, common.SpanCell{Value: strings.TrimSpace(value)})
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// splitUnescaped splits s at each separator not preceded by a backslash, removing the escaping backslashes
+func splitUnescaped(s, separator string) []string {
+	var parts []string
+	var part strings.Builder
+	for {
+		i := strings.Index(s, separator)
+		if i < 0 {
+			break
+		}
+		if i > 0 && s[i-1] == '\\' {
+			part.WriteString(s[:i-1] + separator)
+		} else {
+			part.WriteString(s[:i])
+			parts = append(parts, part.String())
+			part.Reset()
+		}
+		s = s[i+len(separator):]
+	}
+	part.WriteString(s)
+	return append(parts, part.String())
+}
+
+// psvRows reads a table of "|" separated cells. Cells fill the columns given by
+// the cols attribute, or the cells of the first line, and then start a new row.
+func psvRows(cfg *common.Config, lines []string, attrs map[string]string, firstLine int) ([][]common.SpanCell, error) {
+	separator := attrs["separator"]
+	if separator == "" {
+		separator = "|"
+	}
+	cells, err := psvCells(strings.Join(lines, "\n"), separator)
+	if err != nil {
+		return nil, &common.ParseError{LineNumber: firstLine, Message: err.Error(), Line: lines[0]}
+	}
+	if len(cells) == 0 {
+		return nil, nil
+	}
+
+	columns := columnCount(attrs["cols"])
+	if attrs["cols"] == "" {
+		// The cells on the first line that holds any set the number of columns
+		for _, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			first, _ := psvCells(line, separator)
+			for _, cell := range first {
+				columns += max(cell.ColSpan, 1)
+			}
+			break
+		}
+	}
+	if columns == 0 {
+		return nil, &common.ParseError{LineNumber: firstLine, Message: "table has no columns", Line: lines[0]}
+	}
+	if columns > common.MaxSpan {
+		return nil, &common.ParseError{LineNumber: firstLine - 1, Message: fmt.Sprintf("table has %d columns, more than the limit of %d", columns, common.MaxSpan)}
+	}
+
+	covered := make([]int, columns) // rows below the current one covered by a row span, per column
+	next := make([]int, columns)
+	var rows [][]common.SpanCell
+	var row []common.SpanCell
+	col := 0
+	skip := func() {
+		for col < columns && covered[col] > 0 {
+			col++
+		}
+	}
+	endRow := func() {
+		if err == nil && len(rows) >= common.MaxGridCells {
+			err = fmt.Errorf("table with merged cells exceeds the limit of %d cells", common.MaxGridCells)
+		}
+		if err == nil {
+			// The first row is the header
+			err = cfg.CheckSpanRow(len(rows)-1, row)
+		}
+		rows = append(rows, row)
+		for c := range covered {
+			covered[c] = max(covered[c]-1, next[c])
+			next[c] = 0
+		}
+		row, col = nil, 0
+	}
+	for _, cell := range cells {
+		for skip(); col >= columns && err == nil; skip() {
+			endRow()
+		}
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, cell)
+		for c := col; c < min(col+max(cell.ColSpan, 1), columns); c++ {
+			next[c] = max(cell.RowSpan, 1) - 1
+		}
+		col += max(cell.ColSpan, 1)
+		if skip(); col >= columns {
+			endRow()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// psvCells splits text into cells at each unescaped separator, reading the cell specifier in front of it
+func psvCells(text, separator string) ([]common.SpanCell, error) {
+	var cells []common.SpanCell
+	var cell *common.SpanCell
+	repeat := 1
+	parts := splitUnescaped(text, separator)
+	for i, part := range parts {
+		value := part
+		var spec []string
+		if i < len(parts)-1 {
+			if m := specPattern.FindStringSubmatchIndex(part); m != nil {
+				value = part[:m[0]]
+				spec = make([]string, len(m)/2)
+				for g := range spec {
+					if m[2*g] >= 0 {
+						spec[g] = part[m[2*g]:m[2*g+1]]
+					}
+				}
+			}
+		}
+		if cell != nil {
+			cell.Value = strings.TrimSpace(value)
+			for n := 0; n < repeat; n++ {
+				cells = append(cells, *cell)
+			}
+		}
+		if i == len(parts)-1 {
+			break
+		}
+
+		cell, repeat = &common.SpanCell{}, 1
+		if spec != nil {
+			first, _ := strconv.Atoi(spec[2])
+			rowSpan, _ := strconv.Atoi(spec[3])
+			switch spec[4] {
+			case "+":
+				cell.ColSpan, cell.RowSpan = first, rowSpan
+			case "*":
+				if first > common.MaxSpan {
+					return nil, fmt.Errorf("cell is repeated %d times, more than the limit of %d", first, common.MaxSpan)
+				}
+				repeat = max(first, 1)
+			}
+		}
+	}
+	return cells, nil
+}
+
+// Unmarshal reads the first table block of the input
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Unmarshal: target table pointer cannot be nil")
+	}
+	tables, err := parse(cfg, 1)
+	if err != nil {
+		return err
+	}
+	table.Name, table.Headers, table.Rows, table.Spans = tables[0].Name, tables[0].Headers, tables[0].Rows, tables[0].Spans
+	return nil
+}
+
+// UnmarshalDocument reads every table block of the input
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	tables, err := parse(cfg, 0)
+	if err != nil {
+		return err
+	}
+	doc.Tables = append(doc.Tables, tables...)
+	return nil
+}
+
+// Marshal writes a "|===" table block with its header row, titled with --title
+func Marshal(cfg *common.Config, table *common.Table) error {
+	text, err := render(cfg, table, cfg.GetExtensionString("title", ""))
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(cfg.Writer, text)
+	return err
+}
+
+// MarshalDocument writes every table as a table block titled with its name
+func MarshalDocument(cfg *common.Config, doc *common.Document) error {
+	names := doc.UniqueTableNames(nil)
+	for i, table := range doc.Tables {
+		text, err := render(cfg, table, names[i])
+		if err != nil {
+			return fmt.Errorf("table %q: %w", names[i], err)
+		}
+		if i > 0 {
+			text = "\n" + text
+		}
+		if _, err := io.WriteString(cfg.Writer, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// render returns the table block, with the cols attribute taken from --align
+func render(cfg *common.Config, table *common.Table, title string) (string, error) {
+	if table == nil {
+		return "", fmt.Errorf("Marshal: input table pointer cannot be nil")
+	}
+	if len(table.Headers) == 0 {
+		return "", fmt.Errorf("Marshal: table must have at least one header")
+	}
+	for i, row := range table.Rows {
+		if len(row) != len(table.Headers) {
+			return "", fmt.Errorf("Marshal: row %d has %d columns, but table has %d", i, len(row), len(table.Headers))
+		}
+	}
+
+	cols := cfg.ColumnAligns(len(table.Headers))
+	for i, align := range cols {
+		cols[i] = map[string]string{"l": "<", "c": "^", "r": ">"}[align]
+	}
+
+	var b strings.Builder
+	if title != "" {
+		b.WriteString("." + title + "\n")
+	}
+	fmt.Fprintf(&b, "[cols=\"%s\",options=\"header\"]\n|===\n", strings.Join(cols, ","))
+	layout := table.SpanLayout()
+	b.WriteString(formatCells(layout, -1, table.Headers) + "\n")
+	if len(table.Rows) > 0 {
+		b.WriteString("\n")
+	}
+	for i, row := range table.Rows {
+		b.WriteString(formatCells(layout, i, row) + "\n")
+	}
+	b.WriteString("|===\n")
+	return b.String(), nil
+}
+
+// formatCells writes the cells of a row on one line, leaving out cells covered by a merged cell
+func formatCells(layout common.SpanLayout, row int, cells []string) string {
+	var parts []string
+	for col, cell := range cells {
+		if layout.Covered(row, col) {
+			continue
+		}
+		spec := ""
+		if span, ok := layout.At(row, col); ok {
+			switch {
+			case span.RowSpan > 1 && span.ColSpan > 1:
+				spec = fmt.Sprintf("%d.%d+", span.ColSpan, span.RowSpan)
+			case span.RowSpan > 1:
+				spec = fmt.Sprintf(".%d+", span.RowSpan)
+			default:
+				spec = fmt.Sprintf("%d+", span.ColSpan)
+			}
+		}
+		parts = append(parts, spec+"|"+strings.ReplaceAll(cell, "|", `\|`))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,216 @@
+package asciidoc
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func unmarshal(t *testing.T, input string) *common.Table {
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table))
+	return table
+}
+
+func TestUnmarshal(t *testing.T) {
+	input := `
+.Inventory
+[cols="1,2,1",options="header"]
+|===
+|Name |Description |Price
+
+|Apple |Red \| green |1.5
+|Pear
+|Grows
+on trees
+|2
+|===
+`
+	table := unmarshal(t, input)
+	assert.Equal(t, "Inventory", table.Name)
+	assert.Equal(t, []string{"Name", "Description", "Price"}, table.Headers)
+	assert.Equal(t, [][]string{{"Apple", "Red | green", "1.5"}, {"Pear", "Grows\non trees", "2"}}, table.Rows)
+}
+
+func TestUnmarshalSpecifiers(t *testing.T) {
+	input := `
+[cols="3*"]
+|===
+|A |B |C
+2+|two columns |c1
+.2+^|two rows a|
+* item
+|b2
+h|b3 |c3
+3*|x
+2.2+|block |d
+|e
+|===
+`
+	table := unmarshal(t, input)
+	assert.Equal(t, []string{"A", "B", "C"}, table.Headers)
+	assert.Equal(t, [][]string{
+		{"two columns", "", "c1"},
+		{"two rows", "* item", "b2"},
+		{"", "b3", "c3"},
+		{"x", "x", "x"},
+		{"block", "", "d"},
+		{"", "", "e"},
+	}, table.Rows)
+	assert.Equal(t, []common.Span{
+		{Row: 0, Col: 0, RowSpan: 1, ColSpan: 2},
+		{Row: 1, Col: 0, RowSpan: 2, ColSpan: 1},
+		{Row: 4, Col: 0, RowSpan: 2, ColSpan: 2},
+	}, table.Spans)
+
+	// Without cols, the first line sets the number of columns
+	table = unmarshal(t, "|===\n|a |b\n|1\n|2\n|===\n")
+	assert.Equal(t, [][]string{{"1", "2"}}, table.Rows)
+}
+
+func TestUnmarshalCSVAndDSV(t *testing.T) {
+	table := unmarshal(t, "[format=csv,options=\"header\"]\n|===\nname,note\nalice,\"a, b\"\n|===\n")
+	assert.Equal(t, []string{"name", "note"}, table.Headers)
+	assert.Equal(t, [][]string{{"alice", "a, b"}}, table.Rows)
+
+	table = unmarshal(t, ",===\na;b\n1;2\n,===\n")
+	assert.Equal(t, [][]string{{"1;2"}}, table.Rows)
+
+	table = unmarshal(t, "[separator=;]\n,===\na;b\n1;2\n,===\n")
+	assert.Equal(t, [][]string{{"1", "2"}}, table.Rows)
+
+	table = unmarshal(t, "[format=tsv]\n|===\na\tb\n1\t2\n|===\n")
+	assert.Equal(t, [][]string{{"1", "2"}}, table.Rows)
+
+	table = unmarshal(t, ":===\na:b\\:c\n\n1:2\n:===\n")
+	assert.Equal(t, []string{"a", "b:c"}, table.Headers)
+	assert.Equal(t, [][]string{{"1", "2"}}, table.Rows)
+}
+
+func TestMarshal(t *testing.T) {
+	table := &common.Table{
+		Headers: []string{"name", "note", "price"},
+		Rows:    [][]string{{"a|b", "merged", ""}, {"c", "x", "1"}, {"", "y", "2"}},
+		Spans:   []common.Span{{Row: 0, Col: 1, RowSpan: 1, ColSpan: 2}, {Row: 1, Col: 0, RowSpan: 2, ColSpan: 1}},
+	}
+	var buf bytes.Buffer
+	cfg := &common.Config{Writer: &buf, Extension: map[string]string{"align": "l,c,r", "title": "Prices"}}
+	require.NoError(t, Marshal(cfg, table))
+	expected := `.Prices
+[cols="<,^,>",options="header"]
+|===
+|name |note |price
+
+|a\|b 2+|merged
+.2+|c |x |1
+|y |2
+|===
+`
+	assert.Equal(t, expected, buf.String())
+
+	got := unmarshal(t, expected)
+	assert.Equal(t, "Prices", got.Name)
+	assert.Equal(t, table.Headers, got.Headers)
+	assert.Equal(t, table.Rows, got.Rows)
+	assert.Equal(t, table.Spans, got.Spans)
+
+	buf.Reset()
+	require.NoError(t, Marshal(&common.Config{Writer: &buf}, &common.Table{Headers: []string{"a", "b"}}))
+	assert.Equal(t, "[cols=\"<,<\",options=\"header\"]\n|===\n|a |b\n|===\n", buf.String())
+}
+
+func TestDocument(t *testing.T) {
+	input := `= Guide
+
+== Users
+
+Some text.
+
+|===
+|id |name
+|1 |alice
+|===
+
+.Orders
+|===
+|id
+|7
+|===
+`
+	doc := &common.Document{}
+	require.NoError(t, UnmarshalDocument(&common.Config{Reader: strings.NewReader(input)}, doc))
+	require.Len(t, doc.Tables, 2)
+	assert.Equal(t, "Users", doc.Tables[0].Name)
+	assert.Equal(t, "Orders", doc.Tables[1].Name)
+	assert.Equal(t, [][]string{{"7"}}, doc.Tables[1].Rows)
+
+	var buf bytes.Buffer
+	require.NoError(t, MarshalDocument(&common.Config{Writer: &buf}, doc))
+	assert.Equal(t, `.Users
+[cols="<,<",options="header"]
+|===
+|id |name
+
+|1 |alice
+|===
+
+.Orders
+[cols="<",options="header"]
+|===
+|id
+
+|7
+|===
+`, buf.String())
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := map[string]string{
+		"no table":       "just text\n",
+		"not closed":     "|===\n|a |b\n",
+		"no cells":       "|===\n|===\n",
+		"unknown format": "[format=xml]\n|===\n<a/>\n|===\n",
+	}
+	for name, input := range tests {
+		assert.Error(t, Unmarshal(&common.Config{Reader: strings.NewReader(input)}, &common.Table{}), name)
+	}
+
+	var parseErr *common.ParseError
+	err := Unmarshal(&common.Config{Reader: strings.NewReader("text\n\n|===\n|a\n")}, &common.Table{})
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 3, parseErr.LineNumber)
+
+	assert.Error(t, Unmarshal(&common.Config{Reader: strings.NewReader("")}, nil))
+	table := &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1"}}}
+	assert.ErrorContains(t, Marshal(&common.Config{Writer: &bytes.Buffer{}}, table), "row 0 has 1 columns")
+}
+
+func TestAttributes(t *testing.T) {
+	assert.Equal(t, map[string]string{"cols": "1,2", "options": "header,autowidth", "separator": ";"},
+		attributes(`[%header%autowidth,cols="1,2",separator=;]`))
+	assert.Equal(t, 3, columnCount("3"))
+	assert.Equal(t, 4, columnCount("2*,1;<1"))
+}
+
+func TestFixture(t *testing.T) {
+	file, err := os.Open("../test/mysql.adoc")
+	require.NoError(t, err)
+	defer file.Close()
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: file}, table))
+	assert.Equal(t, []string{"FIELD", "TYPE", "NULL", "KEY", "DEFAULT", "EXTRA"}, table.Headers)
+	assert.Equal(t, []string{"username", "varchar(10)", "NO", "", "NULL", ""}, table.Rows[1])
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, 95, detect("[cols=\"1,1\"]\n|===\n|a |b\n|===\n"))
+	assert.Equal(t, 85, detect(",===\na,b\n,===\n"))
+	assert.Equal(t, common.DetectLikely, detect("[cols=\"1,1\"]\n"))
+	assert.Equal(t, common.DetectNone, detect("| a | b |\n|---|---|\n"))
+}
@@ -0,0 +1,43 @@
+package asciidoc
+
+import (
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "asciidoc",
+		Description: "AsciiDoc table",
+		Aliases:     []string{"adoc"},
+		Extensions:  []string{".adoc", ".asciidoc"},
+		Detect:      detect,
+		DetectOrder: 15,
+		Params: []common.FormatParam{
+			common.AlignParam,
+			{Name: "title", DefaultValue: "", AllowedValues: "", Description: "Table title", Use: common.ParamWrite},
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+		MarshalDocument:   MarshalDocument,
+	})
+}
+
+// detect scores content holding a "|===" table block, or its CSV and DSV forms ",===" and ":==="
+func detect(sample string) int {
+	score := common.DetectNone
+	for _, line := range common.SampleLines(sample, 50) {
+		line = strings.TrimSpace(line)
+		switch {
+		case delimiter(line) == '|':
+			return 95
+		case delimiter(line) == ',' || delimiter(line) == ':':
+			score = max(score, 85)
+		case strings.HasPrefix(line, "[") && (strings.Contains(line, "cols=") || strings.Contains(line, `options="header"`)):
+			score = max(score, common.DetectLikely)
+		}
+	}
+	return score
+}
@@ -0,0 +1,987 @@
+package avro
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/martianzhang/tableconvert/arrow"
+	"github.com/martianzhang/tableconvert/common"
+
+	arrowtype "github.com/apache/arrow-go/v18/arrow"
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/ocf"
+)
+
+const (
+	// magic starts every Avro object container file
+	magic = "Obj\x01"
+	// defaultRecordName is the name of the record type of a derived schema unless --name is given
+	defaultRecordName = "Record"
+)
+
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	reader, err := newRowReader(cfg)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return common.ReadAllRows(cfg, reader, table)
+}
+
+// leaf is a field of the writer schema read into one table column. Fields of
+// nested records are flattened into columns named by their dotted path.
+type leaf struct {
+	path   []string
+	unions []string // branch names of the nested records on path that are in a union, or ""
+	schema avro.Schema
+}
+
+// rowReader reads the records of an object container file one at a time
+type rowReader struct {
+	decoder *ocf.Decoder
+	close   func()
+	leaves  []leaf
+	columns []common.Column
+	nulls   []bool
+}
+
+// NewRowReader returns a reader of the records of an object container file
+func NewRowReader(cfg *common.Config) (common.RowReader, error) {
+	return newRowReader(cfg)
+}
+
+// newRowReader reads cfg.Reader, or opens cfg.File when no reader is given, and
+// reads the columns from the writer schema in the file header
+func newRowReader(cfg *common.Config) (*rowReader, error) {
+	input := cfg.Reader
+	closeInput := func() {}
+	if cfg.Reader == nil {
+		f, err := os.Open(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		input, closeInput = f, func() { f.Close() }
+	}
+
+	decoder, err := ocf.NewDecoder(input)
+	if err != nil {
+		closeInput()
+		return nil, fmt.Errorf("invalid Avro file: %w", err)
+	}
+	record, ok := deref(decoder.Schema()).(*avro.RecordSchema)
+	if !ok {
+		closeInput()
+		return nil, fmt.Errorf("the Avro schema is a %s, expected a record", decoder.Schema().Type())
+	}
+
+	reader := &rowReader{decoder: decoder, close: closeInput}
+	reader.flatten(record, leaf{}, map[string]bool{record.FullName(): true})
+	return reader, nil
+}
+
+// flatten adds the fields of record as columns, seen holds the records on the
+// path, which are not flattened again when a schema refers to itself
+func (r *rowReader) flatten(record *avro.RecordSchema, parent leaf, seen map[string]bool) {
+	for _, field := range record.Fields() {
+		path := append(append([]string(nil), parent.path...), field.Name())
+		if nested, branch := nestedRecord(field.Type()); nested != nil && !seen[nested.FullName()] {
+			seen[nested.FullName()] = true
+			r.flatten(nested, leaf{path: path, unions: append(append([]string(nil), parent.unions...), branch)}, seen)
+			delete(seen, nested.FullName())
+			continue
+		}
+
+		r.leaves = append(r.leaves, leaf{path: path, unions: parent.unions, schema: field.Type()})
+		t, nullable := nonNull(field.Type())
+		r.columns = append(r.columns, common.Column{
+			Name:       strings.Join(path, "."),
+			Type:       columnType(t),
+			Nullable:   nullable,
+			SourceType: sourceType(t),
+		})
+	}
+}
+
+func (r *rowReader) Columns() []common.Column {
+	return r.columns
+}
+
+func (r *rowReader) Next() ([]string, error) {
+	if !r.decoder.HasNext() {
+		r.Close()
+		if err := r.decoder.Error(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	var record map[string]any
+	if err := r.decoder.Decode(&record); err != nil {
+		return nil, err
+	}
+
+	row := make([]string, len(r.leaves))
+	r.nulls = make([]bool, len(r.leaves))
+	for i, leaf := range r.leaves {
+		value := leaf.lookup(record)
+		if value == nil {
+			row[i] = common.DefaultNullString
+			r.nulls[i] = true
+			continue
+		}
+		row[i] = text(leaf.schema, value)
+	}
+	return row, nil
+}
+
+func (r *rowReader) Nulls() []bool {
+	return r.nulls
+}
+
+// Close closes the input file, it is safe to call twice
+func (r *rowReader) Close() error {
+	r.close()
+	r.close = func() {}
+	return nil
+}
+
+// lookup returns the value of the leaf in record, nil if it or a nested record is null
+func (l leaf) lookup(record map[string]any) any {
+	var value any = record
+	for i, name := range l.path {
+		if i > 0 && l.unions[i-1] != "" {
+			// Records in a union are decoded as a map from the branch name to the record
+			wrapped, _ := value.(map[string]any)
+			value = wrapped[l.unions[i-1]]
+		}
+		fields, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = fields[name]
+	}
+	return value
+}
+
+// deref resolves a reference to a named type
+func deref(s avro.Schema) avro.Schema {
+	if ref, ok := s.(*avro.RefSchema); ok {
+		return ref.Schema()
+	}
+	return s
+}
+
+// nonNull returns the type of a union of null and one other type, and whether s is nullable
+func nonNull(s avro.Schema) (avro.Schema, bool) {
+	s = deref(s)
+	union, ok := s.(*avro.UnionSchema)
+	if !ok {
+		return s, s.Type() == avro.Null
+	}
+	if !hasNull(union) {
+		return s, false
+	}
+	for _, branch := range union.Types() {
+		if branch.Type() != avro.Null && len(union.Types()) == 2 {
+			return deref(branch), true
+		}
+	}
+	return s, true
+}
+
+// hasNull reports whether null is a branch of union, which UnionSchema.Nullable
+// only reports for unions of two types
+func hasNull(union *avro.UnionSchema) bool {
+	for _, branch := range union.Types() {
+		if branch.Type() == avro.Null {
+			return true
+		}
+	}
+	return false
+}
+
+// nestedRecord returns the record type of a record field, or of a nullable
+// record field together with its union branch name
+func nestedRecord(s avro.Schema) (*avro.RecordSchema, string) {
+	t, nullable := nonNull(s)
+	record, ok := t.(*avro.RecordSchema)
+	if !ok {
+		return nil, ""
+	}
+	if nullable {
+		return record, record.FullName()
+	}
+	return record, ""
+}
+
+// logicalType returns the logical type of s, or "" if it has none
+func logicalType(s avro.Schema) avro.LogicalType {
+	if ls, ok := deref(s).(avro.LogicalTypeSchema); ok && ls.Logical() != nil {
+		return ls.Logical().Type()
+	}
+	return ""
+}
+
+// columnType maps an Avro type to a column type
+func columnType(s avro.Schema) common.ColumnType {
+	switch logicalType(s) {
+	case avro.Date, avro.TimestampMillis, avro.TimestampMicros,
+		avro.LocalTimestampMillis, avro.LocalTimestampMicros:
+		return common.ColumnTypeDate
+	case avro.Decimal:
+		return common.ColumnTypeFloat
+	case "":
+	default:
+		return common.ColumnTypeString
+	}
+	switch s.Type() {
+	case avro.Int, avro.Long:
+		return common.ColumnTypeInt
+	case avro.Float, avro.Double:
+		return common.ColumnTypeFloat
+	case avro.Boolean:
+		return common.ColumnTypeBool
+	}
+	return common.ColumnTypeString
+}
+
+// sourceType names an Avro type by its logical type, or else its type
+func sourceType(s avro.Schema) string {
+	if ls, ok := s.(avro.LogicalTypeSchema); ok && ls.Logical() != nil {
+		if decimal, ok := ls.Logical().(*avro.DecimalLogicalSchema); ok {
+			return fmt.Sprintf("decimal(%d,%d)", decimal.Precision(), decimal.Scale())
+		}
+		return string(ls.Logical().Type())
+	}
+	return string(s.Type())
+}
+
+// text formats a decoded value of type s as a cell
+func text(s avro.Schema, value any) string {
+	s = deref(s)
+	if union, ok := s.(*avro.UnionSchema); ok {
+		s = unionBranch(union, value)
+		if wrapped, ok := value.(map[string]any); ok && len(wrapped) == 1 && wrapped[typeName(s)] != nil {
+			value = wrapped[typeName(s)]
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		switch logicalType(s) {
+		case avro.Date:
+			return v.Format("2006-01-02")
+		case avro.LocalTimestampMillis, avro.LocalTimestampMicros:
+			return v.UTC().Format("2006-01-02T15:04:05.999999999")
+		}
+		return v.UTC().Format(time.RFC3339Nano)
+	case time.Duration:
+		return time.Time{}.Add(v).Format("15:04:05.999999")
+	case *big.Rat:
+		scale := 0
+		if ls, ok := s.(avro.LogicalTypeSchema); ok {
+			if decimal, ok := ls.Logical().(*avro.DecimalLogicalSchema); ok {
+				scale = decimal.Scale()
+			}
+		}
+		return v.FloatString(scale)
+	case []byte:
+		return bytesText(v)
+	}
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		// Fixed values are decoded as byte arrays
+		data := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(data), rv)
+		return bytesText(data)
+	}
+	// Arrays, maps, records that are not flattened and unions of several types
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// bytesText returns bytes that are valid UTF-8 as text and other bytes in base64
+func bytesText(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// unionBranch returns the branch of union that holds a decoded value. Named
+// types are decoded as a map from their name to the value, others as is.
+func unionBranch(union *avro.UnionSchema, value any) avro.Schema {
+	wrapped, isMap := value.(map[string]any)
+	for _, branch := range union.Types() {
+		if isMap && len(wrapped) == 1 && wrapped[typeName(branch)] != nil {
+			return deref(branch)
+		}
+	}
+	for _, branch := range union.Types() {
+		branch = deref(branch)
+		lt := logicalType(branch)
+		var match bool
+		switch value.(type) {
+		case time.Time:
+			match = lt != "" && columnType(branch) == common.ColumnTypeDate
+		case time.Duration:
+			match = lt == avro.TimeMillis || lt == avro.TimeMicros
+		case *big.Rat:
+			match = lt == avro.Decimal
+		case int:
+			match = branch.Type() == avro.Int && lt == ""
+		case int64:
+			match = branch.Type() == avro.Long && lt == ""
+		case float32:
+			match = branch.Type() == avro.Float
+		case float64:
+			match = branch.Type() == avro.Double
+		case bool:
+			match = branch.Type() == avro.Boolean
+		case string:
+			match = branch.Type() == avro.String
+		case []byte:
+			match = branch.Type() == avro.Bytes
+		case []any:
+			match = branch.Type() == avro.Array
+		case map[string]any:
+			match = branch.Type() == avro.Map
+		}
+		if match {
+			return branch
+		}
+	}
+	return union
+}
+
+// typeName is the name of a union branch in decoded and encoded union values
+func typeName(s avro.Schema) string {
+	s = deref(s)
+	if named, ok := s.(avro.NamedSchema); ok {
+		return named.FullName()
+	}
+	if lt := logicalType(s); lt != "" {
+		return string(s.Type()) + "." + string(lt)
+	}
+	return string(s.Type())
+}
+
+// field is a field of the record written for each row
+type field struct {
+	name   string
+	schema avro.Schema
+	column int     // table column of the value, -1 for a nested record or a field without a column
+	fields []field // fields of a nested record written from dotted column names
+}
+
+// plan matches the fields of record to the columns in index by their dotted
+// path. A field without a column must have a default or be nullable.
+func plan(record *avro.RecordSchema, prefix string, index map[string]int, seen map[string]bool) ([]field, error) {
+	fields := make([]field, 0, len(record.Fields()))
+	for _, f := range record.Fields() {
+		name := prefix + f.Name()
+		planned := field{name: f.Name(), schema: f.Type(), column: -1}
+		if i, ok := index[name]; ok {
+			planned.column = i
+			delete(index, name)
+		} else if nested, _ := nestedRecord(f.Type()); nested != nil && !seen[nested.FullName()] && hasPrefix(index, name+".") {
+			seen[nested.FullName()] = true
+			var err error
+			if planned.fields, err = plan(nested, name+".", index, seen); err != nil {
+				return nil, err
+			}
+			delete(seen, nested.FullName())
+		} else if _, nullable := nonNull(f.Type()); !nullable && !f.HasDefault() {
+			return nil, fmt.Errorf("field %q of the Avro schema has no column and no default", name)
+		}
+		fields = append(fields, planned)
+	}
+	return fields, nil
+}
+
+// hasPrefix reports whether a column name in index starts with prefix
+func hasPrefix(index map[string]int, prefix string) bool {
+	for name := range index {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// encoder writes the rows of a table as records of a planned schema
+type encoder struct {
+	writer *ocf.Encoder
+	file   *os.File // cfg.Result, nil when writing to cfg.Writer
+	fields []field
+}
+
+// newEncoder plans the fields of schema for headers and creates cfg.Result,
+// or writes to cfg.Writer when no file is given
+func newEncoder(cfg *common.Config, schema avro.Schema, headers []string) (*encoder, error) {
+	record, ok := deref(schema).(*avro.RecordSchema)
+	if !ok {
+		return nil, fmt.Errorf("the Avro schema is a %s, expected a record", schema.Type())
+	}
+	index := make(map[string]int, len(headers))
+	for i, header := range headers {
+		index[header] = i
+	}
+	fields, err := plan(record, "", index, map[string]bool{record.FullName(): true})
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range headers {
+		if _, ok := index[header]; ok {
+			return nil, fmt.Errorf("column %q is not a field of the Avro schema", header)
+		}
+	}
+
+	e := &encoder{fields: fields}
+	out := cfg.Writer
+	if cfg.Result != "" || cfg.Writer == nil {
+		file, err := os.Create(cfg.Result)
+		if err != nil {
+			return nil, err
+		}
+		e.file, out = file, file
+	}
+	// The full schema keeps defaults and documentation, which readers use for schema evolution
+	codec := ocf.CodecName(cfg.GetExtensionString("codec", "null"))
+	if e.writer, err = ocf.NewEncoderWithSchema(schema, out, ocf.WithCodec(codec), ocf.WithSchemaMarshaler(ocf.FullSchemaMarshaler)); err != nil {
+		e.closeFile()
+		return nil, err
+	}
+	return e, nil
+}
+
+// encode writes one row
+func (e *encoder) encode(row []string, nulls []bool) error {
+	record, _, err := recordValue(e.fields, row, nulls)
+	if err != nil {
+		return err
+	}
+	return e.writer.Encode(record)
+}
+
+// Close writes the last block
+func (e *encoder) Close() error {
+	err := e.writer.Close()
+	if closeErr := e.closeFile(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (e *encoder) closeFile() error {
+	if e.file == nil {
+		return nil
+	}
+	return e.file.Close()
+}
+
+// recordValue converts the cells of row to a record of fields, reporting
+// whether any field has a value
+func recordValue(fields []field, row []string, nulls []bool) (map[string]any, bool, error) {
+	record := make(map[string]any, len(fields))
+	present := false
+	for _, f := range fields {
+		switch {
+		case f.column >= 0:
+			if f.column >= len(row) {
+				return nil, false, fmt.Errorf("row length %d does not match header length", len(row))
+			}
+			null := f.column < len(nulls) && nulls[f.column]
+			v, err := value(f.schema, row[f.column], null)
+			if err != nil {
+				return nil, false, fmt.Errorf("field %q: %w", f.name, err)
+			}
+			record[f.name] = v
+			present = present || v != nil
+		case f.fields != nil:
+			t, nullable := nonNull(f.schema)
+			nested, nestedPresent, err := recordValue(f.fields, row, nulls)
+			if nullable && (err != nil || !nestedPresent) && blank(f.fields, row, nulls) {
+				record[f.name] = nil
+				continue
+			}
+			if err != nil {
+				return nil, false, fmt.Errorf("field %q: %w", f.name, err)
+			}
+			switch {
+			case nullable:
+				record[f.name] = map[string]any{typeName(t): nested}
+			default:
+				record[f.name] = nested
+			}
+			present = present || nestedPresent
+		}
+		// Fields without a column are left out and get their default value
+	}
+	return record, present, nil
+}
+
+// blank reports whether the columns of fields are all NULL or empty in row.
+// A nullable nested record is null when it has no other values.
+func blank(fields []field, row []string, nulls []bool) bool {
+	for _, f := range fields {
+		if f.column >= 0 && f.column < len(row) && strings.TrimSpace(row[f.column]) != "" &&
+			(f.column >= len(nulls) || !nulls[f.column]) {
+			return false
+		}
+		if !blank(f.fields, row, nulls) {
+			return false
+		}
+	}
+	return true
+}
+
+// errNull is returned for a NULL cell of a field that is not nullable
+var errNull = errors.New("NULL value of a field that is not nullable")
+
+// value converts a cell to a value of type s. Blank cells and NULL spellings
+// are null in a nullable field unless it holds strings.
+func value(s avro.Schema, cell string, null bool) (any, error) {
+	s = deref(s)
+	if union, ok := s.(*avro.UnionSchema); ok {
+		if null {
+			if hasNull(union) {
+				return nil, nil
+			}
+			return nil, errNull
+		}
+		for _, branch := range union.Types() {
+			if branch.Type() == avro.Null {
+				continue
+			}
+			if v, err := value(branch, cell, false); err == nil {
+				return map[string]any{typeName(branch): v}, nil
+			}
+		}
+		if trimmed := strings.TrimSpace(cell); hasNull(union) && (trimmed == "" || common.InferType(trimmed) == nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("value %q does not fit the Avro type %s", cell, s)
+	}
+	if null {
+		if s.Type() == avro.Null {
+			return nil, nil
+		}
+		return nil, errNull
+	}
+
+	trimmed := strings.TrimSpace(cell)
+	var v any
+	var err error
+	switch lt := logicalType(s); {
+	case lt == avro.Date:
+		var t time.Time
+		if t, err = time.Parse("2006-01-02", trimmed); err == nil {
+			v = t
+		}
+	case lt == avro.TimeMillis, lt == avro.TimeMicros:
+		var t time.Time
+		if t, err = time.Parse("15:04:05", trimmed); err == nil {
+			v = t.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC))
+		}
+	case columnType(s) == common.ColumnTypeDate:
+		if t, _, ok := arrow.ParseTimestamp(trimmed); ok {
+			v = t
+		} else {
+			err = errors.New("not a timestamp")
+		}
+	case lt == avro.Decimal:
+		if r, ok := new(big.Rat).SetString(trimmed); ok {
+			v = r
+		} else {
+			err = errors.New("not a decimal")
+		}
+	default:
+		v, err = primitiveValue(s, cell, trimmed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("value %q does not fit the Avro type %s", cell, s)
+	}
+	return v, nil
+}
+
+// primitiveValue converts a cell to a value of a type without a logical type
+func primitiveValue(s avro.Schema, cell, trimmed string) (any, error) {
+	switch s.Type() {
+	case avro.Null:
+		if trimmed == "" || common.InferType(trimmed) == nil {
+			return nil, nil
+		}
+	case avro.Boolean:
+		if v, ok := common.TypedValue(trimmed, common.ColumnTypeBool).(bool); ok {
+			return v, nil
+		}
+	case avro.Int:
+		v, err := strconv.ParseInt(trimmed, 10, 32)
+		return int(v), err
+	case avro.Long:
+		return strconv.ParseInt(trimmed, 10, 64)
+	case avro.Float:
+		v, err := strconv.ParseFloat(trimmed, 32)
+		return float32(v), err
+	case avro.Double:
+		return strconv.ParseFloat(trimmed, 64)
+	case avro.String:
+		return cell, nil
+	case avro.Bytes:
+		return []byte(cell), nil
+	case avro.Fixed:
+		size := s.(*avro.FixedSchema).Size()
+		if len(cell) == size {
+			v := reflect.New(reflect.ArrayOf(size, reflect.TypeOf(byte(0)))).Elem()
+			reflect.Copy(v, reflect.ValueOf([]byte(cell)))
+			return v.Interface(), nil
+		}
+	case avro.Enum:
+		for _, symbol := range s.(*avro.EnumSchema).Symbols() {
+			if symbol == trimmed {
+				return symbol, nil
+			}
+		}
+	case avro.Array, avro.Map, avro.Record:
+		// Values of complex types are written as JSON
+		decoder := json.NewDecoder(strings.NewReader(cell))
+		decoder.UseNumber()
+		var v any
+		if err := decoder.Decode(&v); err != nil {
+			return nil, err
+		}
+		return jsonValue(s, v)
+	}
+	return nil, errors.New("no match")
+}
+
+// jsonValue converts a decoded JSON value to a value of type s
+func jsonValue(s avro.Schema, v any) (any, error) {
+	s = deref(s)
+	switch t := v.(type) {
+	case nil:
+		return value(s, "", true)
+	case []any:
+		if s.Type() == avro.Union {
+			break
+		}
+		array, ok := s.(*avro.ArraySchema)
+		if !ok {
+			return nil, fmt.Errorf("array does not fit the Avro type %s", s)
+		}
+		items := make([]any, len(t))
+		for i, item := range t {
+			var err error
+			if items[i], err = jsonValue(array.Items(), item); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	case map[string]any:
+		if s.Type() == avro.Union {
+			break
+		}
+		converted := make(map[string]any, len(t))
+		switch m := s.(type) {
+		case *avro.MapSchema:
+			for key, item := range t {
+				var err error
+				if converted[key], err = jsonValue(m.Values(), item); err != nil {
+					return nil, err
+				}
+			}
+		case *avro.RecordSchema:
+			for _, f := range m.Fields() {
+				if item, ok := t[f.Name()]; ok {
+					var err error
+					if converted[f.Name()], err = jsonValue(f.Type(), item); err != nil {
+						return nil, err
+					}
+				}
+			}
+		default:
+			return nil, fmt.Errorf("object does not fit the Avro type %s", s)
+		}
+		return converted, nil
+	case bool:
+		return value(s, strconv.FormatBool(t), false)
+	case json.Number:
+		return value(s, t.String(), false)
+	case string:
+		return value(s, t, false)
+	}
+
+	// A complex value in a union takes the first branch it fits
+	union := s.(*avro.UnionSchema)
+	for _, branch := range union.Types() {
+		if converted, err := jsonValue(branch, v); err == nil && branch.Type() != avro.Null {
+			return map[string]any{typeName(branch): converted}, nil
+		}
+	}
+	return nil, fmt.Errorf("value does not fit the Avro type %s", s)
+}
+
+// loadSchema reads the Avro schema given with --schema, nil if there is none
+func loadSchema(cfg *common.Config) (avro.Schema, error) {
+	path := cfg.GetExtensionString("schema", "")
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := avro.ParseBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Avro schema %s: %w", path, err)
+	}
+	return schema, nil
+}
+
+func Marshal(cfg *common.Config, table *common.Table) error {
+	schema, err := loadSchema(cfg)
+	if err != nil {
+		return err
+	}
+	for _, row := range table.Rows {
+		if len(row) != len(table.Headers) {
+			return fmt.Errorf("row length %d does not match header length %d", len(row), len(table.Headers))
+		}
+	}
+	headers := table.Headers
+	if schema == nil {
+		if schema, headers, err = deriveSchema(cfg, table); err != nil {
+			return err
+		}
+	}
+
+	e, err := newEncoder(cfg, schema, headers)
+	if err != nil {
+		return err
+	}
+	for r, row := range table.Rows {
+		if err := e.encode(row, table.RowNulls(r)); err != nil {
+			e.Close()
+			return fmt.Errorf("row %d: %w", r+1, err)
+		}
+	}
+	return e.Close()
+}
+
+// deriveSchema derives a record schema from the column types of table, see
+// arrow.NewSchema. It returns the Avro path of each column, which nests
+// columns with dotted names in records and replaces characters that are not
+// valid in Avro names.
+func deriveSchema(cfg *common.Config, table *common.Table) (avro.Schema, []string, error) {
+	name := cfg.GetExtensionString("name", defaultRecordName)
+	inferred, err := arrow.NewSchema(table)
+	if err != nil {
+		return nil, nil, err
+	}
+	types := inferred.Fields()
+	root := &node{}
+	paths := make([]string, len(table.Headers))
+	for i, header := range table.Headers {
+		paths[i] = root.insert(header, i)
+	}
+
+	var fields func(n *node, recordName string) []map[string]any
+	fields = func(n *node, recordName string) []map[string]any {
+		list := make([]map[string]any, 0, len(n.children))
+		for _, child := range n.children {
+			var t any
+			if child.column < 0 {
+				nested := recordName + "_" + child.name
+				t = map[string]any{"type": "record", "name": nested, "fields": fields(child, nested)}
+			} else {
+				t = avroType(types[child.column].Type)
+			}
+			list = append(list, map[string]any{"name": child.name, "type": []any{"null", t}, "default": nil})
+		}
+		return list
+	}
+	data, err := json.Marshal(map[string]any{"type": "record", "name": name, "fields": fields(root, name)})
+	if err != nil {
+		return nil, nil, err
+	}
+	schema, err := avro.ParseBytes(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid Avro schema: %w", err)
+	}
+	return schema, paths, nil
+}
+
+// avroType maps an Arrow type of arrow.NewSchema to an Avro type
+func avroType(t arrowtype.DataType) any {
+	switch t.ID() {
+	case arrowtype.INT64:
+		return "long"
+	case arrowtype.FLOAT64:
+		return "double"
+	case arrowtype.BOOL:
+		return "boolean"
+	case arrowtype.DATE32:
+		return map[string]any{"type": "int", "logicalType": "date"}
+	case arrowtype.TIMESTAMP:
+		if t.(*arrowtype.TimestampType).TimeZone != "" {
+			return map[string]any{"type": "long", "logicalType": "timestamp-micros"}
+		}
+		return map[string]any{"type": "long", "logicalType": "local-timestamp-micros"}
+	}
+	return "string"
+}
+
+// node is a field of a derived schema, a column or a record of columns
+type node struct {
+	name     string
+	column   int // -1 for a record
+	children []*node
+}
+
+// insert adds the column named header, nested in records by the dots in its
+// name when every part is a valid Avro name, and returns its Avro path
+func (n *node) insert(header string, column int) string {
+	parts := strings.Split(header, ".")
+	nested := len(parts) > 1
+	parent := n
+	for _, part := range parts {
+		if !validName(part) {
+			nested = false
+		}
+	}
+	for _, part := range parts[:len(parts)-1] {
+		if parent == nil || !nested {
+			break
+		}
+		if child := parent.child(part); child != nil && child.column >= 0 {
+			nested = false
+		} else {
+			parent = child
+		}
+	}
+	if nested && (parent == nil || parent.child(parts[len(parts)-1]) == nil) {
+		parent = n
+		for _, part := range parts[:len(parts)-1] {
+			child := parent.child(part)
+			if child == nil {
+				child = &node{name: part, column: -1}
+				parent.children = append(parent.children, child)
+			}
+			parent = child
+		}
+		parent.children = append(parent.children, &node{name: parts[len(parts)-1], column: column})
+		return header
+	}
+
+	// Not nested: one field named like the column
+	name := sanitizeName(header)
+	for base, i := name, 2; n.child(name) != nil; i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	n.children = append(n.children, &node{name: name, column: column})
+	return name
+}
+
+func (n *node) child(name string) *node {
+	for _, child := range n.children {
+		if child.name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// validName reports whether name is a valid Avro name: [A-Za-z_][A-Za-z0-9_]*
+func validName(name string) bool {
+	return name != "" && sanitizeName(name) == name
+}
+
+// sanitizeName replaces the characters that are not valid in an Avro name with underscores
+func sanitizeName(name string) string {
+	var b strings.Builder
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+		default:
+			c = '_'
+		}
+		b.WriteRune(c)
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// rowWriter writes each row as a record of the schema given with --schema
+type rowWriter struct {
+	cfg     *common.Config
+	schema  avro.Schema
+	encoder *encoder
+}
+
+// NewRowWriter returns a streaming writer if --schema is given. A derived
+// schema needs all rows for its column types.
+func NewRowWriter(cfg *common.Config) (common.RowWriter, error) {
+	schema, err := loadSchema(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return nil, common.ErrStreamNotSupported
+	}
+	return &rowWriter{cfg: cfg, schema: schema}, nil
+}
+
+func (w *rowWriter) WriteHeader(columns []common.Column) error {
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = column.Name
+	}
+	var err error
+	w.encoder, err = newEncoder(w.cfg, w.schema, headers)
+	return err
+}
+
+func (w *rowWriter) WriteRow(row []string) error {
+	return w.WriteNullRow(row, nil)
+}
+
+func (w *rowWriter) WriteNullRow(row []string, nulls []bool) error {
+	return w.encoder.encode(row, nulls)
+}
+
+func (w *rowWriter) Close() error {
+	if w.encoder == nil {
+		return nil // WriteHeader was not called or failed
+	}
+	return w.encoder.Close()
+}
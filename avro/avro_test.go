@@ -0,0 +1,201 @@
+package avro
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/ocf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalAndUnmarshal(t *testing.T) {
+	table := &common.Table{
+		Headers: []string{"id", "name", "score", "born", "seen", "user.city", "user.zip", "first name"},
+		Rows: [][]string{
+			{"1", "alice", "9.5", "2020-01-02", "2023-01-02T03:04:05+02:00", "Paris", "75000", "A"},
+			{"2", "", "", "2021-03-04", "", "", "", "NULL"},
+		},
+	}
+	table.SetNull(1, 7)
+
+	result := filepath.Join(t.TempDir(), "data.avro")
+	require.NoError(t, Marshal(&common.Config{Result: result, Extension: map[string]string{"codec": "deflate"}}, table))
+
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{File: result}, got))
+	// Dotted columns are nested records, other names are made valid
+	assert.Equal(t, []string{"id", "name", "score", "born", "seen", "user.city", "user.zip", "first_name"}, got.Headers)
+	assert.Equal(t, [][]string{
+		{"1", "alice", "9.5", "2020-01-02", "2023-01-02T01:04:05Z", "Paris", "75000", "A"},
+		{"2", "", "NULL", "2021-03-04", "NULL", "", "NULL", "NULL"},
+	}, got.Rows)
+	assert.True(t, got.IsNull(1, 7))
+	assert.False(t, got.IsNull(1, 1))
+
+	types := []common.ColumnType{common.ColumnTypeInt, common.ColumnTypeString, common.ColumnTypeFloat,
+		common.ColumnTypeDate, common.ColumnTypeDate, common.ColumnTypeString, common.ColumnTypeInt, common.ColumnTypeString}
+	for i, column := range got.Columns {
+		assert.Equal(t, types[i], column.Type, column.Name)
+		assert.True(t, column.Nullable, column.Name)
+	}
+	assert.Equal(t, "long", got.Columns[0].SourceType)
+	assert.Equal(t, "date", got.Columns[3].SourceType)
+	assert.Equal(t, "timestamp-micros", got.Columns[4].SourceType)
+}
+
+const eventSchema = `{"type": "record", "name": "Event", "namespace": "com.example", "fields": [
+	{"name": "id", "type": "int"},
+	{"name": "kind", "type": {"type": "enum", "name": "Kind", "symbols": ["CLICK", "VIEW"]}},
+	{"name": "amount", "type": ["null", {"type": "bytes", "logicalType": "decimal", "precision": 9, "scale": 2}], "default": null},
+	{"name": "at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+	{"name": "tags", "type": {"type": "array", "items": "string"}, "default": []},
+	{"name": "geo", "type": ["null", {"type": "record", "name": "Geo", "fields": [
+		{"name": "lat", "type": "double"}, {"name": "lon", "type": "double"}]}], "default": null},
+	{"name": "extra", "type": ["null", "long", "string"], "default": null}
+]}`
+
+func writeSchema(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "event.avsc")
+	require.NoError(t, os.WriteFile(path, []byte(eventSchema), 0o644))
+	return path
+}
+
+func TestMarshalWithSchema(t *testing.T) {
+	table := &common.Table{
+		Headers: []string{"id", "kind", "amount", "at", "tags", "geo.lat", "geo.lon", "extra"},
+		Rows: [][]string{
+			{"1", "CLICK", "12.5", "2024-05-01T10:00:00Z", `["a","b"]`, "1.5", "2.5", "x"},
+			{"2", "VIEW", "", "2024-05-01 10:00:00", "[]", "", "", "7"},
+		},
+	}
+	var buf bytes.Buffer
+	cfg := &common.Config{Writer: &buf, Extension: map[string]string{"schema": writeSchema(t)}}
+	require.NoError(t, Marshal(cfg, table))
+
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: bytes.NewReader(buf.Bytes())}, got))
+	assert.Equal(t, table.Headers, got.Headers)
+	assert.Equal(t, [][]string{
+		{"1", "CLICK", "12.50", "2024-05-01T10:00:00Z", `["a","b"]`, "1.5", "2.5", "x"},
+		// A nullable record without values is null
+		{"2", "VIEW", "NULL", "2024-05-01T10:00:00Z", "[]", "NULL", "NULL", "7"},
+	}, got.Rows)
+	assert.Equal(t, "decimal(9,2)", got.Columns[2].SourceType)
+	assert.Equal(t, common.ColumnTypeFloat, got.Columns[2].Type)
+	assert.Equal(t, "union", got.Columns[7].SourceType)
+
+	// The file has the given schema
+	decoder, err := ocf.NewDecoder(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, "com.example.Event", decoder.Schema().(*avro.RecordSchema).FullName())
+
+	// Fields without a column get their default
+	table = &common.Table{Headers: []string{"id", "kind", "at"}, Rows: [][]string{{"3", "VIEW", "2024-05-01"}}}
+	buf.Reset()
+	require.NoError(t, Marshal(cfg, table))
+	got = &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: &buf}, got))
+	assert.Equal(t, []string{"3", "VIEW", "NULL", "2024-05-01T00:00:00Z", "[]", "NULL", "NULL", "NULL"}, got.Rows[0])
+}
+
+func TestMarshalWithSchemaErrors(t *testing.T) {
+	schema := writeSchema(t)
+	tests := []struct {
+		name    string
+		table   *common.Table
+		wantErr string
+	}{
+		{"missing field", &common.Table{Headers: []string{"id", "kind"}}, `field "at" of the Avro schema has no column and no default`},
+		{"unknown column", &common.Table{Headers: []string{"id", "kind", "at", "other"}}, `column "other" is not a field of the Avro schema`},
+		{"enum symbol", &common.Table{Headers: []string{"id", "kind", "at"}, Rows: [][]string{{"1", "OTHER", "2024-05-01"}}}, `field "kind": value "OTHER" does not fit`},
+		{"int", &common.Table{Headers: []string{"id", "kind", "at"}, Rows: [][]string{{"x", "VIEW", "2024-05-01"}}}, `row 1: field "id": value "x" does not fit`},
+		{"null", &common.Table{Headers: []string{"id", "kind", "at"}, Rows: [][]string{{"1", "VIEW", "NULL"}}, Nulls: [][]bool{{false, false, true}}}, "NULL value of a field that is not nullable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &common.Config{Writer: &bytes.Buffer{}, Extension: map[string]string{"schema": schema}}
+			assert.ErrorContains(t, Marshal(cfg, tt.table), tt.wantErr)
+		})
+	}
+
+	cfg := &common.Config{Writer: &bytes.Buffer{}, Extension: map[string]string{"schema": `"string"`}}
+	assert.Error(t, Marshal(cfg, &common.Table{Headers: []string{"a"}}))
+}
+
+func TestRowReaderAndWriter(t *testing.T) {
+	_, err := NewRowWriter(&common.Config{})
+	assert.ErrorIs(t, err, common.ErrStreamNotSupported)
+
+	var buf bytes.Buffer
+	writer, err := NewRowWriter(&common.Config{Writer: &buf, Extension: map[string]string{"schema": writeSchema(t)}})
+	require.NoError(t, err)
+	assert.NoError(t, writer.Close(), "a failed conversion closes the writer before the header")
+	require.NoError(t, writer.WriteHeader([]common.Column{{Name: "id"}, {Name: "kind"}, {Name: "at"}, {Name: "extra"}}))
+	require.NoError(t, writer.WriteRow([]string{"1", "CLICK", "2024-05-01", "7"}))
+	require.NoError(t, writer.(common.NullRowWriter).WriteNullRow([]string{"2", "VIEW", "2024-05-02", "NULL"}, []bool{false, false, false, true}))
+	require.NoError(t, writer.Close())
+
+	reader, err := NewRowReader(&common.Config{Reader: &buf})
+	require.NoError(t, err)
+	assert.Len(t, reader.Columns(), 8)
+	row, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "7", row[7])
+	row, err = reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "2", row[0])
+	assert.True(t, reader.(common.NullRowReader).Nulls()[7])
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	err := Unmarshal(&common.Config{Reader: strings.NewReader("a,b\n1,2\n")}, &common.Table{})
+	assert.ErrorContains(t, err, "invalid Avro file")
+
+	err = Unmarshal(&common.Config{File: "does-not-exist.avro"}, &common.Table{})
+	assert.True(t, os.IsNotExist(err))
+
+	var buf bytes.Buffer
+	encoder, err := ocf.NewEncoder(`"long"`, &buf)
+	require.NoError(t, err)
+	require.NoError(t, encoder.Encode(int64(1)))
+	require.NoError(t, encoder.Close())
+	err = Unmarshal(&common.Config{Reader: &buf}, &common.Table{})
+	assert.ErrorContains(t, err, "expected a record")
+
+	table := &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1"}}}
+	assert.Error(t, Marshal(&common.Config{Writer: &bytes.Buffer{}}, table))
+}
+
+func TestFixture(t *testing.T) {
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{File: "../test/mysql.avro"}, table))
+	assert.Equal(t, []string{"FIELD", "TYPE", "NULL", "KEY", "DEFAULT", "EXTRA"}, table.Headers)
+	assert.Equal(t, []string{"user_id", "smallint(5)", "NO", "PRI", "NULL", "auto_increment"}, table.Rows[0])
+}
+
+func TestSchemaNames(t *testing.T) {
+	root := &node{}
+	assert.Equal(t, "a.b", root.insert("a.b", 0))
+	assert.Equal(t, "a.c", root.insert("a.c", 1))
+	assert.Equal(t, "a_b", root.insert("a-b", 2))
+	assert.Equal(t, "a_b_2", root.insert("a b", 3))
+	assert.Equal(t, "x", root.insert("x", 4))
+	assert.Equal(t, "x_y", root.insert("x.y", 5), "x is a column, not a record")
+	assert.Equal(t, "_1st", root.insert("1st", 6))
+	assert.Equal(t, "_", root.insert("", 7))
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, common.DetectCertain, detect("Obj\x01\x04\x14avro.codec"))
+	assert.Equal(t, common.DetectNone, detect("a,b\n"))
+}
@@ -0,0 +1,33 @@
+package avro
+
+import (
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "avro",
+		Description: "Apache Avro object container file",
+		Extensions:  []string{".avro"},
+		Detect:      detect,
+		Params: []common.FormatParam{
+			{Name: "schema", DefaultValue: "", AllowedValues: "", Description: "Avro schema file (.avsc) used instead of a schema derived from the table", Use: common.ParamWrite},
+			{Name: "codec", DefaultValue: "null", AllowedValues: "null, deflate, snappy, zstandard", Description: "Compression codec of the data blocks", Use: common.ParamWrite},
+			{Name: "name", DefaultValue: defaultRecordName, AllowedValues: "string", Description: "Name of the record type of a derived schema", Use: common.ParamWrite},
+		},
+		Unmarshal:    Unmarshal,
+		Marshal:      Marshal,
+		NewRowReader: NewRowReader,
+		NewRowWriter: NewRowWriter,
+	})
+}
+
+// detect scores a file starting with the Avro object container magic number
+func detect(sample string) int {
+	if strings.HasPrefix(sample, magic) {
+		return common.DetectCertain
+	}
+	return common.DetectNone
+}
@@ -0,0 +1,241 @@
+package bbcode
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+var (
+	// tagPattern matches an opening or closing tag such as [td], [url=https://example.com] or [/b]
+	tagPattern = regexp.MustCompile(`\[(/?)([a-zA-Z*]+)([= ][^\[\]]*)?\]`)
+	// spanAttrPattern matches the rowspan and colspan attributes of a cell
+	spanAttrPattern = regexp.MustCompile(`(?i)\b(rowspan|colspan)\s*=\s*["']?(\d+)`)
+)
+
+// parseTables reads the rows of every top level [table] of the input. Other tags
+// inside cells such as [b] or [url=...] are kept in the cell text, as Marshal
+// writes them back, [br] is a line break and [noparse] text is kept as it is.
+// Each cell is checked with cfg.CheckRow.
+func parseTables(cfg *common.Config, content string) ([][][]common.SpanCell, error) {
+	var tables [][][]common.SpanCell
+	var rows [][]common.SpanCell
+	var cell *common.SpanCell
+	var text strings.Builder
+	var err error
+	depth := 0
+	noparse := false
+
+	endCell := func() {
+		if cell != nil && len(rows) > 0 {
+			cell.Value = strings.TrimSpace(text.String())
+			// The first row is the header
+			err = cfg.CheckRow(len(rows)-2, []string{cell.Value})
+			rows[len(rows)-1] = append(rows[len(rows)-1], *cell)
+		}
+		cell = nil
+		text.Reset()
+	}
+
+	pos := 0
+	for _, m := range tagPattern.FindAllStringSubmatchIndex(content, -1) {
+		if err != nil {
+			return nil, err
+		}
+		closing := m[3] > m[2]
+		name := strings.ToLower(content[m[4]:m[5]])
+		attrs := ""
+		if m[6] >= 0 {
+			attrs = content[m[6]:m[7]]
+		}
+		if noparse {
+			if closing && name == "noparse" {
+				text.WriteString(content[pos:m[0]])
+				noparse = false
+				pos = m[1]
+			}
+			continue
+		}
+		if cell != nil {
+			text.WriteString(content[pos:m[0]])
+		}
+		pos = m[1]
+
+		switch {
+		case name == "table" && !closing:
+			depth++
+			if depth == 1 {
+				rows = nil
+			}
+		case name == "table" && closing && depth > 0:
+			if depth == 1 {
+				endCell()
+				if len(rows) > 0 {
+					tables = append(tables, rows)
+				}
+			}
+			depth--
+		case depth != 1:
+			// Tags outside tables and inside nested tables
+		case name == "tr":
+			endCell()
+			if !closing {
+				rows = append(rows, nil)
+			}
+		case name == "td" || name == "th":
+			endCell()
+			if !closing {
+				cell = &common.SpanCell{}
+				for _, attr := range spanAttrPattern.FindAllStringSubmatch(attrs, -1) {
+					n, _ := strconv.Atoi(attr[2])
+					if strings.EqualFold(attr[1], "rowspan") {
+						cell.RowSpan = n
+					} else {
+						cell.ColSpan = n
+					}
+				}
+			}
+		case name == "noparse" && !closing:
+			noparse = cell != nil
+		case name == "br" && cell != nil:
+			text.WriteString("\n")
+		case cell != nil:
+			text.WriteString(content[m[0]:m[1]])
+		}
+	}
+	return tables, err
+}
+
+// parse reads up to limit [table]s of the input, all of them when limit is 0,
+// the first row of each being the header
+func parse(cfg *common.Config, limit int) ([]*common.Table, error) {
+	if cfg.Reader == nil {
+		return nil, fmt.Errorf("Unmarshal: Reader in Config cannot be nil")
+	}
+	content, err := io.ReadAll(cfg.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading input: %w", err)
+	}
+
+	rows, err := parseTables(cfg, string(content))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("parsing failed: no [table] with rows found in input")
+	}
+	if limit > 0 && len(rows) > limit {
+		rows = rows[:limit]
+	}
+	tables := make([]*common.Table, len(rows))
+	for i := range rows {
+		grid, spans, _, err := common.LayoutSpans(rows[i])
+		if err != nil {
+			return nil, err
+		}
+		tables[i] = &common.Table{}
+		tables[i].SetGrid(grid, spans)
+	}
+	return tables, nil
+}
+
+// Unmarshal reads the first [table] of the input, its first row being the header
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Unmarshal: target table pointer cannot be nil")
+	}
+	tables, err := parse(cfg, 1)
+	if err != nil {
+		return err
+	}
+	*table = *tables[0]
+	return nil
+}
+
+// UnmarshalDocument reads every [table] of the input
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	tables, err := parse(cfg, 0)
+	if err != nil {
+		return err
+	}
+	doc.Tables = append(doc.Tables, tables...)
+	return nil
+}
+
+// Marshal writes a [table] with a [th] header row, one [tr] per line
+func Marshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Marshal: input table pointer cannot be nil")
+	}
+	if len(table.Headers) == 0 {
+		return fmt.Errorf("Marshal: table must have at least one header")
+	}
+	for i, row := range table.Rows {
+		if len(row) != len(table.Headers) {
+			return fmt.Errorf("Marshal: row %d has %d columns, but table has %d", i, len(row), len(table.Headers))
+		}
+	}
+
+	headerTag := "th"
+	if !cfg.GetExtensionBool("header", true) {
+		headerTag = "td"
+	}
+	var b strings.Builder
+	if border := cfg.GetExtensionString("border", ""); border != "" {
+		fmt.Fprintf(&b, "[table border=%s]\n", border)
+	} else {
+		b.WriteString("[table]\n")
+	}
+	layout := table.SpanLayout()
+	b.WriteString("  [tr]" + formatCells(layout, -1, table.Headers, headerTag) + "[/tr]\n")
+	for i, row := range table.Rows {
+		b.WriteString("  [tr]" + formatCells(layout, i, row, "td") + "[/tr]\n")
+	}
+	b.WriteString("[/table]\n")
+	_, err := io.WriteString(cfg.Writer, b.String())
+	return err
+}
+
+// formatCells writes the cells of a row, leaving out cells covered by a merged cell
+func formatCells(layout common.SpanLayout, row int, cells []string, tag string) string {
+	var b strings.Builder
+	for col, value := range cells {
+		if layout.Covered(row, col) {
+			continue
+		}
+		attrs := ""
+		if span, ok := layout.At(row, col); ok {
+			if span.ColSpan > 1 {
+				attrs += fmt.Sprintf(" colspan=%d", span.ColSpan)
+			}
+			if span.RowSpan > 1 {
+				attrs += fmt.Sprintf(" rowspan=%d", span.RowSpan)
+			}
+		}
+		fmt.Fprintf(&b, "[%s%s]%s[/%s]", tag, attrs, protect(value), tag)
+	}
+	return b.String()
+}
+
+// protect wraps the tags of a value that parseTables would not keep as text,
+// table tags, [br] and [noparse], in [noparse]. Other tags are written as they are.
+func protect(value string) string {
+	return tagPattern.ReplaceAllStringFunc(value, func(tag string) string {
+		m := tagPattern.FindStringSubmatch(tag)
+		switch strings.ToLower(m[2]) {
+		case "table", "tr", "th", "td", "br":
+		case "noparse":
+			if m[1] != "" {
+				// A closing [/noparse] outside [noparse] is text
+				return tag
+			}
+		default:
+			return tag
+		}
+		return "[noparse]" + tag + "[/noparse]"
+	})
+}
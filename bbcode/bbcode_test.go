@@ -0,0 +1,255 @@
+package bbcode
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	// The other wiki dialects, scored against this one by TestDetect
+	_ "github.com/martianzhang/tableconvert/dokuwiki"
+	_ "github.com/martianzhang/tableconvert/jira"
+	_ "github.com/martianzhang/tableconvert/markdown"
+	_ "github.com/martianzhang/tableconvert/mediawiki"
+	_ "github.com/martianzhang/tableconvert/textile"
+	_ "github.com/martianzhang/tableconvert/tracwiki"
+	_ "github.com/martianzhang/tableconvert/twiki"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalForumPost(t *testing.T) {
+	// Shaped like a table copied from a forum post: uppercase tags, a
+	// [TABLE=border] option, inline tags and a second table that is not read
+	input := `Here are the results:
+[TABLE=border]
+[TR][TH][b]Name[/b][/TH][TH]Site[/TH][/TR]
+[TR][TD][color=red]Alice[/color][/TD][TD][url=https://example.com]home [i]page[/i][/url][/TD][/TR]
+[/TABLE]
+[table][tr][td]second[/td][/tr][/table]
+`
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	// Inline tags are kept as cell text, as Marshal writes them back
+	assert.Equal(t, []string{"[b]Name[/b]", "Site"}, table.Headers)
+	assert.Equal(t, [][]string{
+		{"[color=red]Alice[/color]", "[url=https://example.com]home [i]page[/i][/url]"},
+	}, table.Rows)
+}
+
+func TestUnmarshalDocument(t *testing.T) {
+	// Every top level [table] of a post is a table
+	input := `[table][tr][th]a[/th][th]b[/th][/tr][tr][td]1[/td][td]2[/td][/tr][/table]
+Some text between the tables
+[TABLE][TR][TH]c[/TH][/TR][TR][TD]3[/TD][/TR][/TABLE]`
+	var doc common.Document
+	err := UnmarshalDocument(&common.Config{Reader: strings.NewReader(input)}, &doc)
+	assert.NoError(t, err)
+	require.Len(t, doc.Tables, 2)
+	assert.Equal(t, []string{"a", "b"}, doc.Tables[0].Headers)
+	assert.Equal(t, [][]string{{"1", "2"}}, doc.Tables[0].Rows)
+	assert.Equal(t, []string{"c"}, doc.Tables[1].Headers)
+	assert.Equal(t, [][]string{{"3"}}, doc.Tables[1].Rows)
+}
+
+func TestUnmarshalWithSpans(t *testing.T) {
+	input := `[table]
+[tr][th]a[/th][th]b[/th][th]c[/th][/tr]
+[tr][td]x[/td][td]y[/td][td rowspan=2]shared[/td][/tr]
+[tr][td colspan="2"]wide[/td][/tr]
+[/table]`
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"x", "y", "shared"}, {"wide", "", ""}}, table.Rows)
+	assert.Equal(t, []common.Span{
+		{Row: 0, Col: 2, RowSpan: 2, ColSpan: 1},
+		{Row: 1, Col: 0, RowSpan: 1, ColSpan: 2},
+	}, table.Spans)
+
+	// A row span past the last row is cut at the end of the table
+	table = &common.Table{}
+	input = "[table][tr][th]a[/th][th]b[/th][/tr][tr][td rowspan=1000000000]x[/td][td]y[/td][/tr][/table]"
+	err = Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"x", "y"}}, table.Rows)
+	assert.Nil(t, table.Spans)
+}
+
+func TestUnmarshalWithLineBreaksAndNoparse(t *testing.T) {
+	// [br] and a line break in a cell are line breaks, [noparse] text is kept as it is,
+	// the layout between the tags is not text
+	input := `[table]
+[tr]
+  [td]a[/td] [td]b[/td]
+[/tr]
+[tr]
+  [td]line 1[br]line 2[/td]
+  [td]first
+second [noparse][td] and [b][/noparse][/td]
+[/tr]
+[/table]`
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, table.Headers)
+	assert.Equal(t, [][]string{{"line 1\nline 2", "first\nsecond [td] and [b]"}}, table.Rows)
+}
+
+func TestUnmarshalNestedTable(t *testing.T) {
+	// The rows and cells of a nested table are dropped, their text kept
+	input := "[table][tr][th]a[/th][th]b[/th][/tr][tr][td][table][tr][td]x[/td][/tr][/table][/td][td]y[/td][/tr][/table]"
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, table.Headers)
+	assert.Equal(t, [][]string{{"x", "y"}}, table.Rows)
+}
+
+func TestMarshalWithTags(t *testing.T) {
+	tests := []struct {
+		name string
+		cell string
+		// written is the [td] of the data row
+		written string
+	}{
+		// Inline tags are cell text, written as they are
+		{"bold", "[b]t[/b]", "[b]t[/b]"},
+		{"link", "[url=http://e.com]e[/url]", "[url=http://e.com]e[/url]"},
+		{"list item", "[*] one", "[*] one"},
+		{"closing noparse", "[/noparse] x", "[/noparse] x"},
+		{"brackets", "[x] and ]", "[x] and ]"},
+		// Tags that would end the cell or the table are wrapped in [noparse]
+		{"cell tag", "[td]", "[noparse][td][/noparse]"},
+		{"table tag", "a [/TABLE] b", "a [noparse][/TABLE][/noparse] b"},
+		{"line break tag", "a[br]b", "a[noparse][br][/noparse]b"},
+		{"noparse", "[noparse]x[/noparse]", "[noparse][noparse][/noparse]x[/noparse]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &common.Table{Headers: []string{"h"}, Rows: [][]string{{tt.cell}}}
+			var buf bytes.Buffer
+			require.NoError(t, Marshal(&common.Config{Writer: &buf}, table))
+			expected := "[table]\n  [tr][th]h[/th][/tr]\n  [tr][td]" + tt.written + "[/td][/tr]\n[/table]\n"
+			assert.Equal(t, expected, buf.String())
+
+			// The cell is read back as it was
+			got := &common.Table{}
+			require.NoError(t, Unmarshal(&common.Config{Reader: &buf}, got))
+			assert.Equal(t, table.Rows, got.Rows)
+		})
+	}
+}
+
+func TestMarshalWithSpans(t *testing.T) {
+	// Merged cells are written once with their spans
+	table := &common.Table{
+		Headers: []string{"name", "note"},
+		Rows:    [][]string{{"merged", ""}, {"a", "b"}, {"c", ""}},
+		Spans:   []common.Span{{Row: 0, Col: 0, RowSpan: 1, ColSpan: 2}, {Row: 1, Col: 1, RowSpan: 2, ColSpan: 1}},
+	}
+	var buf bytes.Buffer
+	err := Marshal(&common.Config{Writer: &buf}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, `[table]
+  [tr][th]name[/th][th]note[/th][/tr]
+  [tr][td colspan=2]merged[/td][/tr]
+  [tr][td]a[/td][td rowspan=2]b[/td][/tr]
+  [tr][td]c[/td][/tr]
+[/table]
+`, buf.String())
+
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: &buf}, got))
+	assert.Equal(t, table.Headers, got.Headers)
+	assert.Equal(t, table.Rows, got.Rows)
+	assert.Equal(t, table.Spans, got.Spans)
+}
+
+func TestMarshalWithHeaderAndBorder(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := &common.Config{Writer: &buf, Extension: map[string]string{"header": "false", "border": "1"}}
+	err := Marshal(cfg, &common.Table{Headers: []string{"a"}, Rows: [][]string{{"1"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "[table border=1]\n  [tr][td]a[/td][/tr]\n  [tr][td]1[/td][/tr]\n[/table]\n", buf.String())
+
+	assert.NoError(t, validateBorder(""))
+	assert.NoError(t, validateBorder("2"))
+	assert.Error(t, validateBorder("thick"))
+	assert.Error(t, validateBorder("-1"))
+}
+
+func TestUnmarshalErrorCases(t *testing.T) {
+	t.Run("nil table", func(t *testing.T) {
+		err := Unmarshal(&common.Config{Reader: strings.NewReader("[table][tr][td]a[/td][/tr][/table]")}, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("no table", func(t *testing.T) {
+		err := Unmarshal(&common.Config{Reader: strings.NewReader("[b]no table[/b]")}, &common.Table{})
+		assert.ErrorContains(t, err, "no [table]")
+	})
+
+	t.Run("table without rows", func(t *testing.T) {
+		err := Unmarshal(&common.Config{Reader: strings.NewReader("[table][/table]")}, &common.Table{})
+		assert.ErrorContains(t, err, "no [table]")
+	})
+
+	t.Run("column span over the limit", func(t *testing.T) {
+		err := Unmarshal(&common.Config{Reader: strings.NewReader("[table][tr][th colspan=1001]a[/th][/tr][/table]")}, &common.Table{})
+		assert.ErrorContains(t, err, "more than the limit of 1000")
+	})
+}
+
+func TestMarshalErrorCases(t *testing.T) {
+	t.Run("no columns", func(t *testing.T) {
+		err := Marshal(&common.Config{Writer: &bytes.Buffer{}}, &common.Table{})
+		assert.Error(t, err)
+	})
+
+	t.Run("short row", func(t *testing.T) {
+		table := &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1"}}}
+		err := Marshal(&common.Config{Writer: &bytes.Buffer{}}, table)
+		assert.ErrorContains(t, err, "row 0 has 1 columns")
+	})
+}
+
+func TestFixture(t *testing.T) {
+	data, err := os.ReadFile("../test/mysql.bbcode")
+	require.NoError(t, err)
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: bytes.NewReader(data)}, table))
+	assert.Equal(t, []string{"FIELD", "TYPE", "NULL", "KEY", "DEFAULT", "EXTRA"}, table.Headers)
+	assert.Len(t, table.Rows, 3)
+	assert.Equal(t, []string{"username", "varchar(10)", "NO", "", "NULL", ""}, table.Rows[1])
+
+	// The fixture is written with one row per line, as the writer writes it
+	var buf bytes.Buffer
+	require.NoError(t, Marshal(&common.Config{Writer: &buf}, table))
+	assert.Equal(t, string(data), buf.String())
+}
+
+func TestDetect(t *testing.T) {
+	fixture, err := os.ReadFile("../test/mysql.bbcode")
+	require.NoError(t, err)
+	// Against the other wiki dialects imported above, table tags in any case are BBCode
+	inputs := []string{string(fixture), "[TABLE=border][TR][TH]a[/TH][/TR][/TABLE]", "[tr][td]a[/td][/tr]"}
+	for _, input := range inputs {
+		scores := common.ScoreFormats([]byte(input))
+		require.NotEmpty(t, scores, input)
+		assert.Equal(t, "bbcode", scores[0].Format, input)
+	}
+	// Rows without a [table] tag could be a piece of a post
+	assert.Greater(t, detect(inputs[1]), detect(inputs[2]))
+
+	// Inline tags without a table are any forum post
+	assert.Equal(t, common.DetectNone, detect("[b]bold[/b] and [url=https://example.com]a link[/url]"))
+	// Links of the other wiki dialects use brackets too
+	assert.Equal(t, common.DetectNone, detect("^ a ^\n| [[page|title]] |"))
+	assert.Equal(t, common.DetectNone, detect("||a||\n|[title|https://example.com]|"))
+	assert.Equal(t, common.DetectNone, detect("{|\n|-\n| [[Page|title]] [https://example.com x]\n|}"))
+}
@@ -0,0 +1,45 @@
+package bbcode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "bbcode",
+		Description: "BBCode forum table",
+		Detect:      detect,
+		DetectOrder: 18,
+		Params: []common.FormatParam{
+			{Name: "header", DefaultValue: "true", AllowedValues: "true, false", Description: "Write the header row with [th] cells, false writes [td] cells", Use: common.ParamWrite},
+			{Name: "border", DefaultValue: "", Validate: validateBorder, Description: "Border width written as [table border=N]", Use: common.ParamWrite},
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+	})
+}
+
+// validateBorder accepts an empty value or a border width such as 1
+func validateBorder(value string) error {
+	if n, err := strconv.Atoi(value); value != "" && (err != nil || n < 0) {
+		return fmt.Errorf("invalid value %q, expected a border width such as 1", value)
+	}
+	return nil
+}
+
+// detect scores content holding a [table] with [tr] rows
+func detect(sample string) int {
+	lower := strings.ToLower(sample)
+	switch {
+	case strings.Contains(lower, "[table") && strings.Contains(lower, "[tr]"):
+		return 95
+	case strings.Contains(lower, "[tr]") && (strings.Contains(lower, "[td") || strings.Contains(lower, "[th")):
+		return 70
+	}
+	return common.DetectNone
+}
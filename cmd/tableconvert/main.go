@@ -7,21 +7,8 @@ import (
 	"log"
 	"os"
 
-	"github.com/martianzhang/tableconvert/ascii"
+	"github.com/martianzhang/tableconvert"
 	"github.com/martianzhang/tableconvert/common"
-	"github.com/martianzhang/tableconvert/csv"
-	"github.com/martianzhang/tableconvert/excel"
-	"github.com/martianzhang/tableconvert/html"
-	"github.com/martianzhang/tableconvert/json"
-	"github.com/martianzhang/tableconvert/jsonl"
-	"github.com/martianzhang/tableconvert/latex"
-	"github.com/martianzhang/tableconvert/markdown"
-	"github.com/martianzhang/tableconvert/mediawiki"
-	"github.com/martianzhang/tableconvert/mysql"
-	"github.com/martianzhang/tableconvert/sql"
-	"github.com/martianzhang/tableconvert/tmpl"
-	"github.com/martianzhang/tableconvert/twiki"
-	"github.com/martianzhang/tableconvert/xml"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
@@ -29,15 +16,8 @@ import (
 // Example: go build -ldflags "-X main.version=v1.0.0" ./cmd/tableconvert
 var version = "dev"
 
-var formatRegistry *common.FormatRegistry
-
-func init() {
-	// Initialize format registry
-	formatRegistry = common.NewFormatRegistry()
-
-	// Register all formats
-	registerFormats()
-}
+// formatRegistry holds all built-in formats
+var formatRegistry = tableconvert.DefaultRegistry
 
 func main() {
 	// Check for version flag early (before ParseConfig)
@@ -59,7 +39,7 @@ func main() {
 
 	// Check if MCP mode is requested
 	if cfg.MCPMode {
-		runMCPMode()
+		runMCPMode(&cfg)
 		return
 	}
 
@@ -69,6 +49,15 @@ func main() {
 		return
 	}
 
+	// Check if format detection is requested
+	if cfg.Detect || cfg.ExplainDetect {
+		if err := detectFormat(&cfg, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Perform conversion
 	err = performConversion(&cfg)
 	if err != nil {
@@ -77,10 +66,10 @@ func main() {
 	}
 }
 
-// runMCPMode starts the MCP server
-func runMCPMode() {
+// runMCPMode starts the MCP server, --max-* and --timeout replace the default limits
+func runMCPMode(cfg *common.Config) {
 	// Create the MCP server using the common package
-	server := common.CreateMCPServer(formatRegistry)
+	server := common.CreateMCPServerWithLimits(formatRegistry, cfg.Limits.WithDefaults(common.DefaultMCPLimits))
 
 	// Run the server using stdio transport
 	ctx := context.Background()
@@ -89,6 +78,26 @@ func runMCPMode() {
 	}
 }
 
+// detectFormat prints the format detected from the content of the input.
+// With --explain-detect it prints every candidate format with its score.
+func detectFormat(cfg *common.Config, w io.Writer) error {
+	format, scores, _, err := common.DetectFormat(cfg.Reader)
+	if cfg.ExplainDetect {
+		if reportErr := common.WriteDetectReport(w, scores); reportErr != nil {
+			return reportErr
+		}
+		if len(scores) == 0 {
+			return fmt.Errorf("cannot detect the input format")
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot detect the input format, run with --explain-detect to see the candidate formats")
+	}
+	_, err = fmt.Fprintln(w, format)
+	return err
+}
+
 // runBatchMode processes multiple files in batch
 func runBatchMode(cfg *common.Config) {
 	// Get list of files to process
@@ -143,18 +152,14 @@ func runBatchMode(cfg *common.Config) {
 			continue
 		}
 
-		// Create a temporary config for this file
-		fileCfg := &common.Config{
-			From:      file.FromFormat,
-			To:        file.ToFormat,
-			Reader:    inputFile,
-			Writer:    outputFile,
-			Verbose:   cfg.Verbose,
-			Extension: cfg.Extension,
-		}
+		// Convert this file with every other option of the batch
+		fileCfg := *cfg
+		fileCfg.From, fileCfg.To = file.FromFormat, file.ToFormat
+		fileCfg.File, fileCfg.Result = file.InputPath, ""
+		fileCfg.Reader, fileCfg.Writer = inputFile, outputFile
 
 		// Perform conversion
-		err = common.PerformConversionWithRegistry(formatRegistry, fileCfg)
+		err = common.PerformConversionWithRegistry(formatRegistry, &fileCfg)
 		inputFile.Close()
 		outputFile.Close()
 
@@ -179,56 +184,6 @@ func runBatchMode(cfg *common.Config) {
 	}
 }
 
-// registerFormats registers all format unmarshal/marshal functions
-func registerFormats() {
-	// ASCII
-	formatRegistry.RegisterFormat("ascii", ascii.Unmarshal, ascii.Marshal)
-
-	// CSV
-	formatRegistry.RegisterFormat("csv", csv.Unmarshal, csv.Marshal)
-
-	// Excel
-	formatRegistry.RegisterFormat("excel", excel.Unmarshal, excel.Marshal)
-	formatRegistry.RegisterFormatAlias("xlsx", "excel")
-
-	// HTML
-	formatRegistry.RegisterFormat("html", html.Unmarshal, html.Marshal)
-
-	// JSON
-	formatRegistry.RegisterFormat("json", json.Unmarshal, json.Marshal)
-
-	// JSONL
-	formatRegistry.RegisterFormat("jsonl", jsonl.Unmarshal, jsonl.Marshal)
-	formatRegistry.RegisterFormatAlias("jsonlines", "jsonl")
-
-	// LaTeX
-	formatRegistry.RegisterFormat("latex", latex.Unmarshal, latex.Marshal)
-
-	// Markdown
-	formatRegistry.RegisterFormat("markdown", markdown.Unmarshal, markdown.Marshal)
-	formatRegistry.RegisterFormatAlias("md", "markdown")
-
-	// MediaWiki
-	formatRegistry.RegisterFormat("mediawiki", mediawiki.Unmarshal, mediawiki.Marshal)
-
-	// MySQL
-	formatRegistry.RegisterFormat("mysql", mysql.Unmarshal, mysql.Marshal)
-
-	// SQL
-	formatRegistry.RegisterFormat("sql", sql.Unmarshal, sql.Marshal)
-
-	// Template (write-only format)
-	formatRegistry.RegisterWriteOnlyFormat("tmpl", tmpl.Marshal)
-	formatRegistry.RegisterFormatAlias("template", "tmpl")
-
-	// TWiki
-	formatRegistry.RegisterFormat("twiki", twiki.Unmarshal, twiki.Marshal)
-	formatRegistry.RegisterFormatAlias("tracwiki", "twiki")
-
-	// XML
-	formatRegistry.RegisterFormat("xml", xml.Unmarshal, xml.Marshal)
-}
-
 // performConversion performs the core table conversion logic
 func performConversion(cfg *common.Config) error {
 	// Print verbose output
@@ -244,34 +199,52 @@ func performConversion(cfg *common.Config) error {
 	}
 
 	// Use the registry-based conversion
-	return common.PerformConversionWithRegistry(formatRegistry, cfg)
+	err := common.PerformConversionWithRegistry(formatRegistry, cfg)
+	if err != nil {
+		removeResult(cfg)
+	}
+	return err
 }
 
-// performDryRun performs a dry-run conversion, showing preview without writing
-func performDryRun(cfg *common.Config) error {
-	// Parse input
-	var table common.Table
-
-	unmarshalFn, ok := formatRegistry.GetUnmarshalFunc(cfg.From)
-	if !ok {
-		return fmt.Errorf("unsupported `--from` format: %s", cfg.From)
+// removeResult deletes the --result file of a failed conversion, which holds
+// the rows written before the error, e.g. by a streamed conversion stopped by
+// --max-rows. Formats that update an existing --result, such as sqlite, keep it.
+func removeResult(cfg *common.Config) {
+	if cfg.Result == "" {
+		return
 	}
-
-	if unmarshalFn == nil {
-		return fmt.Errorf("format %s does not support reading (unmarshal)", cfg.From)
+	if format, ok := common.LookupFormat(cfg.To); ok && format.UpdatesResult {
+		return
 	}
+	if closer, ok := cfg.Writer.(io.Closer); ok {
+		closer.Close()
+	}
+	os.Remove(cfg.Result)
+}
 
-	if err := unmarshalFn(cfg, &table); err != nil {
-		return fmt.Errorf("error parsing input: %w", err)
+// performDryRun performs a dry-run conversion, showing preview without writing.
+// The input is read and written within the limits, like a conversion.
+func performDryRun(cfg *common.Config) error {
+	// Parse input
+	tables, err := common.ReadTablesContext(context.Background(), formatRegistry, cfg)
+	if err != nil {
+		return err
 	}
 
 	// Apply transformations
-	cfg.ApplyTransformations(&table)
+	for _, table := range tables {
+		table.FillNulls(cfg.NullString())
+		cfg.ApplyTransformations(table)
+	}
+	table := tables[0]
 
 	// Show dry-run summary
 	fmt.Fprintf(os.Stderr, "=== DRY RUN MODE ===\n")
 	fmt.Fprintf(os.Stderr, "Input format:  %s\n", cfg.From)
 	fmt.Fprintf(os.Stderr, "Output format: %s\n", cfg.To)
+	if len(tables) > 1 {
+		fmt.Fprintf(os.Stderr, "Tables: %d, the first one is shown\n", len(tables))
+	}
 	fmt.Fprintf(os.Stderr, "Rows: %d\n", len(table.Rows))
 	fmt.Fprintf(os.Stderr, "Columns: %d\n", len(table.Headers))
 	if len(table.Headers) > 0 {
@@ -284,25 +257,12 @@ func performDryRun(cfg *common.Config) error {
 		}
 	}
 
-	// Try to generate output to validate it would work
-	// Use a discard writer since we don't want actual output
-	marshalFn, ok := formatRegistry.GetMarshalFunc(cfg.To)
-	if !ok {
-		return fmt.Errorf("unsupported `--to` format: %s", cfg.To)
-	}
-
-	if marshalFn == nil {
-		return fmt.Errorf("format %s does not support writing (marshal)", cfg.To)
-	}
-
-	// Create a discard writer to test marshaling
-	// We need to temporarily replace the writer
-	originalWriter := cfg.Writer
-	cfg.Writer = io.Discard
-
-	err := marshalFn(cfg, &table)
-	cfg.Writer = originalWriter
-
+	// Try to generate output to validate it would work, writing to a discard
+	// writer instead of the output; formats such as excel save to --result by name
+	originalWriter, originalResult := cfg.Writer, cfg.Result
+	cfg.Writer, cfg.Result = io.Discard, ""
+	err = common.WriteTablesContext(context.Background(), formatRegistry, cfg, tables)
+	cfg.Writer, cfg.Result = originalWriter, originalResult
 	if err != nil {
 		return fmt.Errorf("error generating output: %w", err)
 	}
@@ -45,7 +45,7 @@ func TestMain(t *testing.T) {
 	}{
 		{
 			name:   "mysql to markdown",
-			args:   []string{"tableconvert", "--from", "mysql", "--to", "markdown", "--key", "value"},
+			args:   []string{"tableconvert", "--from", "mysql", "--to", "markdown", "--key", "value", "--lenient"},
 			file:   "mysql.txt",
 			result: "mysql.md",
 		},
@@ -85,6 +85,30 @@ func TestMain(t *testing.T) {
 			file:   "mysql.txt",
 			result: "mysql.column.json",
 		},
+		{
+			name:   "mysql to yaml",
+			args:   []string{"tableconvert", "--from", "mysql", "--to", "yaml"},
+			file:   "mysql.txt",
+			result: "mysql.yaml",
+		},
+		{
+			name:   "yaml to mysql",
+			args:   []string{"tableconvert", "--from", "yaml", "--to", "mysql"},
+			file:   "mysql.yaml",
+			result: "mysql.txt",
+		},
+		{
+			name:   "mysql to toml",
+			args:   []string{"tableconvert", "--from", "mysql", "--to", "toml"},
+			file:   "mysql.txt",
+			result: "mysql.toml",
+		},
+		{
+			name:   "toml to mysql",
+			args:   []string{"tableconvert", "--from", "toml", "--to", "mysql"},
+			file:   "mysql.toml",
+			result: "mysql.txt",
+		},
 		{
 			name:   "mysql to sql",
 			args:   []string{"tableconvert", "--from", "mysql", "--to", "sql"},
@@ -127,6 +151,120 @@ func TestMain(t *testing.T) {
 			file:   "mysql.xlsx",
 			result: "mysql.txt",
 		},
+		{
+			name:   "arrow to mysql",
+			args:   []string{"tableconvert", "--from", "arrow", "--to", "mysql"},
+			file:   "mysql.arrow",
+			result: "mysql.txt",
+		},
+		{
+			name:   "avro to mysql",
+			args:   []string{"tableconvert", "--from", "avro", "--to", "mysql"},
+			file:   "mysql.avro",
+			result: "mysql.txt",
+		},
+		{
+			name:   "ods to mysql",
+			args:   []string{"tableconvert", "--from", "ods", "--to", "mysql"},
+			file:   "mysql.ods",
+			result: "mysql.txt",
+		},
+		{
+			name:   "parquet to mysql",
+			args:   []string{"tableconvert", "--from", "parquet", "--to", "mysql"},
+			file:   "mysql.parquet",
+			result: "mysql.txt",
+		},
+		{
+			name:   "mysql to asciidoc",
+			args:   []string{"tableconvert", "--from", "mysql", "--to", "asciidoc"},
+			file:   "mysql.txt",
+			result: "mysql.adoc",
+		},
+		{
+			name:   "asciidoc to mysql",
+			args:   []string{"tableconvert", "--from", "asciidoc", "--to", "mysql"},
+			file:   "mysql.adoc",
+			result: "mysql.txt",
+		},
+		{
+			name:   "mysql to org",
+			args:   []string{"tableconvert", "--from", "mysql", "--to", "org"},
+			file:   "mysql.txt",
+			result: "mysql.org",
+		},
+		{
+			name:   "org to mysql",
+			args:   []string{"tableconvert", "--from", "org", "--to", "mysql"},
+			file:   "mysql.org",
+			result: "mysql.txt",
+		},
+		{
+			name:   "mysql to bbcode",
+			args:   []string{"tableconvert", "--from", "mysql", "--to", "bbcode"},
+			file:   "mysql.txt",
+			result: "mysql.bbcode",
+		},
+		{
+			name:   "bbcode to mysql",
+			args:   []string{"tableconvert", "--from", "bbcode", "--to", "mysql"},
+			file:   "mysql.bbcode",
+			result: "mysql.txt",
+		},
+		{
+			name:   "mysql to dokuwiki",
+			args:   []string{"tableconvert", "--from", "mysql", "--to", "dokuwiki"},
+			file:   "mysql.txt",
+			result: "mysql.dokuwiki",
+		},
+		{
+			name:   "dokuwiki to mysql",
+			args:   []string{"tableconvert", "--from", "dokuwiki", "--to", "mysql"},
+			file:   "mysql.dokuwiki",
+			result: "mysql.txt",
+		},
+		{
+			name:   "mysql to textile",
+			args:   []string{"tableconvert", "--from", "mysql", "--to", "textile"},
+			file:   "mysql.txt",
+			result: "mysql.textile",
+		},
+		{
+			name:   "textile to mysql",
+			args:   []string{"tableconvert", "--from", "textile", "--to", "mysql"},
+			file:   "mysql.textile",
+			result: "mysql.txt",
+		},
+		{
+			name:   "mysql to jira",
+			args:   []string{"tableconvert", "--from", "mysql", "--to", "jira"},
+			file:   "mysql.txt",
+			result: "mysql.jira",
+		},
+		{
+			name:   "jira to mysql",
+			args:   []string{"tableconvert", "--from", "jira", "--to", "mysql"},
+			file:   "mysql.jira",
+			result: "mysql.txt",
+		},
+		{
+			name:   "mysql to rst",
+			args:   []string{"tableconvert", "--from", "mysql", "--to", "rst"},
+			file:   "mysql.txt",
+			result: "mysql.rst",
+		},
+		{
+			name:   "rst to mysql",
+			args:   []string{"tableconvert", "--from", "rst", "--to", "mysql"},
+			file:   "mysql.rst",
+			result: "mysql.txt",
+		},
+		{
+			name:   "sqlite to mysql",
+			args:   []string{"tableconvert", "--from", "sqlite", "--to", "mysql"},
+			file:   "mysql.sqlite",
+			result: "mysql.txt",
+		},
 		{
 			name:   "mysql to twiki",
 			args:   []string{"tableconvert", "--from", "mysql", "--to", "twiki"},
@@ -139,6 +277,30 @@ func TestMain(t *testing.T) {
 			file:   "mysql.twiki",
 			result: "mysql.txt",
 		},
+		{
+			name:   "mysql to tracwiki",
+			args:   []string{"tableconvert", "--from", "mysql", "--to", "tracwiki"},
+			file:   "mysql.txt",
+			result: "mysql.tracwiki",
+		},
+		{
+			name:   "tracwiki to mysql",
+			args:   []string{"tableconvert", "--from", "tracwiki", "--to", "mysql"},
+			file:   "mysql.tracwiki",
+			result: "mysql.txt",
+		},
+		{
+			name:   "mysql to code",
+			args:   []string{"tableconvert", "--from", "mysql", "--to", "code"},
+			file:   "mysql.txt",
+			result: "mysql.js",
+		},
+		{
+			name:   "mysql to python code",
+			args:   []string{"tableconvert", "--from", "mysql", "--to", "code", "--lang", "python"},
+			file:   "mysql.txt",
+			result: "mysql.py",
+		},
 		{
 			name:   "mysql to html",
 			args:   []string{"tableconvert", "--from", "mysql", "--to", "html"},
@@ -252,6 +414,26 @@ func TestPerformConversion(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+// TestPerformConversionRemovesResult tests that a failed conversion leaves no partial --result
+func TestPerformConversionRemovesResult(t *testing.T) {
+	for _, to := range []string{"csv", "jsonl", "sql"} {
+		resultFile := filepath.Join(t.TempDir(), "out."+to)
+		cfg, err := common.ParseConfig([]string{
+			"--file", ProjectRoot + "test/mysql.csv",
+			"--result", resultFile,
+			"--max-rows=1",
+		})
+		require.NoError(t, err)
+
+		// The rows are streamed, the first one is written before the limit stops the conversion
+		err = performConversion(&cfg)
+		var limitErr *common.LimitError
+		require.ErrorAs(t, err, &limitErr, to)
+		_, err = os.Stat(resultFile)
+		assert.True(t, os.IsNotExist(err), to)
+	}
+}
+
 // TestPerformConversionError tests error handling in performConversion
 func TestPerformConversionError(t *testing.T) {
 	// Test with invalid from format
@@ -267,7 +449,7 @@ func TestPerformConversionError(t *testing.T) {
 	assert.Contains(t, err.Error(), "unsupported `--from` format")
 }
 
-// TestRegisterFormats tests that registerFormats properly registers all formats
+// TestRegisterFormats tests the registration pattern used by tableconvert.NewRegistry
 func TestRegisterFormats(t *testing.T) {
 	// This test verifies that the format registry is properly initialized
 	// by checking that all expected formats are registered
@@ -275,7 +457,7 @@ func TestRegisterFormats(t *testing.T) {
 	// Create a new registry
 	testRegistry := common.NewFormatRegistry()
 
-	// Manually register formats (simulating what registerFormats does)
+	// Manually register formats (simulating what tableconvert.NewRegistry does)
 	// We'll test a subset to verify the pattern
 	testRegistry.RegisterFormat("csv", func(cfg *common.Config, table *common.Table) error {
 		return nil
@@ -291,7 +473,7 @@ func TestRegisterFormats(t *testing.T) {
 	assert.NotNil(t, unmarshalFn)
 
 	// Test that alias registration works (even if "markdown" isn't registered)
-	// This tests the pattern used in registerFormats
+	// This tests the pattern used in tableconvert.NewRegistry
 }
 
 // TestMainFunctionErrorHandling tests error handling in main
@@ -360,18 +542,24 @@ func TestRegisterFormatsCompleteness(t *testing.T) {
 	// Test that the format registry contains all expected formats
 	// by checking the main registry after initialization
 
-	// The formatRegistry is initialized in init(), so we can check it
+	// formatRegistry is tableconvert.DefaultRegistry, so we can check it
 	expectedFormats := []string{
-		"ascii", "csv", "excel", "html", "json", "jsonl",
-		"latex", "markdown", "mediawiki", "mysql", "sql", "tmpl", "twiki", "xml",
+		"arrow", "ascii", "asciidoc", "avro", "bbcode", "code", "csv", "dokuwiki", "excel", "html", "jira", "json", "jsonl",
+		"latex", "markdown", "mediawiki", "mysql", "ods", "org", "parquet", "rst", "sql", "sqlite", "textile", "tmpl", "toml",
+		"tracwiki", "twiki", "xml", "yaml",
 	}
 
 	expectedAliases := map[string]string{
-		"xlsx":      "excel",
-		"jsonlines": "jsonl",
-		"md":        "markdown",
-		"template":  "tmpl",
-		"tracwiki":  "twiki",
+		"adoc":             "asciidoc",
+		"confluence":       "jira",
+		"feather":          "arrow",
+		"xlsx":             "excel",
+		"jsonlines":        "jsonl",
+		"md":               "markdown",
+		"orgmode":          "org",
+		"restructuredtext": "rst",
+		"template":         "tmpl",
+		"yml":              "yaml",
 	}
 
 	// Check each expected format
@@ -379,10 +567,10 @@ func TestRegisterFormatsCompleteness(t *testing.T) {
 		unmarshalFn, unmarshalOk := formatRegistry.GetUnmarshalFunc(format)
 		marshalFn, marshalOk := formatRegistry.GetMarshalFunc(format)
 
-		// tmpl is write-only, so it should have nil unmarshal
-		if format == "tmpl" {
-			assert.True(t, marshalOk, "tmpl should have marshal function")
-			assert.Nil(t, unmarshalFn, "tmpl should have nil unmarshal function")
+		// tmpl and code are write-only, so they should have nil unmarshal
+		if format == "tmpl" || format == "code" {
+			assert.True(t, marshalOk, "%s should have marshal function", format)
+			assert.Nil(t, unmarshalFn, "%s should have nil unmarshal function", format)
 		} else {
 			assert.True(t, unmarshalOk, "format %s should have unmarshal function", format)
 			assert.True(t, marshalOk, "format %s should have marshal function", format)
@@ -775,6 +963,43 @@ func TestFormatAutoDetection(t *testing.T) {
 	}
 }
 
+func TestContentDetection(t *testing.T) {
+	tmpDir := t.TempDir()
+	input := filepath.Join(tmpDir, "data.txt")
+	require.NoError(t, os.WriteFile(input, []byte("{\"name\":\"Alice\"}\n{\"name\":\"Bob\"}\n"), 0644))
+
+	// Without --from the input format comes from the content
+	output := filepath.Join(tmpDir, "out.csv")
+	cfg, err := common.ParseConfig([]string{input, output})
+	require.NoError(t, err)
+	assert.Equal(t, "jsonl", cfg.From)
+	require.NoError(t, performConversion(&cfg))
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, "name\nAlice\nBob\n", string(data))
+
+	cfg, err = common.ParseConfig([]string{"--detect", input})
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, detectFormat(&cfg, &out))
+	assert.Equal(t, "jsonl\n", out.String())
+
+	cfg, err = common.ParseConfig([]string{"--explain-detect", "--file", input})
+	require.NoError(t, err)
+	out.Reset()
+	require.NoError(t, detectFormat(&cfg, &out))
+	assert.Contains(t, out.String(), "FORMAT  SCORE\njsonl ")
+	assert.Contains(t, out.String(), "Detected: jsonl")
+
+	// Plain text matches no format
+	require.NoError(t, os.WriteFile(input, []byte("hello world\n"), 0644))
+	_, err = common.ParseConfig([]string{input, output})
+	assert.ErrorContains(t, err, "cannot detect the input format")
+	cfg, err = common.ParseConfig([]string{"--detect", input})
+	require.NoError(t, err)
+	assert.Error(t, detectFormat(&cfg, io.Discard))
+}
+
 // TestErrorMessages tests helpful error messages
 func TestErrorMessages(t *testing.T) {
 	tests := []struct {
@@ -853,6 +1078,35 @@ func TestRunBatchMode(t *testing.T) {
 	assert.Len(t, outputFiles, 2)
 }
 
+// TestRunBatchModeOptions tests that table selection and transformations apply to every file
+func TestRunBatchModeOptions(t *testing.T) {
+	tmpDir := t.TempDir()
+	input := "| a |\n|---|\n| 1 |\n\n| b |\n|---|\n| x |\n| x |\n"
+	os.WriteFile(filepath.Join(tmpDir, "file1.md"), []byte(input), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "file2.md"), []byte(input), 0644)
+	outputDir := filepath.Join(tmpDir, "output")
+
+	cfg := &common.Config{
+		Batch:      filepath.Join(tmpDir, "*.md"),
+		To:         "csv",
+		OutputDir:  outputDir,
+		TableIndex: 1,
+		Extension:  map[string]string{"uppercase": "true", "deduplicate": "true"},
+	}
+	oldStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	runBatchMode(cfg)
+	w.Close()
+	os.Stderr = oldStderr
+
+	for _, name := range []string{"file1.csv", "file2.csv"} {
+		output, err := os.ReadFile(filepath.Join(outputDir, name))
+		require.NoError(t, err)
+		assert.Equal(t, "B\nX\n", string(output), name)
+	}
+}
+
 // TestRunBatchModeWithVerbose tests batch mode with verbose output
 func TestRunBatchModeWithVerbose(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "runbatch-verbose-test-*")
@@ -1157,6 +1411,19 @@ func TestDetectFormatFromExtension(t *testing.T) {
 		{"no extension", "datafile", ""},
 		{"empty string", "", ""},
 		{"xlsx", "data.xlsx", "excel"},
+		{"arrow", "data.arrow", "arrow"},
+		{"feather", "data.feather", "arrow"},
+		{"avro", "data.avro", "avro"},
+		{"adoc", "data.adoc", "asciidoc"},
+		{"asciidoc", "data.asciidoc", "asciidoc"},
+		{"ods", "data.ods", "ods"},
+		{"org", "notes.org", "org"},
+		{"parquet", "data.parquet", "parquet"},
+		{"rst", "data.rst", "rst"},
+		{"rest", "data.rest", "rst"},
+		{"sqlite", "data.sqlite", "sqlite"},
+		{"textile", "page.textile", "textile"},
+		{"db", "app.db", "sqlite"},
 		{"md", "data.md", "markdown"},
 		{"markdown", "data.markdown", "markdown"},
 	}
@@ -1360,7 +1627,6 @@ func TestFormatRegistryAliases(t *testing.T) {
 		"jsonlines": "jsonl",
 		"md":        "markdown",
 		"template":  "tmpl",
-		"tracwiki":  "twiki",
 	}
 
 	for alias, target := range aliases {
@@ -1604,6 +1870,35 @@ func TestDryRunErrorHandling(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+// TestDryRunLimits tests that dry-run reads and writes within the limits
+func TestDryRunLimits(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputFile := filepath.Join(tmpDir, "input.csv")
+	require.NoError(t, os.WriteFile(inputFile, []byte("name\nAlice\nBob\n"), 0644))
+
+	cfg, err := common.ParseConfig([]string{
+		"--from", "csv",
+		"--to", "xlsx",
+		"--file", inputFile,
+		"--result", filepath.Join(tmpDir, "output.xlsx"),
+		"--dry-run",
+		"--max-rows=1",
+	})
+	require.NoError(t, err)
+	err = performConversion(&cfg)
+	var limitErr *common.LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "max-rows", limitErr.Limit)
+
+	// Formats saving to --result by name write nothing either
+	cfg, err = common.ParseConfig([]string{"--from", "csv", "--to", "xlsx", "--file", inputFile,
+		"--result", filepath.Join(tmpDir, "output.xlsx"), "--dry-run"})
+	require.NoError(t, err)
+	require.NoError(t, performConversion(&cfg))
+	_, err = os.Stat(filepath.Join(tmpDir, "output.xlsx"))
+	assert.True(t, os.IsNotExist(err))
+}
+
 // TestDryRunFlagParsing tests dry-run flag parsing variations
 func TestDryRunFlagParsing(t *testing.T) {
 	tests := []struct {
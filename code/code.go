@@ -0,0 +1,426 @@
+package code
+
+import (
+	"fmt"
+	"go/format"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+// jsIdentifierPattern matches an object key that JavaScript takes without quotes
+var jsIdentifierPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// language holds how values are spelled in a language
+type language struct {
+	quote       func(string) string
+	null        string
+	true, false string
+}
+
+var languages = map[string]language{
+	"php":    {quote: quotePHP, null: "null", true: "true", false: "false"},
+	"python": {quote: func(s string) string { return quoteDouble(s, "python") }, null: "None", true: "True", false: "False"},
+	"ruby":   {quote: func(s string) string { return quoteDouble(s, "ruby") }, null: "nil", true: "true", false: "false"},
+	"js":     {quote: func(s string) string { return quoteDouble(s, "js") }, null: "null", true: "true", false: "false"},
+	"go":     {quote: strconv.Quote, null: "nil", true: "true", false: "false"},
+}
+
+// quotePHP writes s as a single-quoted PHP string, where only "\" and "'" are escaped
+func quotePHP(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// quoteDouble writes s as a double-quoted Python, Ruby or JavaScript string. Ruby
+// also escapes the "#" of "#{", "#$" and "#@", JavaScript the line and paragraph separators.
+func quoteDouble(s string, lang string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i, r := range s {
+		switch {
+		case r == '\\' || r == '"':
+			b.WriteString(`\` + string(r))
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		case lang == "js" && (r == '\u2028' || r == '\u2029'):
+			fmt.Fprintf(&b, `\u%04x`, r)
+		case lang == "ruby" && r == '#' && i+1 < len(s) && strings.ContainsRune("{$@", rune(s[i+1])):
+			b.WriteString(`\#`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// number is the text of a float cell, kept so that it is written with all its digits
+type number string
+
+// floatLiteral writes the decimal text s as a float literal every language takes:
+// without a plus sign or leading zeros, with digits on both sides of the point,
+// and with ".0" added when it has neither a point nor an exponent
+func floatLiteral(s string) string {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "+")
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	mantissa, exponent := s, ""
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mantissa, exponent = s[:i], s[i:]
+	}
+	whole, fraction, point := strings.Cut(mantissa, ".")
+	whole = strings.TrimLeft(whole, "0")
+	if whole == "" {
+		whole = "0"
+	}
+	switch {
+	case point && fraction == "":
+		fraction = "0"
+	case !point && exponent == "":
+		point, fraction = true, "0"
+	}
+	if point {
+		return sign + whole + "." + fraction + exponent
+	}
+	return sign + whole + exponent
+}
+
+// literal writes a cell value as returned by values
+func (l language) literal(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return l.null
+	case bool:
+		if v {
+			return l.true
+		}
+		return l.false
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case number:
+		return floatLiteral(string(v))
+	default:
+		return l.quote(fmt.Sprint(v))
+	}
+}
+
+// values returns the cells of the table as native values, numbers and booleans
+// inferred with common.InferType unless infer is false. Floats are kept as their
+// number text. A column is typed as a whole: when its values other than nil mix
+// strings with other types, or booleans with numbers, they are all strings.
+// Sources without NULL, such as CSV, leave missing values blank: blank cells of
+// a number or boolean column are nil rather than making the column strings.
+func values(table *common.Table, infer bool) [][]interface{} {
+	rows := make([][]interface{}, len(table.Rows))
+	for r, row := range table.Rows {
+		rows[r] = make([]interface{}, len(row))
+		for c := range row {
+			value := table.Value(r, c, infer)
+			if _, ok := value.(float64); ok {
+				// Hex, underscores, Inf and NaN have no literal in most languages
+				value = row[c]
+				if common.IsDecimal(row[c]) {
+					value = number(row[c])
+				}
+			}
+			rows[r][c] = value
+		}
+	}
+	for c := range table.Headers {
+		blank := func(r int) bool {
+			return !table.MarksNulls() && strings.TrimSpace(table.Rows[r][c]) == ""
+		}
+		switch columnKind(rows, c, blank) {
+		case "mixed":
+			for r, row := range rows {
+				if row[c] != nil {
+					row[c] = table.Rows[r][c]
+				}
+			}
+		case "bool", "number":
+			for r, row := range rows {
+				if blank(r) {
+					row[c] = nil
+				}
+			}
+		}
+	}
+	return rows
+}
+
+// columnKind returns the type shared by the values of column c other than nil
+// and blank cells: "bool", "number" or "string", "mixed" when they are strings
+// mixed with other types or booleans mixed with numbers, and "" if there are none
+func columnKind(rows [][]interface{}, c int, blank func(r int) bool) string {
+	kind := ""
+	for r, row := range rows {
+		var t string
+		switch row[c].(type) {
+		case nil:
+			continue
+		case bool:
+			t = "bool"
+		case int64, number:
+			t = "number"
+		default:
+			if blank(r) {
+				continue
+			}
+			t = "string"
+		}
+		if kind != "" && kind != t {
+			return "mixed"
+		}
+		kind = t
+	}
+	return kind
+}
+
+// list writes items one per line, each followed by a comma, between open and close
+func list(b *strings.Builder, open string, items []string, indent, close string) {
+	if len(items) == 0 {
+		b.WriteString(open + close)
+		return
+	}
+	b.WriteString(open + "\n")
+	for _, item := range items {
+		b.WriteString(indent + item + ",\n")
+	}
+	b.WriteString(close)
+}
+
+// Marshal writes the table as a list of records in the language given by --lang:
+// a PHP array of associative arrays, a Python list of dicts or pandas DataFrame,
+// a Ruby array of hashes, a JavaScript or TypeScript array of objects, or a Go
+// slice of structs
+func Marshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Marshal: input table pointer cannot be nil")
+	}
+	if len(table.Headers) == 0 {
+		return fmt.Errorf("Marshal: table must have at least one header")
+	}
+	for i, row := range table.Rows {
+		if len(row) != len(table.Headers) {
+			return fmt.Errorf("Marshal: row %d has %d columns, but table has %d", i, len(row), len(table.Headers))
+		}
+	}
+
+	lang := cfg.GetExtensionString("lang", "js")
+	name := cfg.GetExtensionString("name", "data")
+	rows := values(table, cfg.GetExtensionBool("infer", true))
+	var b strings.Builder
+	switch lang {
+	case "php":
+		writePHP(&b, name, table.Headers, rows)
+	case "python":
+		if cfg.GetExtensionBool("pandas", false) {
+			writePandas(&b, name, table.Headers, rows)
+		} else {
+			writePython(&b, name, table.Headers, rows)
+		}
+	case "ruby":
+		writeRuby(&b, name, table.Headers, rows)
+	case "js", "ts":
+		writeJS(&b, name, table.Headers, rows, lang == "ts" && cfg.GetExtensionBool("as-const", false))
+	case "go":
+		if err := writeGo(&b, name, cfg.GetExtensionString("struct", ""), table.Headers, rows); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Marshal: unsupported lang %q, expected php, python, ruby, js, ts or go", lang)
+	}
+	_, err := io.WriteString(cfg.Writer, b.String())
+	return err
+}
+
+// records writes each row as its fields joined by ", ", each field written by field
+func records(headers []string, rows [][]interface{}, field func(header string, value interface{}) string) []string {
+	items := make([]string, len(rows))
+	for r, row := range rows {
+		fields := make([]string, len(row))
+		for c, value := range row {
+			fields[c] = field(headers[c], value)
+		}
+		items[r] = strings.Join(fields, ", ")
+	}
+	return items
+}
+
+func writePHP(b *strings.Builder, name string, headers []string, rows [][]interface{}) {
+	php := languages["php"]
+	items := records(headers, rows, func(header string, value interface{}) string {
+		return php.quote(header) + " => " + php.literal(value)
+	})
+	for i := range items {
+		items[i] = "[" + items[i] + "]"
+	}
+	list(b, "$"+name+" = [", items, "    ", "];\n")
+}
+
+func writePython(b *strings.Builder, name string, headers []string, rows [][]interface{}) {
+	python := languages["python"]
+	items := records(headers, rows, func(header string, value interface{}) string {
+		return python.quote(header) + ": " + python.literal(value)
+	})
+	for i := range items {
+		items[i] = "{" + items[i] + "}"
+	}
+	list(b, name+" = [", items, "    ", "]\n")
+}
+
+// writePandas writes a DataFrame constructor taking a list of values per column
+func writePandas(b *strings.Builder, name string, headers []string, rows [][]interface{}) {
+	python := languages["python"]
+	columns := make([]string, len(headers))
+	for c, header := range headers {
+		cells := make([]string, len(rows))
+		for r, row := range rows {
+			cells[r] = python.literal(row[c])
+		}
+		columns[c] = python.quote(header) + ": [" + strings.Join(cells, ", ") + "]"
+	}
+	b.WriteString("import pandas as pd\n\n")
+	list(b, name+" = pd.DataFrame({", columns, "    ", "})\n")
+}
+
+func writeRuby(b *strings.Builder, name string, headers []string, rows [][]interface{}) {
+	ruby := languages["ruby"]
+	items := records(headers, rows, func(header string, value interface{}) string {
+		return ruby.quote(header) + " => " + ruby.literal(value)
+	})
+	for i := range items {
+		items[i] = "{ " + items[i] + " }"
+	}
+	list(b, name+" = [", items, "  ", "]\n")
+}
+
+// writeJS writes an array of objects, keys that are identifiers being left unquoted
+func writeJS(b *strings.Builder, name string, headers []string, rows [][]interface{}, asConst bool) {
+	js := languages["js"]
+	items := records(headers, rows, func(header string, value interface{}) string {
+		key := header
+		if !jsIdentifierPattern.MatchString(key) {
+			key = js.quote(key)
+		}
+		return key + ": " + js.literal(value)
+	})
+	for i := range items {
+		items[i] = "{ " + items[i] + " }"
+	}
+	end := "];\n"
+	if asConst {
+		end = "] as const;\n"
+	}
+	list(b, "const "+name+" = [", items, "  ", end)
+}
+
+// goFieldNames turns the headers into unique exported field names, e.g. "user id" into UserId
+func goFieldNames(headers []string) []string {
+	names := make([]string, len(headers))
+	seen := make(map[string]bool)
+	for i, header := range headers {
+		var b strings.Builder
+		for _, part := range strings.FieldsFunc(header, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			runes := []rune(part)
+			b.WriteString(string(unicode.ToUpper(runes[0])) + string(runes[1:]))
+		}
+		name := b.String()
+		switch {
+		case name == "":
+			name = fmt.Sprintf("Col%d", i+1)
+		case !unicode.IsUpper([]rune(name)[0]):
+			// Digits and letters without case cannot start an exported name
+			name = "Col" + name
+		}
+		for base, n := name, 2; seen[name]; n++ {
+			name = fmt.Sprintf("%s%d", base, n)
+		}
+		seen[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// goType returns the Go type of column c: the type of its values when they all have
+// the same one, float64 for integers mixed with floats, and any otherwise
+func goType(rows [][]interface{}, c int) string {
+	kind := ""
+	for _, row := range rows {
+		t := "any"
+		switch row[c].(type) {
+		case bool:
+			t = "bool"
+		case int64:
+			t = "int64"
+		case number:
+			t = "float64"
+		case string:
+			t = "string"
+		}
+		switch {
+		case kind == "" || kind == t:
+			kind = t
+		case kind+t == "int64float64" || kind+t == "float64int64":
+			kind = "float64"
+		default:
+			return "any"
+		}
+	}
+	if kind == "" {
+		return "string"
+	}
+	return kind
+}
+
+// writeGo writes a slice of an anonymous struct, or of the struct type typeName
+// declared before it. Fields renamed from their header get a json tag with the header.
+func writeGo(b *strings.Builder, name, typeName string, headers []string, rows [][]interface{}) error {
+	golang := languages["go"]
+	fields := goFieldNames(headers)
+	var src strings.Builder
+	structType := "struct {\n"
+	for c, field := range fields {
+		structType += field + " " + goType(rows, c)
+		if field != headers[c] {
+			tag := "json:" + strconv.Quote(headers[c])
+			if strings.Contains(tag, "`") {
+				structType += " " + strconv.Quote(tag)
+			} else {
+				structType += " `" + tag + "`"
+			}
+		}
+		structType += "\n"
+	}
+	structType += "}"
+	if typeName != "" {
+		src.WriteString("type " + typeName + " " + structType + "\n\n")
+		structType = typeName
+	}
+	items := records(fields, rows, func(field string, value interface{}) string {
+		return field + ": " + golang.literal(value)
+	})
+	for i := range items {
+		items[i] = "{" + items[i] + "}"
+	}
+	list(&src, "var "+name+" = []"+structType+"{", items, "", "}\n")
+
+	formatted, err := format.Source([]byte(src.String()))
+	if err != nil {
+		return fmt.Errorf("Marshal: formatting Go source: %w", err)
+	}
+	b.Write(formatted)
+	return nil
+}
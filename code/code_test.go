@@ -0,0 +1,198 @@
+package code
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func marshal(t *testing.T, table *common.Table, extension map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, Marshal(&common.Config{Writer: &buf, Extension: extension}, table))
+	return buf.String()
+}
+
+func testTable() *common.Table {
+	return &common.Table{
+		Headers: []string{"name", "user id", "score", "active"},
+		Rows: [][]string{
+			{`it's "a\b"`, "1", "2.0", "true"},
+			{"#{x}\n", "NULL", "1.5", "false"},
+		},
+	}
+}
+
+func TestMarshalPHP(t *testing.T) {
+	expected := `$rows = [
+    ['name' => 'it\'s "a\\b"', 'user id' => 1, 'score' => 2.0, 'active' => true],
+    ['name' => '#{x}
+', 'user id' => null, 'score' => 1.5, 'active' => false],
+];
+`
+	assert.Equal(t, expected, marshal(t, testTable(), map[string]string{"lang": "php", "name": "rows"}))
+}
+
+func TestMarshalPython(t *testing.T) {
+	expected := `data = [
+    {"name": "it's \"a\\b\"", "user id": 1, "score": 2.0, "active": True},
+    {"name": "#{x}\n", "user id": None, "score": 1.5, "active": False},
+]
+`
+	assert.Equal(t, expected, marshal(t, testTable(), map[string]string{"lang": "python"}))
+
+	expected = `import pandas as pd
+
+data = pd.DataFrame({
+    "name": ["it's \"a\\b\"", "#{x}\n"],
+    "user id": [1, None],
+    "score": [2.0, 1.5],
+    "active": [True, False],
+})
+`
+	assert.Equal(t, expected, marshal(t, testTable(), map[string]string{"lang": "python", "pandas": "true"}))
+}
+
+func TestMarshalRuby(t *testing.T) {
+	expected := `data = [
+  { "name" => "it's \"a\\b\"", "user id" => 1, "score" => 2.0, "active" => true },
+  { "name" => "\#{x}\n", "user id" => nil, "score" => 1.5, "active" => false },
+]
+`
+	assert.Equal(t, expected, marshal(t, testTable(), map[string]string{"lang": "ruby"}))
+}
+
+func TestMarshalJS(t *testing.T) {
+	expected := `const data = [
+  { name: "it's \"a\\b\"", "user id": 1, score: 2.0, active: true },
+  { name: "#{x}\n", "user id": null, score: 1.5, active: false },
+];
+`
+	assert.Equal(t, expected, marshal(t, testTable(), nil))
+	assert.Equal(t, expected, marshal(t, testTable(), map[string]string{"lang": "ts"}))
+	assert.Contains(t, marshal(t, testTable(), map[string]string{"lang": "ts", "as-const": "true"}), "] as const;\n")
+	assert.Equal(t, "const data = [];\n", marshal(t, &common.Table{Headers: []string{"a"}}, nil))
+}
+
+func TestMarshalGo(t *testing.T) {
+	expected := "var data = []struct {\n" +
+		"\tName   string\n" +
+		"\tUserId any `json:\"user id\"`\n" +
+		"\tScore  float64\n" +
+		"\tActive bool\n" +
+		"}{\n" +
+		"\t{Name: \"it's \\\"a\\\\b\\\"\", UserId: 1, Score: 2.0, Active: true},\n" +
+		"\t{Name: \"#{x}\\n\", UserId: nil, Score: 1.5, Active: false},\n" +
+		"}\n"
+	table := testTable()
+	table.Headers[0] = "Name"
+	table.Headers[2] = "Score"
+	table.Headers[3] = "Active"
+	assert.Equal(t, expected, marshal(t, table, map[string]string{"lang": "go"}))
+
+	named := marshal(t, table, map[string]string{"lang": "go", "struct": "Row"})
+	assert.Contains(t, named, "type Row struct {\n")
+	assert.Contains(t, named, "var data = []Row{\n")
+}
+
+func TestMarshalWithoutInfer(t *testing.T) {
+	expected := `data = [
+    {"n": "1", "b": "true"},
+]
+`
+	table := &common.Table{Headers: []string{"n", "b"}, Rows: [][]string{{"1", "true"}}}
+	assert.Equal(t, expected, marshal(t, table, map[string]string{"lang": "python", "infer": "false"}))
+}
+
+func TestMarshalNumbers(t *testing.T) {
+	// Floats keep all their digits, text that is not a plain decimal stays a string
+	expected := `data = [
+    {"big": 12345678901234567890.0, "f": 0.5, "text": "1_000"},
+    {"big": 1.5e300, "f": -7.0, "text": "0x1p3"},
+]
+`
+	table := &common.Table{Headers: []string{"big", "f", "text"}, Rows: [][]string{
+		{"12345678901234567890", ".5", "1_000"},
+		{"1.5e300", " -007. ", "0x1p3"},
+	}}
+	assert.Equal(t, expected, marshal(t, table, map[string]string{"lang": "python"}))
+
+	// A column mixing numbers and text is written as strings in every row
+	table = &common.Table{Headers: []string{"code", "flag"}, Rows: [][]string{{"1", "true"}, {"x", "0"}, {"NULL", "NULL"}}}
+	expected = `$data = [
+    ['code' => '1', 'flag' => 'true'],
+    ['code' => 'x', 'flag' => '0'],
+    ['code' => null, 'flag' => null],
+];
+`
+	assert.Equal(t, expected, marshal(t, table, map[string]string{"lang": "php"}))
+	expected = `const data = [
+  { code: "1", flag: "true" },
+  { code: "x", flag: "0" },
+  { code: null, flag: null },
+];
+`
+	assert.Equal(t, expected, marshal(t, table, map[string]string{"lang": "js"}))
+
+	// CSV has no NULL, a blank cell of a number column is null, not a string
+	table = &common.Table{Headers: []string{"name", "age"}, Rows: [][]string{{"A", "30"}, {"B", ""}}}
+	expected = `data = [
+  { "name" => "A", "age" => 30 },
+  { "name" => "B", "age" => nil },
+]
+`
+	assert.Equal(t, expected, marshal(t, table, map[string]string{"lang": "ruby"}))
+	expected = `data = [
+    {"name": "A", "age": 30},
+    {"name": "B", "age": None},
+]
+`
+	assert.Equal(t, expected, marshal(t, table, map[string]string{"lang": "python"}))
+}
+
+func TestFloatLiteral(t *testing.T) {
+	for s, expected := range map[string]string{
+		"2":        "2.0",
+		"+1.50":    "1.50",
+		"-.5":      "-0.5",
+		"00.25e-3": "0.25e-3",
+		"5.E7":     "5.0E7",
+		"1e400":    "1e400",
+	} {
+		assert.Equal(t, expected, floatLiteral(s), s)
+	}
+}
+
+func TestGoFieldNames(t *testing.T) {
+	assert.Equal(t, []string{"UserId", "Col2024", "Col3", "Col名前", "UserId2", "ID"},
+		goFieldNames([]string{"user_id", "2024", "", "名前", "user id", "ID"}))
+}
+
+func TestGoType(t *testing.T) {
+	rows := [][]interface{}{{int64(1), int64(1), true, "a", nil}, {number("2.5"), int64(2), "x", "b", "c"}}
+	assert.Equal(t, "float64", goType(rows, 0))
+	assert.Equal(t, "int64", goType(rows, 1))
+	assert.Equal(t, "any", goType(rows, 2))
+	assert.Equal(t, "string", goType(rows, 3))
+	assert.Equal(t, "any", goType(rows, 4))
+	assert.Equal(t, "string", goType(nil, 0))
+}
+
+func TestErrors(t *testing.T) {
+	assert.Error(t, Marshal(&common.Config{Writer: &bytes.Buffer{}}, nil))
+	assert.Error(t, Marshal(&common.Config{Writer: &bytes.Buffer{}}, &common.Table{}))
+	table := &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1"}}}
+	assert.ErrorContains(t, Marshal(&common.Config{Writer: &bytes.Buffer{}}, table), "row 0 has 1 columns")
+	cfg := &common.Config{Writer: &bytes.Buffer{}, Extension: map[string]string{"lang": "cobol"}}
+	assert.ErrorContains(t, Marshal(cfg, &common.Table{Headers: []string{"a"}}), "unsupported lang")
+
+	assert.NoError(t, validateIdentifier("user_rows"))
+	assert.Error(t, validateIdentifier("1rows"))
+	assert.Error(t, validateIdentifier("my-rows"))
+	assert.NoError(t, validateStruct(""))
+	assert.Error(t, validateStruct("Row Type"))
+}
@@ -0,0 +1,43 @@
+package code
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+// identifierPattern matches a variable or type name valid in every supported language
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "code",
+		Description: "Source code literal for PHP, Python, Ruby, JavaScript, TypeScript or Go (write-only)",
+		Params: []common.FormatParam{
+			{Name: "lang", DefaultValue: "js", AllowedValues: "php, python, ruby, js, ts, go", Description: "Language of the literal", Use: common.ParamWrite},
+			{Name: "name", DefaultValue: "data", Validate: validateIdentifier, Description: "Variable name", Use: common.ParamWrite},
+			{Name: "infer", DefaultValue: "true", AllowedValues: "true, false", Description: "Write numbers, booleans and null unquoted, false quotes every value but NULL cells", Use: common.ParamWrite},
+			{Name: "pandas", DefaultValue: "false", AllowedValues: "true, false", Description: "For python, write a pandas DataFrame constructor instead of a list of dicts", Use: common.ParamWrite},
+			{Name: "as-const", DefaultValue: "false", AllowedValues: "true, false", Description: "For ts, write the array as const", Use: common.ParamWrite},
+			{Name: "struct", DefaultValue: "", Validate: validateStruct, Description: "For go, name of the struct type declared for the rows, anonymous when empty", Use: common.ParamWrite},
+		},
+		Marshal: Marshal,
+	})
+}
+
+// validateIdentifier accepts a name such as data or user_rows
+func validateIdentifier(value string) error {
+	if !identifierPattern.MatchString(value) {
+		return fmt.Errorf("invalid value %q, expected a name such as data", value)
+	}
+	return nil
+}
+
+// validateStruct accepts an empty value or a type name such as Row
+func validateStruct(value string) error {
+	if value == "" {
+		return nil
+	}
+	return validateIdentifier(value)
+}
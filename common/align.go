@@ -0,0 +1,58 @@
+package common
+
+import (
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// AlignParam is the align parameter of the text formats that pad their columns
+var AlignParam = FormatParam{
+	Name:          "align",
+	DefaultValue:  "l",
+	AllowedValues: "l, c, r",
+	Description:   "Text alignment, one per column separated by commas",
+	Validate:      ValidateAlign,
+	Use:           ParamWrite,
+}
+
+// ValidateAlign accepts one alignment per column, e.g. l,c,r
+func ValidateAlign(value string) error {
+	for _, align := range strings.Split(value, ",") {
+		if err := OneOf("l", "c", "r")(strings.ToLower(strings.TrimSpace(align))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ColumnAligns returns the --align alignment of each of n columns, "l", "c" or "r".
+// Columns without a valid alignment are left aligned.
+func (c *Config) ColumnAligns(n int) []string {
+	given := strings.Split(c.GetExtensionString("align", "l"), ",")
+	aligns := make([]string, n)
+	for i := range aligns {
+		aligns[i] = "l"
+		if i < len(given) {
+			switch align := strings.ToLower(strings.TrimSpace(given[i])); align {
+			case "c", "r":
+				aligns[i] = align
+			}
+		}
+	}
+	return aligns
+}
+
+// PadAlign pads s with spaces to width display columns: on the right for "l",
+// on the left for "r" and evenly on both sides for "c"
+func PadAlign(s string, width int, align string) string {
+	switch align {
+	case "c":
+		total := max(width-runewidth.StringWidth(s), 0)
+		return strings.Repeat(" ", total/2) + s + strings.Repeat(" ", total-total/2)
+	case "r":
+		return runewidth.FillLeft(s, width)
+	default:
+		return runewidth.FillRight(s, width)
+	}
+}
@@ -0,0 +1,28 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAlign(t *testing.T) {
+	assert.NoError(t, ValidateAlign("l"))
+	assert.NoError(t, ValidateAlign("l, C,r"))
+	assert.ErrorContains(t, ValidateAlign("l,x"), `invalid value "x"`)
+}
+
+func TestColumnAligns(t *testing.T) {
+	cfg := &Config{Extension: map[string]string{"align": " R,c,x"}}
+	assert.Equal(t, []string{"r", "c", "l", "l"}, cfg.ColumnAligns(4))
+	assert.Equal(t, []string{"r"}, cfg.ColumnAligns(1))
+	assert.Equal(t, []string{"l", "l"}, (&Config{}).ColumnAligns(2))
+}
+
+func TestPadAlign(t *testing.T) {
+	assert.Equal(t, "ab   ", PadAlign("ab", 5, "l"))
+	assert.Equal(t, "   ab", PadAlign("ab", 5, "r"))
+	assert.Equal(t, " ab  ", PadAlign("ab", 5, "c"))
+	assert.Equal(t, "名前 ", PadAlign("名前", 5, "l"))
+	assert.Equal(t, "abc", PadAlign("abc", 2, "c"))
+}
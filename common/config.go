@@ -1,6 +1,7 @@
 package common
 
 import (
+	"context"
 	_ "embed"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 //go:embed usage.txt
@@ -24,13 +26,14 @@ func ShowFormatsHelp() {
 	fmt.Fprintln(os.Stderr, "========================================")
 	fmt.Fprintln(os.Stderr, "")
 
-	// Get all formats in a consistent order (including aliases)
-	formats := []string{"ascii", "csv", "excel", "xlsx", "html", "json", "jsonl", "jsonlines", "latex", "markdown", "md", "mediawiki", "mysql", "sql", "tmpl", "template", "twiki", "tracwiki", "xml"}
-
-	for _, format := range formats {
-		params := GetFormatParams(format)
+	// Formats are listed by name, aliases share the parameters of their format
+	for _, format := range Formats() {
+		params := format.Params
 		if len(params) > 0 {
-			fmt.Fprintf(os.Stderr, "## %s\n\n", format)
+			fmt.Fprintf(os.Stderr, "## %s\n\n", strings.Join(format.Names(), ", "))
+			if format.Description != "" {
+				fmt.Fprintf(os.Stderr, "%s\n\n", format.Description)
+			}
 
 			// Calculate column widths for this format
 			maxParamLen := len("Parameter")
@@ -129,21 +132,10 @@ func ShowFormatsHelp() {
 
 // ShowFormatHelp displays help for a specific format
 func ShowFormatHelp(format string) {
-	// Normalize format name
+	// Normalize format name, aliases resolve to their format
 	format = strings.ToLower(format)
-
-	// Handle aliases
-	switch format {
-	case "md":
-		format = "markdown"
-	case "xlsx":
-		format = "excel"
-	case "jsonlines", "jsonl":
-		format = "jsonl"
-	case "tracwiki":
-		format = "twiki"
-	case "template":
-		format = "tmpl"
+	if f, ok := LookupFormat(format); ok {
+		format = f.Name
 	}
 
 	params := GetFormatParams(format)
@@ -204,16 +196,50 @@ func ShowFormatHelp(format string) {
 
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Global Transformation Parameters (also available):")
-	fmt.Fprintln(os.Stderr, "  --transpose, --delete-empty, --deduplicate, --uppercase, --lowercase, --capitalize")
+	globals := make([]string, len(GlobalTransformParams))
+	for i, param := range GlobalTransformParams {
+		globals[i] = "--" + param.Name
+	}
+	fmt.Fprintf(os.Stderr, "  %s\n", strings.Join(globals, ", "))
 	fmt.Fprintln(os.Stderr, "")
-	fmt.Fprintf(os.Stderr, "Usage Example:\n")
-	fmt.Fprintf(os.Stderr, "  tableconvert --from=%s --to=csv", format)
-	for i, param := range params {
-		if i < 2 { // Show first 2 params as examples
-			fmt.Fprintf(os.Stderr, " --%s=%s", param.Name, param.DefaultValue)
+	fmt.Fprintf(os.Stderr, "Usage Examples:\n")
+	f, _ := LookupFormat(format)
+	for _, example := range formatExamples(f) {
+		fmt.Fprintf(os.Stderr, "  %s\n", example)
+	}
+}
+
+// formatExamples returns a command reading format f with the first two of its
+// reading parameters and one writing it with the first two of its writing
+// parameters, for the sides the format supports
+func formatExamples(f *Format) []string {
+	other := "csv"
+	if f.Name == other {
+		other = "json"
+	}
+	var examples []string
+	for _, side := range []struct {
+		from, to string
+		skip     ParamUse
+		ok       bool
+	}{
+		{f.Name, other, ParamWrite, f.Unmarshal != nil},
+		{other, f.Name, ParamRead, f.Marshal != nil},
+	} {
+		if !side.ok {
+			continue
 		}
+		example := fmt.Sprintf("tableconvert --from=%s --to=%s", side.from, side.to)
+		shown := 0
+		for _, param := range f.Params {
+			if param.Use != side.skip && shown < 2 {
+				example += fmt.Sprintf(" --%s=%s", param.Name, param.DefaultValue)
+				shown++
+			}
+		}
+		examples = append(examples, example)
 	}
-	fmt.Fprintln(os.Stderr, "")
+	return examples
 }
 
 // ParseConfig parses arguments in format "--key=value" or "--key value" and returns a key-value map
@@ -236,8 +262,9 @@ func ParseConfig(args []string) (Config, error) {
 			} else {
 				// Flag-style: check if it's a boolean flag
 				key = trimmed
-				// Check if next argument exists and doesn't start with "-", use as value if true
-				if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				// Check if next argument exists and doesn't start with "-", use as value if true.
+				// Switches only take a following true/false, so `--detect input.csv` keeps the file.
+				if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") && (!switchFlags[key] || isBoolValue(args[i+1])) {
 					value = args[i+1]
 					i++ // Skip the next element since we consumed it as the value
 				} else {
@@ -273,6 +300,42 @@ func ParseConfig(args []string) (Config, error) {
 			cfg.OutputDir = v
 		case "dry-run", "dryrun", "preview":
 			cfg.DryRun = parseBool(v, true) // empty -> true, unknown -> false
+		case "no-stream":
+			cfg.NoStream = parseBool(v, true) // empty -> true, unknown -> false
+		case "table-index":
+			index, err := strconv.Atoi(v)
+			if err != nil || index < 0 {
+				return cfg, fmt.Errorf("invalid --table-index: %q, expected a 0-based table number", v)
+			}
+			cfg.TableIndex = index
+		case "table-name":
+			cfg.TableName = v
+		case "all-tables":
+			cfg.AllTables = parseBool(v, true) // empty -> true, unknown -> false
+		case "expand-spans":
+			cfg.ExpandSpans = parseBool(v, true) // empty -> true, unknown -> false
+		case "max-rows":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return cfg, fmt.Errorf("invalid --max-rows: %q, expected a number of rows", v)
+			}
+			cfg.Limits.MaxRows = n
+		case "max-bytes", "max-cell-bytes":
+			n, err := parseByteSize(v)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid --%s: %w", k, err)
+			}
+			if k == "max-bytes" {
+				cfg.Limits.MaxBytes = n
+			} else {
+				cfg.Limits.MaxCellBytes = int(n)
+			}
+		case "timeout":
+			timeout, err := time.ParseDuration(v)
+			if err != nil || timeout < 0 {
+				return cfg, fmt.Errorf("invalid --timeout: %q, expected a duration such as 30s or 2m", v)
+			}
+			cfg.Limits.Timeout = timeout
 		case "h", "help":
 			Usage()
 			os.Exit(0)
@@ -284,11 +347,26 @@ func ParseConfig(args []string) (Config, error) {
 			os.Exit(0)
 		case "mcp":
 			cfg.MCPMode = parseBool(v, true) // empty -> true, unknown -> false
+		case "lenient":
+			cfg.Lenient = parseBool(v, true) // empty -> true, unknown -> false
+		case "detect":
+			cfg.Detect = parseBool(v, true) // empty -> true, unknown -> false
+		case "explain-detect":
+			cfg.ExplainDetect = parseBool(v, true) // empty -> true, unknown -> false
 		default:
 			cfg.Extension[k] = v
 		}
 	}
 
+	// Switches take no value or a boolean, e.g. --verbose=maybe is a mistake
+	if !cfg.Lenient {
+		for k, v := range configs {
+			if switchFlags[k] && v != "" && !isBoolValue(v) {
+				return cfg, fmt.Errorf("invalid value %q for --%s, expected true or false", v, k)
+			}
+		}
+	}
+
 	// Handle positional arguments (auto-detect input/output files)
 	// Pattern: tableconvert input output
 	if len(positionalArgs) > 0 {
@@ -315,6 +393,12 @@ func ParseConfig(args []string) (Config, error) {
 		if !FormatExists(cfg.To) {
 			return cfg, fmt.Errorf("unsupported output format: %s\n\nSupported formats: %v", cfg.To, getSupportedFormats())
 		}
+		if !cfg.Lenient {
+			// Without --from each file has the format of its extension
+			if err := cfg.ValidateParams(cfg.From, cfg.To); err != nil {
+				return cfg, err
+			}
+		}
 		// Batch mode doesn't use Reader/Writer for single file
 		return cfg, nil
 	}
@@ -333,34 +417,51 @@ func ParseConfig(args []string) (Config, error) {
 		}
 	}
 
-	// Check if required parameters are provided
-	if cfg.From == "" || cfg.To == "" {
+	// Detection mode only reads the input
+	if cfg.Detect || cfg.ExplainDetect {
+		return cfg, cfg.openInput()
+	}
+
+	// Check if required parameters are provided, a missing --from is detected from the content
+	if cfg.To == "" {
 		return cfg, newParseError(cfg.From, cfg.To, cfg.File, cfg.Result)
 	}
 
 	// Validate formats are supported
-	if !FormatExists(cfg.From) {
+	if cfg.From != "" && !FormatExists(cfg.From) {
 		return cfg, fmt.Errorf("unsupported input format: %s\n\nSupported formats: %v\nRun 'tableconvert --help-formats' for details", cfg.From, getSupportedFormats())
 	}
 	if !FormatExists(cfg.To) {
 		return cfg, fmt.Errorf("unsupported output format: %s\n\nSupported formats: %v\nRun 'tableconvert --help-formats' for details", cfg.To, getSupportedFormats())
 	}
+	// A detected --from is validated once it is known
+	if cfg.From != "" && !cfg.Lenient {
+		if err := cfg.ValidateParams(cfg.From, cfg.To); err != nil {
+			return cfg, err
+		}
+	}
 
 	// Determine input target (Reader)
-	if cfg.File != "" {
-		// Check if file exists
-		if _, err := os.Stat(cfg.File); os.IsNotExist(err) {
-			return cfg, fmt.Errorf("file does not exist: %s", cfg.File)
-		}
-		// Open file for reading
-		file, err := os.Open(cfg.File)
+	if err := cfg.openInput(); err != nil {
+		return cfg, err
+	}
+
+	// Auto-detect the input format from the content
+	if cfg.From == "" {
+		format, _, reader, err := DetectFormat(cfg.Reader)
+		cfg.Reader = reader
 		if err != nil {
-			return cfg, fmt.Errorf("failed to open file: %w", err)
+			return cfg, fmt.Errorf("cannot detect the input format from its content, use --from to set it\nRun with --explain-detect to see the candidate formats")
+		}
+		cfg.From = format
+		if cfg.Verbose {
+			fmt.Fprintf(os.Stderr, "# Auto-detected input format from content: %s\n", cfg.From)
+		}
+		if !cfg.Lenient {
+			if err := cfg.ValidateParams(cfg.From, cfg.To); err != nil {
+				return cfg, err
+			}
 		}
-		cfg.Reader = file
-	} else {
-		// Use stdin
-		cfg.Reader = os.Stdin
 	}
 
 	// Determine output destination (Writer)
@@ -370,7 +471,11 @@ func ParseConfig(args []string) (Config, error) {
 			// In dry-run mode, use a discard writer since we won't write output
 			cfg.Writer = io.Discard
 		} else {
-			file, err := os.Create(cfg.Result)
+			flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			if format, ok := LookupFormat(cfg.To); ok && format.UpdatesResult {
+				flag &^= os.O_TRUNC
+			}
+			file, err := os.OpenFile(cfg.Result, flag, 0o666)
 			if err != nil {
 				return cfg, err
 			}
@@ -383,47 +488,34 @@ func ParseConfig(args []string) (Config, error) {
 	return cfg, nil
 }
 
+// openInput opens cfg.File for reading, or uses stdin when no file is given
+func (c *Config) openInput() error {
+	if c.File == "" {
+		c.Reader = os.Stdin
+		return nil
+	}
+	// Check if file exists
+	if _, err := os.Stat(c.File); os.IsNotExist(err) {
+		return fmt.Errorf("file does not exist: %s", c.File)
+	}
+	// Open file for reading
+	file, err := os.Open(c.File)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	c.Reader = file
+	return nil
+}
+
 // detectFormatFromExtension detects format from file extension
 // Returns empty string if extension is not recognized
 func detectFormatFromExtension(filename string) string {
-	ext := strings.TrimPrefix(filepath.Ext(filename), ".")
-	ext = strings.ToLower(ext)
-
-	switch ext {
-	case "csv":
-		return "csv"
-	case "json":
-		return "json"
-	case "jsonl", "jsonlines":
-		return "jsonl"
-	case "md", "markdown":
-		return "markdown"
-	case "xlsx", "xls":
-		return "excel"
-	case "html", "htm":
-		return "html"
-	case "xml":
-		return "xml"
-	case "sql":
-		return "sql"
-	case "tex", "latex":
-		return "latex"
-	case "wiki":
-		return "mediawiki"
-	case "tmpl", "template":
-		return "tmpl"
-	case "txt":
-		// txt is too common, don't auto-detect
-		return ""
-	default:
-		return ""
-	}
+	return DetectTableFormatByExtension(filename)
 }
 
 // getSupportedFormats returns a sorted list of supported formats
 func getSupportedFormats() []string {
-	formats := []string{"ascii", "csv", "excel", "html", "json", "jsonl", "latex", "markdown", "mediawiki", "mysql", "sql", "tmpl", "twiki", "xml", "xlsx", "jsonlines", "md", "template", "tracwiki"}
-	return formats
+	return GetAllFormats()
 }
 
 // newParseError creates a helpful error message for missing required parameters
@@ -468,6 +560,23 @@ func newParseError(from, to, file, result string) error {
 	return fmt.Errorf("%s", msg.String())
 }
 
+// switchFlags are the boolean command line flags, they do not consume a following file name
+var switchFlags = map[string]bool{
+	"verbose": true, "v": true, "recursive": true, "r": true,
+	"dry-run": true, "dryrun": true, "preview": true, "no-stream": true,
+	"all-tables": true, "expand-spans": true, "mcp": true,
+	"detect": true, "explain-detect": true, "lenient": true,
+}
+
+// isBoolValue reports whether parseBool understands value
+func isBoolValue(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "yes", "y", "1", "false", "no", "n", "0":
+		return true
+	}
+	return false
+}
+
 // parseBool parses a string value to boolean, with a default for empty string
 // For non-empty values: true/yes/y/1 -> true, false/no/n/0 -> false, anything else -> defaultValue
 func parseBool(value string, defaultValue bool) bool {
@@ -497,9 +606,22 @@ type Config struct {
 	Recursive bool   // Recursive directory traversal
 	OutputDir string // Output directory for batch mode
 	DryRun    bool   // Dry run mode - preview without writing
-	Reader    io.Reader
-	Writer    io.Writer
-	Extension map[string]string
+	NoStream  bool   // Always load the whole table, even if both formats can stream
+	// Table selection for inputs holding several tables
+	TableIndex  int    // 0-based index of the table to convert
+	TableName   string // name of the table to convert
+	AllTables   bool   // convert every table of the input
+	ExpandSpans bool   // copy merged cell values into the cells they cover
+	Limits      Limits // bounds on input size, rows, cell size and duration
+	Lenient     bool   // ignore unknown format parameters and invalid values, as before strict validation
+	// Content detection: print the detected input format, or all candidates with their scores
+	Detect        bool
+	ExplainDetect bool
+	Reader        io.Reader
+	Writer        io.Writer
+	Extension     map[string]string
+
+	ctx context.Context // context of the running conversion, see CheckRow
 }
 
 // GetExtensionBool gets a boolean value from Extension with default
@@ -576,6 +698,12 @@ func (c *Config) ApplyTransformations(table *Table) {
 		return
 	}
 
+	// 0. Expand merged cells, also when rows are moved and the spans would no longer line up
+	if len(table.Spans) > 0 && (c.ExpandSpans || c.GetExtensionBool("transpose", false) ||
+		c.GetExtensionBool("delete-empty", false) || c.GetExtensionBool("deduplicate", false)) {
+		ExpandSpans(table)
+	}
+
 	// 1. Transpose (columns to rows, rows to columns)
 	if c.GetExtensionBool("transpose", false) {
 		Transpose(table)
@@ -602,6 +730,18 @@ func (c *Config) ApplyTransformations(table *Table) {
 	}
 }
 
+// caseTransform returns the cell function of the configured case transformation, or nil if there is none
+func (c *Config) caseTransform() func(string) string {
+	if c.GetExtensionBool("uppercase", false) {
+		return strings.ToUpper
+	} else if c.GetExtensionBool("lowercase", false) {
+		return strings.ToLower
+	} else if c.GetExtensionBool("capitalize", false) {
+		return capitalizeFirst
+	}
+	return nil
+}
+
 // BatchFile represents a single file in batch processing
 type BatchFile struct {
 	InputPath  string
@@ -866,41 +1006,12 @@ func (c *Config) walkPattern(baseDir, pattern string) ([]string, error) {
 
 // changeExtension changes the file extension to match the target format
 func (c *Config) changeExtension(path, format string) string {
-	ext := ""
-	switch format {
-	case "csv":
-		ext = ".csv"
-	case "json":
-		ext = ".json"
-	case "jsonl", "jsonlines":
-		ext = ".jsonl"
-	case "markdown", "md":
-		ext = ".md"
-	case "html":
-		ext = ".html"
-	case "xml":
-		ext = ".xml"
-	case "sql":
-		ext = ".sql"
-	case "latex":
-		ext = ".tex"
-	case "excel":
-		ext = ".xlsx"
-	case "mysql":
-		ext = ".txt"
-	case "mediawiki":
-		ext = ".wiki"
-	case "twiki":
-		ext = ".twiki"
-	case "tmpl", "template":
-		ext = ".tmpl"
-	case "ascii":
-		ext = ".txt"
-	default:
+	f, ok := LookupFormat(format)
+	if !ok || f.FileExtension() == "" {
 		// Keep original extension
 		return path
 	}
 
 	// Replace extension
-	return strings.TrimSuffix(path, filepath.Ext(path)) + ext
+	return strings.TrimSuffix(path, filepath.Ext(path)) + f.FileExtension()
 }
@@ -94,7 +94,7 @@ func TestParseConfigDuplicateParameters(t *testing.T) {
 
 func TestParseConfigMultipleUnknownParameters(t *testing.T) {
 	// Arrange
-	args := []string{"--from", "csv", "--to", "json", "--param1", "val1", "--param2", "val2"}
+	args := []string{"--from", "csv", "--to", "json", "--param1", "val1", "--param2", "val2", "--lenient"}
 
 	// Act
 	config, err := ParseConfig(args)
@@ -131,7 +131,7 @@ func TestParseConfigWithEmptyInput(t *testing.T) {
 
 func TestParseConfigUnknownParameters(t *testing.T) {
 	// Arrange
-	args := []string{"--from", "csv", "--to", "json", "--unknown", "value"}
+	args := []string{"--from", "csv", "--to", "json", "--unknown", "value", "--lenient"}
 
 	// Act
 	config, err := ParseConfig(args)
@@ -559,6 +559,29 @@ func TestParseConfigHelpFlags(t *testing.T) {
 	t.Skip("Help flags call os.Exit and cannot be tested directly")
 }
 
+func TestFormatExamples(t *testing.T) {
+	noop := func(cfg *Config, table *Table) error { return nil }
+	format := &Format{
+		Name: "sqlite",
+		Params: []FormatParam{
+			{Name: "table", DefaultValue: "data"},
+			{Name: "input-table", DefaultValue: "", Use: ParamRead},
+			{Name: "query", DefaultValue: "", Use: ParamRead},
+			{Name: "if-exists", DefaultValue: "fail", Use: ParamWrite},
+		},
+		Unmarshal: noop,
+		Marshal:   noop,
+	}
+	assert.Equal(t, []string{
+		"tableconvert --from=sqlite --to=csv --table=data --input-table=",
+		"tableconvert --from=csv --to=sqlite --table=data --if-exists=fail",
+	}, formatExamples(format))
+
+	// A write-only format has no reading example
+	format = &Format{Name: "csv", Params: []FormatParam{{Name: "bom", DefaultValue: "false", Use: ParamWrite}}, Marshal: noop}
+	assert.Equal(t, []string{"tableconvert --from=json --to=csv --bom=false"}, formatExamples(format))
+}
+
 func TestParseConfigVerboseFlagVariations(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -576,7 +599,7 @@ func TestParseConfigVerboseFlagVariations(t *testing.T) {
 		{"verbose=0", []string{"--mcp", "--verbose=0"}, false},
 		{"verbose=y", []string{"--mcp", "--verbose=y"}, true},
 		{"verbose=n", []string{"--mcp", "--verbose=n"}, false},
-		{"verbose=unknown", []string{"--mcp", "--verbose=unknown"}, false},
+		{"verbose=unknown", []string{"--mcp", "--verbose=unknown", "--lenient"}, false},
 	}
 
 	for _, tt := range tests {
@@ -606,7 +629,7 @@ func TestParseConfigMCPFlagVariations(t *testing.T) {
 		{"mcp=0", []string{"--mcp=0", "--from", "csv", "--to", "json"}, false, true},
 		{"mcp=y", []string{"--mcp=y"}, true, false},
 		{"mcp=n", []string{"--mcp=n", "--from", "csv", "--to", "json"}, false, true},
-		{"mcp=unknown", []string{"--mcp=unknown", "--from", "csv", "--to", "json"}, false, true},
+		{"mcp=unknown", []string{"--mcp=unknown", "--from", "csv", "--to", "json", "--lenient"}, false, true},
 	}
 
 	for _, tt := range tests {
@@ -620,7 +643,7 @@ func TestParseConfigMCPFlagVariations(t *testing.T) {
 
 func TestParseConfigMixedAndEdgeCases(t *testing.T) {
 	// Test mixed parameter formats
-	args := []string{"--from=csv", "-t", "json", "--verbose", "--param1", "val1", "--param2=val2"}
+	args := []string{"--from=csv", "-t", "json", "--verbose", "--param1", "val1", "--param2=val2", "--lenient"}
 	cfg, err := ParseConfig(args)
 	assert.NoError(t, err)
 	assert.Equal(t, "csv", cfg.From)
@@ -630,14 +653,14 @@ func TestParseConfigMixedAndEdgeCases(t *testing.T) {
 	assert.Equal(t, "val2", cfg.Extension["param2"])
 
 	// Test parameters with special characters
-	args = []string{"--from", "csv", "--to", "json", "--delimiter", ";", "--table", "users_data"}
+	args = []string{"--from", "csv", "--to", "sql", "--delimiter", ";", "--table", "users_data"}
 	cfg, err = ParseConfig(args)
 	assert.NoError(t, err)
 	assert.Equal(t, ";", cfg.Extension["delimiter"])
 	assert.Equal(t, "users_data", cfg.Extension["table"])
 
 	// Test multiple unknown parameters
-	args = []string{"--from", "csv", "--to", "json", "--param1", "1", "--param2", "2", "--param3", "3"}
+	args = []string{"--from", "csv", "--to", "json", "--param1", "1", "--param2", "2", "--param3", "3", "--lenient"}
 	cfg, err = ParseConfig(args)
 	assert.NoError(t, err)
 	assert.Equal(t, "1", cfg.Extension["param1"])
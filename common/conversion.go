@@ -1,6 +1,7 @@
 package common
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"reflect"
@@ -14,15 +15,25 @@ type MarshalFunc func(cfg *Config, table *Table) error
 
 // FormatRegistry holds format-specific unmarshal and marshal functions
 type FormatRegistry struct {
-	UnmarshalMap map[string]UnmarshalFunc
-	MarshalMap   map[string]MarshalFunc
+	UnmarshalMap    map[string]UnmarshalFunc
+	MarshalMap      map[string]MarshalFunc
+	StreamReaderMap map[string]NewRowReaderFunc
+	StreamWriterMap map[string]NewRowWriterFunc
+	// Formats that can read or write several tables at once
+	DocumentUnmarshalMap map[string]UnmarshalDocumentFunc
+	DocumentMarshalMap   map[string]MarshalDocumentFunc
 }
 
 // NewFormatRegistry creates a new format registry
 func NewFormatRegistry() *FormatRegistry {
 	return &FormatRegistry{
-		UnmarshalMap: make(map[string]UnmarshalFunc),
-		MarshalMap:   make(map[string]MarshalFunc),
+		UnmarshalMap:    make(map[string]UnmarshalFunc),
+		MarshalMap:      make(map[string]MarshalFunc),
+		StreamReaderMap: make(map[string]NewRowReaderFunc),
+		StreamWriterMap: make(map[string]NewRowWriterFunc),
+
+		DocumentUnmarshalMap: make(map[string]UnmarshalDocumentFunc),
+		DocumentMarshalMap:   make(map[string]MarshalDocumentFunc),
 	}
 }
 
@@ -40,6 +51,40 @@ func (fr *FormatRegistry) RegisterFormatAlias(alias, format string) {
 	if marshal, ok := fr.MarshalMap[format]; ok {
 		fr.MarshalMap[alias] = marshal
 	}
+	if reader, ok := fr.StreamReaderMap[format]; ok {
+		fr.StreamReaderMap[alias] = reader
+	}
+	if writer, ok := fr.StreamWriterMap[format]; ok {
+		fr.StreamWriterMap[alias] = writer
+	}
+	if unmarshal, ok := fr.DocumentUnmarshalMap[format]; ok {
+		fr.DocumentUnmarshalMap[alias] = unmarshal
+	}
+	if marshal, ok := fr.DocumentMarshalMap[format]; ok {
+		fr.DocumentMarshalMap[alias] = marshal
+	}
+}
+
+// RegisterDocumentFormat adds the multi-table unmarshal and marshal functions of a format.
+// Either may be nil when the format only handles several tables in one direction.
+func (fr *FormatRegistry) RegisterDocumentFormat(name string, unmarshal UnmarshalDocumentFunc, marshal MarshalDocumentFunc) {
+	if unmarshal != nil {
+		fr.DocumentUnmarshalMap[name] = unmarshal
+	}
+	if marshal != nil {
+		fr.DocumentMarshalMap[name] = marshal
+	}
+}
+
+// RegisterStreamFormat adds a format's row reader and row writer constructors to the registry.
+// Either may be nil when the format can only stream in one direction.
+func (fr *FormatRegistry) RegisterStreamFormat(name string, reader NewRowReaderFunc, writer NewRowWriterFunc) {
+	if reader != nil {
+		fr.StreamReaderMap[name] = reader
+	}
+	if writer != nil {
+		fr.StreamWriterMap[name] = writer
+	}
 }
 
 // RegisterWriteOnlyFormat registers a format that only supports writing (no Unmarshal)
@@ -75,28 +120,119 @@ func (e *ConversionError) Unwrap() error {
 	return e.Err
 }
 
-// PerformConversionWithRegistry performs conversion using a registry of format functions
+// PerformConversionWithRegistry performs conversion using a registry of format functions.
+// When both formats can stream and no transformation needs the whole table,
+// rows are converted one at a time instead of loading the table into memory.
 func PerformConversionWithRegistry(registry *FormatRegistry, cfg *Config) error {
-	// Parse input
-	var table Table
+	return PerformConversionContext(context.Background(), registry, cfg)
+}
+
+// PerformConversionContext is PerformConversionWithRegistry that stops once ctx
+// is done. The input is bounded by cfg.Limits; an exceeded limit is reported
+// as a *LimitError wrapped in a ConversionError.
+func PerformConversionContext(ctx context.Context, registry *FormatRegistry, cfg *Config) (err error) {
+	finish, err := cfg.guard(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { err = finish(err) }()
+
+	if streamed, err := performStreamConversion(registry, cfg); streamed {
+		return err
+	}
+
+	tables, err := readTables(registry, cfg)
+	if err != nil {
+		return err
+	}
+
+	// Write NULL cells as --null-string, then apply transformations
+	for _, table := range tables {
+		table.FillNulls(cfg.NullString())
+		cfg.ApplyTransformations(table)
+	}
+
+	return writeTables(registry, cfg, tables)
+}
+
+// ReadTables parses cfg.Reader in format cfg.From and returns the tables chosen
+// by the table selection options, without applying transformations
+func ReadTables(registry *FormatRegistry, cfg *Config) ([]*Table, error) {
+	return ReadTablesContext(context.Background(), registry, cfg)
+}
 
+// ReadTablesContext is ReadTables that stops once ctx is done and enforces cfg.Limits
+func ReadTablesContext(ctx context.Context, registry *FormatRegistry, cfg *Config) (tables []*Table, err error) {
+	finish, err := cfg.guard(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err = finish(err); err != nil {
+			tables = nil
+		}
+	}()
+	return readTables(registry, cfg)
+}
+
+func readTables(registry *FormatRegistry, cfg *Config) ([]*Table, error) {
 	unmarshalFn, ok := registry.GetUnmarshalFunc(cfg.From)
 	if !ok {
-		return fmt.Errorf("unsupported `--from` format: %s", cfg.From)
+		return nil, fmt.Errorf("unsupported `--from` format: %s", cfg.From)
 	}
 
 	if unmarshalFn == nil {
-		return fmt.Errorf("format %s does not support reading (unmarshal)", cfg.From)
+		return nil, fmt.Errorf("format %s does not support reading (unmarshal)", cfg.From)
 	}
 
-	if err := unmarshalFn(cfg, &table); err != nil {
-		return &ConversionError{Stage: "unmarshal", Format: cfg.From, Err: err}
+	var doc Document
+	unmarshalDocFn := registry.DocumentUnmarshalMap[cfg.From]
+	if unmarshalDocFn == nil && (cfg.TableName != "" || cfg.TableIndex != 0) {
+		// The one table read would never match, say why instead
+		err := fmt.Errorf("format %s holds a single table, --table-index and --table-name do not apply", cfg.From)
+		return nil, &ConversionError{Stage: "unmarshal", Format: cfg.From, Err: err}
+	}
+	if unmarshalDocFn != nil && cfg.selectsTables() {
+		if err := unmarshalDocFn(cfg, &doc); err != nil {
+			return nil, &ConversionError{Stage: "unmarshal", Format: cfg.From, Err: err}
+		}
+	} else {
+		var table Table
+		if err := unmarshalFn(cfg, &table); err != nil {
+			return nil, &ConversionError{Stage: "unmarshal", Format: cfg.From, Err: err}
+		}
+		doc.Tables = []*Table{&table}
 	}
 
-	// Apply transformations
-	cfg.ApplyTransformations(&table)
+	tables, err := cfg.SelectTables(&doc)
+	if err != nil {
+		return nil, &ConversionError{Stage: "unmarshal", Format: cfg.From, Err: err}
+	}
+	for _, table := range tables {
+		if err := cfg.Limits.checkTable(table); err != nil {
+			return nil, &ConversionError{Stage: "unmarshal", Format: cfg.From, Err: err}
+		}
+	}
+	return tables, nil
+}
 
-	// Generate output
+// WriteTables writes tables to cfg.Writer in format cfg.To. Several tables need
+// --all-tables and a format that holds several tables.
+func WriteTables(registry *FormatRegistry, cfg *Config, tables []*Table) error {
+	return WriteTablesContext(context.Background(), registry, cfg, tables)
+}
+
+// WriteTablesContext is WriteTables that stops once ctx is done
+func WriteTablesContext(ctx context.Context, registry *FormatRegistry, cfg *Config, tables []*Table) (err error) {
+	finish, err := cfg.guard(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { err = finish(err) }()
+	return writeTables(registry, cfg, tables)
+}
+
+func writeTables(registry *FormatRegistry, cfg *Config, tables []*Table) error {
 	marshalFn, ok := registry.GetMarshalFunc(cfg.To)
 	if !ok {
 		return fmt.Errorf("unsupported `--to` format: %s", cfg.To)
@@ -106,7 +242,23 @@ func PerformConversionWithRegistry(registry *FormatRegistry, cfg *Config) error
 		return fmt.Errorf("format %s does not support writing (marshal)", cfg.To)
 	}
 
-	if err := marshalFn(cfg, &table); err != nil {
+	if marshalDocFn := registry.DocumentMarshalMap[cfg.To]; marshalDocFn != nil && cfg.AllTables {
+		if err := marshalDocFn(cfg, &Document{Tables: tables}); err != nil {
+			return &ConversionError{Stage: "marshal", Format: cfg.To, Err: err}
+		}
+		return nil
+	}
+
+	if len(tables) == 0 {
+		return fmt.Errorf("no table to write")
+	}
+
+	if len(tables) > 1 {
+		err := fmt.Errorf("format %s can only write one table, the input has %d; choose one with --table-index or --table-name", cfg.To, len(tables))
+		return &ConversionError{Stage: "marshal", Format: cfg.To, Err: err}
+	}
+
+	if err := marshalFn(cfg, tables[0]); err != nil {
 		return &ConversionError{Stage: "marshal", Format: cfg.To, Err: err}
 	}
 
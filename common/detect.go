@@ -0,0 +1,110 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// DetectSampleSize is how many bytes of the input the content sniffers see
+const DetectSampleSize = 64 * 1024
+
+// Detection scores returned by Format.Detect
+const (
+	DetectNone    = 0   // the content cannot be this format
+	DetectWeak    = 20  // something format-like shows up, e.g. a Markdown heading
+	DetectLikely  = 60  // the content parses as this format, but other formats fit too
+	DetectCertain = 100 // a signature only this format has, e.g. the magic bytes of a zip
+)
+
+// DetectScore is the score of one candidate format
+type DetectScore struct {
+	Format string
+	Score  int
+}
+
+// ScoreFormats scores sample with the sniffer of every registered format and
+// returns the candidates with a score above zero, best first. Ties go to the
+// format with the lower DetectOrder, then to the name.
+func ScoreFormats(sample []byte) []DetectScore {
+	content := string(sample)
+	type candidate struct {
+		format *Format
+		score  int
+	}
+	var candidates []candidate
+	for _, format := range Formats() {
+		if format.Detect == nil {
+			continue
+		}
+		score := min(max(format.Detect(content), DetectNone), DetectCertain)
+		if score > DetectNone {
+			candidates = append(candidates, candidate{format, score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].format.DetectOrder < candidates[j].format.DetectOrder
+	})
+
+	scores := make([]DetectScore, len(candidates))
+	for i, c := range candidates {
+		scores[i] = DetectScore{Format: c.format.Name, Score: c.score}
+	}
+	return scores
+}
+
+// DetectFormat scores the first DetectSampleSize bytes of r. It returns the
+// best candidate, all candidates, and a reader that still yields the whole
+// input, sample included.
+func DetectFormat(r io.Reader) (string, []DetectScore, io.Reader, error) {
+	buffered := bufio.NewReaderSize(r, DetectSampleSize)
+	sample, err := buffered.Peek(DetectSampleSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", nil, buffered, err
+	}
+	scores := ScoreFormats(sample)
+	if len(scores) == 0 {
+		return "", nil, buffered, fmt.Errorf("unsupported file format")
+	}
+	return scores[0].Format, scores, buffered, nil
+}
+
+// WriteDetectReport writes the candidate formats and their scores, best first
+func WriteDetectReport(w io.Writer, scores []DetectScore) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FORMAT\tSCORE")
+	for _, score := range scores {
+		fmt.Fprintf(tw, "%s\t%d\n", score.Format, score.Score)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(scores) == 0 {
+		_, err := fmt.Fprintln(w, "\nNo format matches the input, use --from to set it")
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\nDetected: %s\n", scores[0].Format)
+	return err
+}
+
+// SampleLines splits a detection sample into its non-empty lines, at most limit lines
+func SampleLines(sample string, limit int) []string {
+	var lines []string
+	for _, line := range strings.Split(sample, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+		if len(lines) == limit {
+			break
+		}
+	}
+	return lines
+}
@@ -0,0 +1,154 @@
+package common_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/martianzhang/tableconvert"
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScoreFormats(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"markdown pipe table", "| a | b |\n|---|---|\n| 1 | 2 |\n", "markdown"},
+		{"jsonl", "{\"a\":1}\n{\"a\":2}\n", "jsonl"},
+		{"json array", "[{\"a\":1},{\"a\":2}]", "json"},
+		{"csv mentioning insert", "id,note\n1,insert into the queue\n2,done\n", "csv"},
+		{"sql", "INSERT INTO `t` (`a`) VALUES (1);\n", "sql"},
+		{"mysql", "+---+---+\n| a | b |\n+---+---+\n| 1 | 2 |\n+---+---+\n", "mysql"},
+		{"ascii", "+++++\n+ a +\n+++++\n+ 1 +\n+++++\n", "ascii"},
+		{"ascii dot", "·····\n· a ·\n·····\n", "ascii"},
+		{"semicolon csv", "a;b\n1;2\n", "csv"},
+		{"tab csv", "a\tb\n1\t2\n", "csv"},
+		{"twiki", "|=a|=b|\n|1|2|\n", "twiki"},
+		{"html", "<html><body><table><tr><td>1</td></tr></table></body></html>", "html"},
+		{"xml", "<?xml version=\"1.0\"?>\n<rows><row><a>1</a></row></rows>", "xml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores := common.ScoreFormats([]byte(tt.input))
+			require.NotEmpty(t, scores)
+			assert.Equal(t, tt.expected, scores[0].Format)
+			for i := 1; i < len(scores); i++ {
+				assert.GreaterOrEqual(t, scores[i-1].Score, scores[i].Score)
+			}
+		})
+	}
+
+	assert.Empty(t, common.ScoreFormats([]byte("just some words")))
+	// The ascii reader does not read box-drawing characters
+	assert.Empty(t, common.ScoreFormats([]byte("┌───┐\n│ a │\n└───┘\n")))
+}
+
+func TestDetectFixtures(t *testing.T) {
+	// Every fixture is detected from its content as the format that reads it,
+	// code literals are not detected at all
+	fixtures := map[string]string{
+		"mysql.2d.json":       "json",
+		"mysql.adoc":          "asciidoc",
+		"mysql.arrow":         "arrow",
+		"mysql.avro":          "avro",
+		"mysql.bbcode":        "bbcode",
+		"mysql.column.json":   "json",
+		"mysql.csv":           "csv",
+		"mysql.dokuwiki":      "dokuwiki",
+		"mysql.html":          "html",
+		"mysql.jira":          "jira",
+		"mysql.js":            "",
+		"mysql.json":          "json",
+		"mysql.latex":         "latex",
+		"mysql.md":            "markdown",
+		"mysql.mediawiki":     "mediawiki",
+		"mysql.ods":           "ods",
+		"mysql.one.sql":       "sql",
+		"mysql.org":           "org",
+		"mysql.parquet":       "parquet",
+		"mysql.plus.ascii":    "ascii",
+		"mysql.py":            "",
+		"mysql.replace.sql":   "sql",
+		"mysql.rst":           "rst",
+		"mysql.semicolon.csv": "csv",
+		"mysql.sql":           "sql",
+		"mysql.sqlite":        "sqlite",
+		"mysql.textile":       "textile",
+		"mysql.toml":          "toml",
+		"mysql.tracwiki":      "tracwiki",
+		"mysql.twiki":         "twiki",
+		"mysql.txt":           "mysql",
+		"mysql.xlsx":          "excel",
+		"mysql.xml":           "xml",
+		"mysql.yaml":          "yaml",
+	}
+	paths, err := filepath.Glob("../test/mysql.*")
+	require.NoError(t, err)
+	for _, path := range paths {
+		name := filepath.Base(path)
+		expected, ok := fixtures[name]
+		require.True(t, ok, "no detection expected for fixture %s", name)
+
+		file, err := os.Open(path)
+		require.NoError(t, err)
+		format, _, reader, err := common.DetectFormat(file)
+		if expected == "" {
+			assert.Error(t, err, name)
+			file.Close()
+			continue
+		}
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, format, name)
+
+		// The detected format reads the fixture without parameters
+		table, err := tableconvert.Read(context.Background(), reader, format)
+		file.Close()
+		if name == "mysql.one.sql" {
+			// Written with --dialect=none, the reserved column names do not parse
+			assert.ErrorContains(t, err, "syntax error", name)
+			continue
+		}
+		require.NoError(t, err, name)
+		// JSON objects hold their keys in sorted order
+		assert.ElementsMatch(t, []string{"FIELD", "TYPE", "NULL", "KEY", "DEFAULT", "EXTRA"}, table.Headers, name)
+		assert.Len(t, table.Rows, 3, name)
+	}
+}
+
+func TestDetectFormatReplaysInput(t *testing.T) {
+	// Inputs larger than the sample are read in full after detection
+	input := "a,b\n" + strings.Repeat("1,2\n", common.DetectSampleSize/2)
+	format, scores, reader, err := common.DetectFormat(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, "csv", format)
+	assert.Equal(t, "csv", scores[0].Format)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, input, string(data))
+
+	_, scores, reader, err = common.DetectFormat(strings.NewReader("plain text"))
+	assert.Error(t, err)
+	assert.Empty(t, scores)
+	data, _ = io.ReadAll(reader)
+	assert.Equal(t, "plain text", string(data))
+}
+
+func TestWriteDetectReport(t *testing.T) {
+	var out bytes.Buffer
+	scores := []common.DetectScore{{Format: "jsonl", Score: 96}, {Format: "json", Score: 30}}
+	require.NoError(t, common.WriteDetectReport(&out, scores))
+	assert.Equal(t, "FORMAT  SCORE\njsonl   96\njson    30\n\nDetected: jsonl\n", out.String())
+
+	out.Reset()
+	require.NoError(t, common.WriteDetectReport(&out, nil))
+	assert.Contains(t, out.String(), "No format matches the input")
+}
@@ -0,0 +1,117 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// Document holds several tables read from one input, e.g. the sheets of a
+// workbook or all tables of an HTML page
+type Document struct {
+	Tables []*Table
+}
+
+// UnmarshalDocumentFunc is a function type that parses every table of the input into a Document
+type UnmarshalDocumentFunc func(cfg *Config, doc *Document) error
+
+// MarshalDocumentFunc is a function type that writes all tables of a Document natively
+type MarshalDocumentFunc func(cfg *Config, doc *Document) error
+
+// TableName returns the name of table i, or "Table<i+1>" if it has none
+func (d *Document) TableName(i int) string {
+	if i < len(d.Tables) && d.Tables[i].Name != "" {
+		return d.Tables[i].Name
+	}
+	return fmt.Sprintf("Table%d", i+1)
+}
+
+// UniqueTableNames returns the names of the tables for writers that key or
+// title tables by name: a name already taken by an earlier table, in any case,
+// gets " (2)", " (3)"... appended. fit, if not nil, makes a name valid for the
+// output format with room for the suffix, e.g. a shortened worksheet name.
+func (d *Document) UniqueTableNames(fit func(name, suffix string) string) []string {
+	if fit == nil {
+		fit = func(name, suffix string) string { return name + suffix }
+	}
+	names := make([]string, len(d.Tables))
+	used := make(map[string]bool)
+	for i := range d.Tables {
+		name := fit(d.TableName(i), "")
+		for n := 2; used[strings.ToLower(name)]; n++ {
+			name = fit(d.TableName(i), fmt.Sprintf(" (%d)", n))
+		}
+		used[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+// selectsTables reports whether a table selection option is set
+func (c *Config) selectsTables() bool {
+	return c.AllTables || c.TableName != "" || c.TableIndex != 0
+}
+
+// SelectTables returns the tables chosen by --all-tables, --table-name or
+// --table-index (0-based, default the first table)
+func (c *Config) SelectTables(doc *Document) ([]*Table, error) {
+	if len(doc.Tables) == 0 {
+		return nil, fmt.Errorf("no table found in input")
+	}
+	if c.AllTables {
+		return doc.Tables, nil
+	}
+
+	if c.TableName != "" {
+		for i := range doc.Tables {
+			if doc.TableName(i) == c.TableName {
+				return doc.Tables[i : i+1], nil
+			}
+		}
+		for i := range doc.Tables {
+			if strings.EqualFold(doc.TableName(i), c.TableName) {
+				return doc.Tables[i : i+1], nil
+			}
+		}
+		names := make([]string, len(doc.Tables))
+		for i := range doc.Tables {
+			names[i] = doc.TableName(i)
+		}
+		return nil, fmt.Errorf("table %q not found, available tables: %s", c.TableName, strings.Join(names, ", "))
+	}
+
+	if c.TableIndex < 0 || c.TableIndex >= len(doc.Tables) {
+		return nil, fmt.Errorf("table index %d out of range, input has %d table(s)", c.TableIndex, len(doc.Tables))
+	}
+	return doc.Tables[c.TableIndex : c.TableIndex+1], nil
+}
+
+// ScanTables reads up to limit tables of cfg.Reader, all of them when limit is
+// 0, for formats whose tables are blocks of lines. next reads the table
+// starting at the next line of scanner, counting lines in lineNumber, and
+// returns nil once the input has no more tables.
+func ScanTables(cfg *Config, limit int, next func(cfg *Config, scanner *bufio.Scanner, lineNumber *int) (*Table, error)) ([]*Table, error) {
+	if cfg.Reader == nil {
+		return nil, fmt.Errorf("Unmarshal: Reader in Config cannot be nil")
+	}
+	scanner := bufio.NewScanner(cfg.Reader)
+	lineNumber := 0
+	var tables []*Table
+	for limit == 0 || len(tables) < limit {
+		table, err := next(cfg, scanner, &lineNumber)
+		if err != nil {
+			return nil, err
+		}
+		if table == nil {
+			break
+		}
+		tables = append(tables, table)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading input: %w", err)
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("parsing failed: no table row found in input")
+	}
+	return tables, nil
+}
@@ -0,0 +1,142 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDocument() *Document {
+	return &Document{Tables: []*Table{
+		{Name: "Users", Headers: []string{"id"}, Rows: [][]string{{"1"}}},
+		{Headers: []string{"order"}, Rows: [][]string{{"42"}}},
+	}}
+}
+
+func TestSelectTables(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    []string
+		wantErr string
+	}{
+		{name: "default first", cfg: Config{}, want: []string{"Users"}},
+		{name: "index", cfg: Config{TableIndex: 1}, want: []string{"Table2"}},
+		{name: "name", cfg: Config{TableName: "Users"}, want: []string{"Users"}},
+		{name: "name case insensitive", cfg: Config{TableName: "table2"}, want: []string{"Table2"}},
+		{name: "all", cfg: Config{AllTables: true}, want: []string{"Users", "Table2"}},
+		{name: "index out of range", cfg: Config{TableIndex: 2}, wantErr: "table index 2 out of range, input has 2 table(s)"},
+		{name: "unknown name", cfg: Config{TableName: "x"}, wantErr: `table "x" not found, available tables: Users, Table2`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := newTestDocument()
+			tables, err := tt.cfg.SelectTables(doc)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			var names []string
+			for _, table := range tables {
+				for i := range doc.Tables {
+					if doc.Tables[i] == table {
+						names = append(names, doc.TableName(i))
+					}
+				}
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+
+	_, err := (&Config{}).SelectTables(&Document{})
+	assert.EqualError(t, err, "no table found in input")
+}
+
+func TestUniqueTableNames(t *testing.T) {
+	doc := &Document{Tables: []*Table{{Name: "A"}, {Name: "a"}, {}, {Name: "A"}, {Name: "A (2)"}}}
+	assert.Equal(t, []string{"A", "a (2)", "Table3", "A (3)", "A (2) (2)"}, doc.UniqueTableNames(nil))
+
+	// fit makes the name valid with room for the suffix
+	fit := func(name, suffix string) string { return name[:1] + suffix }
+	doc = &Document{Tables: []*Table{{Name: "Users"}, {Name: "Units"}}}
+	assert.Equal(t, []string{"U", "U (2)"}, doc.UniqueTableNames(fit))
+}
+
+// newDocumentTestRegistry registers a "doc" format with a two table reader,
+// and a "one" format that only writes single tables
+func newDocumentTestRegistry(written *[]*Table) *FormatRegistry {
+	registry := NewFormatRegistry()
+	single := func(cfg *Config, table *Table) error {
+		*table = *newTestDocument().Tables[0]
+		return nil
+	}
+	write := func(cfg *Config, table *Table) error {
+		*written = append(*written, table)
+		return nil
+	}
+	registry.RegisterFormat("doc", single, write)
+	registry.RegisterDocumentFormat("doc",
+		func(cfg *Config, doc *Document) error {
+			doc.Tables = newTestDocument().Tables
+			return nil
+		},
+		func(cfg *Config, doc *Document) error {
+			*written = append(*written, doc.Tables...)
+			return nil
+		},
+	)
+	registry.RegisterFormat("one", single, write)
+	return registry
+}
+
+func TestPerformConversionDocument(t *testing.T) {
+	var written []*Table
+	registry := newDocumentTestRegistry(&written)
+
+	err := PerformConversionWithRegistry(registry, &Config{From: "doc", To: "doc", TableIndex: 1, Extension: map[string]string{"uppercase": "true"}})
+	require.NoError(t, err)
+	require.Len(t, written, 1)
+	assert.Equal(t, []string{"ORDER"}, written[0].Headers)
+
+	written = nil
+	err = PerformConversionWithRegistry(registry, &Config{From: "doc", To: "doc", AllTables: true})
+	require.NoError(t, err)
+	assert.Len(t, written, 2)
+
+	written = nil
+	err = PerformConversionWithRegistry(registry, &Config{From: "doc", To: "one", AllTables: true})
+	var convErr *ConversionError
+	require.ErrorAs(t, err, &convErr)
+	assert.Equal(t, "marshal", convErr.Stage)
+	assert.Empty(t, written)
+
+	// A format without a document reader has no other table to select
+	err = PerformConversionWithRegistry(registry, &Config{From: "one", To: "doc", TableName: "missing"})
+	require.ErrorAs(t, err, &convErr)
+	assert.Equal(t, "unmarshal", convErr.Stage)
+	assert.ErrorContains(t, err, "format one holds a single table, --table-index and --table-name do not apply")
+
+	err = PerformConversionWithRegistry(registry, &Config{From: "one", To: "doc", TableIndex: 1})
+	assert.ErrorContains(t, err, "format one holds a single table")
+
+	// --all-tables gives its one table
+	written = nil
+	err = PerformConversionWithRegistry(registry, &Config{From: "one", To: "doc", AllTables: true})
+	require.NoError(t, err)
+	assert.Len(t, written, 1)
+}
+
+func TestParseConfigTableSelection(t *testing.T) {
+	cfg, err := ParseConfig([]string{"--from", "excel", "--to", "json", "--table-index", "2", "--table-name=Users", "--all-tables"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, cfg.TableIndex)
+	assert.Equal(t, "Users", cfg.TableName)
+	assert.True(t, cfg.AllTables)
+	assert.NotContains(t, cfg.Extension, "table-index")
+
+	_, err = ParseConfig([]string{"--from", "excel", "--to", "json", "--table-index=-1"})
+	assert.Error(t, err)
+}
@@ -137,7 +137,11 @@ func SQLValueEscape(s string) string {
 	if strings.EqualFold(s, "NULL") {
 		return "NULL"
 	}
+	return SQLStringEscape(s)
+}
 
+// SQLStringEscape quotes s as a SQL string literal, "NULL" included
+func SQLStringEscape(s string) string {
 	// Double Quote string
 	s = strconv.Quote(s)
 	// Convert to Single Quote string
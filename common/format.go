@@ -0,0 +1,123 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Format describes a table format. Each format package registers its
+// descriptor once with Register in an init function; help output, format
+// detection, batch output names, MCP and the conversion registry are all
+// derived from the registered formats.
+type Format struct {
+	Name        string
+	Description string   // one-line summary shown by --help-formats and MCP get_formats
+	Aliases     []string // other names accepted for --from and --to
+	// File extensions including the dot, used to detect the format of input
+	// and output files. The first one names batch output files.
+	Extensions []string
+	// OutputExtension names batch output files of formats that are not
+	// detected by extension, e.g. ".txt"
+	OutputExtension string
+	// Detect scores how much the first DetectSampleSize bytes of the input
+	// look like this format, from DetectNone to DetectCertain. It is nil if the
+	// format is not detected from content.
+	Detect func(sample string) int
+	// DetectOrder breaks ties between equal scores, lower goes first
+	DetectOrder int
+	Params      []FormatParam // format-specific parameters
+	// UpdatesResult is set by formats that write into an existing --result
+	// file by name, such as a database, so the file is not truncated first
+	UpdatesResult bool
+
+	Unmarshal         UnmarshalFunc // nil for write-only formats
+	Marshal           MarshalFunc
+	NewRowReader      NewRowReaderFunc // optional, see RegisterStreamFormat
+	NewRowWriter      NewRowWriterFunc
+	UnmarshalDocument UnmarshalDocumentFunc // optional, see RegisterDocumentFormat
+	MarshalDocument   MarshalDocumentFunc
+}
+
+var (
+	formats     = make(map[string]*Format)
+	formatNames = make(map[string]string) // name or alias -> format name
+)
+
+// Register makes a format available. It panics if the name or an alias is
+// already taken, like database/sql.Register.
+func Register(format *Format) {
+	if format == nil || format.Name == "" {
+		panic("tableconvert: Register format without a name")
+	}
+	for _, name := range append([]string{format.Name}, format.Aliases...) {
+		name = strings.ToLower(name)
+		if _, dup := formatNames[name]; dup {
+			panic(fmt.Sprintf("tableconvert: Register called twice for format %s", name))
+		}
+		formatNames[name] = format.Name
+	}
+	formats[format.Name] = format
+}
+
+// LookupFormat returns the registered format with this name or alias
+func LookupFormat(name string) (*Format, bool) {
+	format, ok := formats[formatNames[strings.ToLower(name)]]
+	return format, ok
+}
+
+// Formats returns all registered formats sorted by name
+func Formats() []*Format {
+	list := make([]*Format, 0, len(formats))
+	for _, format := range formats {
+		list = append(list, format)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Names returns the format name followed by its aliases
+func (f *Format) Names() []string {
+	return append([]string{f.Name}, f.Aliases...)
+}
+
+// FileExtension returns the extension of batch output files, "" to keep the input extension
+func (f *Format) FileExtension() string {
+	if f.OutputExtension != "" {
+		return f.OutputExtension
+	}
+	if len(f.Extensions) > 0 {
+		return f.Extensions[0]
+	}
+	return ""
+}
+
+// formatByExtension returns the format registered for the extension of filename
+func formatByExtension(filename string) (*Format, bool) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		return nil, false
+	}
+	for _, format := range Formats() {
+		for _, e := range format.Extensions {
+			if e == ext {
+				return format, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Add registers all functions of a format descriptor under its name and aliases
+func (fr *FormatRegistry) Add(format *Format) {
+	for _, name := range format.Names() {
+		if format.Unmarshal == nil {
+			fr.RegisterWriteOnlyFormat(name, format.Marshal)
+		} else {
+			fr.RegisterFormat(name, format.Unmarshal, format.Marshal)
+		}
+		fr.RegisterStreamFormat(name, format.NewRowReader, format.NewRowWriter)
+		fr.RegisterDocumentFormat(name, format.UnmarshalDocument, format.MarshalDocument)
+	}
+}
@@ -94,7 +94,8 @@ func TestFormatParamsStructure(t *testing.T) {
 
 func TestFormatParamsRegistryComplete(t *testing.T) {
 	// Verify that all formats in the registry have proper parameters
-	for format, params := range FormatParamsRegistry {
+	for _, f := range Formats() {
+		format, params := f.Name, f.Params
 		assert.NotEmpty(t, params, "Format %s should have parameters", format)
 
 		for _, param := range params {
@@ -0,0 +1,86 @@
+package common_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/stretchr/testify/assert"
+
+	// The built-in formats register themselves in init, the tests of this
+	// package look them up by name, alias, extension and content
+	_ "github.com/martianzhang/tableconvert"
+)
+
+func TestBuiltinFormatDescriptors(t *testing.T) {
+	formats := common.Formats()
+	assert.GreaterOrEqual(t, len(formats), 14)
+
+	for _, format := range formats {
+		assert.NotEmpty(t, format.Description, "format %s has no description", format.Name)
+		assert.NotNil(t, format.Marshal, "format %s has no marshal function", format.Name)
+		for _, ext := range format.Extensions {
+			assert.True(t, strings.HasPrefix(ext, "."), "extension %s of %s needs a leading dot", ext, format.Name)
+			assert.Equal(t, format.Name, common.DetectTableFormatByExtension("data"+ext))
+		}
+		for _, name := range format.Names() {
+			found, ok := common.LookupFormat(strings.ToUpper(name))
+			assert.True(t, ok)
+			assert.Same(t, format, found)
+		}
+	}
+
+	tmpl, ok := common.LookupFormat("template")
+	assert.True(t, ok)
+	assert.Nil(t, tmpl.Unmarshal)
+	assert.Equal(t, ".tmpl", tmpl.FileExtension())
+
+	mysql, _ := common.LookupFormat("mysql")
+	assert.Equal(t, ".txt", mysql.FileExtension())
+	assert.Equal(t, "", common.DetectTableFormatByExtension("data.txt"))
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	assert.Panics(t, func() {
+		common.Register(&common.Format{Name: "csv"})
+	})
+	assert.Panics(t, func() {
+		common.Register(&common.Format{Name: "csv-new", Aliases: []string{"MD"}})
+	})
+	_, ok := common.LookupFormat("csv-new")
+	assert.False(t, ok)
+}
+
+func TestRegistryAdd(t *testing.T) {
+	format, _ := common.LookupFormat("jsonl")
+	registry := common.NewFormatRegistry()
+	registry.Add(format)
+
+	for _, name := range []string{"jsonl", "jsonlines"} {
+		_, ok := registry.GetUnmarshalFunc(name)
+		assert.True(t, ok)
+		assert.NotNil(t, registry.StreamReaderMap[name])
+		assert.NotNil(t, registry.StreamWriterMap[name])
+	}
+}
+
+func TestParseConfigUpdatesResult(t *testing.T) {
+	// The --result file is truncated unless the output format writes into it
+	for to, kept := range map[string]bool{"sqlite": true, "csv": false} {
+		result := filepath.Join(t.TempDir(), "out")
+		assert.NoError(t, os.WriteFile(result, []byte("existing"), 0o644))
+		cfg, err := common.ParseConfig([]string{"--from=csv", "--to=" + to, "--result=" + result})
+		assert.NoError(t, err)
+		cfg.Writer.(*os.File).Close()
+		data, err := os.ReadFile(result)
+		assert.NoError(t, err)
+		if kept {
+			assert.Equal(t, "existing", string(data), to)
+		} else {
+			assert.Empty(t, data, to)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits bound the resources of a conversion, a zero field means no limit
+type Limits struct {
+	MaxRows      int           // data rows per table, --max-rows
+	MaxBytes     int64         // bytes read from the input, --max-bytes
+	MaxCellBytes int           // bytes of a single cell, --max-cell-bytes
+	Timeout      time.Duration // duration of the whole conversion, --timeout
+}
+
+// DefaultMCPLimits are the limits of the MCP convert_table tool unless the
+// server is started with --max-rows, --max-bytes, --max-cell-bytes or --timeout
+var DefaultMCPLimits = Limits{
+	MaxRows:      100000,
+	MaxBytes:     10 << 20,
+	MaxCellBytes: 1 << 20,
+	Timeout:      30 * time.Second,
+}
+
+// WithDefaults returns l with its unset fields taken from defaults
+func (l Limits) WithDefaults(defaults Limits) Limits {
+	if l.MaxRows == 0 {
+		l.MaxRows = defaults.MaxRows
+	}
+	if l.MaxBytes == 0 {
+		l.MaxBytes = defaults.MaxBytes
+	}
+	if l.MaxCellBytes == 0 {
+		l.MaxCellBytes = defaults.MaxCellBytes
+	}
+	if l.Timeout == 0 {
+		l.Timeout = defaults.Timeout
+	}
+	return l
+}
+
+// LimitError is returned when the input exceeds one of the Limits.
+// Conversions wrap it in a ConversionError, use errors.As to find it.
+type LimitError struct {
+	Limit string // name of the exceeded option: max-rows, max-bytes or max-cell-bytes
+	Max   int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("input exceeds the %s limit of %d", e.Limit, e.Max)
+}
+
+// checkRow enforces MaxRows and MaxCellBytes on the data row with 0-based index
+func (l Limits) checkRow(index int, row []string) error {
+	if l.MaxRows > 0 && index >= l.MaxRows {
+		return &LimitError{Limit: "max-rows", Max: int64(l.MaxRows)}
+	}
+	if l.MaxCellBytes > 0 {
+		for _, cell := range row {
+			if len(cell) > l.MaxCellBytes {
+				return &LimitError{Limit: "max-cell-bytes", Max: int64(l.MaxCellBytes)}
+			}
+		}
+	}
+	return nil
+}
+
+// CheckRow enforces MaxRows and MaxCellBytes on the data row with 0-based
+// index and fails once the conversion is cancelled or timed out. Formats call
+// it for each row they parse, so that a conversion stops before the whole
+// table is in memory; pass the headers with index 0.
+func (c *Config) CheckRow(index int, row []string) error {
+	if c.ctx != nil {
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return c.Limits.checkRow(index, row)
+}
+
+// CheckSpanRow is CheckRow for a row of merged cells
+func (c *Config) CheckSpanRow(index int, row []SpanCell) error {
+	values := make([]string, len(row))
+	for i, cell := range row {
+		values[i] = cell.Value
+	}
+	return c.CheckRow(index, values)
+}
+
+// InputPath returns the file a format may open by name instead of reading
+// cfg.Reader, for formats such as sqlite and parquet that need random access:
+// cfg.File when it is a regular file, whose size the conversion checks against
+// MaxBytes, or when there is no reader. Otherwise it returns "" and the format
+// reads cfg.Reader, which stops at MaxBytes and once the conversion is done.
+func (c *Config) InputPath() string {
+	if c.Reader == nil {
+		return c.File
+	}
+	if c.File == "" {
+		return ""
+	}
+	if info, err := os.Stat(c.File); err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+	return c.File
+}
+
+// checkTable enforces MaxRows and MaxCellBytes on the headers and rows of table
+func (l Limits) checkTable(table *Table) error {
+	if l.MaxRows == 0 && l.MaxCellBytes == 0 {
+		return nil
+	}
+	if err := l.checkRow(0, table.Headers); err != nil {
+		return err
+	}
+	for i, row := range table.Rows {
+		if err := l.checkRow(i, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// guard makes cfg.Reader and cfg.Writer fail once ctx is done or the input
+// exceeds MaxBytes, for the duration of one conversion. The returned function
+// restores them and maps the error of the conversion to the limit or context
+// error that caused it; parsers often report a failed read as a syntax error.
+func (c *Config) guard(ctx context.Context) (func(error) error, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// Formats such as excel open the input file by name instead of reading cfg.Reader
+	if c.File != "" && c.Limits.MaxBytes > 0 {
+		if info, err := os.Stat(c.File); err == nil && info.Size() > c.Limits.MaxBytes {
+			return nil, &ConversionError{Stage: "unmarshal", Format: c.From, Err: &LimitError{Limit: "max-bytes", Max: c.Limits.MaxBytes}}
+		}
+	}
+
+	cancel := func() {}
+	if c.Limits.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.Limits.Timeout)
+	}
+	reader, writer, parent := c.Reader, c.Writer, c.ctx
+	guarded := &guardedReader{ctx: ctx, r: reader, max: c.Limits.MaxBytes}
+	c.ctx = ctx
+	if reader != nil {
+		c.Reader = guarded
+	}
+	if writer != nil {
+		c.Writer = &guardedWriter{ctx: ctx, w: writer}
+	}
+
+	return func(err error) error {
+		defer cancel()
+		c.Reader, c.Writer, c.ctx = reader, writer, parent
+		if err == nil {
+			return nil
+		}
+		var limitErr *LimitError
+		if errors.As(err, &limitErr) {
+			return err
+		}
+		if guarded.err != nil {
+			return &ConversionError{Stage: "unmarshal", Format: c.From, Err: guarded.err}
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+			err = ctxErr
+		}
+		if errors.Is(err, context.DeadlineExceeded) && c.Limits.Timeout > 0 {
+			return fmt.Errorf("conversion timed out after %s: %w", c.Limits.Timeout, err)
+		}
+		return err
+	}, nil
+}
+
+// guardedReader fails reads once the context is done or more than max bytes were read
+type guardedReader struct {
+	ctx context.Context
+	r   io.Reader
+	max int64 // 0 means no limit
+	n   int64
+	err *LimitError
+}
+
+func (r *guardedReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.max > 0 && int64(len(p)) > r.max-r.n+1 {
+		// Read one byte past the limit to tell an input of exactly max bytes from a larger one
+		p = p[:r.max-r.n+1]
+	}
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if r.max > 0 && r.n > r.max {
+		r.err = &LimitError{Limit: "max-bytes", Max: r.max}
+		return n - int(r.n-r.max), r.err
+	}
+	return n, err
+}
+
+// guardedWriter fails writes once the context is done
+type guardedWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (w *guardedWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
+
+// parseByteSize parses a byte count with an optional K, M or G suffix (powers of 1024), e.g. 10M
+func parseByteSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	multiplier := int64(1)
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected bytes with an optional K, M or G suffix", value)
+	}
+	return n * multiplier, nil
+}
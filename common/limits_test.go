@@ -0,0 +1,193 @@
+package common_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martianzhang/tableconvert"
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// convert runs a conversion with limits and returns the output
+func convert(ctx context.Context, input, from, to string, limits common.Limits, params map[string]string) (string, error) {
+	var out strings.Builder
+	cfg := common.Config{
+		From:      from,
+		To:        to,
+		Reader:    strings.NewReader(input),
+		Writer:    &out,
+		Extension: make(map[string]string),
+		Limits:    limits,
+	}
+	for k, v := range params {
+		cfg.Extension[k] = v
+	}
+	err := common.PerformConversionContext(ctx, tableconvert.DefaultRegistry, &cfg)
+	return out.String(), err
+}
+
+func requireLimitError(t *testing.T, err error, limit string) {
+	t.Helper()
+	var limitErr *common.LimitError
+	require.True(t, errors.As(err, &limitErr), "expected a LimitError, got %v", err)
+	assert.Equal(t, limit, limitErr.Limit)
+}
+
+func TestLimits(t *testing.T) {
+	ctx := context.Background()
+	csv := "a,b\n1,2\n3,4\n5,6\n"
+	jsonInput := `[{"a":"1"},{"a":"2"},{"a":"3"}]`
+
+	tests := []struct {
+		name   string
+		input  string
+		from   string
+		limits common.Limits
+		params map[string]string
+		limit  string // exceeded limit, "" if the conversion succeeds
+	}{
+		{"bytes streamed", csv, "csv", common.Limits{MaxBytes: 10}, nil, "max-bytes"},
+		{"bytes buffered", jsonInput, "json", common.Limits{MaxBytes: 10}, nil, "max-bytes"},
+		{"exactly max bytes", csv, "csv", common.Limits{MaxBytes: int64(len(csv))}, nil, ""},
+		{"rows streamed", csv, "csv", common.Limits{MaxRows: 2}, nil, "max-rows"},
+		{"rows buffered", csv, "csv", common.Limits{MaxRows: 2}, map[string]string{"transpose": "true"}, "max-rows"},
+		{"rows buffered json", jsonInput, "json", common.Limits{MaxRows: 2}, nil, "max-rows"},
+		{"exactly max rows", csv, "csv", common.Limits{MaxRows: 3}, nil, ""},
+		{"cell streamed", "a\n12345\n", "csv", common.Limits{MaxCellBytes: 4}, nil, "max-cell-bytes"},
+		{"header cell", "abcde\n1\n", "csv", common.Limits{MaxCellBytes: 4}, nil, "max-cell-bytes"},
+		{"cell buffered", `[{"a":"12345"}]`, "json", common.Limits{MaxCellBytes: 4}, nil, "max-cell-bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := convert(ctx, tt.input, tt.from, "csv", tt.limits, tt.params)
+			if tt.limit == "" {
+				assert.NoError(t, err)
+				return
+			}
+			requireLimitError(t, err, tt.limit)
+			var convErr *common.ConversionError
+			require.True(t, errors.As(err, &convErr))
+			assert.Equal(t, "unmarshal", convErr.Stage)
+		})
+	}
+}
+
+// blockingReader returns its data, then blocks until the context is done
+type blockingReader struct {
+	ctx  context.Context
+	data string
+}
+
+func (r *blockingReader) Read(p []byte) (int, error) {
+	if r.data != "" {
+		n := copy(p, r.data)
+		r.data = r.data[n:]
+		return n, nil
+	}
+	<-r.ctx.Done()
+	return 0, io.ErrUnexpectedEOF
+}
+
+func TestTimeoutAndCancel(t *testing.T) {
+	blockCtx, unblock := context.WithCancel(context.Background())
+	defer unblock()
+
+	var out strings.Builder
+	cfg := common.Config{
+		From:      "json",
+		To:        "csv",
+		Reader:    &blockingReader{ctx: blockCtx, data: `[{"a":`},
+		Writer:    &out,
+		Extension: make(map[string]string),
+		Limits:    common.Limits{Timeout: 20 * time.Millisecond},
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		unblock()
+	}()
+	err := common.PerformConversionContext(context.Background(), tableconvert.DefaultRegistry, &cfg)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "conversion timed out after 20ms")
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = convert(cancelled, "a\n1\n", "csv", "json", common.Limits{}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestCheckRowWhileParsing(t *testing.T) {
+	// A format that never ends its row loop is stopped by the timeout
+	registry := tableconvert.NewRegistry()
+	registry.Add(&common.Format{Name: "endless", Unmarshal: func(cfg *common.Config, table *common.Table) error {
+		for {
+			if err := cfg.CheckRow(0, nil); err != nil {
+				return err
+			}
+		}
+	}})
+	cfg := &common.Config{From: "endless", Reader: strings.NewReader(""), Limits: common.Limits{Timeout: 20 * time.Millisecond}}
+	_, err := common.ReadTablesContext(context.Background(), registry, cfg)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Outside a conversion only the limits are checked
+	cfg = &common.Config{Limits: common.Limits{MaxRows: 1}}
+	assert.NoError(t, cfg.CheckRow(0, []string{"a"}))
+	requireLimitError(t, cfg.CheckRow(1, []string{"a"}), "max-rows")
+
+	// Formats reading the whole input stop at the row past the limit
+	for _, from := range []string{"html", "mediawiki", "asciidoc", "rst", "org", "jira", "excel", "yaml"} {
+		table := &common.Table{Headers: []string{"a"}, Rows: [][]string{{"1"}, {"2"}, {"3"}}}
+		var buf strings.Builder
+		require.NoError(t, tableconvert.Write(context.Background(), &buf, table, from), from)
+		_, err := tableconvert.Read(context.Background(), strings.NewReader(buf.String()), from,
+			tableconvert.WithLimits(common.Limits{MaxRows: 2}))
+		requireLimitError(t, err, "max-rows")
+	}
+}
+
+func TestInputPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	require.NoError(t, os.WriteFile(path, []byte("a\n1\n"), 0o644))
+	reader := strings.NewReader("a\n1\n")
+
+	assert.Equal(t, path, (&common.Config{File: path, Reader: reader}).InputPath())
+	assert.Equal(t, path, (&common.Config{File: path}).InputPath())
+	assert.Equal(t, "", (&common.Config{Reader: reader}).InputPath())
+	// Devices and pipes are read through cfg.Reader, their size is unknown
+	assert.Equal(t, "", (&common.Config{File: os.DevNull, Reader: reader}).InputPath())
+}
+
+func TestParseConfigLimits(t *testing.T) {
+	cfg, err := common.ParseConfig([]string{"--mcp", "--max-rows=10", "--max-bytes=2M", "--max-cell-bytes", "64KiB", "--timeout=1m30s"})
+	require.NoError(t, err)
+	assert.Equal(t, common.Limits{MaxRows: 10, MaxBytes: 2 << 20, MaxCellBytes: 64 << 10, Timeout: 90 * time.Second}, cfg.Limits)
+
+	for _, arg := range []string{"--max-rows=-1", "--max-bytes=lots", "--max-cell-bytes=1T", "--timeout=5"} {
+		_, err := common.ParseConfig([]string{"--mcp", arg})
+		assert.Error(t, err, arg)
+	}
+
+	limits := common.Limits{MaxRows: 5}.WithDefaults(common.DefaultMCPLimits)
+	assert.Equal(t, 5, limits.MaxRows)
+	assert.Equal(t, common.DefaultMCPLimits.MaxBytes, limits.MaxBytes)
+}
+
+func TestMCPConvertTableLimits(t *testing.T) {
+	server := common.NewMCPServerContext(tableconvert.DefaultRegistry)
+	assert.Equal(t, common.DefaultMCPLimits, server.Limits)
+
+	server.Limits = common.Limits{MaxBytes: 16}
+	args := common.ConvertTableArgs{From: "csv", To: "json", Input: "name\n" + strings.Repeat("x", 100)}
+	_, result, err := server.HandleConvertTable(context.Background(), nil, args)
+	requireLimitError(t, err, "max-bytes")
+	assert.Contains(t, result.Error, "input exceeds the max-bytes limit of 16")
+}
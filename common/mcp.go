@@ -11,12 +11,14 @@ import (
 // MCPServerContext holds the registry for MCP handlers
 type MCPServerContext struct {
 	Registry *FormatRegistry
+	Limits   Limits // bounds of each convert_table call
 }
 
-// NewMCPServerContext creates a new MCP server context with a format registry
+// NewMCPServerContext creates a new MCP server context with a format registry and DefaultMCPLimits
 func NewMCPServerContext(registry *FormatRegistry) *MCPServerContext {
 	return &MCPServerContext{
 		Registry: registry,
+		Limits:   DefaultMCPLimits,
 	}
 }
 
@@ -75,6 +77,7 @@ func (s *MCPServerContext) HandleConvertTable(ctx context.Context, req *mcp.Call
 		To:        args.To,
 		Reader:    strings.NewReader(args.Input),
 		Extension: make(map[string]string),
+		Limits:    s.Limits,
 	}
 
 	// Add format-specific options
@@ -95,8 +98,8 @@ func (s *MCPServerContext) HandleConvertTable(ctx context.Context, req *mcp.Call
 	var output strings.Builder
 	cfg.Writer = &output
 
-	// Perform conversion using registry
-	err := PerformConversionWithRegistry(s.Registry, &cfg)
+	// Perform conversion using registry, a cancelled request stops the conversion
+	err := PerformConversionContext(ctx, s.Registry, &cfg)
 	if err != nil {
 		result.Error = err.Error()
 		return nil, result, err
@@ -110,45 +113,49 @@ func (s *MCPServerContext) HandleConvertTable(ctx context.Context, req *mcp.Call
 	}, result, nil
 }
 
-// CreateMCPServer creates the MCP server with tableconvert tools
-func CreateMCPServer(registry *FormatRegistry) *mcp.Server {
-	server := mcp.NewServer(&mcp.Implementation{
-		Name:    "tableconvert",
-		Version: "1.0.0",
-		Title:   "TableConvert - Format Converter",
-	}, &mcp.ServerOptions{
-		Instructions: `Tableconvert MCP Server provides tools for converting between different table formats.
+// mcpInstructions describes the tools, the registered formats and their options
+func mcpInstructions() string {
+	var b strings.Builder
+	b.WriteString(`Tableconvert MCP Server provides tools for converting between different table formats.
 
 Available Tools:
 1. convert_table - Convert table data between formats
 2. get_formats - Get information about supported formats and their parameters
 
 Supported Formats:
-- csv, excel, html, json, jsonl, latex, markdown, mediawiki, mysql, sql, tmpl, twiki, xml
+`)
+	var names []string
+	for _, format := range Formats() {
+		names = append(names, format.Name)
+	}
+	fmt.Fprintf(&b, "- %s\n", strings.Join(names, ", "))
 
+	b.WriteString(`
 Format-Specific Options:
 Use the options parameter to pass format-specific settings like:
-- markdown: align, bold-header, bold-first-column, escape, pretty
-- csv: first-column-header, bom, delimiter
-- json: format, minify, parsing-json
-- html: first-column-header, div, minify, thead
-- excel: first-column-header, sheet-name, auto-width, text-format
-- ascii: style
-- latex: bold-first-column, bold-first-row, borders, caption, escape, ht, label, location, mwe, table-align, text-align
-- mediawiki: first-row-header, minify, sort
-- sql: one-insert, replace, dialect, table
-- tmpl: template
-- xml: minify, root-element, row-element, declaration
+`)
+	for _, format := range Formats() {
+		if len(format.Params) == 0 {
+			continue
+		}
+		params := make([]string, len(format.Params))
+		for i, param := range format.Params {
+			params[i] = param.Name
+		}
+		fmt.Fprintf(&b, "- %s: %s\n", format.Name, strings.Join(params, ", "))
+	}
 
+	b.WriteString(`
 Global Transformations:
 Use the transformations parameter for operations that work across all formats:
-- transpose: swap rows and columns
-- delete-empty: remove empty rows
-- deduplicate: remove duplicate rows
-- uppercase: convert all text to UPPERCASE
-- lowercase: convert all text to lowercase
-- capitalize: capitalize first letter of each cell
+`)
+	for _, param := range GlobalTransformParams {
+		if param.AllowedValues == "true, false" {
+			fmt.Fprintf(&b, "- %s: %s\n", param.Name, param.Description)
+		}
+	}
 
+	b.WriteString(`
 Example Usage:
 {
   "from": "csv",
@@ -156,11 +163,29 @@ Example Usage:
   "input": "Name,Age\\nAlice,30\\nBob,25",
   "options": {"align": "l,c", "bold-header": "true"},
   "transformations": {"uppercase": true}
-}`,
+}`)
+	return b.String()
+}
+
+// CreateMCPServer creates the MCP server with tableconvert tools and DefaultMCPLimits
+func CreateMCPServer(registry *FormatRegistry) *mcp.Server {
+	return CreateMCPServerWithLimits(registry, DefaultMCPLimits)
+}
+
+// CreateMCPServerWithLimits creates the MCP server with tableconvert tools,
+// every convert_table call is bounded by limits
+func CreateMCPServerWithLimits(registry *FormatRegistry, limits Limits) *mcp.Server {
+	server := mcp.NewServer(&mcp.Implementation{
+		Name:    "tableconvert",
+		Version: "1.0.0",
+		Title:   "TableConvert - Format Converter",
+	}, &mcp.ServerOptions{
+		Instructions: mcpInstructions(),
 	})
 
 	// Create server context with registry
 	context := NewMCPServerContext(registry)
+	context.Limits = limits
 
 	// Add the convert_table tool
 	mcp.AddTool(server, &mcp.Tool{
@@ -179,23 +204,9 @@ Example Usage:
 
 // HandleGetFormats handles the get_formats tool call (static version)
 func (s *MCPServerContext) HandleGetFormats(ctx context.Context, req *mcp.CallToolRequest, args GetFormatsArgs) (*mcp.CallToolResult, GetFormatsResult, error) {
-	result := GetFormatsResult{
-		Formats: map[string]string{
-			"csv":       "Comma-Separated Values",
-			"excel":     "Excel spreadsheet (XLSX)",
-			"html":      "HTML table",
-			"json":      "JSON (object, 2d array, column-oriented, keyed)",
-			"jsonl":     "JSON Lines",
-			"latex":     "LaTeX table",
-			"markdown":  "Markdown table",
-			"mediawiki": "MediaWiki table",
-			"mysql":     "MySQL query output",
-			"sql":       "SQL INSERT statements",
-			"tmpl":      "Custom template",
-			"twiki":     "TWiki/TracWiki table",
-			"xml":       "XML",
-			"ascii":     "ASCII table",
-		},
+	result := GetFormatsResult{Formats: make(map[string]string)}
+	for _, format := range Formats() {
+		result.Formats[format.Name] = format.Description
 	}
 
 	// If a specific format is requested, return its parameters
@@ -0,0 +1,75 @@
+package common
+
+// DefaultNullString is the text of NULL cells, and what formats without a
+// null value write for them unless --null-string is set
+const DefaultNullString = "NULL"
+
+// NullString returns the text written for NULL cells by formats without a
+// null value, set with --null-string
+func (c *Config) NullString() string {
+	return c.GetExtensionString("null-string", DefaultNullString)
+}
+
+// MarksNulls reports whether the source format tells NULL from strings. In
+// such tables a cell spelled "NULL" is plain text.
+func (t *Table) MarksNulls() bool {
+	return t.Nulls != nil
+}
+
+// TrackNulls records that the source format tells NULL from strings, even if the table has no NULL cell
+func (t *Table) TrackNulls() {
+	if t.Nulls == nil {
+		t.Nulls = [][]bool{}
+	}
+}
+
+// IsNull reports whether the cell at row, col is NULL rather than a string
+func (t *Table) IsNull(row, col int) bool {
+	return row >= 0 && row < len(t.Nulls) && col >= 0 && col < len(t.Nulls[row]) && t.Nulls[row][col]
+}
+
+// SetNull marks the cell at row, col as NULL. Readers store DefaultNullString
+// as the cell text. The row does not need to be added to Rows yet, which lets
+// readers mark cells while building a row.
+func (t *Table) SetNull(row, col int) {
+	t.TrackNulls()
+	for len(t.Nulls) <= row {
+		t.Nulls = append(t.Nulls, nil)
+	}
+	for len(t.Nulls[row]) <= col {
+		t.Nulls[row] = append(t.Nulls[row], false)
+	}
+	t.Nulls[row][col] = true
+}
+
+// RowNulls returns the NULL flags of a data row, or nil if the table does not mark nulls
+func (t *Table) RowNulls(row int) []bool {
+	if !t.MarksNulls() {
+		return nil
+	}
+	nulls := make([]bool, len(t.Rows[row]))
+	for col := range nulls {
+		nulls[col] = t.IsNull(row, col)
+	}
+	return nulls
+}
+
+// FillNulls sets the text of every NULL cell to s, the cells stay marked as NULL
+func (t *Table) FillNulls(s string) {
+	for row, nulls := range t.Nulls {
+		for col, null := range nulls {
+			if null && row < len(t.Rows) && col < len(t.Rows[row]) {
+				t.Rows[row][col] = s
+			}
+		}
+	}
+}
+
+// fillNullCells sets the text of the NULL cells of a streamed row to s
+func fillNullCells(row []string, nulls []bool, s string) {
+	for col, null := range nulls {
+		if null && col < len(row) {
+			row[col] = s
+		}
+	}
+}
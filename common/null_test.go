@@ -0,0 +1,111 @@
+package common
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetNull(t *testing.T) {
+	table := &Table{Headers: []string{"a", "b"}}
+	assert.False(t, table.MarksNulls())
+	assert.Nil(t, table.RowNulls(0))
+
+	// Cells can be marked before their row is added
+	table.SetNull(1, 1)
+	table.Rows = [][]string{{"x", ""}, {"y", DefaultNullString}}
+	assert.True(t, table.MarksNulls())
+	assert.False(t, table.IsNull(0, 1))
+	assert.True(t, table.IsNull(1, 1))
+	assert.False(t, table.IsNull(5, 0))
+	assert.Equal(t, []bool{false, false}, table.RowNulls(0))
+	assert.Equal(t, []bool{false, true}, table.RowNulls(1))
+
+	table.FillNulls("")
+	assert.Equal(t, [][]string{{"x", ""}, {"y", ""}}, table.Rows)
+	assert.True(t, table.IsNull(1, 1))
+}
+
+func TestNullString(t *testing.T) {
+	assert.Equal(t, "NULL", (&Config{}).NullString())
+	assert.Equal(t, "", (&Config{Extension: map[string]string{"null-string": ""}}).NullString())
+	assert.Equal(t, `\N`, (&Config{Extension: map[string]string{"null-string": `\N`}}).NullString())
+}
+
+func TestValueNulls(t *testing.T) {
+	// Without NULL flags "null" text is inferred as null, as before
+	table := &Table{Headers: []string{"a"}, Rows: [][]string{{"null"}}}
+	assert.Nil(t, table.Value(0, 0, true))
+
+	// With NULL flags only marked cells are null
+	table = &Table{Headers: []string{"a"}, Rows: [][]string{{"null"}, {"NULL"}, {""}}}
+	table.SetNull(1, 0)
+	assert.Equal(t, "null", table.Value(0, 0, true))
+	assert.Nil(t, table.Value(1, 0, true))
+	assert.Equal(t, "", table.Value(2, 0, true))
+
+	assert.Equal(t, "NULL", RowValue([]string{"NULL"}, []bool{false}, 0, ColumnTypeUnknown, true))
+	assert.Nil(t, RowValue([]string{"NULL"}, []bool{true}, 0, ColumnTypeString, false))
+	assert.Nil(t, RowValue([]string{"NULL"}, nil, 0, ColumnTypeUnknown, true))
+}
+
+func TestTransformationsKeepNulls(t *testing.T) {
+	table := &Table{
+		Headers: []string{"a", "b"},
+		Rows:    [][]string{{"x", "NULL"}, {"x", "NULL"}, {"", ""}, {"y", "NULL"}},
+	}
+	table.SetNull(0, 1)
+	table.SetNull(2, 1)
+
+	cfg := &Config{Extension: map[string]string{"deduplicate": "true", "uppercase": "true"}}
+	cfg.ApplyTransformations(table)
+
+	// A NULL cell and the text "NULL" are different rows, a NULL row is not empty
+	assert.Equal(t, [][]string{{"X", "NULL"}, {"X", "NULL"}, {"", ""}, {"Y", "NULL"}}, table.Rows)
+	assert.True(t, table.IsNull(0, 1))
+	assert.False(t, table.IsNull(1, 1))
+	assert.True(t, table.IsNull(2, 1))
+
+	cfg = &Config{Extension: map[string]string{"transpose": "true"}}
+	cfg.ApplyTransformations(table)
+	// Column b becomes row 1, after the column of original headers
+	assert.True(t, table.IsNull(1, 1))
+	assert.False(t, table.IsNull(1, 2))
+	assert.True(t, table.IsNull(1, 3))
+}
+
+// nullRowReader streams rows from memory with NULL flags
+type nullRowReader struct {
+	sliceRowReader
+	nulls   [][]bool
+	current []bool
+}
+
+func (r *nullRowReader) Next() ([]string, error) {
+	row, err := r.sliceRowReader.Next()
+	if err == nil {
+		r.current, r.nulls = r.nulls[0], r.nulls[1:]
+	}
+	return row, err
+}
+
+func (r *nullRowReader) Nulls() []bool {
+	return r.current
+}
+
+func TestReadAllRowsNulls(t *testing.T) {
+	reader := &nullRowReader{
+		sliceRowReader: sliceRowReader{columns: NewColumns([]string{"a", "b"}), rows: [][]string{{"1", "NULL"}, {"2", "x"}}},
+		nulls:          [][]bool{{false, true}, {false, false}},
+	}
+	table := &Table{}
+	require.NoError(t, ReadAllRows(&Config{}, reader, table))
+	assert.True(t, table.MarksNulls())
+	assert.True(t, table.IsNull(0, 1))
+	assert.False(t, table.IsNull(1, 1))
+
+	_, err := reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,234 @@
+package common
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ColumnType is the type of the values held by a table column
+type ColumnType string
+
+const (
+	ColumnTypeUnknown ColumnType = ""
+	ColumnTypeString  ColumnType = "string"
+	ColumnTypeInt     ColumnType = "int"
+	ColumnTypeFloat   ColumnType = "float"
+	ColumnTypeBool    ColumnType = "bool"
+	ColumnTypeDate    ColumnType = "date"
+)
+
+// Column describes a single table column
+type Column struct {
+	Name       string
+	Type       ColumnType
+	Nullable   bool
+	SourceType string // type name reported by the source format, e.g. "INT" or "number"
+}
+
+// dateLayouts are the layouts recognized as dates by InferColumnType
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	time.RFC3339Nano,
+}
+
+// NewColumns returns an untyped column list for the given headers
+func NewColumns(headers []string) []Column {
+	columns := make([]Column, len(headers))
+	for i, header := range headers {
+		columns[i].Name = header
+	}
+	return columns
+}
+
+// Observe merges the type of one more cell value into the column
+func (c *Column) Observe(t ColumnType, sourceType string) {
+	if t == ColumnTypeUnknown {
+		return
+	}
+	c.Type = MergeColumnType(c.Type, t)
+	if c.SourceType == "" {
+		c.SourceType = sourceType
+	} else if sourceType != "" && c.SourceType != sourceType {
+		c.SourceType = "mixed"
+	}
+}
+
+// MergeColumnType returns the narrowest type that can hold values of both a and b
+func MergeColumnType(a, b ColumnType) ColumnType {
+	switch {
+	case a == ColumnTypeUnknown:
+		return b
+	case b == ColumnTypeUnknown || a == b:
+		return a
+	case (a == ColumnTypeInt && b == ColumnTypeFloat) || (a == ColumnTypeFloat && b == ColumnTypeInt):
+		return ColumnTypeFloat
+	default:
+		return ColumnTypeString
+	}
+}
+
+// InferColumnType guesses the column type of a single string value
+func InferColumnType(value string) ColumnType {
+	switch InferType(value).(type) {
+	case nil:
+		return ColumnTypeUnknown
+	case bool:
+		return ColumnTypeBool
+	case int64:
+		return ColumnTypeInt
+	case float64:
+		return ColumnTypeFloat
+	}
+	if isDate(strings.TrimSpace(value)) {
+		return ColumnTypeDate
+	}
+	return ColumnTypeString
+}
+
+func isDate(value string) bool {
+	for _, layout := range dateLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// InferColumns infers a column schema from the table data.
+// Empty cells and cells spelled "null" mark the column nullable and do not affect its type.
+func InferColumns(table *Table) []Column {
+	if table == nil {
+		return nil
+	}
+	columns := NewColumns(table.Headers)
+	for _, row := range table.Rows {
+		for i := range columns {
+			if i >= len(row) {
+				continue
+			}
+			t := InferColumnType(row[i])
+			if t == ColumnTypeUnknown || strings.TrimSpace(row[i]) == "" {
+				columns[i].Nullable = true
+				continue
+			}
+			columns[i].Observe(t, "")
+		}
+	}
+	for i := range columns {
+		if columns[i].Type == ColumnTypeUnknown {
+			columns[i].Type = ColumnTypeString
+		}
+	}
+	return columns
+}
+
+// HasSchema reports whether the table carries a column schema matching its headers
+func (t *Table) HasSchema() bool {
+	return t != nil && len(t.Columns) > 0 && len(t.Columns) == len(t.Headers)
+}
+
+// ColumnType returns the type of column i, or ColumnTypeUnknown if the table has no schema
+func (t *Table) ColumnType(i int) ColumnType {
+	if !t.HasSchema() || i < 0 || i >= len(t.Columns) {
+		return ColumnTypeUnknown
+	}
+	return t.Columns[i].Type
+}
+
+// HeaderColumns returns the column schema if the table has one, otherwise untyped columns named after the headers
+func (t *Table) HeaderColumns() []Column {
+	if t.HasSchema() {
+		return t.Columns
+	}
+	return NewColumns(t.Headers)
+}
+
+// Schema returns the table's column schema, inferring it from the data when the
+// source format did not provide one
+func (t *Table) Schema() []Column {
+	if t.HasSchema() {
+		return t.Columns
+	}
+	return InferColumns(t)
+}
+
+// Value returns the cell at (row, col) as a native Go value, nil for NULL cells.
+// Typed columns are converted according to the schema; otherwise the value is
+// passed through InferType when infer is true and returned as a string when it is false.
+func (t *Table) Value(row, col int, infer bool) interface{} {
+	if t.IsNull(row, col) {
+		return nil
+	}
+	value := CellValue(t.Rows[row][col], t.ColumnType(col), infer)
+	if value == nil && t.MarksNulls() {
+		// The source tells NULL from strings, so this is text spelled "null"
+		return t.Rows[row][col]
+	}
+	return value
+}
+
+// RowValue converts cell col of a streamed row like Table.Value. nulls holds
+// the NULL flags of the row, nil if the source format has no null value.
+func RowValue(row []string, nulls []bool, col int, t ColumnType, infer bool) interface{} {
+	if col < len(nulls) && nulls[col] {
+		return nil
+	}
+	value := CellValue(row[col], t, infer)
+	if value == nil && nulls != nil {
+		// The source tells NULL from strings, so this is text spelled "null"
+		return row[col]
+	}
+	return value
+}
+
+// CellValue converts value to a native Go value according to the column type t,
+// falling back to InferType (when infer is true) or the plain string for untyped columns
+func CellValue(value string, t ColumnType, infer bool) interface{} {
+	if t != ColumnTypeUnknown {
+		return TypedValue(value, t)
+	}
+	if infer {
+		return InferType(value)
+	}
+	return value
+}
+
+// TypedValue converts value to the Go type matching t.
+// Values that cannot be converted are returned unchanged as strings.
+func TypedValue(value string, t ColumnType) interface{} {
+	trimmed := strings.TrimSpace(value)
+	switch t {
+	case ColumnTypeInt:
+		if i, err := strconv.ParseInt(trimmed, 10, 64); err == nil {
+			return i
+		}
+		if f, err := strconv.ParseFloat(trimmed, 64); err == nil {
+			return f
+		}
+	case ColumnTypeFloat:
+		if f, err := strconv.ParseFloat(trimmed, 64); err == nil {
+			return f
+		}
+	case ColumnTypeBool:
+		switch strings.ToLower(trimmed) {
+		case "true", "1":
+			return true
+		case "false", "0":
+			return false
+		}
+	}
+	return value
+}
+
+// syncColumnNames copies the table headers into the column schema names
+func (t *Table) syncColumnNames() {
+	if !t.HasSchema() {
+		return
+	}
+	for i := range t.Columns {
+		t.Columns[i].Name = t.Headers[i]
+	}
+}
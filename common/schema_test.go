@@ -0,0 +1,134 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInferColumnType(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ColumnType
+	}{
+		{"42", ColumnTypeInt},
+		{"-7", ColumnTypeInt},
+		{"3.14", ColumnTypeFloat},
+		{"true", ColumnTypeBool},
+		{"FALSE", ColumnTypeBool},
+		{"2024-01-31", ColumnTypeDate},
+		{"2024-01-31 08:30:00", ColumnTypeDate},
+		{"2024-01-31T08:30:00Z", ColumnTypeDate},
+		{"hello", ColumnTypeString},
+		{"null", ColumnTypeUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			assert.Equal(t, tt.expected, InferColumnType(tt.input))
+		})
+	}
+}
+
+func TestMergeColumnType(t *testing.T) {
+	assert.Equal(t, ColumnTypeInt, MergeColumnType(ColumnTypeUnknown, ColumnTypeInt))
+	assert.Equal(t, ColumnTypeInt, MergeColumnType(ColumnTypeInt, ColumnTypeUnknown))
+	assert.Equal(t, ColumnTypeFloat, MergeColumnType(ColumnTypeInt, ColumnTypeFloat))
+	assert.Equal(t, ColumnTypeFloat, MergeColumnType(ColumnTypeFloat, ColumnTypeInt))
+	assert.Equal(t, ColumnTypeString, MergeColumnType(ColumnTypeInt, ColumnTypeBool))
+	assert.Equal(t, ColumnTypeString, MergeColumnType(ColumnTypeDate, ColumnTypeString))
+	assert.Equal(t, ColumnTypeBool, MergeColumnType(ColumnTypeBool, ColumnTypeBool))
+}
+
+func TestColumnObserve(t *testing.T) {
+	var column Column
+	column.Observe(ColumnTypeInt, "INT")
+	assert.Equal(t, ColumnTypeInt, column.Type)
+	assert.Equal(t, "INT", column.SourceType)
+
+	column.Observe(ColumnTypeFloat, "FLOAT")
+	assert.Equal(t, ColumnTypeFloat, column.Type)
+	assert.Equal(t, "mixed", column.SourceType)
+
+	column.Observe(ColumnTypeUnknown, "NULL")
+	assert.Equal(t, ColumnTypeFloat, column.Type)
+}
+
+func TestInferColumns(t *testing.T) {
+	table := &Table{
+		Headers: []string{"id", "price", "active", "name", "joined"},
+		Rows: [][]string{
+			{"1", "9.99", "true", "Alice", "2024-01-01"},
+			{"2", "10", "false", "", "2024-02-01"},
+			{"3", "NULL", "true", "Carol", "2024-03-01"},
+		},
+	}
+
+	columns := InferColumns(table)
+	assert.Equal(t, []Column{
+		{Name: "id", Type: ColumnTypeInt},
+		{Name: "price", Type: ColumnTypeFloat, Nullable: true},
+		{Name: "active", Type: ColumnTypeBool},
+		{Name: "name", Type: ColumnTypeString, Nullable: true},
+		{Name: "joined", Type: ColumnTypeDate},
+	}, columns)
+
+	assert.Nil(t, InferColumns(nil))
+}
+
+func TestTableSchema(t *testing.T) {
+	table := &Table{
+		Headers: []string{"id", "code"},
+		Rows:    [][]string{{"1", "007"}},
+	}
+	assert.False(t, table.HasSchema())
+	assert.Equal(t, ColumnTypeUnknown, table.ColumnType(0))
+	assert.Equal(t, ColumnTypeInt, table.Schema()[1].Type)
+
+	table.Columns = []Column{{Name: "id", Type: ColumnTypeInt}, {Name: "code", Type: ColumnTypeString}}
+	assert.True(t, table.HasSchema())
+	assert.Equal(t, ColumnTypeString, table.Schema()[1].Type)
+	assert.Equal(t, ColumnTypeUnknown, table.ColumnType(5))
+}
+
+func TestTableValue(t *testing.T) {
+	table := &Table{
+		Headers: []string{"id", "code"},
+		Rows:    [][]string{{"1", "007"}},
+	}
+
+	// Without a schema the value is inferred only on request
+	assert.Equal(t, "007", table.Value(0, 1, false))
+	assert.Equal(t, int64(7), table.Value(0, 1, true))
+
+	// A schema overrides inference
+	table.Columns = []Column{{Name: "id", Type: ColumnTypeInt}, {Name: "code", Type: ColumnTypeString}}
+	assert.Equal(t, int64(1), table.Value(0, 0, false))
+	assert.Equal(t, "007", table.Value(0, 1, true))
+}
+
+func TestTypedValue(t *testing.T) {
+	assert.Equal(t, int64(42), TypedValue("42", ColumnTypeInt))
+	assert.Equal(t, 4.5, TypedValue("4.5", ColumnTypeInt))
+	assert.Equal(t, 4.5, TypedValue(" 4.5 ", ColumnTypeFloat))
+	assert.Equal(t, true, TypedValue("TRUE", ColumnTypeBool))
+	assert.Equal(t, false, TypedValue("0", ColumnTypeBool))
+	assert.Equal(t, "maybe", TypedValue("maybe", ColumnTypeBool))
+	assert.Equal(t, "abc", TypedValue("abc", ColumnTypeInt))
+	assert.Equal(t, "2024-01-01", TypedValue("2024-01-01", ColumnTypeDate))
+}
+
+func TestTransformationsKeepSchemaConsistent(t *testing.T) {
+	table := &Table{
+		Headers: []string{"id", "name"},
+		Rows:    [][]string{{"1", "alice"}},
+		Columns: []Column{{Name: "id", Type: ColumnTypeInt}, {Name: "name", Type: ColumnTypeString}},
+	}
+
+	Uppercase(table)
+	assert.Equal(t, "ID", table.Columns[0].Name)
+	assert.Equal(t, "NAME", table.Columns[1].Name)
+
+	Transpose(table)
+	assert.Nil(t, table.Columns)
+}
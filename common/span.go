@@ -0,0 +1,214 @@
+package common
+
+import "fmt"
+
+// Span marks a merged cell. The cell at Row, Col covers RowSpan rows and
+// ColSpan columns, the other cells of that range are left empty unless the
+// spans are expanded. Row -1 is the header row.
+type Span struct {
+	Row     int
+	Col     int
+	RowSpan int
+	ColSpan int
+}
+
+// SpanCell is a parsed cell that may cover several rows or columns
+type SpanCell struct {
+	Value   string
+	RowSpan int // values below 1 count as 1
+	ColSpan int
+}
+
+// MaxSpan is the largest number of columns a merged cell may span, the HTML limit for colspan
+const MaxSpan = 1000
+
+// MaxGridCells bounds the rows times columns of a table laid out by LayoutSpans,
+// so that a few bytes of span attributes cannot grow into a table exhausting memory
+const MaxGridCells = 1 << 24
+
+// PadRows pads rows with empty cells to the widest row, as LayoutSpans does
+// for rows without merged cells. A grid of more than MaxGridCells cells is an error.
+func PadRows(rows [][]string) error {
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	if len(rows) > 0 && width > MaxGridCells/len(rows) {
+		return fmt.Errorf("table of %d rows and %d columns exceeds the limit of %d cells", len(rows), width, MaxGridCells)
+	}
+	for i, row := range rows {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		rows[i] = row
+	}
+	return nil
+}
+
+// LayoutSpans places rows of parsed cells on a grid the way HTML does: each
+// cell takes the first column that is not covered by a cell of a previous row.
+// Rows are padded to the widest row and row spans are cut at the last row.
+// It returns the grid, the merged cells by grid row, and how many columns
+// each row filled. A cell spanning more than MaxSpan columns, or a grid of
+// more than MaxGridCells cells, is an error.
+func LayoutSpans(rows [][]SpanCell) ([][]string, []Span, []int, error) {
+	covered := make(map[[2]int]bool) // cells covered by a row span from a row above
+	grid := make([][]string, len(rows))
+	widths := make([]int, len(rows))
+	var spans []Span
+	width, coveredCells := 0, 0
+
+	for r, cells := range rows {
+		col := 0
+		for _, cell := range cells {
+			for covered[[2]int{r, col}] {
+				col++
+			}
+			rowSpan, colSpan := max(cell.RowSpan, 1), max(cell.ColSpan, 1)
+			if colSpan > MaxSpan {
+				return nil, nil, nil, fmt.Errorf("row %d: cell spans %d columns, more than the limit of %d", r+1, colSpan, MaxSpan)
+			}
+			rowSpan = min(rowSpan, len(rows)-r)
+			width = max(width, col+colSpan)
+			coveredCells += (rowSpan - 1) * colSpan
+			if width > MaxGridCells/len(rows) || coveredCells > MaxGridCells {
+				return nil, nil, nil, fmt.Errorf("row %d: table with merged cells exceeds the limit of %d cells", r+1, MaxGridCells)
+			}
+
+			for dr := 1; dr < rowSpan; dr++ {
+				for dc := 0; dc < colSpan; dc++ {
+					covered[[2]int{r + dr, col + dc}] = true
+				}
+				widths[r+dr] = max(widths[r+dr], col+colSpan)
+			}
+			grid[r] = setCell(grid[r], col, cell.Value)
+			if rowSpan > 1 || colSpan > 1 {
+				spans = append(spans, Span{Row: r, Col: col, RowSpan: rowSpan, ColSpan: colSpan})
+			}
+			col += colSpan
+			widths[r] = max(widths[r], col)
+		}
+	}
+
+	for r := range grid {
+		grid[r] = setCell(grid[r], width-1, "")[:width]
+	}
+	return grid, spans, widths, nil
+}
+
+// setCell stores value at col, growing the row as needed. Existing values are kept when value is empty.
+func setCell(row []string, col int, value string) []string {
+	for len(row) <= col {
+		row = append(row, "")
+	}
+	if value != "" {
+		row[col] = value
+	}
+	return row
+}
+
+// SetGrid fills the table from a grid whose first row is the header row.
+// Spans are given by grid row and stored with the header row as -1.
+func (t *Table) SetGrid(grid [][]string, spans []Span) {
+	t.Headers, t.Rows, t.Spans = nil, nil, nil
+	if len(grid) == 0 {
+		return
+	}
+	t.Headers = grid[0]
+	t.Rows = grid[1:]
+	for _, span := range spans {
+		span.Row--
+		t.Spans = append(t.Spans, span)
+	}
+}
+
+// cell returns a pointer to the cell at row, col, row -1 being the header row, or nil if it is out of range
+func (t *Table) cell(row, col int) *string {
+	var cells []string
+	switch {
+	case row == -1:
+		cells = t.Headers
+	case row >= 0 && row < len(t.Rows):
+		cells = t.Rows[row]
+	}
+	if col < 0 || col >= len(cells) {
+		return nil
+	}
+	return &cells[col]
+}
+
+// SpanLayout indexes the merged cells of a table for writers
+type SpanLayout struct {
+	anchors map[[2]int]Span
+	covered map[[2]int]Span // covered cell -> merged cell covering it
+}
+
+// SpanLayout returns the merged cells of the table, cut to the table bounds
+func (t *Table) SpanLayout() SpanLayout {
+	layout := SpanLayout{anchors: make(map[[2]int]Span), covered: make(map[[2]int]Span)}
+	for _, span := range t.Spans {
+		if t.cell(span.Row, span.Col) == nil {
+			continue
+		}
+		span.RowSpan = min(max(span.RowSpan, 1), len(t.Rows)-span.Row)
+		span.ColSpan = min(max(span.ColSpan, 1), len(t.Headers)-span.Col)
+		if span.RowSpan == 1 && span.ColSpan == 1 {
+			continue
+		}
+		layout.anchors[[2]int{span.Row, span.Col}] = span
+		for dr := 0; dr < span.RowSpan; dr++ {
+			for dc := 0; dc < span.ColSpan; dc++ {
+				if dr > 0 || dc > 0 {
+					layout.covered[[2]int{span.Row + dr, span.Col + dc}] = span
+				}
+			}
+		}
+	}
+	return layout
+}
+
+// At returns the merged cell anchored at row, col
+func (l SpanLayout) At(row, col int) (Span, bool) {
+	span, ok := l.anchors[[2]int{row, col}]
+	return span, ok
+}
+
+// Covered reports whether the cell at row, col is hidden by a merged cell anchored elsewhere
+func (l SpanLayout) Covered(row, col int) bool {
+	_, ok := l.covered[[2]int{row, col}]
+	return ok
+}
+
+// CoveredBy returns the merged cell hiding the cell at row, col
+func (l SpanLayout) CoveredBy(row, col int) (Span, bool) {
+	span, ok := l.covered[[2]int{row, col}]
+	return span, ok
+}
+
+// ExpandSpans copies the value of each merged cell, and whether it is NULL,
+// into every cell it covers and drops the span metadata, leaving a plain grid
+func ExpandSpans(table *Table) {
+	if table == nil {
+		return
+	}
+	layout := table.SpanLayout()
+	for _, span := range layout.anchors {
+		value := *table.cell(span.Row, span.Col)
+		null := table.IsNull(span.Row, span.Col)
+		for dr := 0; dr < span.RowSpan; dr++ {
+			for dc := 0; dc < span.ColSpan; dc++ {
+				row, col := span.Row+dr, span.Col+dc
+				if cell := table.cell(row, col); cell != nil {
+					*cell = value
+					if null {
+						table.SetNull(row, col)
+					} else if table.IsNull(row, col) {
+						table.Nulls[row][col] = false
+					}
+				}
+			}
+		}
+	}
+	table.Spans = nil
+	table.syncColumnNames()
+}
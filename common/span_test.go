@@ -0,0 +1,155 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayoutSpans(t *testing.T) {
+	rows := [][]SpanCell{
+		{{Value: "Region", RowSpan: 2}, {Value: "Sales", ColSpan: 2}},
+		{{Value: "Q1"}, {Value: "Q2"}},
+		{{Value: "North"}, {Value: "1"}, {Value: "2"}},
+		{{Value: "South"}, {Value: "3"}},
+	}
+
+	grid, spans, widths, err := LayoutSpans(rows)
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Region", "Sales", ""},
+		{"", "Q1", "Q2"},
+		{"North", "1", "2"},
+		{"South", "3", ""},
+	}, grid)
+	assert.Equal(t, []Span{
+		{Row: 0, Col: 0, RowSpan: 2, ColSpan: 1},
+		{Row: 0, Col: 1, RowSpan: 1, ColSpan: 2},
+	}, spans)
+	assert.Equal(t, []int{3, 3, 3, 2}, widths)
+}
+
+func TestLayoutSpansClipsRowSpan(t *testing.T) {
+	grid, spans, _, err := LayoutSpans([][]SpanCell{
+		{{Value: "a"}, {Value: "b"}},
+		{{Value: "c", RowSpan: 5}, {Value: "d"}},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}}, grid)
+	assert.Empty(t, spans)
+}
+
+func TestLayoutSpansLimits(t *testing.T) {
+	_, _, _, err := LayoutSpans([][]SpanCell{{{Value: "a", ColSpan: 50000000}}})
+	assert.ErrorContains(t, err, "spans 50000000 columns")
+
+	// Spans within MaxSpan that still add up to a huge grid
+	rows := make([][]SpanCell, 1000)
+	rows[0] = []SpanCell{{Value: "a", RowSpan: 1000, ColSpan: MaxSpan}}
+	for i := 0; i < 20; i++ {
+		rows[1] = append(rows[1], SpanCell{Value: "b", ColSpan: MaxSpan})
+	}
+	_, _, _, err = LayoutSpans(rows)
+	assert.ErrorContains(t, err, "exceeds the limit")
+
+	grid, _, _, err := LayoutSpans([][]SpanCell{{{Value: "a", ColSpan: MaxSpan}}, {{Value: "b"}}})
+	assert.NoError(t, err)
+	assert.Len(t, grid[1], MaxSpan)
+}
+
+func newSpanTable() *Table {
+	table := &Table{}
+	table.SetGrid([][]string{
+		{"Region", "Sales", ""},
+		{"North", "1", "2"},
+		{"", "3", "4"},
+	}, []Span{
+		{Row: 0, Col: 1, RowSpan: 1, ColSpan: 2},
+		{Row: 1, Col: 0, RowSpan: 2, ColSpan: 1},
+	})
+	return table
+}
+
+func TestSpanLayout(t *testing.T) {
+	table := newSpanTable()
+	assert.Equal(t, []Span{
+		{Row: -1, Col: 1, RowSpan: 1, ColSpan: 2},
+		{Row: 0, Col: 0, RowSpan: 2, ColSpan: 1},
+	}, table.Spans)
+
+	layout := table.SpanLayout()
+
+	span, ok := layout.At(-1, 1)
+	assert.True(t, ok)
+	assert.Equal(t, 2, span.ColSpan)
+	assert.True(t, layout.Covered(-1, 2))
+	assert.False(t, layout.Covered(-1, 1))
+
+	span, ok = layout.CoveredBy(1, 0)
+	assert.True(t, ok)
+	assert.Equal(t, 0, span.Row)
+	_, ok = layout.CoveredBy(1, 1)
+	assert.False(t, ok)
+
+	// Spans beyond the table are cut at its bounds
+	table.Spans = append(table.Spans, Span{Row: 1, Col: 2, RowSpan: 3, ColSpan: 3}, Span{Row: 7, Col: 0, RowSpan: 2})
+	layout = table.SpanLayout()
+	_, ok = layout.At(1, 2)
+	assert.False(t, ok)
+	_, ok = layout.At(7, 0)
+	assert.False(t, ok)
+}
+
+func TestExpandSpans(t *testing.T) {
+	table := newSpanTable()
+
+	ExpandSpans(table)
+
+	assert.Equal(t, []string{"Region", "Sales", "Sales"}, table.Headers)
+	assert.Equal(t, [][]string{{"North", "1", "2"}, {"North", "3", "4"}}, table.Rows)
+	assert.Nil(t, table.Spans)
+}
+
+func TestExpandSpansNulls(t *testing.T) {
+	// Covered cells take the NULL flag of the merged cell
+	table := &Table{
+		Headers: []string{"a", "b"},
+		Rows:    [][]string{{"NULL", "1"}, {"NULL", "2"}, {"x", "3"}, {"NULL", "4"}},
+		Spans:   []Span{{Row: 0, Col: 0, RowSpan: 2, ColSpan: 1}, {Row: 2, Col: 0, RowSpan: 2, ColSpan: 1}},
+	}
+	table.SetNull(0, 0)
+	table.SetNull(3, 0)
+
+	ExpandSpans(table)
+
+	assert.Equal(t, [][]string{{"NULL", "1"}, {"NULL", "2"}, {"x", "3"}, {"x", "4"}}, table.Rows)
+	assert.True(t, table.IsNull(1, 0))
+	assert.False(t, table.IsNull(3, 0))
+}
+
+func TestApplyTransformationsExpandSpans(t *testing.T) {
+	table := newSpanTable()
+	cfg := &Config{Extension: map[string]string{}}
+	cfg.ApplyTransformations(table)
+	assert.Len(t, table.Spans, 2)
+
+	cfg.ExpandSpans = true
+	cfg.ApplyTransformations(table)
+	assert.Nil(t, table.Spans)
+
+	// Row transformations expand the spans, they would no longer line up
+	table = newSpanTable()
+	cfg = &Config{Extension: map[string]string{"deduplicate": "true"}}
+	cfg.ApplyTransformations(table)
+	assert.Nil(t, table.Spans)
+	assert.Equal(t, "North", table.Rows[1][0])
+}
+
+func TestParseConfigExpandSpans(t *testing.T) {
+	cfg, err := ParseConfig([]string{"--from", "html", "--to", "csv", "--expand-spans"})
+	assert.NoError(t, err)
+	assert.True(t, cfg.ExpandSpans)
+	assert.NotContains(t, cfg.Extension, "expand-spans")
+}
@@ -0,0 +1,210 @@
+package common
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrStreamNotSupported is returned by stream constructors when the requested
+// options need the whole table, so the conversion has to use the buffered path.
+// Constructors returning it must not have consumed any input or written any output.
+var ErrStreamNotSupported = errors.New("streaming is not supported with these options")
+
+// RowReader reads a table one row at a time. A reader holding resources such as
+// open files or temporary copies of the input may also implement io.Closer, it is
+// closed when the conversion stops reading, whether or not it reached io.EOF.
+type RowReader interface {
+	// Columns returns the table columns, known before the first row is read
+	Columns() []Column
+	// Next returns the next data row, or io.EOF when there are no more rows
+	Next() ([]string, error)
+}
+
+// RowWriter writes a table one row at a time
+type RowWriter interface {
+	// WriteHeader writes the table header, it is called once before any row
+	WriteHeader(columns []Column) error
+	// WriteRow writes one data row
+	WriteRow(row []string) error
+	// Close writes any trailer and flushes buffered output. It is also called
+	// when the conversion fails, possibly before WriteHeader.
+	Close() error
+}
+
+// NullRowReader is implemented by row readers of formats that tell NULL from strings
+type NullRowReader interface {
+	RowReader
+	// Nulls returns the NULL flags of the row last returned by Next
+	Nulls() []bool
+}
+
+// NullRowWriter is implemented by row writers of formats with a native NULL value
+type NullRowWriter interface {
+	RowWriter
+	// WriteNullRow writes one data row, nulls is nil if the source format has no null value
+	WriteNullRow(row []string, nulls []bool) error
+}
+
+// NewRowReaderFunc creates a RowReader that reads from cfg.Reader
+type NewRowReaderFunc func(cfg *Config) (RowReader, error)
+
+// NewRowWriterFunc creates a RowWriter that writes to cfg.Writer
+type NewRowWriterFunc func(cfg *Config) (RowWriter, error)
+
+// ReadAllRows drains a RowReader into table, checking each row with cfg.CheckRow
+func ReadAllRows(cfg *Config, reader RowReader, table *Table) error {
+	columns := reader.Columns()
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = column.Name
+	}
+	if err := cfg.CheckRow(0, headers); err != nil {
+		return err
+	}
+	nullReader, marksNulls := reader.(NullRowReader)
+	if marksNulls {
+		table.TrackNulls()
+	}
+	var rows [][]string
+	for {
+		row, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = cfg.CheckRow(len(rows), row)
+		}
+		if err != nil {
+			return err
+		}
+		if marksNulls {
+			for col, null := range nullReader.Nulls() {
+				if null {
+					table.SetNull(len(rows), col)
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	table.Headers = headers
+	table.Rows = rows
+	for _, column := range columns {
+		if column.Type != ColumnTypeUnknown {
+			table.Columns = columns
+			break
+		}
+	}
+	return nil
+}
+
+// streamable reports whether the configured transformations can be applied row by row
+func (c *Config) streamable() bool {
+	return !c.NoStream && !c.selectsTables() &&
+		!c.GetExtensionBool("transpose", false) &&
+		!c.GetExtensionBool("deduplicate", false)
+}
+
+// performStreamConversion converts cfg.Reader to cfg.Writer row by row.
+// It returns false without touching the input or output when either format
+// cannot stream with the given configuration.
+func performStreamConversion(registry *FormatRegistry, cfg *Config) (bool, error) {
+	if !cfg.streamable() {
+		return false, nil
+	}
+	newReader := registry.StreamReaderMap[cfg.From]
+	newWriter := registry.StreamWriterMap[cfg.To]
+	if newReader == nil || newWriter == nil {
+		return false, nil
+	}
+
+	// Create the writer first, writer constructors don't produce any output
+	writer, err := newWriter(cfg)
+	if errors.Is(err, ErrStreamNotSupported) {
+		return false, nil
+	} else if err != nil {
+		return true, &ConversionError{Stage: "marshal", Format: cfg.To, Err: err}
+	}
+	// Close the writer when the conversion fails too, so writers holding an
+	// output file release it; the error of the failed step is the one returned
+	closed := false
+	defer func() {
+		if !closed {
+			writer.Close()
+		}
+	}()
+	reader, err := newReader(cfg)
+	if errors.Is(err, ErrStreamNotSupported) {
+		// The buffered conversion writes the output, closing the writer
+		// could write a trailer before it
+		closed = true
+		return false, nil
+	} else if err != nil {
+		return true, &ConversionError{Stage: "unmarshal", Format: cfg.From, Err: err}
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	deleteEmpty := cfg.GetExtensionBool("delete-empty", false)
+	caseFn := cfg.caseTransform()
+	nullString := cfg.NullString()
+	nullReader, _ := reader.(NullRowReader)
+	nullWriter, _ := writer.(NullRowWriter)
+
+	columns := append([]Column(nil), reader.Columns()...)
+	for _, column := range columns {
+		if err := cfg.CheckRow(0, []string{column.Name}); err != nil {
+			return true, &ConversionError{Stage: "unmarshal", Format: cfg.From, Err: err}
+		}
+	}
+	if caseFn != nil {
+		for i := range columns {
+			columns[i].Name = caseFn(columns[i].Name)
+		}
+	}
+	if err := writer.WriteHeader(columns); err != nil {
+		return true, &ConversionError{Stage: "marshal", Format: cfg.To, Err: err}
+	}
+
+	for index := 0; ; index++ {
+		row, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = cfg.CheckRow(index, row)
+		}
+		if err != nil {
+			return true, &ConversionError{Stage: "unmarshal", Format: cfg.From, Err: err}
+		}
+		var nulls []bool
+		if nullReader != nil {
+			nulls = nullReader.Nulls()
+			fillNullCells(row, nulls, nullString)
+		}
+		if deleteEmpty && isEmptyRow(row) {
+			continue
+		}
+		if caseFn != nil {
+			for i := range row {
+				if i >= len(nulls) || !nulls[i] {
+					row[i] = caseFn(row[i])
+				}
+			}
+		}
+		if nullWriter != nil {
+			err = nullWriter.WriteNullRow(row, nulls)
+		} else {
+			err = writer.WriteRow(row)
+		}
+		if err != nil {
+			return true, &ConversionError{Stage: "marshal", Format: cfg.To, Err: err}
+		}
+	}
+
+	closed = true
+	if err := writer.Close(); err != nil {
+		return true, &ConversionError{Stage: "marshal", Format: cfg.To, Err: err}
+	}
+	return true, nil
+}
@@ -0,0 +1,240 @@
+package common
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sliceRowReader streams rows from memory
+type sliceRowReader struct {
+	columns []Column
+	rows    [][]string
+}
+
+func (r *sliceRowReader) Columns() []Column {
+	return r.columns
+}
+
+func (r *sliceRowReader) Next() ([]string, error) {
+	if len(r.rows) == 0 {
+		return nil, io.EOF
+	}
+	row := r.rows[0]
+	r.rows = r.rows[1:]
+	return row, nil
+}
+
+// closingRowReader records whether the conversion closed it
+type closingRowReader struct {
+	sliceRowReader
+	closed bool
+}
+
+func (r *closingRowReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+// sliceRowWriter collects streamed rows in memory
+type sliceRowWriter struct {
+	headers []string
+	rows    [][]string
+	closed  bool
+}
+
+func (w *sliceRowWriter) WriteHeader(columns []Column) error {
+	for _, column := range columns {
+		w.headers = append(w.headers, column.Name)
+	}
+	return nil
+}
+
+func (w *sliceRowWriter) WriteRow(row []string) error {
+	w.rows = append(w.rows, row)
+	return nil
+}
+
+func (w *sliceRowWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+// newStreamTestRegistry registers a "mem" format that streams the given rows
+// in both directions and records whether the buffered functions were used
+func newStreamTestRegistry(rows [][]string, writer *sliceRowWriter, buffered *bool) *FormatRegistry {
+	registry := NewFormatRegistry()
+	registry.RegisterFormat("mem",
+		func(cfg *Config, table *Table) error {
+			*buffered = true
+			table.Headers = []string{"a", "b"}
+			table.Rows = rows
+			return nil
+		},
+		func(cfg *Config, table *Table) error {
+			*buffered = true
+			writer.headers = table.Headers
+			writer.rows = table.Rows
+			return nil
+		},
+	)
+	registry.RegisterStreamFormat("mem",
+		func(cfg *Config) (RowReader, error) {
+			return &sliceRowReader{columns: NewColumns([]string{"a", "b"}), rows: rows}, nil
+		},
+		func(cfg *Config) (RowWriter, error) {
+			return writer, nil
+		},
+	)
+	return registry
+}
+
+func TestRegisterStreamFormat(t *testing.T) {
+	registry := NewFormatRegistry()
+	reader := func(cfg *Config) (RowReader, error) { return nil, nil }
+
+	registry.RegisterFormat("test", nil, nil)
+	registry.RegisterStreamFormat("test", reader, nil)
+	registry.RegisterFormatAlias("alias", "test")
+
+	assert.NotNil(t, registry.StreamReaderMap["test"])
+	assert.NotNil(t, registry.StreamReaderMap["alias"])
+	_, ok := registry.StreamWriterMap["test"]
+	assert.False(t, ok)
+}
+
+func TestPerformConversionStreaming(t *testing.T) {
+	rows := [][]string{{"x", "y"}, {"", " "}, {"z", "w"}}
+	writer := &sliceRowWriter{}
+	buffered := false
+	registry := newStreamTestRegistry(rows, writer, &buffered)
+
+	cfg := &Config{
+		From:      "mem",
+		To:        "mem",
+		Extension: map[string]string{"delete-empty": "true", "uppercase": "true"},
+	}
+	err := PerformConversionWithRegistry(registry, cfg)
+
+	require.NoError(t, err)
+	assert.False(t, buffered)
+	assert.True(t, writer.closed)
+	assert.Equal(t, []string{"A", "B"}, writer.headers)
+	assert.Equal(t, [][]string{{"X", "Y"}, {"Z", "W"}}, writer.rows)
+}
+
+func TestPerformConversionStreamingFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		extension map[string]string
+		noStream  bool
+	}{
+		{name: "transpose", extension: map[string]string{"transpose": "true"}},
+		{name: "deduplicate", extension: map[string]string{"deduplicate": "true"}},
+		{name: "no-stream", extension: map[string]string{}, noStream: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &sliceRowWriter{}
+			buffered := false
+			registry := newStreamTestRegistry([][]string{{"x", "y"}}, writer, &buffered)
+
+			cfg := &Config{From: "mem", To: "mem", NoStream: tt.noStream, Extension: tt.extension}
+			err := PerformConversionWithRegistry(registry, cfg)
+
+			require.NoError(t, err)
+			assert.True(t, buffered)
+			assert.False(t, writer.closed)
+		})
+	}
+}
+
+func TestPerformConversionStreamNotSupported(t *testing.T) {
+	writer := &sliceRowWriter{}
+	buffered := false
+	registry := newStreamTestRegistry([][]string{{"x", "y"}}, writer, &buffered)
+	registry.RegisterStreamFormat("mem", func(cfg *Config) (RowReader, error) {
+		return nil, ErrStreamNotSupported
+	}, nil)
+
+	err := PerformConversionWithRegistry(registry, &Config{From: "mem", To: "mem"})
+
+	require.NoError(t, err)
+	assert.True(t, buffered)
+	assert.Equal(t, [][]string{{"x", "y"}}, writer.rows)
+	// The stream writer is dropped without writing a trailer before the buffered output
+	assert.False(t, writer.closed)
+}
+
+func TestPerformConversionStreamError(t *testing.T) {
+	writer := &sliceRowWriter{}
+	buffered := false
+	registry := newStreamTestRegistry(nil, writer, &buffered)
+	registry.RegisterStreamFormat("mem", func(cfg *Config) (RowReader, error) {
+		return nil, errors.New("bad input")
+	}, nil)
+
+	err := PerformConversionWithRegistry(registry, &Config{From: "mem", To: "mem"})
+
+	var convErr *ConversionError
+	require.ErrorAs(t, err, &convErr)
+	assert.Equal(t, "unmarshal", convErr.Stage)
+	assert.False(t, buffered)
+	assert.True(t, writer.closed)
+}
+
+func TestPerformConversionStreamClosesReader(t *testing.T) {
+	for _, maxRows := range []int{0, 1} {
+		writer := &sliceRowWriter{}
+		buffered := false
+		registry := newStreamTestRegistry(nil, writer, &buffered)
+		reader := &closingRowReader{sliceRowReader: sliceRowReader{
+			columns: NewColumns([]string{"a", "b"}),
+			rows:    [][]string{{"x", "y"}, {"z", "w"}},
+		}}
+		registry.RegisterStreamFormat("mem", func(cfg *Config) (RowReader, error) {
+			return reader, nil
+		}, nil)
+
+		err := PerformConversionWithRegistry(registry, &Config{From: "mem", To: "mem", Limits: Limits{MaxRows: maxRows}})
+
+		if maxRows > 0 {
+			var limitErr *LimitError
+			assert.ErrorAs(t, err, &limitErr)
+		} else {
+			assert.NoError(t, err)
+		}
+		assert.True(t, reader.closed, "max-rows %d", maxRows)
+		assert.True(t, writer.closed, "max-rows %d", maxRows)
+	}
+}
+
+func TestReadAllRows(t *testing.T) {
+	reader := &sliceRowReader{
+		columns: []Column{{Name: "id", Type: ColumnTypeInt}, {Name: "name"}},
+		rows:    [][]string{{"1", "a"}, {"2", "b"}},
+	}
+	table := &Table{}
+
+	err := ReadAllRows(&Config{}, reader, table)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "name"}, table.Headers)
+	assert.Equal(t, [][]string{{"1", "a"}, {"2", "b"}}, table.Rows)
+	assert.Equal(t, ColumnTypeInt, table.ColumnType(0))
+}
+
+func TestParseConfigNoStream(t *testing.T) {
+	cfg, err := ParseConfig([]string{"--from", "csv", "--to", "json", "--no-stream"})
+	require.NoError(t, err)
+	assert.True(t, cfg.NoStream)
+	assert.NotContains(t, cfg.Extension, "no-stream")
+
+	cfg, err = ParseConfig([]string{"--from", "csv", "--to", "json"})
+	require.NoError(t, err)
+	assert.False(t, cfg.NoStream)
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 	"unicode"
@@ -43,8 +44,23 @@ func Transpose(table *Table) {
 		}
 	}
 
+	// NULL flags move with their cells
+	if table.MarksNulls() {
+		nulls := table.Nulls
+		table.Nulls = [][]bool{}
+		for j, row := range nulls {
+			for i, null := range row {
+				if null {
+					table.SetNull(i, j+1)
+				}
+			}
+		}
+	}
+
 	table.Headers = newHeaders
 	table.Rows = newRows
+	// Columns no longer match the original schema
+	table.Columns = nil
 }
 
 // DeleteEmptyRows removes rows that are completely empty (all cells are empty strings)
@@ -54,19 +70,39 @@ func DeleteEmptyRows(table *Table) {
 	}
 
 	nonEmptyRows := make([][]string, 0, len(table.Rows))
-	for _, row := range table.Rows {
-		isEmpty := true
-		for _, cell := range row {
-			if strings.TrimSpace(cell) != "" {
-				isEmpty = false
-				break
-			}
-		}
-		if !isEmpty {
+	var kept []int
+	for i, row := range table.Rows {
+		if !isEmptyRow(row) {
 			nonEmptyRows = append(nonEmptyRows, row)
+			kept = append(kept, i)
 		}
 	}
 	table.Rows = nonEmptyRows
+	table.keepNullRows(kept)
+}
+
+// keepNullRows keeps the NULL flags of the given original rows, after rows were removed
+func (t *Table) keepNullRows(kept []int) {
+	if !t.MarksNulls() {
+		return
+	}
+	nulls := make([][]bool, len(kept))
+	for i, row := range kept {
+		if row < len(t.Nulls) {
+			nulls[i] = t.Nulls[row]
+		}
+	}
+	t.Nulls = nulls
+}
+
+// isEmptyRow reports whether all cells of row are blank
+func isEmptyRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
 }
 
 // DeduplicateRows removes duplicate rows (rows with identical values in all columns)
@@ -77,17 +113,24 @@ func DeduplicateRows(table *Table) {
 
 	seen := make(map[string]bool)
 	uniqueRows := make([][]string, 0, len(table.Rows))
+	var kept []int
 
-	for _, row := range table.Rows {
+	for i, row := range table.Rows {
 		// Create a key by joining all cells with a delimiter
 		// Using a delimiter that's unlikely to appear in normal data
 		key := strings.Join(row, "\x00")
+		if table.MarksNulls() {
+			// NULL differs from the same text
+			key += "\x00" + fmt.Sprint(table.RowNulls(i))
+		}
 		if !seen[key] {
 			seen[key] = true
 			uniqueRows = append(uniqueRows, row)
+			kept = append(kept, i)
 		}
 	}
 	table.Rows = uniqueRows
+	table.keepNullRows(kept)
 }
 
 // Uppercase converts all cell values to uppercase
@@ -102,9 +145,12 @@ func Uppercase(table *Table) {
 
 	for i := range table.Rows {
 		for j := range table.Rows[i] {
-			table.Rows[i][j] = strings.ToUpper(table.Rows[i][j])
+			if !table.IsNull(i, j) {
+				table.Rows[i][j] = strings.ToUpper(table.Rows[i][j])
+			}
 		}
 	}
+	table.syncColumnNames()
 }
 
 // Lowercase converts all cell values to lowercase
@@ -119,9 +165,12 @@ func Lowercase(table *Table) {
 
 	for i := range table.Rows {
 		for j := range table.Rows[i] {
-			table.Rows[i][j] = strings.ToLower(table.Rows[i][j])
+			if !table.IsNull(i, j) {
+				table.Rows[i][j] = strings.ToLower(table.Rows[i][j])
+			}
 		}
 	}
+	table.syncColumnNames()
 }
 
 // Capitalize converts the first letter of each cell value to uppercase
@@ -130,24 +179,28 @@ func Capitalize(table *Table) {
 		return
 	}
 
-	capitalizeFirst := func(s string) string {
-		if s == "" {
-			return s
-		}
-		r, size := utf8.DecodeRuneInString(s)
-		if r == utf8.RuneError {
-			return s
-		}
-		return string(unicode.ToUpper(r)) + s[size:]
-	}
-
 	for i := range table.Headers {
 		table.Headers[i] = capitalizeFirst(table.Headers[i])
 	}
 
 	for i := range table.Rows {
 		for j := range table.Rows[i] {
-			table.Rows[i][j] = capitalizeFirst(table.Rows[i][j])
+			if !table.IsNull(i, j) {
+				table.Rows[i][j] = capitalizeFirst(table.Rows[i][j])
+			}
 		}
 	}
+	table.syncColumnNames()
+}
+
+// capitalizeFirst converts the first letter of s to uppercase
+func capitalizeFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
 }
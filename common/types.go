@@ -3,16 +3,45 @@ package common
 import (
 	"fmt"
 	"io"
-	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 )
 
+// decimalPattern matches a plain decimal number such as -12, 1.50, .5 or 2e10
+var decimalPattern = regexp.MustCompile(`^[+-]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+
 // Table represents the parsed data from the MySQL output.
 type Table struct {
+	Name    string // optional table name, e.g. the sheet name or HTML caption
 	Headers []string
 	Rows    [][]string
+	Columns []Column // optional column schema, filled by readers that know the source types
+	Spans   []Span   // optional merged cells, filled by readers of formats with row/column spans
+	Nulls   [][]bool // optional NULL flags by data row, nil if the source format has no null value
+}
+
+// Clone returns a deep copy of the table
+func (t *Table) Clone() *Table {
+	clone := &Table{
+		Name:    t.Name,
+		Headers: append([]string(nil), t.Headers...),
+		Columns: append([]Column(nil), t.Columns...),
+		Spans:   append([]Span(nil), t.Spans...),
+	}
+	if t.Rows != nil {
+		clone.Rows = make([][]string, len(t.Rows))
+		for i, row := range t.Rows {
+			clone.Rows[i] = append([]string(nil), row...)
+		}
+	}
+	if t.Nulls != nil {
+		clone.Nulls = make([][]bool, len(t.Nulls))
+		for i, nulls := range t.Nulls {
+			clone.Nulls[i] = append([]bool(nil), nulls...)
+		}
+	}
+	return clone
 }
 
 // ParseError represents an error during parsing.
@@ -41,117 +70,19 @@ const (
 	TableFormatTWiki     = "twiki"
 )
 
-// Define a mapping from file extensions to table formats
-var extensions = map[string]string{
-	".xlsx":      TableFormatExcel,
-	".xls":       TableFormatExcel,
-	".csv":       TableFormatCSV,
-	".sql":       TableFormatSQL,
-	".json":      TableFormatJSON,
-	".tex":       TableFormatLatex,
-	".xml":       TableFormatXML,
-	".md":        TableFormatMarkdown,
-	".markdown":  TableFormatMarkdown,
-	".html":      TableFormatHTML,
-	".mediawiki": TableFormatMediaWiki,
-	".wiki":      TableFormatMediaWiki,
-}
-
 // DetectTableFormatByExtension detects the table format by the file extension.
 func DetectTableFormatByExtension(filename string) string {
-	// Check if the input filename is empty
-	if filename == "" {
-		return ""
+	if format, ok := formatByExtension(filename); ok {
+		return format.Name
 	}
-
-	// Get the file extension in lowercase
-	ext := strings.ToLower(filepath.Ext(filename))
-
-	// Look up the extension in the map
-	if format, ok := extensions[ext]; ok {
-		return format
-	}
-
 	return ""
 }
 
+// DetectTableFormatByData detects the table format from the first
+// DetectSampleSize bytes of reader, see ScoreFormats
 func DetectTableFormatByData(reader io.Reader) (string, error) {
-	content, err := io.ReadAll(reader)
-	if err != nil {
-		return "", err
-	}
-	strContent := string(content)
-
-	// Attention: file type detection order is important
-	switch {
-	case isHTML(strContent):
-		return TableFormatHTML, nil
-	case isMarkdown(strContent):
-		return TableFormatMarkdown, nil
-	case isLaTeX(strContent):
-		return TableFormatLatex, nil
-	case isMediaWiki(strContent):
-		return TableFormatMediaWiki, nil
-	case isCSV(strContent):
-		return TableFormatCSV, nil
-	case isSQL(strContent):
-		return TableFormatSQL, nil
-	case isJSON(strContent):
-		return TableFormatJSON, nil
-	case isXML(strContent):
-		return TableFormatXML, nil
-	default:
-		return "", fmt.Errorf("unsupported file format")
-	}
-}
-
-func isHTML(content string) bool {
-	return strings.Contains(content, "<thead") ||
-		strings.Contains(content, "<table")
-}
-
-func isMarkdown(content string) bool {
-	re, err := regexp.Compile(`^#+\s|^-\s|^\*\s`)
-	if err != nil {
-		return false
-	}
-	return re.MatchString(content)
-}
-
-func isLaTeX(content string) bool {
-	return strings.Contains(content, "\\hline") ||
-		strings.Contains(content, "\\begin{tabular}")
-}
-
-func isMediaWiki(content string) bool {
-	return strings.Contains(content, "{|") ||
-		strings.Contains(content, "|}")
-}
-
-func isCSV(content string) bool {
-	lines := strings.Split(content, "\n")
-	for _, line := range lines {
-		if strings.Contains(line, ",") {
-			return true
-		}
-	}
-	return false
-}
-
-func isSQL(content string) bool {
-	return strings.Contains(strings.ToLower(content), "replace") ||
-		strings.Contains(strings.ToLower(content), "insert")
-}
-
-func isJSON(content string) bool {
-	content = strings.TrimSpace(content)
-	return (strings.HasPrefix(content, "{") && strings.HasSuffix(content, "}")) ||
-		(strings.HasPrefix(content, "[") && strings.HasSuffix(content, "]"))
-}
-
-func isXML(content string) bool {
-	return strings.Contains(content, "<") && strings.Contains(content, ">") &&
-		strings.Contains(content, "</")
+	format, _, _, err := DetectFormat(reader)
+	return format, err
 }
 
 // InferType attempts to convert a string value to a more specific type (bool, int64, float64, nil)
@@ -198,6 +129,13 @@ func InferType(value string) interface{} {
 	return value
 }
 
+// IsDecimal reports whether s, leading and trailing spaces aside, is a plain decimal
+// number. Unlike strconv it rejects hex, underscores, Inf and NaN, so the text can
+// be written as a number literal as it is instead of through a rounded float64.
+func IsDecimal(s string) bool {
+	return decimalPattern.MatchString(strings.TrimSpace(s))
+}
+
 func InferPrintType(s string) string {
 	switch InferType(s).(type) {
 	case nil:
@@ -534,6 +534,16 @@ func TestInferTypeEdgeCases(t *testing.T) {
 	})
 }
 
+// TestIsDecimal tests plain decimal number syntax
+func TestIsDecimal(t *testing.T) {
+	for _, s := range []string{"0", "-12", "+3", "1.50", ".5", "5.", " 7 ", "2e10", "-1.5E-3", "12345678901234567.89"} {
+		assert.True(t, IsDecimal(s), s)
+	}
+	for _, s := range []string{"", "-", ".", "1_000", "0x10", "0x1p-2", "Inf", "NaN", "1e", "1.2.3", "1 2"} {
+		assert.False(t, IsDecimal(s), s)
+	}
+}
+
 // TestExtensionsMap tests the extensions mapping
 func TestExtensionsMap(t *testing.T) {
 	// This test verifies the extensions map is properly initialized
@@ -0,0 +1,245 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// cliFlags are the options ParseConfig handles itself, they are suggested for misspelled parameters
+var cliFlags = []string{
+	"from", "to", "file", "input", "result", "output", "verbose", "batch", "recursive",
+	"output-dir", "dry-run", "preview", "no-stream", "table-index", "table-name", "all-tables",
+	"expand-spans", "help", "help-formats", "help-format", "mcp", "detect", "explain-detect",
+	"max-rows", "max-bytes", "max-cell-bytes", "timeout", "lenient",
+}
+
+// ValidateParams checks the keys and values of Extension against the global
+// transformations, the reading parameters of format from and the writing
+// parameters of format to. An empty from stands for any format, as in batch
+// mode where each file is read by its extension. Unknown parameters and
+// values that are not allowed are reported together, with suggestions for
+// misspellings.
+func (c *Config) ValidateParams(from, to string) error {
+	readers := []string{from}
+	if from == "" {
+		readers = GetAllFormats()
+	}
+	return c.validateParams(from, readers, to)
+}
+
+// ValidateWriteParams is ValidateParams for writing a table in format to
+// that was not read from another format, as the library Write does
+func (c *Config) ValidateWriteParams(to string) error {
+	return c.validateParams("", nil, to)
+}
+
+// ownedParam is a parameter of the format named owner, "" for the global transformations
+type ownedParam struct {
+	owner string
+	FormatParam
+}
+
+// validateParams checks Extension against the reading parameters of readers
+// and the writing parameters of to, see ValidateParams
+func (c *Config) validateParams(from string, readers []string, to string) error {
+	// A parameter of both formats, such as style, may take the values of either
+	params := make(map[string][]ownedParam)
+	var names []string
+	for _, p := range GlobalTransformParams {
+		params[p.Name] = append(params[p.Name], ownedParam{"", p})
+	}
+	add := func(name string, skip ParamUse) {
+		format, ok := LookupFormat(name)
+		if !ok {
+			return
+		}
+		names = append(names, format.Name)
+		for _, p := range format.Params {
+			if p.Use != skip {
+				params[p.Name] = append(params[p.Name], ownedParam{format.Name, p})
+			}
+		}
+	}
+	for _, name := range readers {
+		add(name, ParamWrite)
+	}
+	add(to, ParamRead)
+
+	keys := make([]string, 0, len(c.Extension))
+	for key := range c.Extension {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// The formats the problems are about, for the --help-format hint
+	var problems, rejectedBy []string
+	reject := func(format, problem string) {
+		problems = append(problems, problem)
+		if format != "" && !slices.Contains(rejectedBy, format) {
+			rejectedBy = append(rejectedBy, format)
+		}
+	}
+	for _, key := range keys {
+		candidates, ok := params[key]
+		if !ok {
+			format, msg := unknownParamMessage(key, params, names, from, to)
+			reject(format, msg)
+			continue
+		}
+		var err error
+		for _, param := range candidates {
+			if err = param.check(c.Extension[key]); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			reject(candidates[len(candidates)-1].owner, fmt.Sprintf("--%s: %v", key, err))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	// Without a format to blame, e.g. for a misspelled flag, the hint names the output format
+	if len(rejectedBy) == 0 && len(names) > 0 {
+		rejectedBy = names[len(names)-1:]
+	}
+	hint := "Run 'tableconvert --help-formats' to list the parameters of each format"
+	switch len(rejectedBy) {
+	case 0:
+	case 1:
+		hint = fmt.Sprintf("Run 'tableconvert --help-format=%s' to list its parameters", rejectedBy[0])
+	default:
+		helps := make([]string, len(rejectedBy))
+		for i, format := range rejectedBy {
+			helps[i] = fmt.Sprintf("'tableconvert --help-format=%s'", format)
+		}
+		hint = fmt.Sprintf("Run %s to list their parameters", strings.Join(helps, " or "))
+	}
+	return fmt.Errorf("%s\n\n%s, or use --lenient to skip this check", strings.Join(problems, "\n"), hint)
+}
+
+// unknownParamMessage explains an unknown parameter, naming the side of the
+// conversion it applies to, the formats it belongs to or the closest known
+// parameter. It also returns the format of the conversion the parameter was
+// rejected for, "" when it belongs to neither.
+func unknownParamMessage(key string, params map[string][]ownedParam, formats []string, from, to string) (string, string) {
+	for _, side := range []struct {
+		name, verb string
+		use        ParamUse
+	}{{from, "reading", ParamWrite}, {to, "writing", ParamRead}} {
+		if format, ok := LookupFormat(side.name); ok {
+			for _, p := range format.Params {
+				if p.Name == key && p.Use == side.use {
+					return format.Name, fmt.Sprintf("unknown parameter --%s: %s does not take it when %s", key, format.Name, side.verb)
+				}
+			}
+		}
+	}
+
+	var owners []string
+	for _, format := range Formats() {
+		for _, p := range format.Params {
+			if p.Name == key {
+				owners = append(owners, format.Name)
+				break
+			}
+		}
+	}
+	if len(owners) > 0 && len(formats) > 0 {
+		return "", fmt.Sprintf("unknown parameter --%s: it is a parameter of %s, not of %s",
+			key, strings.Join(owners, ", "), strings.Join(formats, " or "))
+	}
+
+	candidates := append([]string(nil), cliFlags...)
+	for name := range params {
+		candidates = append(candidates, name)
+	}
+	sort.Strings(candidates)
+	msg := fmt.Sprintf("unknown parameter --%s", key)
+	suggestion := suggest(key, candidates)
+	if suggestion == "" {
+		return "", msg
+	}
+	msg += fmt.Sprintf(", did you mean --%s?", suggestion)
+	// A misspelled parameter of one of the formats
+	for _, p := range params[suggestion] {
+		if p.owner != "" {
+			return p.owner, msg
+		}
+	}
+	return "", msg
+}
+
+// check validates a parameter value with Validate, or against AllowedValues
+func (p FormatParam) check(value string) error {
+	if p.Validate != nil {
+		return p.Validate(value)
+	}
+	switch p.AllowedValues {
+	case "", "string":
+		return nil
+	case "true, false":
+		if value == "" || isBoolValue(value) {
+			return nil
+		}
+		return fmt.Errorf("invalid value %q, expected true or false", value)
+	}
+	return OneOf(strings.Split(p.AllowedValues, ", ")...)(value)
+}
+
+// OneOf returns a FormatParam.Validate function accepting only the given values
+func OneOf(values ...string) func(string) error {
+	return func(value string) error {
+		for _, v := range values {
+			if value == v {
+				return nil
+			}
+		}
+		msg := fmt.Sprintf("invalid value %q, allowed values: %s", value, strings.Join(values, ", "))
+		if suggestion := suggest(value, values); suggestion != "" {
+			msg += fmt.Sprintf(", did you mean %q?", suggestion)
+		}
+		return fmt.Errorf("%s", msg)
+	}
+}
+
+// suggest returns the candidate closest to s, or "" if none is close enough
+// to be a misspelling of it
+func suggest(s string, candidates []string) string {
+	best, bestDistance := "", len(s)/3+1
+	lower := strings.ToLower(s)
+	for _, candidate := range candidates {
+		if strings.EqualFold(candidate, s) || (len(s) > 2 && strings.HasPrefix(strings.ToLower(candidate), lower)) {
+			return candidate
+		}
+		if d := levenshtein(lower, strings.ToLower(candidate)); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,78 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		params   map[string]string
+		err      string // expected part of the error, "" if valid
+		help     string // format of the --help-format hint, default to
+	}{
+		{"known params", "csv", "markdown", map[string]string{"bold-header": "", "align": "l, C,r", "delimiter": ";", "transpose": "yes"}, "", ""},
+		{"param of either format", "mysql", "ascii", map[string]string{"style": "plus"}, "", ""},
+		{"misspelled param", "csv", "markdown", map[string]string{"bold-heder": "true"}, "unknown parameter --bold-heder, did you mean --bold-header?", ""},
+		{"misspelled flag", "csv", "json", map[string]string{"verbos": ""}, "did you mean --verbose?", ""},
+		{"param of other format", "csv", "markdown", map[string]string{"minify": "true"}, "--minify: it is a parameter of html, json, mediawiki, xml, not of csv or markdown", ""},
+		{"unrelated param", "csv", "json", map[string]string{"colour": "red"}, "unknown parameter --colour\n", ""},
+		{"disallowed value", "csv", "sql", map[string]string{"dialect": "postgre"}, `--dialect: invalid value "postgre", allowed values: none, mysql, oracle, mssql, postgresql, postgres, sqlite, did you mean "postgresql"?`, ""},
+		{"wrong case", "csv", "csv", map[string]string{"delimiter": "tab"}, `did you mean "TAB"?`, ""},
+		{"bad boolean", "csv", "markdown", map[string]string{"escape": "maybe"}, `--escape: invalid value "maybe", expected true or false`, ""},
+		{"bad column alignment", "csv", "markdown", map[string]string{"align": "l,x"}, `--align: invalid value "x"`, ""},
+		{"writing param of the input", "parquet", "arrow", map[string]string{"compression": "snappy"}, `--compression: invalid value "snappy", allowed values: none, lz4, zstd`, ""},
+		{"writing param of the output", "csv", "parquet", map[string]string{"compression": "snappy", "row-group-size": "10"}, "", ""},
+		{"writing param when reading", "parquet", "csv", map[string]string{"row-group-size": "10"}, "unknown parameter --row-group-size: parquet does not take it when reading", "parquet"},
+		{"reading param when writing", "csv", "sqlite", map[string]string{"input-table": "users"}, "unknown parameter --input-table: sqlite does not take it when writing", ""},
+		{"table of each side", "sqlite", "sql", map[string]string{"input-table": "users", "table": "people"}, "", ""},
+		{"table when reading", "sqlite", "csv", map[string]string{"table": "users"}, "", ""},
+		{"writing param of sqlite when reading", "sqlite", "csv", map[string]string{"if-exists": "replace"}, "sqlite does not take it when reading", "sqlite"},
+		{"misspelled param of the input", "sqlite", "csv", map[string]string{"querry": "SELECT 1"}, "did you mean --query?", "sqlite"},
+		{"disallowed value of the input", "excel", "json", map[string]string{"first-column-header": "x"}, `--first-column-header: invalid value "x"`, "excel"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := common.Config{Extension: tt.params}
+			err := cfg.ValidateParams(tt.from, tt.to)
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+			help := tt.help
+			if help == "" {
+				help = tt.to
+			}
+			assert.Contains(t, err.Error(), "--help-format="+help+"'")
+		})
+	}
+
+	// All problems are reported at once
+	cfg := common.Config{Extension: map[string]string{"bold-heder": "", "pretty": "nope"}}
+	err := cfg.ValidateParams("csv", "markdown")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--bold-heder")
+	assert.Contains(t, err.Error(), "--pretty")
+}
+
+func TestParseConfigLenient(t *testing.T) {
+	_, err := common.ParseConfig([]string{"--from", "csv", "--to", "markdown", "--bold-heder"})
+	assert.ErrorContains(t, err, "did you mean --bold-header?")
+	_, err = common.ParseConfig([]string{"--mcp", "--verbose=maybe"})
+	assert.ErrorContains(t, err, `invalid value "maybe" for --verbose`)
+	_, err = common.ParseConfig([]string{"--batch=*.csv", "--to", "json", "--minfy"})
+	assert.ErrorContains(t, err, "did you mean --minify?")
+
+	cfg, err := common.ParseConfig([]string{"--from", "csv", "--to", "markdown", "--bold-heder", "--lenient"})
+	require.NoError(t, err)
+	assert.True(t, cfg.Lenient)
+	assert.Equal(t, "", cfg.Extension["bold-heder"])
+}
@@ -1,36 +1,108 @@
 package csv
 
 import (
+	"bufio"
 	"encoding/csv"
 	"fmt"
+	"io"
+	"strings"
 
 	"github.com/martianzhang/tableconvert/common"
 )
 
-func Unmarshal(cfg *common.Config, table *common.Table) error {
-	csvReader := csv.NewReader(cfg.Reader)
-	// Allow variable number of fields per record
-	csvReader.FieldsPerRecord = -1
-
-	// Set custom delimiter (default: comma)
-	delimiter := cfg.GetExtensionString("delimiter", ",")
-	switch delimiter {
+// delimiter returns the configured value delimiter (default: comma)
+func delimiter(cfg *common.Config) rune {
+	switch cfg.GetExtensionString("delimiter", ",") {
 	case "TAB", "\t":
-		csvReader.Comma = '\t'
+		return '\t'
 	case "SEMICOLON", ";":
-		csvReader.Comma = ';'
+		return ';'
 	case "PIPE", "|":
-		csvReader.Comma = '|'
+		return '|'
 	case "SLASH", "/":
-		csvReader.Comma = '/'
+		return '/'
 	case "HASH", "#":
-		csvReader.Comma = '#'
-		// default remains comma
+		return '#'
+	default:
+		return ','
 	}
+}
 
-	records, err := csvReader.ReadAll()
-	if err != nil {
-		return fmt.Errorf("failed to read CSV: %w", err)
+// sniffDelimiter returns the delimiter of sample lines: a comma if the first
+// line has one, else a semicolon or tab splitting every line into the same
+// number of fields, at least two. It returns 0 if none fits.
+func sniffDelimiter(lines []string) rune {
+	if len(lines) == 0 {
+		return 0
+	}
+	if strings.Contains(lines[0], ",") {
+		return ','
+	}
+	for _, comma := range []rune{';', '\t'} {
+		if !strings.ContainsRune(lines[0], comma) {
+			continue
+		}
+		reader := csv.NewReader(strings.NewReader(strings.Join(lines, "\n")))
+		reader.Comma = comma
+		if records, err := reader.ReadAll(); err == nil && len(records[0]) > 1 {
+			return comma
+		}
+	}
+	return 0
+}
+
+// sampleLines returns the complete lines of a detection sample
+func sampleLines(sample string) []string {
+	lines := common.SampleLines(sample, 20)
+	if len(lines) > 1 && len(sample) >= common.DetectSampleSize {
+		lines = lines[:len(lines)-1] // the last line may be cut off
+	}
+	return lines
+}
+
+// newReader reads cfg.Reader with the configured delimiter. Without one the
+// delimiter is sniffed from the first lines, so semicolon and tab separated
+// files are read like the detection scored them.
+func newReader(cfg *common.Config) *csv.Reader {
+	input := cfg.Reader
+	comma := delimiter(cfg)
+	if _, ok := cfg.Extension["delimiter"]; !ok {
+		buffered := bufio.NewReaderSize(input, common.DetectSampleSize)
+		sample, _ := buffered.Peek(common.DetectSampleSize)
+		if sniffed := sniffDelimiter(sampleLines(string(sample))); sniffed != 0 {
+			comma = sniffed
+		}
+		input = buffered
+	}
+	csvReader := csv.NewReader(input)
+	// Allow variable number of fields per record
+	csvReader.FieldsPerRecord = -1
+	csvReader.Comma = comma
+	return csvReader
+}
+
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	csvReader := newReader(cfg)
+
+	// Records are rows, or columns with first-column-header
+	firstColHeader := cfg.GetExtensionBool("first-column-header", false)
+	var records [][]string
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read CSV: %w", err)
+		}
+		index := len(records) - 1
+		if firstColHeader {
+			index = 0
+		}
+		if err := cfg.CheckRow(index, record); err != nil {
+			return err
+		}
+		records = append(records, record)
 	}
 
 	if len(records) == 0 {
@@ -42,7 +114,6 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 	}
 
 	// Handle first-column-header option
-	firstColHeader := cfg.GetExtensionBool("first-column-header", false)
 	if firstColHeader {
 		// Find max column count across all records
 		maxCols := 0
@@ -60,6 +131,8 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 			if len(records) > 1 {
 				table.Rows = records[1:]
 			}
+		} else if maxCols-1 > common.MaxGridCells/len(records) {
+			return fmt.Errorf("table of %d rows and %d columns exceeds the limit of %d cells", maxCols-1, len(records), common.MaxGridCells)
 		} else {
 			// Multi-column: transpose
 			// Use first column as headers
@@ -100,46 +173,110 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 	return nil
 }
 
-func Marshal(cfg *common.Config, table *common.Table) error {
-	// Write UTF-8 BOM
-	bom := cfg.GetExtensionBool("bom", false)
-	if bom {
-		if _, err := cfg.Writer.Write([]byte{0xEF, 0xBB, 0xBF}); err != nil {
-			return fmt.Errorf("failed to write BOM: %w", err)
+// rowReader streams CSV records, the first record holds the headers
+type rowReader struct {
+	reader  *csv.Reader
+	columns []common.Column
+}
+
+// NewRowReader returns a streaming CSV reader. The header record is read immediately.
+// first-column-header needs the whole file and is not supported.
+func NewRowReader(cfg *common.Config) (common.RowReader, error) {
+	if cfg.GetExtensionBool("first-column-header", false) {
+		return nil, common.ErrStreamNotSupported
+	}
+
+	csvReader := newReader(cfg)
+	headers, err := csvReader.Read()
+	if err == io.EOF {
+		return nil, &common.ParseError{
+			LineNumber: 0,
+			Message:    "empty CSV file",
+			Line:       "",
 		}
 	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+	return &rowReader{reader: csvReader, columns: common.NewColumns(headers)}, nil
+}
+
+func (r *rowReader) Columns() []common.Column {
+	return r.columns
+}
+
+func (r *rowReader) Next() ([]string, error) {
+	record, err := r.reader.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+	return record, nil
+}
+
+// rowWriter writes CSV records as they arrive
+type rowWriter struct {
+	cfg    *common.Config
+	writer *csv.Writer
+}
 
+// NewRowWriter returns a streaming CSV writer
+func NewRowWriter(cfg *common.Config) (common.RowWriter, error) {
 	// Config CSV writer
 	csvWriter := csv.NewWriter(cfg.Writer)
+	csvWriter.Comma = delimiter(cfg)
+	return &rowWriter{cfg: cfg, writer: csvWriter}, nil
+}
 
-	// Set custom delimiter (default: comma)
-	delimiter := cfg.GetExtensionString("delimiter", ",")
-	switch delimiter {
-	case "TAB", "\t":
-		csvWriter.Comma = '\t'
-	case "SEMICOLON", ";":
-		csvWriter.Comma = ';'
-	case "PIPE", "|":
-		csvWriter.Comma = '|'
-	case "SLASH", "/":
-		csvWriter.Comma = '/'
-	case "HASH", "#":
-		csvWriter.Comma = '#'
-		// default remains comma
+func (w *rowWriter) WriteHeader(columns []common.Column) error {
+	// Write UTF-8 BOM
+	if w.cfg.GetExtensionBool("bom", false) {
+		if _, err := w.cfg.Writer.Write([]byte{0xEF, 0xBB, 0xBF}); err != nil {
+			return fmt.Errorf("failed to write BOM: %w", err)
+		}
 	}
 
-	// Default behavior: first row is headers
-	if err := csvWriter.Write(table.Headers); err != nil {
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = column.Name
+	}
+	if err := w.writer.Write(headers); err != nil {
 		return fmt.Errorf("failed to write headers: %w", err)
 	}
+	return nil
+}
+
+func (w *rowWriter) WriteRow(row []string) error {
+	if err := w.writer.Write(row); err != nil {
+		return fmt.Errorf("failed to write row: %w", err)
+	}
+	return nil
+}
+
+func (w *rowWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func Marshal(cfg *common.Config, table *common.Table) error {
+	writer, err := NewRowWriter(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Default behavior: first row is headers
+	if err := writer.WriteHeader(table.HeaderColumns()); err != nil {
+		return err
+	}
 
 	// Write data rows
 	for _, row := range table.Rows {
-		if err := csvWriter.Write(row); err != nil {
-			return fmt.Errorf("failed to write row: %w", err)
+		if err := writer.WriteRow(row); err != nil {
+			return err
 		}
 	}
 
-	csvWriter.Flush()
-	return csvWriter.Error()
+	return writer.Close()
 }
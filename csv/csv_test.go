@@ -2,6 +2,7 @@ package csv
 
 import (
 	"bytes"
+	"io"
 	"strings"
 	"testing"
 
@@ -438,3 +439,75 @@ func TestMarshalWithSpecialCharacters(t *testing.T) {
 	// So "Hates; semicolons" may not be quoted
 	assert.Contains(t, output, "Hates; semicolons")
 }
+
+func TestRowReaderAndWriter(t *testing.T) {
+	cfg := &common.Config{
+		Reader:    strings.NewReader("name;age\nAlice;30\nBob;25\n"),
+		Extension: map[string]string{"delimiter": "SEMICOLON"},
+	}
+	reader, err := NewRowReader(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, common.NewColumns([]string{"name", "age"}), reader.Columns())
+
+	var buf bytes.Buffer
+	writer, err := NewRowWriter(&common.Config{Writer: &buf})
+	assert.NoError(t, err)
+	assert.NoError(t, writer.WriteHeader(reader.Columns()))
+	for {
+		row, err := reader.Next()
+		if err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+		assert.NoError(t, writer.WriteRow(row))
+	}
+	assert.NoError(t, writer.Close())
+
+	assert.Equal(t, "name,age\nAlice,30\nBob,25\n", buf.String())
+}
+
+func TestRowReaderErrors(t *testing.T) {
+	// first-column-header needs the whole file
+	_, err := NewRowReader(&common.Config{
+		Reader:    strings.NewReader("a,b\n1,2\n"),
+		Extension: map[string]string{"first-column-header": "true"},
+	})
+	assert.ErrorIs(t, err, common.ErrStreamNotSupported)
+
+	_, err = NewRowReader(&common.Config{Reader: strings.NewReader("")})
+	var parseErr *common.ParseError
+	assert.ErrorAs(t, err, &parseErr)
+}
+
+func TestUnmarshalSniffedDelimiter(t *testing.T) {
+	tests := map[string]string{
+		"semicolon": "name;age\nAlice;30\nBob;25\n",
+		"tab":       "name\tage\nAlice\t30\nBob\t25\n",
+	}
+	for name, input := range tests {
+		table := &common.Table{}
+		assert.NoError(t, Unmarshal(&common.Config{Reader: strings.NewReader(input), Extension: map[string]string{}}, table), name)
+		assert.Equal(t, []string{"name", "age"}, table.Headers, name)
+		assert.Equal(t, [][]string{{"Alice", "30"}, {"Bob", "25"}}, table.Rows, name)
+	}
+
+	// A given delimiter is kept
+	table := &common.Table{}
+	cfg := &common.Config{Reader: strings.NewReader("a;b\n1;2\n"), Extension: map[string]string{"delimiter": "COMMA"}}
+	assert.NoError(t, Unmarshal(cfg, table))
+	assert.Equal(t, []string{"a;b"}, table.Headers)
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, common.DetectLikely, detect("a,b\n1,2\n"))
+	assert.Equal(t, common.DetectLikely, detect("a;b\n1;2\n"))
+	assert.Equal(t, common.DetectLikely, detect("a\tb\n1\t2\n"))
+
+	// Field counts that differ across lines, as in prose, quotes the reader
+	// fails on, and text without delimiters
+	assert.Equal(t, common.DetectNone, detect("a,b\n1,2,3\n"))
+	assert.Equal(t, common.DetectNone, detect("hello, world\nthis is prose.\n"))
+	assert.Equal(t, common.DetectNone, detect("a,b\n\"1,2\n"))
+	assert.Equal(t, common.DetectNone, detect("a b\n1 2\n"))
+	assert.Equal(t, common.DetectNone, detect("a;b\n1;2;3\n"))
+}
@@ -0,0 +1,61 @@
+package csv
+
+import (
+	"encoding/csv"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "csv",
+		Description: "Comma-Separated Values",
+		Extensions:  []string{".csv"},
+		Detect:      detect,
+		DetectOrder: 6,
+		Params: []common.FormatParam{
+			{Name: "first-column-header", DefaultValue: "false", AllowedValues: "true, false", Description: "Use first column as headers"},
+			{Name: "bom", DefaultValue: "false", AllowedValues: "true, false", Description: "Add Byte Order Mark"},
+			{Name: "delimiter", DefaultValue: ",", AllowedValues: "COMMA, TAB, SEMICOLON, PIPE, SLASH, HASH", Description: "Value Delimiter",
+				Validate: validateDelimiter},
+		},
+		Unmarshal:    Unmarshal,
+		Marshal:      Marshal,
+		NewRowReader: NewRowReader,
+		NewRowWriter: NewRowWriter,
+	})
+}
+
+// detect scores content whose first lines split into the same number of comma,
+// semicolon or tab separated fields
+func detect(sample string) int {
+	lines := sampleLines(sample)
+	comma := sniffDelimiter(lines)
+	if comma == 0 || strings.ContainsRune(sample, 0) {
+		return common.DetectNone
+	}
+
+	reader := csv.NewReader(strings.NewReader(strings.Join(lines, "\n")))
+	reader.Comma = comma
+	records, err := reader.ReadAll()
+	switch {
+	case err != nil:
+		// Prose with a comma splits into ragged fields, and the reader fails on
+		// the same quotes
+		return common.DetectNone
+	case len(records) > 1:
+		return common.DetectLikely
+	default:
+		return 30
+	}
+}
+
+// validateDelimiter accepts a delimiter name or the delimiter character itself
+func validateDelimiter(value string) error {
+	switch value {
+	case ",", "\t", ";", "|", "/", "#":
+		return nil
+	}
+	return common.OneOf("COMMA", "TAB", "SEMICOLON", "PIPE", "SLASH", "HASH")(value)
+}
@@ -0,0 +1,264 @@
+package dokuwiki
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// rowSpan is the cell text that merges a cell with the one above it
+const rowSpan = ":::"
+
+// unformattedPairs are the markups whose text is kept as it is
+var unformattedPairs = [][2]string{{"%%", "%%"}, {"<nowiki>", "</nowiki>"}}
+
+// protectedPairs are the markups whose text may hold "|" or "^" without ending the cell
+var protectedPairs = append([][2]string{{"[[", "]]"}, {"{{", "}}"}}, unformattedPairs...)
+
+// escaper writes the text that would end a cell or start a line break or unformatted text as unformatted text
+var escaper = strings.NewReplacer("%%", "<nowiki>%%</nowiki>", "<nowiki>", "%%<nowiki>%%", "|", "%%|%%", "^", "%%^%%", `\\`, `%%\\%%`, "\r\n", `\\ `, "\n", `\\ `)
+
+// markup returns the text inside the first of pairs s starts with and the length
+// of the whole markup, 0 if s starts with none of them or it is not closed
+func markup(s string, pairs [][2]string) (string, int) {
+	for _, pair := range pairs {
+		if !strings.HasPrefix(s, pair[0]) {
+			continue
+		}
+		if end := strings.Index(s[len(pair[0]):], pair[1]); end >= 0 {
+			return s[len(pair[0]) : len(pair[0])+end], len(pair[0]) + end + len(pair[1])
+		}
+	}
+	return "", 0
+}
+
+// splitRow splits a row into the raw text of its cells. "|" and "^" inside links,
+// media and unformatted text are part of the cell, text after the last delimiter is left out.
+func splitRow(line string) []string {
+	var cells []string
+	var value strings.Builder
+	started := false
+	for i := 0; i < len(line); {
+		if _, n := markup(line[i:], protectedPairs); n > 0 {
+			value.WriteString(line[i : i+n])
+			i += n
+			continue
+		}
+		if line[i] == '|' || line[i] == '^' {
+			if started {
+				cells = append(cells, value.String())
+			}
+			value.Reset()
+			started = true
+		} else {
+			value.WriteByte(line[i])
+		}
+		i++
+	}
+	return cells
+}
+
+// unescape reads the text of a cell: %%unformatted%% and <nowiki> text is kept
+// as it is and "\\" followed by a space or the end of the cell is a line break
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if text, n := markup(s[i:], unformattedPairs); n > 0 {
+			b.WriteString(text)
+			i += n
+			continue
+		}
+		if strings.HasPrefix(s[i:], `\\`) && (i+2 == len(s) || s[i+2] == ' ') {
+			b.WriteString("\n")
+			i += min(3, len(s)-i)
+			continue
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// readTable reads the next table of the input, its first row being the header.
+// An empty cell ("||") widens the cell before it and a ":::" cell merges with the
+// cell above it. Alignment given by cell padding is not kept.
+func readTable(cfg *common.Config, scanner *bufio.Scanner, lineNumber *int) (*common.Table, error) {
+	var rows [][]common.SpanCell
+	origins := make(map[int][2]int) // column -> row and index of the cell covering it
+	for scanner.Scan() {
+		*lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "|") && !strings.HasPrefix(line, "^") {
+			if len(rows) > 0 {
+				break
+			}
+			continue
+		}
+		raws := splitRow(line)
+		if len(raws) == 0 {
+			return nil, &common.ParseError{LineNumber: *lineNumber, Message: "row contains no cells", Line: line}
+		}
+
+		r := len(rows)
+		var cells []common.SpanCell
+		col, merged := 0, false
+		for _, raw := range raws {
+			origin, above := origins[col]
+			switch {
+			case raw == "" && (len(cells) > 0 || merged):
+				// The cell before spans this column too
+				if !merged {
+					cells[len(cells)-1].ColSpan = max(cells[len(cells)-1].ColSpan, 1) + 1
+					origins[col] = [2]int{r, len(cells) - 1}
+				}
+			case strings.TrimSpace(raw) == rowSpan && above && origin[0] < r:
+				spanned := &rows[origin[0]][origin[1]]
+				spanned.RowSpan = max(spanned.RowSpan, 1) + 1
+				merged = true
+			default:
+				cells = append(cells, common.SpanCell{Value: unescape(raw)})
+				origins[col] = [2]int{r, len(cells) - 1}
+				merged = false
+			}
+			col++
+		}
+		// The first row is the header
+		if err := cfg.CheckSpanRow(r-1, cells); err != nil {
+			return nil, err
+		}
+		rows = append(rows, cells)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	grid, spans, _, err := common.LayoutSpans(rows)
+	if err != nil {
+		return nil, err
+	}
+	table := &common.Table{}
+	table.SetGrid(grid, spans)
+	return table, nil
+}
+
+// Unmarshal reads the first table of the input
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Unmarshal: target table pointer cannot be nil")
+	}
+	tables, err := common.ScanTables(cfg, 1, readTable)
+	if err != nil {
+		return err
+	}
+	*table = *tables[0]
+	return nil
+}
+
+// UnmarshalDocument reads every table of the input, tables being separated by other lines
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	tables, err := common.ScanTables(cfg, 0, readTable)
+	if err != nil {
+		return err
+	}
+	doc.Tables = append(doc.Tables, tables...)
+	return nil
+}
+
+// pad fills a cell to width display columns. DokuWiki reads two or more spaces
+// on the left of a cell as right alignment and on both sides as centered.
+func pad(s string, width int, align string) string {
+	switch align {
+	case "c":
+		return common.PadAlign(s, width, align)
+	case "r":
+		return common.PadAlign(s, width-1, align) + " "
+	default:
+		return " " + common.PadAlign(s, width-1, align)
+	}
+}
+
+// Marshal writes a "^ header ^" row followed by "| cell |" rows, padding cells to
+// the alignment of their column. A merged cell is followed by an empty cell for
+// each column it covers and by ":::" cells in the rows below.
+func Marshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Marshal: input table pointer cannot be nil")
+	}
+	if len(table.Headers) == 0 {
+		return fmt.Errorf("Marshal: table must have at least one header")
+	}
+	for i, row := range table.Rows {
+		if len(row) != len(table.Headers) {
+			return fmt.Errorf("Marshal: row %d has %d columns, but table has %d", i, len(row), len(table.Headers))
+		}
+	}
+
+	aligns := cfg.ColumnAligns(len(table.Headers))
+
+	layout := table.SpanLayout()
+	rows := append([][]string{table.Headers}, table.Rows...)
+	cells := make([][]string, len(rows))
+	widths := make([]int, len(table.Headers))
+	for r, row := range rows {
+		cells[r] = make([]string, len(row))
+		for col, value := range row {
+			value = escaper.Replace(value)
+			if value == rowSpan {
+				value = "%%" + rowSpan + "%%"
+			}
+			cells[r][col] = value
+			if span, ok := layout.CoveredBy(r-1, col); ok {
+				if span.Col == col && span.ColSpan == 1 {
+					widths[col] = max(widths[col], len(rowSpan))
+				}
+			} else if span, ok := layout.At(r-1, col); !ok || span.ColSpan == 1 {
+				widths[col] = max(widths[col], runewidth.StringWidth(value))
+			}
+		}
+	}
+	// Cells of right aligned and centered columns need two spaces on the left
+	slots := make([]int, len(widths))
+	for i, width := range widths {
+		slots[i] = width + 2
+		if aligns[i] != "l" {
+			slots[i] = width + 4
+		}
+	}
+
+	var b strings.Builder
+	for r, row := range cells {
+		delimiter := "|"
+		if r == 0 {
+			delimiter = "^"
+		}
+		b.WriteString(delimiter)
+		for col := 0; col < len(row); col++ {
+			value, n := row[col], 1
+			if span, ok := layout.CoveredBy(r-1, col); ok {
+				if span.Col != col {
+					// Written with the delimiters of the merged cell
+					continue
+				}
+				value, n = rowSpan, span.ColSpan
+			} else if span, ok := layout.At(r-1, col); ok {
+				n = span.ColSpan
+			}
+			width := 0
+			for _, slot := range slots[col : col+n] {
+				width += slot
+			}
+			b.WriteString(pad(value, width, aligns[col]) + strings.Repeat(delimiter, n))
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(cfg.Writer, b.String())
+	return err
+}
@@ -0,0 +1,241 @@
+package dokuwiki
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	// The other wiki dialects, scored against this one by TestDetect
+	_ "github.com/martianzhang/tableconvert/bbcode"
+	_ "github.com/martianzhang/tableconvert/jira"
+	_ "github.com/martianzhang/tableconvert/markdown"
+	_ "github.com/martianzhang/tableconvert/mediawiki"
+	_ "github.com/martianzhang/tableconvert/textile"
+	_ "github.com/martianzhang/tableconvert/tracwiki"
+	_ "github.com/martianzhang/tableconvert/twiki"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalPage(t *testing.T) {
+	// Shaped like a table of a DokuWiki page, with a heading and text around it
+	input := `===== Servers =====
+^ Host                 ^ Role    ^ Notes           ^
+| [[web1|Web server]]  | web     | %%a|b%%         |
+^ db1                  | :::     | line 1\\ line 2 |
+| spans two columns           || <nowiki>^</nowiki> |
+
+Text below the table
+`
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Host", "Role", "Notes"}, table.Headers)
+	assert.Equal(t, [][]string{
+		{"[[web1|Web server]]", "web", "a|b"},
+		{"db1", "", "line 1\nline 2"},
+		{"spans two columns", "", "^"},
+	}, table.Rows)
+	assert.Equal(t, []common.Span{
+		{Row: 0, Col: 1, RowSpan: 2, ColSpan: 1},
+		{Row: 2, Col: 0, RowSpan: 1, ColSpan: 2},
+	}, table.Spans)
+}
+
+func TestUnmarshalDocument(t *testing.T) {
+	// A ":::" cell extends the header of its own table, not a cell of the first one
+	input := "^ Host ^ Role ^\n| web1 | web |\n\n^ Name ^\n| ::: |\n"
+	var doc common.Document
+	err := UnmarshalDocument(&common.Config{Reader: strings.NewReader(input)}, &doc)
+	assert.NoError(t, err)
+	require.Len(t, doc.Tables, 2)
+	assert.Equal(t, []string{"Host", "Role"}, doc.Tables[0].Headers)
+	assert.Equal(t, [][]string{{"web1", "web"}}, doc.Tables[0].Rows)
+	assert.Equal(t, []string{"Name"}, doc.Tables[1].Headers)
+	assert.Equal(t, [][]string{{""}}, doc.Tables[1].Rows)
+	assert.Equal(t, []common.Span{{Row: -1, Col: 0, RowSpan: 2, ColSpan: 1}}, doc.Tables[1].Spans)
+
+	// Unmarshal reads the first table only
+	table := &common.Table{}
+	err = Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, doc.Tables[0].Rows, table.Rows)
+}
+
+func TestUnmarshalWithRowHeadersAndAlignment(t *testing.T) {
+	// "^" starts a header cell anywhere, the padding that aligns a cell is not text
+	input := "^       ^ Q1 ^ Q2 ^\n^ north |  right| center  |\n"
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "Q1", "Q2"}, table.Headers)
+	assert.Equal(t, [][]string{{"north", "right", "center"}}, table.Rows)
+}
+
+func TestUnmarshalWithMediaAndUnformattedText(t *testing.T) {
+	// The "|" of a {{media|title}} does not separate cells, %% and <nowiki> text is not markup
+	input := "^ logo ^ code ^\n| {{wiki:logo.png?50|A|B}} | %%**x**%% <nowiki>//y//</nowiki> |\n"
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"{{wiki:logo.png?50|A|B}}", "**x** //y//"}}, table.Rows)
+}
+
+func TestUnmarshalWithLineBreaks(t *testing.T) {
+	// "\\" is a line break before a space or the end of the cell only
+	input := "^ a ^ b ^\n| one\\\\ two\\\\ | C:\\\\share |\n"
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"one\ntwo", `C:\\share`}}, table.Rows)
+}
+
+func TestUnmarshalWithRowSpans(t *testing.T) {
+	// ":::" cells extend the merged cell above, including one spanning columns
+	input := "^ a ^ b ^ c ^\n| wide || x |\n| ::: || y |\n| ::: || z |\n"
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"wide", "", "x"}, {"", "", "y"}, {"", "", "z"}}, table.Rows)
+	assert.Equal(t, []common.Span{{Row: 0, Col: 0, RowSpan: 3, ColSpan: 2}}, table.Spans)
+
+	// Without a cell above ":::" is text
+	table = &common.Table{}
+	err = Unmarshal(&common.Config{Reader: strings.NewReader("^ ::: ^ b ^\n| x | y |\n")}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{":::", "b"}, table.Headers)
+	assert.Nil(t, table.Spans)
+}
+
+func TestMarshalWithSpecialCharacters(t *testing.T) {
+	// Markup in cells is written as %% unformatted text, "%%" itself in <nowiki>
+	table := &common.Table{
+		Headers: []string{"case", "cell"},
+		Rows: [][]string{
+			{"pipe", "a|b"},
+			{"caret", "x^2"},
+			{"row span marker", ":::"},
+			{"backslashes", `C:\\share`},
+			{"line break", "two\nlines"},
+			{"unformatted marker", "%%"},
+			{"nowiki tag", "<nowiki>x</nowiki>"},
+			{"link", "[[a|b]]"},
+		},
+	}
+	var buf bytes.Buffer
+	err := Marshal(&common.Config{Writer: &buf}, table)
+	assert.NoError(t, err)
+	expected := `^ case               ^ cell                   ^
+| pipe               | a%%|%%b                |
+| caret              | x%%^%%2                |
+| row span marker    | %%:::%%                |
+| backslashes        | C:%%\\%%share          |
+| line break         | two\\ lines            |
+| unformatted marker | <nowiki>%%</nowiki>    |
+| nowiki tag         | %%<nowiki>%%x</nowiki> |
+| link               | [[a%%|%%b]]            |
+`
+	assert.Equal(t, expected, buf.String())
+
+	// The cells are read back as they were
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: &buf}, got))
+	assert.Equal(t, table.Rows, got.Rows)
+}
+
+func TestMarshalWithSpansAndAlignment(t *testing.T) {
+	// Merged cells are written as empty and ":::" cells, padded to the column alignment
+	table := &common.Table{
+		Headers: []string{"name", "n", "note"},
+		Rows:    [][]string{{"merged", "1", "a|b"}, {"", "22", ":::"}, {"two\nlines", "3", ""}},
+		Spans:   []common.Span{{Row: 0, Col: 0, RowSpan: 2, ColSpan: 1}, {Row: 2, Col: 1, RowSpan: 1, ColSpan: 2}},
+	}
+	var buf bytes.Buffer
+	cfg := &common.Config{Writer: &buf, Extension: map[string]string{"align": "l,r,c"}}
+	err := Marshal(cfg, table)
+	assert.NoError(t, err)
+	expected := `^ name        ^    n ^   note    ^
+| merged      |    1 |  a%%|%%b  |
+| :::         |   22 |  %%:::%%  |
+| two\\ lines |               3 ||
+`
+	assert.Equal(t, expected, buf.String())
+
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: &buf}, got))
+	assert.Equal(t, table.Headers, got.Headers)
+	assert.Equal(t, table.Rows, got.Rows)
+	assert.Equal(t, table.Spans, got.Spans)
+}
+
+func TestUnmarshalErrorCases(t *testing.T) {
+	t.Run("nil table", func(t *testing.T) {
+		err := Unmarshal(&common.Config{Reader: strings.NewReader("^ a ^\n")}, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("no table", func(t *testing.T) {
+		err := Unmarshal(&common.Config{Reader: strings.NewReader("no table")}, &common.Table{})
+		assert.ErrorContains(t, err, "no table row")
+	})
+
+	t.Run("column span over the limit", func(t *testing.T) {
+		input := "^ a ^\n| x " + strings.Repeat("|", 1001) + "\n"
+		err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, &common.Table{})
+		assert.ErrorContains(t, err, "more than the limit of 1000")
+	})
+}
+
+func TestMarshalErrorCases(t *testing.T) {
+	t.Run("no columns", func(t *testing.T) {
+		err := Marshal(&common.Config{Writer: &bytes.Buffer{}}, &common.Table{})
+		assert.Error(t, err)
+	})
+
+	t.Run("short row", func(t *testing.T) {
+		table := &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1"}}}
+		err := Marshal(&common.Config{Writer: &bytes.Buffer{}}, table)
+		assert.ErrorContains(t, err, "row 0 has 1 columns")
+	})
+}
+
+func TestFixture(t *testing.T) {
+	data, err := os.ReadFile("../test/mysql.dokuwiki")
+	require.NoError(t, err)
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: bytes.NewReader(data)}, table))
+	assert.Equal(t, []string{"FIELD", "TYPE", "NULL", "KEY", "DEFAULT", "EXTRA"}, table.Headers)
+	assert.Len(t, table.Rows, 3)
+	assert.Equal(t, []string{"username", "varchar(10)", "NO", "", "NULL", ""}, table.Rows[1])
+
+	// The header row is written with "^" cells, padded like the data rows
+	var buf bytes.Buffer
+	require.NoError(t, Marshal(&common.Config{Writer: &buf}, table))
+	assert.Equal(t, string(data), buf.String())
+}
+
+func TestDetect(t *testing.T) {
+	fixture, err := os.ReadFile("../test/mysql.dokuwiki")
+	require.NoError(t, err)
+	// Against the other wiki dialects imported above, "^" header cells, even
+	// starting a row, or ":::" cells are DokuWiki
+	inputs := []string{string(fixture), "^ a | b |\n| 1 | 2 |", "| a | b |\n| ::: | 2 |"}
+	for _, input := range inputs {
+		scores := common.ScoreFormats([]byte(input))
+		require.NotEmpty(t, scores, input)
+		assert.Equal(t, "dokuwiki", scores[0].Format, input)
+	}
+	// Only "^" cells tell DokuWiki from every other pipe table
+	assert.Greater(t, detect(inputs[1]), detect(inputs[2]))
+
+	// The other wiki dialects also write cells between pipes
+	for _, name := range []string{"mysql.md", "mysql.jira", "mysql.textile", "mysql.tracwiki", "mysql.twiki"} {
+		other, err := os.ReadFile("../test/" + name)
+		require.NoError(t, err)
+		assert.Equal(t, common.DetectNone, detect(string(other)), name)
+	}
+}
@@ -0,0 +1,41 @@
+package dokuwiki
+
+import (
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "dokuwiki",
+		Description: "DokuWiki table",
+		Detect:      detect,
+		DetectOrder: 19,
+		Params: []common.FormatParam{
+			common.AlignParam,
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+	})
+}
+
+// detect scores content starting with a "^ Header ^" row, or "|" rows holding a ":::" row span
+func detect(sample string) int {
+	lines := common.SampleLines(sample, 20)
+	if len(lines) == 0 {
+		return common.DetectNone
+	}
+	first := strings.TrimSpace(lines[0])
+	if strings.HasPrefix(first, "^") && (strings.HasSuffix(first, "^") || strings.HasSuffix(first, "|")) && len(first) > 1 {
+		return 95
+	}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "|") && strings.Contains(line, "| ::: ") {
+			return 60
+		}
+	}
+	return common.DetectNone
+}
@@ -2,6 +2,8 @@ package excel
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/martianzhang/tableconvert/common"
 
@@ -10,7 +12,7 @@ import (
 
 func Unmarshal(cfg *common.Config, table *common.Table) error {
 	// Open Excel file
-	f, err := excelize.OpenFile(cfg.File)
+	f, err := openWorkbook(cfg)
 	if err != nil {
 		return err
 	}
@@ -22,13 +24,83 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 		return fmt.Errorf("empty Excel file: no sheets found")
 	}
 
-	// Get all rows
-	rows, err := f.GetRows(sheetName)
+	return readSheet(cfg, f, sheetName, table)
+}
+
+// UnmarshalDocument reads every sheet of the workbook as a table named after the sheet
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	f, err := openWorkbook(cfg)
 	if err != nil {
 		return err
 	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return fmt.Errorf("empty Excel file: no sheets found")
+	}
+
+	for _, sheetName := range sheets {
+		table := &common.Table{Name: sheetName}
+		if err := readSheet(cfg, f, sheetName, table); err != nil {
+			return fmt.Errorf("sheet %q: %w", sheetName, err)
+		}
+		doc.Tables = append(doc.Tables, table)
+	}
+	return nil
+}
+
+// openWorkbook reads the workbook from cfg.Reader, or opens cfg.File when no reader is given
+func openWorkbook(cfg *common.Config) (*excelize.File, error) {
+	if cfg.Reader != nil {
+		return excelize.OpenReader(cfg.Reader)
+	}
+	return excelize.OpenFile(cfg.File)
+}
 
+// saveWorkbook saves the workbook to cfg.Result, or writes it to cfg.Writer when no file is given
+func saveWorkbook(cfg *common.Config, f *excelize.File) error {
+	if cfg.Result == "" && cfg.Writer != nil {
+		return f.Write(cfg.Writer)
+	}
+	return f.SaveAs(cfg.Result)
+}
+
+// readSheet fills table from the rows of one worksheet
+func readSheet(cfg *common.Config, f *excelize.File, sheetName string, table *common.Table) error {
 	useFirstColAsHeader := cfg.GetExtensionBool("first-column-header", false)
+
+	// Get all rows, checking each as it is read
+	iter, err := f.Rows(sheetName)
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+	var rows [][]string
+	last := 0 // number of rows up to the last one holding a cell
+	for iter.Next() {
+		row, err := iter.Columns()
+		if err != nil {
+			return err
+		}
+		// The first row is the header, or the first column with first-column-header
+		index := len(rows) - 1
+		if useFirstColAsHeader {
+			index = 0
+		}
+		if err := cfg.CheckRow(index, row); err != nil {
+			return err
+		}
+		rows = append(rows, row)
+		if len(row) > 0 {
+			last = len(rows)
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	rows = rows[:last]
+
 	if useFirstColAsHeader {
 		if len(rows) > 0 {
 			// 1. Process headers and calculate max number of data columns
@@ -56,26 +128,118 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 			}
 		}
 	} else {
-		// Process headers
+		// Merged ranges become spans, the header row is row -1
+		mergeCells, err := f.GetMergeCells(sheetName)
+		if err != nil {
+			return err
+		}
+		width := 0 // the rightmost column a merged range ends at
+		for _, mergeCell := range mergeCells {
+			startCol, startRow, err := excelize.CellNameToCoordinates(mergeCell.GetStartAxis())
+			if err != nil {
+				return err
+			}
+			endCol, endRow, err := excelize.CellNameToCoordinates(mergeCell.GetEndAxis())
+			if err != nil {
+				return err
+			}
+			table.Spans = append(table.Spans, common.Span{
+				Row:     startRow - 2,
+				Col:     startCol - 1,
+				RowSpan: endRow - startRow + 1,
+				ColSpan: endCol - startCol + 1,
+			})
+			width = max(width, endCol)
+		}
+
+		// Rows drop their trailing blank cells, so the cells covered by a merged
+		// header read back short. Headers and rows are padded to the widest of them.
 		if len(rows) > 0 {
+			if len(rows[0]) < width {
+				rows[0] = append(rows[0], make([]string, width-len(rows[0]))...)
+			}
+			if err := common.PadRows(rows); err != nil {
+				return err
+			}
 			table.Headers = rows[0]
+			table.Rows = append(table.Rows, rows[1:]...)
 		}
 
-		// Process data rows
-		for i := 1; i < len(rows); i++ {
-			row := rows[i]
-			// Ensure each row has the same number of columns as headers
-			if len(row) < len(table.Headers) {
-				// Fill missing cells with empty strings
-				row = append(row, make([]string, len(table.Headers)-len(row))...)
+		// Collect column types from the cell types stored in the sheet. Cells
+		// covered by a merged cell hold no value of their own.
+		layout := table.SpanLayout()
+		table.Columns = common.NewColumns(table.Headers)
+		for r, row := range table.Rows {
+			for c := range table.Columns {
+				if layout.Covered(r, c) {
+					continue
+				}
+				if c >= len(row) || row[c] == "" {
+					table.Columns[c].Nullable = true
+					continue
+				}
+				colType, sourceType, err := cellType(f, sheetName, c+1, r+2)
+				if err != nil {
+					return err
+				}
+				table.Columns[c].Observe(colType, sourceType)
+			}
+		}
+		for c := range table.Columns {
+			if table.Columns[c].Type == common.ColumnTypeUnknown {
+				table.Columns[c].Type = common.ColumnTypeString
+			}
+		}
+
+		// Blank cells of number, date or bool columns hold no value, they are NULL.
+		// Blank cells of text columns, and cells covered by a merged cell, stay empty strings.
+		table.TrackNulls()
+		for r, row := range table.Rows {
+			for c, value := range row {
+				if value == "" && c < len(table.Columns) && table.Columns[c].Type != common.ColumnTypeString && !layout.Covered(r, c) {
+					table.SetNull(r, c)
+					row[c] = common.DefaultNullString
+				}
 			}
-			table.Rows = append(table.Rows, row)
 		}
 	}
 
 	return nil
 }
 
+// cellType maps the stored type of a worksheet cell to a column type
+func cellType(f *excelize.File, sheetName string, col, row int) (common.ColumnType, string, error) {
+	cell, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		return common.ColumnTypeUnknown, "", err
+	}
+	t, err := f.GetCellType(sheetName, cell)
+	if err != nil {
+		return common.ColumnTypeUnknown, "", err
+	}
+	switch t {
+	case excelize.CellTypeBool:
+		return common.ColumnTypeBool, "bool", nil
+	case excelize.CellTypeDate:
+		return common.ColumnTypeDate, "date", nil
+	case excelize.CellTypeNumber, excelize.CellTypeUnset:
+		// Numeric cells usually omit the type attribute
+		raw, err := f.GetCellValue(sheetName, cell, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return common.ColumnTypeUnknown, "", err
+		}
+		if _, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return common.ColumnTypeInt, "number", nil
+		}
+		if _, err := strconv.ParseFloat(raw, 64); err == nil {
+			return common.ColumnTypeFloat, "number", nil
+		}
+		return common.ColumnTypeString, "number", nil
+	default:
+		return common.ColumnTypeString, "string", nil
+	}
+}
+
 func Marshal(cfg *common.Config, table *common.Table) error {
 	f := excelize.NewFile()
 
@@ -87,6 +251,66 @@ func Marshal(cfg *common.Config, table *common.Table) error {
 	}
 	f.SetActiveSheet(index)
 
+	if err := writeSheet(cfg, f, sheetName, table); err != nil {
+		return err
+	}
+	return saveWorkbook(cfg, f)
+}
+
+// MarshalDocument writes each table of the document to its own worksheet
+func MarshalDocument(cfg *common.Config, doc *common.Document) error {
+	f := excelize.NewFile()
+
+	names := doc.UniqueTableNames(sheetNameFor)
+	for i, table := range doc.Tables {
+		sheetName := names[i]
+		if i == 0 {
+			// Reuse the default sheet of the new workbook
+			if err := f.SetSheetName(f.GetSheetName(0), sheetName); err != nil {
+				return err
+			}
+		} else if _, err := f.NewSheet(sheetName); err != nil {
+			return err
+		}
+		if err := writeSheet(cfg, f, sheetName, table); err != nil {
+			return fmt.Errorf("sheet %q: %w", sheetName, err)
+		}
+	}
+	f.SetActiveSheet(0)
+
+	return saveWorkbook(cfg, f)
+}
+
+// sheetNameFor makes a valid worksheet name ending with suffix: at most 31
+// characters and none of the characters []:*?/\
+func sheetNameFor(name, suffix string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, "'")
+	if name == "" {
+		name = "Sheet"
+	}
+
+	return truncateRunes(name, maxSheetNameLength-len(suffix)) + suffix
+}
+
+// maxSheetNameLength is the longest worksheet name Excel accepts
+const maxSheetNameLength = 31
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
+
+// writeSheet writes the headers and rows of table to a worksheet
+func writeSheet(cfg *common.Config, f *excelize.File, sheetName string, table *common.Table) error {
 	// Auto-width configuration
 	autoWidth := cfg.GetExtensionBool("auto-width", false)
 
@@ -120,23 +344,50 @@ func Marshal(cfg *common.Config, table *common.Table) error {
 
 	for rowIndex, row := range table.Rows {
 		for colIndex, value := range row {
+			// NULL cells are left blank
+			if table.IsNull(rowIndex, colIndex) {
+				continue
+			}
+
 			cell, err := excelize.CoordinatesToCellName(colIndex+1, rowIndex+2)
 			if err != nil {
 				return err
 			}
 
-			if textFormat {
+			// Typed numeric and boolean columns are stored as native cell values
+			typed := common.TypedValue(value, table.ColumnType(colIndex))
+			if _, isString := typed.(string); isString && textFormat {
 				if err := f.SetCellStyle(sheetName, cell, cell, textStyleID); err != nil {
 					return err
 				}
 			}
 
-			if err := f.SetCellValue(sheetName, cell, value); err != nil {
+			if err := f.SetCellValue(sheetName, cell, typed); err != nil {
 				return err
 			}
 		}
 	}
 
+	// Merge the ranges of merged cells, the header row is row 1
+	layout := table.SpanLayout()
+	for _, merged := range table.Spans {
+		span, ok := layout.At(merged.Row, merged.Col)
+		if !ok {
+			continue
+		}
+		topLeft, err := excelize.CoordinatesToCellName(span.Col+1, span.Row+2)
+		if err != nil {
+			return err
+		}
+		bottomRight, err := excelize.CoordinatesToCellName(span.Col+span.ColSpan, span.Row+span.RowSpan+1)
+		if err != nil {
+			return err
+		}
+		if err := f.MergeCell(sheetName, topLeft, bottomRight); err != nil {
+			return err
+		}
+	}
+
 	// Auto adjust column widths if enabled
 	if autoWidth {
 		for colIndex := range table.Headers {
@@ -150,5 +401,5 @@ func Marshal(cfg *common.Config, table *common.Table) error {
 		}
 	}
 
-	return f.SaveAs(cfg.Result)
+	return nil
 }
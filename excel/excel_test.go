@@ -1,10 +1,13 @@
 package excel
 
 import (
+	"bytes"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/martianzhang/tableconvert/common"
+	"github.com/martianzhang/tableconvert/html"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -41,6 +44,80 @@ func TestMarshalAndUnmarshal(t *testing.T) {
 	_ = os.Remove(cfg.Result)
 }
 
+func TestMarshalAndUnmarshalTypedColumns(t *testing.T) {
+	testFile := "test_typed.xlsx"
+	defer os.Remove(testFile)
+
+	cfg := &common.Config{
+		To:     "xlsx",
+		Result: testFile,
+	}
+
+	table := &common.Table{
+		Headers: []string{"Name", "Age", "Score"},
+		Rows: [][]string{
+			{"Alice", "30", "9.5"},
+			{"Bob", "25", ""},
+		},
+		Columns: []common.Column{
+			{Name: "Name", Type: common.ColumnTypeString},
+			{Name: "Age", Type: common.ColumnTypeInt},
+			{Name: "Score", Type: common.ColumnTypeFloat},
+		},
+	}
+
+	err := Marshal(cfg, table)
+	assert.NoError(t, err)
+
+	cfg2 := &common.Config{
+		From: "xlsx",
+		File: testFile,
+	}
+	table2 := &common.Table{}
+	err = Unmarshal(cfg2, table2)
+	assert.NoError(t, err)
+
+	// The blank float cell reads back as NULL
+	assert.Equal(t, [][]string{{"Alice", "30", "9.5"}, {"Bob", "25", "NULL"}}, table2.Rows)
+	assert.True(t, table2.IsNull(1, 2))
+	assert.Equal(t, common.ColumnTypeString, table2.ColumnType(0))
+	assert.Equal(t, common.ColumnTypeInt, table2.ColumnType(1))
+	assert.Equal(t, common.ColumnTypeFloat, table2.ColumnType(2))
+	assert.True(t, table2.Columns[2].Nullable)
+}
+
+func TestMarshalAndUnmarshalNulls(t *testing.T) {
+	testFile := "test_nulls.xlsx"
+	defer os.Remove(testFile)
+
+	table := &common.Table{
+		Headers: []string{"Name", "Age"},
+		Rows: [][]string{
+			{"NULL", "30"},
+			{"", "NULL"},
+			{"Carol", "40"},
+		},
+		Columns: []common.Column{
+			{Name: "Name", Type: common.ColumnTypeString},
+			{Name: "Age", Type: common.ColumnTypeInt},
+		},
+	}
+	table.SetNull(1, 1)
+
+	err := Marshal(&common.Config{To: "xlsx", Result: testFile}, table)
+	assert.NoError(t, err)
+
+	table2 := &common.Table{}
+	err = Unmarshal(&common.Config{From: "xlsx", File: testFile}, table2)
+	assert.NoError(t, err)
+
+	// The text "NULL" and the blank text cell stay strings, the NULL number is blank
+	assert.Equal(t, table.Rows, table2.Rows)
+	assert.False(t, table2.IsNull(0, 0))
+	assert.False(t, table2.IsNull(1, 0))
+	assert.True(t, table2.IsNull(1, 1))
+}
+
 func TestUnmarshalWithFirstColumnHeader(t *testing.T) {
 	// Create test file
 	testFile := "test_first_col.xlsx"
@@ -384,3 +461,109 @@ func TestUnmarshalWithVariableColumnCount(t *testing.T) {
 	assert.Equal(t, table.Headers, table2.Headers)
 	assert.Equal(t, table.Rows, table2.Rows)
 }
+
+func TestMarshalAndUnmarshalDocument(t *testing.T) {
+	testFile := "test_document.xlsx"
+	defer os.Remove(testFile)
+
+	doc := &common.Document{Tables: []*common.Table{
+		{Name: "Users", Headers: []string{"id", "name"}, Rows: [][]string{{"a", "Alice"}}},
+		{Name: "a/b:c", Headers: []string{"order"}, Rows: [][]string{{"x"}}},
+		{Headers: []string{"empty"}},
+	}}
+
+	err := MarshalDocument(&common.Config{Result: testFile}, doc)
+	assert.NoError(t, err)
+
+	back := &common.Document{}
+	err = UnmarshalDocument(&common.Config{File: testFile}, back)
+	assert.NoError(t, err)
+	assert.Len(t, back.Tables, 3)
+	assert.Equal(t, "Users", back.Tables[0].Name)
+	assert.Equal(t, [][]string{{"a", "Alice"}}, back.Tables[0].Rows)
+	assert.Equal(t, "a_b_c", back.Tables[1].Name)
+	assert.Equal(t, []string{"order"}, back.Tables[1].Headers)
+	assert.Equal(t, "Table3", back.Tables[2].Name)
+	assert.Equal(t, []string{"empty"}, back.Tables[2].Headers)
+}
+
+func TestSheetNameFor(t *testing.T) {
+	assert.Equal(t, "Data", sheetNameFor("Data", ""))
+	assert.Equal(t, "x_y", sheetNameFor("x[y", ""))
+	assert.Equal(t, "Sheet", sheetNameFor("''", ""))
+	assert.Equal(t, "abcdefghijklmnopqrstuvwxyz01234", sheetNameFor("abcdefghijklmnopqrstuvwxyz0123456789", ""))
+	// The name is shortened to keep the suffix
+	assert.Equal(t, "abcdefghijklmnopqrstuvwxyz0 (2)", sheetNameFor("abcdefghijklmnopqrstuvwxyz0123456789", " (2)"))
+
+	doc := &common.Document{Tables: []*common.Table{{Name: "Data"}, {Name: "data"}, {Name: "a/b"}, {Name: "a:b"}}}
+	assert.Equal(t, []string{"Data", "data (2)", "a_b", "a_b (2)"}, doc.UniqueTableNames(sheetNameFor))
+}
+
+func TestMarshalAndUnmarshalSpans(t *testing.T) {
+	testFile := "test_spans.xlsx"
+	defer os.Remove(testFile)
+
+	table := &common.Table{
+		Headers: []string{"Region", "Sales", ""},
+		Rows:    [][]string{{"North", "1", "2"}, {"", "3", "4"}},
+		Spans: []common.Span{
+			{Row: -1, Col: 1, RowSpan: 1, ColSpan: 2},
+			{Row: 0, Col: 0, RowSpan: 2, ColSpan: 1},
+		},
+	}
+	err := Marshal(&common.Config{Result: testFile}, table)
+	assert.NoError(t, err)
+
+	table2 := &common.Table{}
+	err = Unmarshal(&common.Config{File: testFile}, table2)
+	assert.NoError(t, err)
+	assert.Equal(t, table.Rows, table2.Rows)
+	assert.ElementsMatch(t, table.Spans, table2.Spans)
+}
+
+func TestUnmarshalMergedTypedCells(t *testing.T) {
+	testFile := "test_merged_typed.xlsx"
+	defer os.Remove(testFile)
+
+	// A2:A3 is merged, A3 is blank because A2 covers it, not because it is NULL
+	table := &common.Table{
+		Headers: []string{"year", "sales"},
+		Rows:    [][]string{{"2024", "10"}, {"", "20"}},
+		Columns: []common.Column{{Name: "year", Type: common.ColumnTypeInt}, {Name: "sales", Type: common.ColumnTypeInt}},
+		Spans:   []common.Span{{Row: 0, Col: 0, RowSpan: 2, ColSpan: 1}},
+	}
+	assert.NoError(t, Marshal(&common.Config{Result: testFile}, table))
+
+	table2 := &common.Table{}
+	assert.NoError(t, Unmarshal(&common.Config{File: testFile}, table2))
+	assert.Equal(t, [][]string{{"2024", "10"}, {"", "20"}}, table2.Rows)
+	assert.False(t, table2.IsNull(1, 0))
+	assert.Equal(t, common.ColumnTypeInt, table2.ColumnType(0))
+	assert.False(t, table2.Columns[0].Nullable)
+
+	common.ExpandSpans(table2)
+	assert.Equal(t, [][]string{{"2024", "10"}, {"2024", "20"}}, table2.Rows)
+	assert.False(t, table2.IsNull(1, 0))
+}
+
+func TestHTMLColspanRoundTrip(t *testing.T) {
+	testFile := "test_html_colspan.xlsx"
+	defer os.Remove(testFile)
+
+	// The merged header B1:C1 leaves C1 blank, which excelize does not return
+	input := `<table><tr><th>Region</th><th colspan="2">Sales</th></tr><tr><td>North</td><td>1</td><td>2</td></tr></table>`
+	table := &common.Table{}
+	assert.NoError(t, html.Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table))
+	assert.NoError(t, Marshal(&common.Config{Result: testFile}, table))
+
+	table2 := &common.Table{}
+	assert.NoError(t, Unmarshal(&common.Config{File: testFile}, table2))
+	assert.Equal(t, []string{"Region", "Sales", ""}, table2.Headers)
+	assert.Equal(t, [][]string{{"North", "1", "2"}}, table2.Rows)
+	assert.Equal(t, []common.Span{{Row: -1, Col: 1, RowSpan: 1, ColSpan: 2}}, table2.Spans)
+
+	var expected, actual bytes.Buffer
+	assert.NoError(t, html.Marshal(&common.Config{Writer: &expected}, table))
+	assert.NoError(t, html.Marshal(&common.Config{Writer: &actual}, table2))
+	assert.Equal(t, expected.String(), actual.String())
+}
@@ -0,0 +1,35 @@
+package excel
+
+import (
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "excel",
+		Description: "Excel spreadsheet (XLSX)",
+		Aliases:     []string{"xlsx"},
+		Extensions:  []string{".xlsx", ".xls"},
+		Detect:      detect,
+		Params: []common.FormatParam{
+			{Name: "first-column-header", DefaultValue: "false", AllowedValues: "true, false", Description: "Use first column as headers"},
+			{Name: "sheet-name", DefaultValue: "Sheet1", AllowedValues: "", Description: "Excel Sheet Name"},
+			{Name: "auto-width", DefaultValue: "false", AllowedValues: "true, false", Description: "Auto Width"},
+			{Name: "text-format", DefaultValue: "true", AllowedValues: "true, false", Description: "force text format"},
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+		MarshalDocument:   MarshalDocument,
+	})
+}
+
+// detect scores a zip archive holding a workbook
+func detect(sample string) int {
+	if strings.HasPrefix(sample, "PK\x03\x04") && strings.Contains(sample, "xl/") {
+		return common.DetectCertain
+	}
+	return common.DetectNone
+}
@@ -0,0 +1,39 @@
+package html
+
+import (
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "html",
+		Description: "HTML table",
+		Extensions:  []string{".html", ".htm"},
+		Detect:      detect,
+		DetectOrder: 1,
+		Params: []common.FormatParam{
+			{Name: "first-column-header", DefaultValue: "false", AllowedValues: "true, false", Description: "Use first column as headers"},
+			{Name: "div", DefaultValue: "false", AllowedValues: "true, false", Description: "Convert into div table"},
+			{Name: "minify", DefaultValue: "false", AllowedValues: "true, false", Description: "Minify HTML table"},
+			{Name: "thead", DefaultValue: "false", AllowedValues: "true, false", Description: "Include thead and tbody tags"},
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+		MarshalDocument:   MarshalDocument,
+	})
+}
+
+// detect scores content holding an HTML table
+func detect(sample string) int {
+	lower := strings.ToLower(sample)
+	switch {
+	case strings.Contains(lower, "<table"):
+		return 95
+	case strings.Contains(lower, "<thead") || strings.Contains(lower, "<tr"):
+		return 70
+	}
+	return common.DetectNone
+}
@@ -3,6 +3,8 @@ package html
 import (
 	"fmt"
 	"html/template"
+	"io"
+	"strconv"
 	"strings"
 
 	"github.com/martianzhang/tableconvert/common"
@@ -11,26 +13,59 @@ import (
 )
 
 const (
-	divTemplate = `<div class="table">
-  <div class="tr">{{range .Headers}}<div class="th">{{.}}</div>{{end}}</div>{{range .Rows}}
-  <div class="tr">{{range .}}<div class="td">{{.}}</div>{{end}}</div>{{end}}
+	divTemplate = `<div class="table">{{if .Caption}}
+  <div class="caption">{{.Caption}}</div>{{end}}
+  <div class="tr">{{range .Headers}}<div class="th">{{.Value}}</div>{{end}}</div>{{range .Rows}}
+  <div class="tr">{{range .}}<div class="td">{{.Value}}</div>{{end}}</div>{{end}}
 </div>`
-	minifyDivTemplate = `<div class="table"><div class="tr">{{range .Headers}}<div class="th">{{.}}</div>{{end}}</div>{{range .Rows}}<div class="tr">{{range .}}<div class="td">{{.}}</div>{{end}}</div>{{end}}</div>`
+	minifyDivTemplate = `<div class="table">{{if .Caption}}<div class="caption">{{.Caption}}</div>{{end}}<div class="tr">{{range .Headers}}<div class="th">{{.Value}}</div>{{end}}</div>{{range .Rows}}<div class="tr">{{range .}}<div class="td">{{.Value}}</div>{{end}}</div>{{end}}</div>`
 
 	tableTemplate = `<table>
-{{if .UseThead}}  <thead>
-{{end}}  <tr>{{range .Headers}}<th>{{.}}</th>{{end}}</tr>{{if .UseThead}}
+{{if .Caption}}  <caption>{{.Caption}}</caption>
+{{end}}{{if .UseThead}}  <thead>
+{{end}}  <tr>{{range .Headers}}<th{{if gt .RowSpan 1}} rowspan="{{.RowSpan}}"{{end}}{{if gt .ColSpan 1}} colspan="{{.ColSpan}}"{{end}}>{{.Value}}</th>{{end}}</tr>{{if .UseThead}}
   </thead>
   <tbody>{{end}}
-{{range .Rows}}  <tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{range .Rows}}  <tr>{{range .}}<td{{if gt .RowSpan 1}} rowspan="{{.RowSpan}}"{{end}}{{if gt .ColSpan 1}} colspan="{{.ColSpan}}"{{end}}>{{.Value}}</td>{{end}}</tr>
 {{end}}{{if .UseThead}}  </tbody>
 {{end}}</table>`
 
-	minfyTableTemplate = `{{if .UseThead}}<table><thead><tr>{{range .Headers}}<th>{{.}}</th>{{end}}</tr></thead><tbody>{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>{{end}}</tbody></table>{{else}}<table><tr>{{range .Headers}}<th>{{.}}</th>{{end}}</tr>{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>{{end}}</table>{{end}}`
+	minfyTableTemplate = `{{if .UseThead}}<table>{{if .Caption}}<caption>{{.Caption}}</caption>{{end}}<thead><tr>{{range .Headers}}<th{{if gt .RowSpan 1}} rowspan="{{.RowSpan}}"{{end}}{{if gt .ColSpan 1}} colspan="{{.ColSpan}}"{{end}}>{{.Value}}</th>{{end}}</tr></thead><tbody>{{range .Rows}}<tr>{{range .}}<td{{if gt .RowSpan 1}} rowspan="{{.RowSpan}}"{{end}}{{if gt .ColSpan 1}} colspan="{{.ColSpan}}"{{end}}>{{.Value}}</td>{{end}}</tr>{{end}}</tbody></table>{{else}}<table>{{if .Caption}}<caption>{{.Caption}}</caption>{{end}}<tr>{{range .Headers}}<th{{if gt .RowSpan 1}} rowspan="{{.RowSpan}}"{{end}}{{if gt .ColSpan 1}} colspan="{{.ColSpan}}"{{end}}>{{.Value}}</th>{{end}}</tr>{{range .Rows}}<tr>{{range .}}<td{{if gt .RowSpan 1}} rowspan="{{.RowSpan}}"{{end}}{{if gt .ColSpan 1}} colspan="{{.ColSpan}}"{{end}}>{{.Value}}</td>{{end}}</tr>{{end}}</table>{{end}}`
 )
 
 // Marshal converts a table structure to HTML format and writes it to the config's Writer.
 func Marshal(cfg *common.Config, table *common.Table) error {
+	return marshalTable(cfg, table, "")
+}
+
+// MarshalDocument writes all tables of the document one after another, named
+// tables captioned with their name
+func MarshalDocument(cfg *common.Config, doc *common.Document) error {
+	names := doc.UniqueTableNames(nil)
+	separator := "\n"
+	if cfg.GetExtensionBool("minify", false) {
+		separator = ""
+	}
+	for i, table := range doc.Tables {
+		if i > 0 {
+			if _, err := io.WriteString(cfg.Writer, separator); err != nil {
+				return err
+			}
+		}
+		// Only named tables get a caption
+		caption := ""
+		if table.Name != "" {
+			caption = names[i]
+		}
+		if err := marshalTable(cfg, table, caption); err != nil {
+			return fmt.Errorf("table %q: %w", names[i], err)
+		}
+	}
+	return nil
+}
+
+// marshalTable writes one HTML table, with a caption if caption is not empty
+func marshalTable(cfg *common.Config, table *common.Table, caption string) error {
 	if table == nil {
 		return fmt.Errorf("Marshal: input table pointer cannot be nil")
 	}
@@ -76,19 +111,52 @@ func Marshal(cfg *common.Config, table *common.Table) error {
 		return err
 	}
 
+	// Div tables cannot merge cells, they get every cell
+	layout := common.SpanLayout{}
+	if !useDiv {
+		layout = table.SpanLayout()
+	}
 	context := struct {
+		Caption  string
 		UseThead bool
-		Headers  []string
-		Rows     [][]string
+		Headers  []cell
+		Rows     [][]cell
 	}{
+		Caption:  caption,
 		UseThead: useThead,
-		Headers:  table.Headers,
-		Rows:     table.Rows,
+		Headers:  rowCells(layout, -1, table.Headers),
+		Rows:     make([][]cell, len(table.Rows)),
+	}
+	for i, row := range table.Rows {
+		context.Rows[i] = rowCells(layout, i, row)
 	}
 
 	return tmpl.Execute(writer, context)
 }
 
+// cell is a table cell as rendered by the templates
+type cell struct {
+	Value   string
+	RowSpan int
+	ColSpan int
+}
+
+// rowCells returns the cells of a row to render, leaving out cells covered by a merged cell
+func rowCells(layout common.SpanLayout, row int, values []string) []cell {
+	cells := make([]cell, 0, len(values))
+	for col, value := range values {
+		if layout.Covered(row, col) {
+			continue
+		}
+		c := cell{Value: value}
+		if span, ok := layout.At(row, col); ok {
+			c.RowSpan, c.ColSpan = span.RowSpan, span.ColSpan
+		}
+		cells = append(cells, c)
+	}
+	return cells
+}
+
 // Unmarshal parses HTML table content using the html parser
 func Unmarshal(cfg *common.Config, table *common.Table) error {
 	if table == nil {
@@ -106,33 +174,87 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 	}
 
 	// Find the first table
-	var tableNode *html.Node
-	var findTable func(*html.Node)
-	findTable = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "table" {
-			tableNode = n
-			return
+	tableNodes := findTables(doc)
+	if len(tableNodes) == 0 {
+		return fmt.Errorf("Unmarshal: no table found in HTML content")
+	}
+	return parseTable(cfg, tableNodes[0], table)
+}
+
+// UnmarshalDocument parses every top-level table of the HTML content.
+// Tables are named after their caption or id attribute.
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	root, err := html.Parse(cfg.Reader)
+	if err != nil {
+		return fmt.Errorf("Unmarshal: failed to parse HTML: %w", err)
+	}
+
+	tableNodes := findTables(root)
+	if len(tableNodes) == 0 {
+		return fmt.Errorf("Unmarshal: no table found in HTML content")
+	}
+
+	for i, tableNode := range tableNodes {
+		table := &common.Table{Name: tableName(tableNode)}
+		if err := parseTable(cfg, tableNode, table); err != nil {
+			return fmt.Errorf("table %d: %w", i, err)
 		}
-		for c := n.FirstChild; c != nil && tableNode == nil; c = c.NextSibling {
-			findTable(c)
+		doc.Tables = append(doc.Tables, table)
+	}
+	return nil
+}
+
+// findTables returns the table elements in document order, tables nested in other tables are skipped
+func findTables(n *html.Node) []*html.Node {
+	if n.Type == html.ElementNode && n.Data == "table" {
+		return []*html.Node{n}
+	}
+	var tables []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		tables = append(tables, findTables(c)...)
+	}
+	return tables
+}
+
+// tableName returns the caption text or the id attribute of a table element
+func tableName(tableNode *html.Node) string {
+	for c := tableNode.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == "caption" {
+			return strings.TrimSpace(textContent(c))
 		}
 	}
-	findTable(doc)
+	for _, attr := range tableNode.Attr {
+		if attr.Key == "id" {
+			return attr.Val
+		}
+	}
+	return ""
+}
 
-	if tableNode == nil {
-		return fmt.Errorf("Unmarshal: no table found in HTML content")
+// textContent concatenates all text below n
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(textContent(c))
 	}
+	return sb.String()
+}
 
+// parseTable fills table from a table element
+func parseTable(cfg *common.Config, tableNode *html.Node, table *common.Table) error {
 	// Check if first-column-header is explicitly set in config
 	if cfg.GetExtensionBool("first-column-header", false) {
-		return parseFirstColumnAsHeader(tableNode, table)
+		return parseFirstColumnAsHeader(cfg, tableNode, table)
 	}
 
 	// Auto-detect header type
 	if isFirstColumnHeader(tableNode) {
-		return parseFirstColumnAsHeader(tableNode, table)
+		return parseFirstColumnAsHeader(cfg, tableNode, table)
 	}
-	return parseFirstRowAsHeader(tableNode, table)
+	return parseFirstRowAsHeader(cfg, tableNode, table)
 }
 
 // isFirstColumnHeader detects if the table uses first column as header
@@ -220,7 +342,7 @@ func collectRows(tableNode *html.Node) [][]string {
 }
 
 // parseFirstRowAsHeader parses table with first row as header (default behavior)
-func parseFirstRowAsHeader(tableNode *html.Node, table *common.Table) error {
+func parseFirstRowAsHeader(cfg *common.Config, tableNode *html.Node, table *common.Table) error {
 	// Find first header row (tr with th cells)
 	var headerRow *html.Node
 	var findHeaderRow func(*html.Node) bool
@@ -247,62 +369,47 @@ func parseFirstRowAsHeader(tableNode *html.Node, table *common.Table) error {
 	}
 
 	// Extract headers from header row
-	var headers []string
+	var headers []common.SpanCell
 	for c := headerRow.FirstChild; c != nil; c = c.NextSibling {
 		if c.Type == html.ElementNode && c.Data == "th" {
-			// Handle empty th cells
-			if c.FirstChild != nil {
-				headers = append(headers, strings.TrimSpace(c.FirstChild.Data))
-			} else {
-				headers = append(headers, "")
-			}
+			headers = append(headers, spanCell(c))
 		}
 	}
 
 	if len(headers) == 0 {
 		return fmt.Errorf("Unmarshal: no header cells found in header row")
 	}
+	if err := cfg.CheckSpanRow(0, headers); err != nil {
+		return err
+	}
 
-	table.Headers = headers
+	rows := [][]common.SpanCell{headers}
 
-	// Process data rows
+	// Process the other rows
+	var err error
 	var processRows func(*html.Node)
 	processRows = func(n *html.Node) {
+		if err != nil {
+			return
+		}
+		if n == headerRow {
+			return
+		}
 		if n.Type == html.ElementNode && n.Data == "tr" {
-			// Skip header rows (already processed)
-			isHeaderRow := false
+			// Later header rows, e.g. the second row of a two-row header, and
+			// rows starting with a row header are read as rows, so that the
+			// cells they cover are laid out under the right columns
+			var row []common.SpanCell
 			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				if c.Type == html.ElementNode && c.Data == "th" {
-					isHeaderRow = true
-					break
-				}
-			}
-			if isHeaderRow {
-				return
-			}
-
-			// Process data row
-			var row []string
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				if c.Type == html.ElementNode && c.Data == "td" {
-					// Handle empty td cells
-					if c.FirstChild != nil {
-						row = append(row, strings.TrimSpace(c.FirstChild.Data))
-					} else {
-						row = append(row, "")
-					}
+				if c.Type == html.ElementNode && (c.Data == "td" || c.Data == "th") {
+					row = append(row, spanCell(c))
 				}
 			}
-
-			// Only add row if it has the same number of columns as headers
-			if len(row) == len(headers) {
-				table.Rows = append(table.Rows, row)
-			} else if len(row) > 0 {
-				// If row has fewer columns, pad with empty strings
-				for len(row) < len(headers) {
-					row = append(row, "")
+			if len(row) > 0 {
+				if err = cfg.CheckSpanRow(len(rows)-1, row); err != nil {
+					return
 				}
-				table.Rows = append(table.Rows, row)
+				rows = append(rows, row)
 			}
 		}
 
@@ -312,18 +419,54 @@ func parseFirstRowAsHeader(tableNode *html.Node, table *common.Table) error {
 	}
 
 	processRows(tableNode)
+	if err != nil {
+		return err
+	}
+
+	// Lay out merged cells, rows with fewer columns are padded with empty strings
+	grid, spans, _, err := common.LayoutSpans(rows)
+	if err != nil {
+		return err
+	}
+	table.SetGrid(grid, spans)
 
 	return nil
 }
 
+// spanCell reads the text and the rowspan/colspan attributes of a td or th element
+func spanCell(n *html.Node) common.SpanCell {
+	cell := common.SpanCell{}
+	// Handle empty cells
+	if n.FirstChild != nil {
+		cell.Value = strings.TrimSpace(n.FirstChild.Data)
+	}
+	for _, attr := range n.Attr {
+		span, err := strconv.Atoi(strings.TrimSpace(attr.Val))
+		if err != nil {
+			continue
+		}
+		switch attr.Key {
+		case "rowspan":
+			cell.RowSpan = span
+		case "colspan":
+			cell.ColSpan = span
+		}
+	}
+	return cell
+}
+
 // parseFirstColumnAsHeader parses table with first column as header
-func parseFirstColumnAsHeader(tableNode *html.Node, table *common.Table) error {
+func parseFirstColumnAsHeader(cfg *common.Config, tableNode *html.Node, table *common.Table) error {
 	var headers []string
 	var rows [][]string
 
 	// Process all rows
+	var err error
 	var processRows func(*html.Node)
 	processRows = func(n *html.Node) {
+		if err != nil {
+			return
+		}
 		if n.Type == html.ElementNode && n.Data == "tr" {
 			var row []string
 			for c := n.FirstChild; c != nil; c = c.NextSibling {
@@ -337,6 +480,9 @@ func parseFirstColumnAsHeader(tableNode *html.Node, table *common.Table) error {
 				}
 			}
 			if len(row) > 0 {
+				if err = cfg.CheckRow(len(rows), row); err != nil {
+					return
+				}
 				rows = append(rows, row)
 			}
 		}
@@ -347,6 +493,9 @@ func parseFirstColumnAsHeader(tableNode *html.Node, table *common.Table) error {
 	}
 
 	processRows(tableNode)
+	if err != nil {
+		return err
+	}
 
 	if len(rows) == 0 {
 		return fmt.Errorf("Unmarshal: no rows found in table")
@@ -666,3 +666,102 @@ func TestIsFirstColumnHeader(t *testing.T) {
 		})
 	}
 }
+
+func TestUnmarshalDocument(t *testing.T) {
+	input := `<html><body>
+<table><caption>Users</caption><tr><th>id</th><th>name</th></tr><tr><td>1</td><td>Alice</td></tr></table>
+<p>text</p>
+<table id="orders"><tr><th>order</th></tr><tr><td>42</td></tr></table>
+<table><tr><th>x</th></tr></table>
+</body></html>`
+
+	cfg := &common.Config{Reader: strings.NewReader(input)}
+	doc := &common.Document{}
+	err := UnmarshalDocument(cfg, doc)
+
+	assert.NoError(t, err)
+	assert.Len(t, doc.Tables, 3)
+	assert.Equal(t, "Users", doc.Tables[0].Name)
+	assert.Equal(t, []string{"id", "name"}, doc.Tables[0].Headers)
+	assert.Equal(t, [][]string{{"1", "Alice"}}, doc.Tables[0].Rows)
+	assert.Equal(t, "orders", doc.Tables[1].Name)
+	assert.Equal(t, [][]string{{"42"}}, doc.Tables[1].Rows)
+	assert.Equal(t, "Table3", doc.TableName(2))
+}
+
+func TestMarshalDocument(t *testing.T) {
+	doc := &common.Document{Tables: []*common.Table{
+		{Name: "Users", Headers: []string{"id"}, Rows: [][]string{{"1"}}},
+		{Headers: []string{"order"}, Rows: [][]string{{"42"}}},
+	}}
+
+	var buf bytes.Buffer
+	cfg := &common.Config{Writer: &buf, Extension: map[string]string{}}
+	err := MarshalDocument(cfg, doc)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `<table>
+  <caption>Users</caption>
+  <tr><th>id</th></tr>
+  <tr><td>1</td></tr>
+</table>
+<table>
+  <tr><th>order</th></tr>
+  <tr><td>42</td></tr>
+</table>`, buf.String())
+
+	// The captions name the tables when reading them back, unnamed tables have no caption
+	back := &common.Document{}
+	err = UnmarshalDocument(&common.Config{Reader: &buf}, back)
+	assert.NoError(t, err)
+	assert.Equal(t, "Users", back.TableName(0))
+	assert.Empty(t, back.Tables[1].Name)
+}
+
+func TestUnmarshalAndMarshalSpans(t *testing.T) {
+	input := `<table>
+  <tr><th rowspan="2">Region</th><th colspan="2">Sales</th></tr>
+  <tr><td>Q1</td><td>Q2</td></tr>
+  <tr><td>North</td><td>1</td><td>2</td></tr>
+</table>`
+
+	var table common.Table
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, &table)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Region", "Sales", ""}, table.Headers)
+	assert.Equal(t, [][]string{{"", "Q1", "Q2"}, {"North", "1", "2"}}, table.Rows)
+	assert.Equal(t, []common.Span{
+		{Row: -1, Col: 0, RowSpan: 2, ColSpan: 1},
+		{Row: -1, Col: 1, RowSpan: 1, ColSpan: 2},
+	}, table.Spans)
+
+	var buf bytes.Buffer
+	err = Marshal(&common.Config{Writer: &buf, Extension: map[string]string{}}, &table)
+	assert.NoError(t, err)
+	assert.Equal(t, `<table>
+  <tr><th rowspan="2">Region</th><th colspan="2">Sales</th></tr>
+  <tr><td>Q1</td><td>Q2</td></tr>
+  <tr><td>North</td><td>1</td><td>2</td></tr>
+</table>`, buf.String())
+}
+
+func TestUnmarshalMultiRowHeader(t *testing.T) {
+	// The second header row is read as the first row, under the columns of the cells above it
+	input := `<table>
+  <tr><th rowspan=2>Region</th><th colspan=2>Q1</th></tr>
+  <tr><th>Jan</th><th>Feb</th></tr>
+  <tr><td>North</td><td colspan=2>10</td></tr>
+  <tr><th>Total</th><td>7</td><td>3</td></tr>
+</table>`
+
+	var table common.Table
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, &table)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Region", "Q1", ""}, table.Headers)
+	assert.Equal(t, [][]string{{"", "Jan", "Feb"}, {"North", "10", ""}, {"Total", "7", "3"}}, table.Rows)
+	assert.Equal(t, []common.Span{
+		{Row: -1, Col: 0, RowSpan: 2, ColSpan: 1},
+		{Row: -1, Col: 1, RowSpan: 1, ColSpan: 2},
+		{Row: 1, Col: 1, RowSpan: 1, ColSpan: 2},
+	}, table.Spans)
+}
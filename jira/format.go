@@ -0,0 +1,37 @@
+package jira
+
+import (
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "jira",
+		Description: "Jira/Confluence wiki markup table",
+		Aliases:     []string{"confluence"},
+		Detect:      detect,
+		DetectOrder: 17,
+		Params: []common.FormatParam{
+			{Name: "escape", DefaultValue: "true", AllowedValues: "true, false", Description: "Escape backslashes, pipes, braces and brackets in cells, false keeps links and macros", Use: common.ParamWrite},
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+	})
+}
+
+// detect scores content with a "||Header||Header||" row followed by a "|cell|cell|" row.
+// TracWiki writes every cell with "||" and headers as "||=Header=||".
+func detect(sample string) int {
+	lines := common.SampleLines(sample, 20)
+	for i := 1; i < len(lines); i++ {
+		header, row := strings.TrimSpace(lines[i-1]), strings.TrimSpace(lines[i])
+		if strings.HasPrefix(header, "||") && !strings.HasPrefix(header, "||=") && strings.HasSuffix(header, "||") &&
+			strings.HasPrefix(row, "|") && !strings.HasPrefix(row, "||") {
+			return 90
+		}
+	}
+	return common.DetectNone
+}
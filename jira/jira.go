@@ -0,0 +1,216 @@
+package jira
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+// specialChars are the characters a backslash escapes in wiki markup
+const specialChars = `|{}[]*_-+^~?!#`
+
+// backslash is the entity written for a backslash in a cell, which wiki markup
+// cannot escape: "\\" is a line break and "\|" a pipe
+const backslash = "&#92;"
+
+// splitRow splits a row into cells at each "|" or "||" that is not escaped and,
+// when nested is true, not inside a [link|url] or {macro:a=b|c=d}
+func splitRow(row string, nested bool) ([]string, bool) {
+	var cells []string
+	var value strings.Builder
+	depth := 0
+	started := false
+	runes := []rune(row)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && i+1 < len(runes):
+			value.WriteRune(r)
+			value.WriteRune(runes[i+1])
+			i++
+			continue
+		case nested && (r == '[' || r == '{'):
+			depth++
+		case nested && (r == ']' || r == '}') && depth > 0:
+			depth--
+		case r == '|' && depth == 0:
+			if started {
+				cells = append(cells, unescape(value.String()))
+			}
+			value.Reset()
+			started = true
+			// Header cells start with "||"
+			if i+1 < len(runes) && runes[i+1] == '|' {
+				i++
+			}
+			continue
+		}
+		value.WriteRune(r)
+	}
+	if strings.TrimSpace(value.String()) != "" {
+		cells = append(cells, unescape(value.String()))
+	}
+	return cells, depth == 0
+}
+
+// unescape reads "\\" as a line break, a backslash before a special character as
+// that character and the backslash entity as a backslash
+func unescape(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '\\' && i+1 < len(runes) {
+			switch next := runes[i+1]; {
+			case next == '\\':
+				b.WriteString("\n")
+				i++
+				continue
+			case strings.ContainsRune(specialChars, next):
+				b.WriteRune(next)
+				i++
+				continue
+			}
+		}
+		b.WriteRune(runes[i])
+	}
+	lines := strings.Split(strings.ReplaceAll(b.String(), backslash, `\`), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// complete reports whether a row ends with an unescaped "|", rows copied from
+// issue descriptions may continue on the next lines otherwise
+func complete(row string) bool {
+	row = strings.TrimSpace(row)
+	return strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`)
+}
+
+// readTable reads the next table of the input. The first row is the header,
+// other rows are data even when they hold "||" row header cells. Rows with
+// fewer cells are padded with empty cells.
+func readTable(cfg *common.Config, scanner *bufio.Scanner, lineNumber *int) (*common.Table, error) {
+	var rows []string
+	var rowLines []int
+	for scanner.Scan() {
+		*lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "|"):
+			rows = append(rows, line)
+			rowLines = append(rowLines, *lineNumber)
+			continue
+		case len(rows) > 0 && !complete(rows[len(rows)-1]):
+			rows[len(rows)-1] += "\n" + line
+			continue
+		}
+		if len(rows) > 0 {
+			break
+		}
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	var grid [][]string
+	for i, row := range rows {
+		cells, ok := splitRow(row, true)
+		if !ok {
+			// An unbalanced bracket is plain text
+			cells, _ = splitRow(row, false)
+		}
+		if len(cells) == 0 {
+			return nil, &common.ParseError{LineNumber: rowLines[i], Message: "row contains no cells", Line: row}
+		}
+		// The first row is the header
+		if err := cfg.CheckRow(i-1, cells); err != nil {
+			return nil, err
+		}
+		grid = append(grid, cells)
+	}
+	if err := common.PadRows(grid); err != nil {
+		return nil, err
+	}
+	return &common.Table{Headers: grid[0], Rows: grid[1:]}, nil
+}
+
+// Unmarshal reads the first table of the input
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Unmarshal: target table pointer cannot be nil")
+	}
+	tables, err := common.ScanTables(cfg, 1, readTable)
+	if err != nil {
+		return err
+	}
+	*table = *tables[0]
+	return nil
+}
+
+// UnmarshalDocument reads every table of the input, tables being separated by other lines
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	tables, err := common.ScanTables(cfg, 0, readTable)
+	if err != nil {
+		return err
+	}
+	doc.Tables = append(doc.Tables, tables...)
+	return nil
+}
+
+// escape writes line breaks of a cell as "\\", and with special set, backslashes as
+// an entity and pipes, braces and brackets with a backslash. Empty cells are written as a space, as "||" would start a header cell.
+func escape(s string, special bool) string {
+	var b strings.Builder
+	for _, r := range strings.ReplaceAll(s, "\r\n", "\n") {
+		switch {
+		case r == '\n':
+			b.WriteString(`\\`)
+		case special && r == '\\':
+			b.WriteString(backslash)
+		case special && strings.ContainsRune(`|{}[]`, r):
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if strings.TrimSpace(b.String()) == "" {
+		return " "
+	}
+	return b.String()
+}
+
+// Marshal writes a "||header||" row followed by "|cell|" rows. With --escape=false
+// cells are written as wiki markup, e.g. links read from a Jira table.
+func Marshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Marshal: input table pointer cannot be nil")
+	}
+	if len(table.Headers) == 0 {
+		return fmt.Errorf("Marshal: table must have at least one header")
+	}
+	for i, row := range table.Rows {
+		if len(row) != len(table.Headers) {
+			return fmt.Errorf("Marshal: row %d has %d columns, but table has %d", i, len(row), len(table.Headers))
+		}
+	}
+
+	special := cfg.GetExtensionBool("escape", true)
+	var b strings.Builder
+	for _, header := range table.Headers {
+		b.WriteString("||" + escape(header, special))
+	}
+	b.WriteString("||\n")
+	for _, row := range table.Rows {
+		for _, value := range row {
+			b.WriteString("|" + escape(value, special))
+		}
+		b.WriteString("|\n")
+	}
+	_, err := io.WriteString(cfg.Writer, b.String())
+	return err
+}
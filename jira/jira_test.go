@@ -0,0 +1,218 @@
+package jira
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	// The other wiki dialects, scored against this one by TestDetect
+	_ "github.com/martianzhang/tableconvert/bbcode"
+	_ "github.com/martianzhang/tableconvert/dokuwiki"
+	_ "github.com/martianzhang/tableconvert/markdown"
+	_ "github.com/martianzhang/tableconvert/mediawiki"
+	_ "github.com/martianzhang/tableconvert/textile"
+	_ "github.com/martianzhang/tableconvert/tracwiki"
+	_ "github.com/martianzhang/tableconvert/twiki"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalIssueDescription(t *testing.T) {
+	// Shaped like a table copied from an issue description, with text around it
+	input := `h2. Steps to reproduce
+
+||Key||Summary||Status||
+|ABC-1|Fix login page|*Done*|
+|ABC-2|Add export|*In progress*|
+
+Some text after the table.
+`
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Key", "Summary", "Status"}, table.Headers)
+	assert.Equal(t, [][]string{
+		{"ABC-1", "Fix login page", "*Done*"},
+		{"ABC-2", "Add export", "*In progress*"},
+	}, table.Rows)
+}
+
+func TestUnmarshalDocument(t *testing.T) {
+	// Tables are separated by other lines, each is as wide as its own header
+	input := "h2. Open\n||Key||Summary||\n|ABC-1|Fix login page|\n\nh2. Owners\n||Name||\n|ops|\n|dev|\n"
+	var doc common.Document
+	err := UnmarshalDocument(&common.Config{Reader: strings.NewReader(input)}, &doc)
+	assert.NoError(t, err)
+	require.Len(t, doc.Tables, 2)
+	assert.Equal(t, []string{"Key", "Summary"}, doc.Tables[0].Headers)
+	assert.Equal(t, [][]string{{"ABC-1", "Fix login page"}}, doc.Tables[0].Rows)
+	assert.Equal(t, []string{"Name"}, doc.Tables[1].Headers)
+	assert.Equal(t, [][]string{{"ops"}, {"dev"}}, doc.Tables[1].Rows)
+
+	// Unmarshal reads the first table only
+	table := &common.Table{}
+	err = Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, doc.Tables[0].Rows, table.Rows)
+}
+
+func TestUnmarshalWithLinksAndMacros(t *testing.T) {
+	// The "|" inside a [title|url] link or a {macro:a=b|c=d} does not separate cells
+	input := "||Key||Link||Status||\n|ABC-1|[the login|https://example.com/login]|{status:colour=Green|title=Done}|\n"
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"ABC-1", "[the login|https://example.com/login]", "{status:colour=Green|title=Done}"},
+	}, table.Rows)
+
+	// An unbalanced bracket does not hide the cell separators
+	table = &common.Table{}
+	err = Unmarshal(&common.Config{Reader: strings.NewReader("||a||b||\n|[x|y|\n")}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"[x", "y"}}, table.Rows)
+}
+
+func TestUnmarshalWithLineBreaks(t *testing.T) {
+	// A row continues on the next line until it ends with "|", "\\" is a line break
+	input := "||a||b||\n|Two\nlines|one\\\\two|\n|a\\\\b \\| c|\n"
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Two\nlines", "one\ntwo"},
+		// The row is padded to the header
+		{"a\nb | c", ""},
+	}, table.Rows)
+}
+
+func TestUnmarshalWithRowHeaderCells(t *testing.T) {
+	// "||" cells after the first row are row headers, read as data
+	input := "||Key||Count||\n|ABC-1|1|\n||Total|1|\n"
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"ABC-1", "1"}, {"Total", "1"}}, table.Rows)
+}
+
+func TestMarshalWithSpecialCharacters(t *testing.T) {
+	tests := []struct {
+		name     string
+		cell     string
+		expected string
+	}{
+		{"pipe", "a|b", "||h||\n|a\\|b|\n"},
+		{"macro", "{code}", "||h||\n|\\{code\\}|\n"},
+		{"link", "[ABC-1|https://example.com]", "||h||\n|\\[ABC-1\\|https://example.com\\]|\n"},
+		{"line break", "one\ntwo", "||h||\n|one\\\\two|\n"},
+		// "\\" is a line break and "\|" a pipe, a backslash is written as an entity
+		{"double backslash", `x\\y`, "||h||\n|x&#92;&#92;y|\n"},
+		{"backslash before pipe", `a\|b`, "||h||\n|a&#92;\\|b|\n"},
+		{"trailing backslash", `C:\`, "||h||\n|C:&#92;|\n"},
+		// "||" would start a header cell
+		{"empty", "", "||h||\n| |\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &common.Table{Headers: []string{"h"}, Rows: [][]string{{tt.cell}}}
+			var buf bytes.Buffer
+			require.NoError(t, Marshal(&common.Config{Writer: &buf}, table))
+			assert.Equal(t, tt.expected, buf.String())
+
+			// The cell is read back as it was
+			got := &common.Table{}
+			require.NoError(t, Unmarshal(&common.Config{Reader: &buf}, got))
+			assert.Equal(t, table.Rows, got.Rows)
+		})
+	}
+}
+
+func TestMarshalWithoutEscape(t *testing.T) {
+	// Links and macros read from Jira are written back as markup
+	table := &common.Table{
+		Headers: []string{"key", "status"},
+		Rows:    [][]string{{"[ABC-1|https://example.com/ABC-1]", "{status:title=Done}"}},
+	}
+	var buf bytes.Buffer
+	cfg := &common.Config{Writer: &buf, Extension: map[string]string{"escape": "false"}}
+
+	err := Marshal(cfg, table)
+	assert.NoError(t, err)
+	assert.Equal(t, "||key||status||\n|[ABC-1|https://example.com/ABC-1]|{status:title=Done}|\n", buf.String())
+
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: &buf}, got))
+	assert.Equal(t, table.Rows, got.Rows)
+}
+
+func TestUnmarshalErrorCases(t *testing.T) {
+	t.Run("nil table", func(t *testing.T) {
+		err := Unmarshal(&common.Config{Reader: strings.NewReader("||a||\n")}, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("no table", func(t *testing.T) {
+		err := Unmarshal(&common.Config{Reader: strings.NewReader("no table\n")}, &common.Table{})
+		assert.ErrorContains(t, err, "no table row")
+	})
+
+	t.Run("header without cells", func(t *testing.T) {
+		err := Unmarshal(&common.Config{Reader: strings.NewReader("||\n")}, &common.Table{})
+		assert.Error(t, err)
+	})
+}
+
+func TestMarshalErrorCases(t *testing.T) {
+	t.Run("no columns", func(t *testing.T) {
+		err := Marshal(&common.Config{Writer: &bytes.Buffer{}}, &common.Table{})
+		assert.Error(t, err)
+	})
+
+	t.Run("short row", func(t *testing.T) {
+		table := &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1"}}}
+		err := Marshal(&common.Config{Writer: &bytes.Buffer{}}, table)
+		assert.ErrorContains(t, err, "row 0 has 1 columns")
+	})
+}
+
+func TestFixture(t *testing.T) {
+	data, err := os.ReadFile("../test/mysql.jira")
+	require.NoError(t, err)
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: bytes.NewReader(data)}, table))
+	assert.Equal(t, []string{"FIELD", "TYPE", "NULL", "KEY", "DEFAULT", "EXTRA"}, table.Headers)
+	assert.Len(t, table.Rows, 3)
+	assert.Equal(t, []string{"username", "varchar(10)", "NO", "", "NULL", ""}, table.Rows[1])
+
+	// Empty cells are written as a single space and read back as empty
+	var buf bytes.Buffer
+	require.NoError(t, Marshal(&common.Config{Writer: &buf}, table))
+	assert.Equal(t, string(data), buf.String())
+}
+
+func TestDetect(t *testing.T) {
+	fixture, err := os.ReadFile("../test/mysql.jira")
+	require.NoError(t, err)
+	// Against the other wiki dialects imported above, a "||" header row followed
+	// by "|" rows is Jira, with or without text around the table
+	for _, input := range []string{string(fixture), "h2. Steps\n\n||Key||Status||\n|ABC-1|Done|\n"} {
+		scores := common.ScoreFormats([]byte(input))
+		require.NotEmpty(t, scores, input)
+		assert.Equal(t, "jira", scores[0].Format, input)
+	}
+
+	// The other wiki dialects also mark cells with "|" and "||"
+	for _, name := range []string{"mysql.tracwiki", "mysql.twiki", "mysql.dokuwiki", "mysql.textile", "mysql.md"} {
+		other, err := os.ReadFile("../test/" + name)
+		require.NoError(t, err)
+		assert.Equal(t, common.DetectNone, detect(string(other)), name)
+	}
+	// TracWiki data rows start with "||" too, even without header cells
+	assert.Equal(t, common.DetectNone, detect("||a||b||\n||1||2||"))
+	// Without the header row the cells could be any pipe table
+	assert.Equal(t, common.DetectNone, detect("|a|b|\n"))
+}
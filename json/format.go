@@ -0,0 +1,45 @@
+package json
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "json",
+		Description: "JSON (object, 2d array, column-oriented, keyed)",
+		Extensions:  []string{".json"},
+		Detect:      detect,
+		DetectOrder: 7,
+		Params: []common.FormatParam{
+			{Name: "format", DefaultValue: "object", AllowedValues: "object, 2d, column, keyed", Description: "JSON Format"},
+			{Name: "minify", DefaultValue: "false", AllowedValues: "true, false", Description: "Minify JSON"},
+			{Name: "parsing-json", DefaultValue: "false", AllowedValues: "true, false", Description: "Parsing JSON"},
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+		MarshalDocument:   MarshalDocument,
+	})
+}
+
+// detect scores content that is one JSON object or array
+func detect(sample string) int {
+	trimmed := strings.TrimSpace(sample)
+	object := strings.TrimSpace(strings.TrimPrefix(trimmed, "{"))
+	if !strings.HasPrefix(trimmed, "[") && (!strings.HasPrefix(trimmed, "{") ||
+		!strings.HasPrefix(object, "\"") && !strings.HasPrefix(object, "}")) {
+		return common.DetectNone
+	}
+	if json.Valid([]byte(trimmed)) {
+		return 95
+	}
+	if len(sample) >= common.DetectSampleSize {
+		// A document longer than the sample cannot be validated
+		return common.DetectLikely
+	}
+	return 30
+}
@@ -1,26 +1,130 @@
 package json
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"sort"
+	"strconv"
 
 	"github.com/martianzhang/tableconvert/common"
 )
 
 func Unmarshal(cfg *common.Config, table *common.Table) error {
-	format := cfg.GetExtensionString("format", "")
+	data, err := io.ReadAll(cfg.Reader)
+	if err != nil {
+		return err
+	}
+
+	// A keyed object holds several tables, read the first one
+	if isKeyedObject(data) {
+		doc := &common.Document{}
+		if err := unmarshalDocument(cfg, data, doc); err != nil {
+			return err
+		}
+		if len(doc.Tables) == 0 {
+			return fmt.Errorf("no table found in JSON object")
+		}
+		*table = *doc.Tables[0]
+		return nil
+	}
+	return unmarshalTable(cfg, data, table)
+}
 
+// UnmarshalDocument reads an object whose keys are table names and whose
+// values are tables in the configured format. An array is read as a single table.
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
 	data, err := io.ReadAll(cfg.Reader)
 	if err != nil {
 		return err
 	}
 
+	if !isKeyedObject(data) {
+		table := &common.Table{}
+		if err := unmarshalTable(cfg, data, table); err != nil {
+			return err
+		}
+		doc.Tables = append(doc.Tables, table)
+		return nil
+	}
+	return unmarshalDocument(cfg, data, doc)
+}
+
+// isKeyedObject reports whether data is a JSON object rather than an array
+func isKeyedObject(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+// unmarshalDocument decodes a keyed object, keeping the order of its keys
+func unmarshalDocument(cfg *common.Config, data []byte, doc *common.Document) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if _, err := decoder.Token(); err != nil { // opening brace
+		return err
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+
+		name := fmt.Sprint(key)
+		table := &common.Table{Name: name}
+		if err := unmarshalTable(cfg, value, table); err != nil {
+			return fmt.Errorf("table %q: %w", name, err)
+		}
+		doc.Tables = append(doc.Tables, table)
+	}
+	_, err := decoder.Token() // closing brace
+	return err
+}
+
+// layout guesses the format option of an array written without one: "2d"
+// when its first element is an array, "column" when it holds several objects
+// with one distinct key each whose values are arrays, and "object" otherwise
+func layout(data []byte) string {
+	var input []json.RawMessage
+	if err := json.Unmarshal(data, &input); err != nil || len(input) == 0 {
+		return "object"
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(input[0]), []byte("[")) {
+		return "2d"
+	}
+	if len(input) < 2 {
+		return "object"
+	}
+	keys := make(map[string]bool)
+	for _, element := range input {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(element, &obj); err != nil || len(obj) != 1 {
+			return "object"
+		}
+		for k, v := range obj {
+			if keys[k] || !bytes.HasPrefix(bytes.TrimSpace(v), []byte("[")) {
+				return "object"
+			}
+			keys[k] = true
+		}
+	}
+	return "column"
+}
+
+// unmarshalTable decodes one table in the format given by the format option
+func unmarshalTable(cfg *common.Config, data []byte, table *common.Table) error {
+	format := cfg.GetExtensionString("format", "")
+	if format == "" {
+		format = layout(data)
+	}
+	table.TrackNulls()
+
 	switch format {
 	case "2d":
 		var input [][]interface{}
-		if err := json.Unmarshal(data, &input); err != nil {
+		if err := decode(data, &input); err != nil {
 			return err
 		}
 		if len(input) == 0 {
@@ -35,27 +139,32 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 				table.Headers[i] = fmt.Sprint(header)
 			}
 		}
+		if err := cfg.CheckRow(0, table.Headers); err != nil {
+			return err
+		}
+		table.Columns = common.NewColumns(table.Headers)
 		// Extract rows
-		for _, row := range input[1:] {
+		for r, row := range input[1:] {
 			stringRow := make([]string, len(table.Headers))
 			for i := range table.Headers {
-				if i < len(row) {
-					if row[i] == nil {
-						stringRow[i] = "NULL"
-					} else {
-						stringRow[i] = fmt.Sprint(row[i])
-					}
+				if i < len(row) && row[i] != nil {
+					stringRow[i] = cellValue(row[i], &table.Columns[i])
 				} else {
-					// Handle missing values
-					stringRow[i] = "NULL"
+					// Handle null and missing values
+					stringRow[i] = common.DefaultNullString
+					table.Columns[i].Nullable = true
+					table.SetNull(r, i)
 				}
 			}
+			if err := cfg.CheckRow(r, stringRow); err != nil {
+				return err
+			}
 			table.Rows = append(table.Rows, stringRow)
 		}
 
 	case "column":
 		var input []map[string]interface{}
-		if err := json.Unmarshal(data, &input); err != nil {
+		if err := decode(data, &input); err != nil {
 			return err
 		}
 
@@ -80,6 +189,10 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 			table.Headers = append(table.Headers, k)
 		}
 		sort.Strings(table.Headers)
+		if err := cfg.CheckRow(0, table.Headers); err != nil {
+			return err
+		}
+		table.Columns = common.NewColumns(table.Headers)
 
 		// Fill rows
 		for i := 0; i < numRows; i++ {
@@ -87,19 +200,21 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 			for j, header := range table.Headers {
 				col := columnData[header]
 				if i < len(col) {
+					row[j] = cellValue(col[i], &table.Columns[j])
 					if col[i] == nil {
-						row[j] = "NULL"
-					} else {
-						row[j] = fmt.Sprint(col[i])
+						table.SetNull(i, j)
 					}
 				}
 			}
+			if err := cfg.CheckRow(i, row); err != nil {
+				return err
+			}
 			table.Rows = append(table.Rows, row)
 		}
 
 	default: // Array of Object
 		var input []map[string]interface{}
-		if err := json.Unmarshal(data, &input); err != nil {
+		if err := decode(data, &input); err != nil {
 			return err
 		}
 
@@ -115,32 +230,154 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 			table.Headers = append(table.Headers, key)
 		}
 		sort.Strings(table.Headers)
+		if err := cfg.CheckRow(0, table.Headers); err != nil {
+			return err
+		}
+		table.Columns = common.NewColumns(table.Headers)
 
-		for _, obj := range input {
+		for r, obj := range input {
 			row := make([]string, len(table.Headers))
 			for i, header := range table.Headers {
 				if val, ok := obj[header]; ok {
+					row[i] = cellValue(val, &table.Columns[i])
 					if val == nil {
-						row[i] = "NULL"
-					} else {
-						row[i] = fmt.Sprint(val)
+						table.SetNull(r, i)
 					}
+				} else {
+					table.Columns[i].Nullable = true
 				}
 			}
+			if err := cfg.CheckRow(r, row); err != nil {
+				return err
+			}
 			table.Rows = append(table.Rows, row)
 		}
 	}
 
+	finishColumns(table.Columns)
 	return nil
 }
 
+// decode unmarshals data keeping numbers as json.Number so integers keep their exact text
+func decode(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// cellValue converts a decoded JSON value to its cell text and records its type in column
+func cellValue(val interface{}, column *common.Column) string {
+	switch v := val.(type) {
+	case nil:
+		column.Nullable = true
+		return common.DefaultNullString
+	case json.Number:
+		if _, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+			column.Observe(common.ColumnTypeInt, "number")
+		} else {
+			column.Observe(common.ColumnTypeFloat, "number")
+		}
+		return v.String()
+	case bool:
+		column.Observe(common.ColumnTypeBool, "boolean")
+		return strconv.FormatBool(v)
+	case string:
+		column.Observe(common.ColumnTypeString, "string")
+		return v
+	case []interface{}:
+		column.Observe(common.ColumnTypeString, "array")
+		return fmt.Sprint(v)
+	default:
+		column.Observe(common.ColumnTypeString, "object")
+		return fmt.Sprint(v)
+	}
+}
+
+// finishColumns types columns that only held nulls as strings
+func finishColumns(columns []common.Column) {
+	for i := range columns {
+		if columns[i].Type == common.ColumnTypeUnknown {
+			columns[i].Type = common.ColumnTypeString
+		}
+	}
+}
+
 func Marshal(cfg *common.Config, table *common.Table) error {
+	output, err := tableValue(cfg, table)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	// minify json output
+	if cfg.GetExtensionBool("minify", false) {
+		data, err = json.Marshal(output)
+	} else {
+		data, err = json.MarshalIndent(output, "", "  ")
+	}
+	// deal with json marshal error
+	if err != nil {
+		return err
+	}
+
+	_, err = cfg.Writer.Write(data)
+	return err
+}
+
+// MarshalDocument writes an object with one key per table, in document order
+func MarshalDocument(cfg *common.Config, doc *common.Document) error {
+	names := doc.UniqueTableNames(nil)
+	minify := cfg.GetExtensionBool("minify", false)
+
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, table := range doc.Tables {
+		output, err := tableValue(cfg, table)
+		if err != nil {
+			return fmt.Errorf("table %q: %w", names[i], err)
+		}
+		key, err := json.Marshal(names[i])
+		if err != nil {
+			return err
+		}
+
+		var value []byte
+		if minify {
+			value, err = json.Marshal(output)
+		} else {
+			value, err = json.MarshalIndent(output, "  ", "  ")
+		}
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		if minify {
+			buf.Write(key)
+			buf.WriteString(":")
+		} else {
+			buf.WriteString("\n  ")
+			buf.Write(key)
+			buf.WriteString(": ")
+		}
+		buf.Write(value)
+	}
+	if !minify && len(doc.Tables) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}")
+
+	_, err := cfg.Writer.Write(buf.Bytes())
+	return err
+}
+
+// tableValue builds the value encoded for one table in the format given by the format option
+func tableValue(cfg *common.Config, table *common.Table) (interface{}, error) {
 	format := cfg.GetExtensionString("format", "")
 	parsing := cfg.GetExtensionBool("parsing-json", false)
-	minify := cfg.GetExtensionBool("minify", false)
 
-	var data []byte
-	var err error
 	switch format {
 	// 2D Array
 	case "2d":
@@ -153,45 +390,32 @@ func Marshal(cfg *common.Config, table *common.Table) error {
 		output = append(output, headers)
 
 		// Rows
-		for _, row := range table.Rows {
+		for r, row := range table.Rows {
 			if len(row) != len(table.Headers) {
-				return fmt.Errorf("row length %d does not match header length %d", len(row), len(table.Headers))
+				return nil, fmt.Errorf("row length %d does not match header length %d", len(row), len(table.Headers))
 			}
 			record := make([]interface{}, len(table.Headers))
 			for i := range table.Headers {
 				if i < len(row) {
-					if parsing {
-						record[i] = common.InferType(row[i])
-					} else {
-						record[i] = row[i]
-					}
+					record[i] = table.Value(r, i, parsing)
 				}
 			}
 			output = append(output, record)
 		}
-
-		if minify {
-			data, err = json.Marshal(output)
-		} else {
-			data, err = json.MarshalIndent(output, "", "  ")
-		}
+		return output, nil
 	// Column Array
 	case "column":
 		columns := make(map[string][]interface{}, len(table.Headers))
 		for _, header := range table.Headers {
 			columns[header] = []interface{}{}
 		}
-		for _, row := range table.Rows {
+		for r, row := range table.Rows {
 			if len(row) != len(table.Headers) {
-				return fmt.Errorf("row length %d does not match header length %d", len(row), len(table.Headers))
+				return nil, fmt.Errorf("row length %d does not match header length %d", len(row), len(table.Headers))
 			}
-			for i, cell := range row {
+			for i := range row {
 				header := table.Headers[i]
-				if parsing {
-					columns[header] = append(columns[header], common.InferType(cell))
-				} else {
-					columns[header] = append(columns[header], cell)
-				}
+				columns[header] = append(columns[header], table.Value(r, i, parsing))
 			}
 		}
 		// convert map[string][]interface{} to []map[string]interface{}
@@ -199,39 +423,19 @@ func Marshal(cfg *common.Config, table *common.Table) error {
 		for _, header := range table.Headers {
 			output = append(output, map[string]interface{}{header: columns[header]})
 		}
-		if minify {
-			data, err = json.Marshal(output)
-		} else {
-			data, err = json.MarshalIndent(output, "", "  ")
-		}
+		return output, nil
 	// Array of Object
 	default:
 		var output []map[string]interface{}
-		for _, row := range table.Rows {
+		for r, row := range table.Rows {
 			record := make(map[string]interface{})
 			for i, header := range table.Headers {
 				if i < len(row) {
-					if parsing {
-						record[header] = common.InferType(row[i])
-					} else {
-						record[header] = row[i]
-					}
+					record[header] = table.Value(r, i, parsing)
 				}
 			}
 			output = append(output, record)
 		}
-		// minify json output
-		if minify {
-			data, err = json.Marshal(output)
-		} else {
-			data, err = json.MarshalIndent(output, "", "  ")
-		}
+		return output, nil
 	}
-	// deal with json marshal error
-	if err != nil {
-		return err
-	}
-
-	_, err = cfg.Writer.Write(data)
-	return err
 }
@@ -1,6 +1,7 @@
 package json
 
 import (
+	"bytes"
 	"encoding/json"
 	"sort"
 	"strings"
@@ -144,6 +145,23 @@ func Test2dFormatMissingValues(t *testing.T) {
 	assert.Equal(t, 1, len(table.Rows))
 	// Missing value should be "NULL"
 	assert.Equal(t, []string{"3", "NULL"}, table.Rows[0])
+	assert.True(t, table.IsNull(0, 1))
+}
+
+func TestNullRoundTrip(t *testing.T) {
+	input := `[{"a":null,"b":"null","c":""}]`
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"NULL", "null", ""}, table.Rows[0])
+	assert.True(t, table.IsNull(0, 0))
+	assert.False(t, table.IsNull(0, 1))
+
+	// Only the JSON null is written as null
+	var buf bytes.Buffer
+	cfg := &common.Config{Writer: &buf, Extension: map[string]string{"minify": "true"}}
+	assert.NoError(t, Marshal(cfg, table))
+	assert.JSONEq(t, input, buf.String())
 }
 
 // Test2dFormatNilHeaders tests handling of nil headers (Bug 5 fix)
@@ -284,9 +302,153 @@ func Test2dFormatRoundTrip(t *testing.T) {
 	err = json.Unmarshal([]byte(buf.String()), &output)
 	assert.NoError(t, err)
 
-	// Verify
+	// Verify: headers stay strings, data keeps its native JSON number type
 	assert.Equal(t, 3, len(output))
 	assert.Equal(t, []interface{}{"1", "2"}, output[0])
-	assert.Equal(t, []interface{}{"3", "4"}, output[1])
-	assert.Equal(t, []interface{}{"5", "6"}, output[2])
+	assert.Equal(t, []interface{}{float64(3), float64(4)}, output[1])
+	assert.Equal(t, []interface{}{float64(5), float64(6)}, output[2])
+}
+
+// TestKeyedFormat tests that the keyed format is written as an array of objects, as yaml writes it
+func TestKeyedFormat(t *testing.T) {
+	table := &common.Table{Headers: []string{"host", "port"}, Rows: [][]string{{"web01", "80"}}}
+	var buf strings.Builder
+	cfg := &common.Config{Extension: map[string]string{"format": "keyed", "minify": "true"}, Writer: &buf}
+	assert.NoError(t, Marshal(cfg, table))
+	assert.Equal(t, `[{"host":"web01","port":"80"}]`, buf.String())
+}
+
+// TestUnmarshalColumnTypes tests that native JSON types are recorded in the column schema
+func TestUnmarshalColumnTypes(t *testing.T) {
+	input := `[{"id": 1, "price": 9.5, "name": "a", "ok": true}, {"id": 2, "price": null, "name": "b", "ok": false}]`
+	cfg := &common.Config{
+		Extension: map[string]string{},
+		Reader:    strings.NewReader(input),
+	}
+	table := &common.Table{}
+	err := Unmarshal(cfg, table)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "ok", "price"}, table.Headers)
+	assert.Equal(t, []common.Column{
+		{Name: "id", Type: common.ColumnTypeInt, SourceType: "number"},
+		{Name: "name", Type: common.ColumnTypeString, SourceType: "string"},
+		{Name: "ok", Type: common.ColumnTypeBool, SourceType: "boolean"},
+		{Name: "price", Type: common.ColumnTypeFloat, Nullable: true, SourceType: "number"},
+	}, table.Columns)
+}
+
+// TestMarshalTypedColumns tests that string columns keep numeric-looking values quoted
+func TestMarshalTypedColumns(t *testing.T) {
+	var buf strings.Builder
+	cfg := &common.Config{
+		Extension: map[string]string{"parsing-json": "true"},
+		Writer:    &buf,
+	}
+	table := &common.Table{
+		Headers: []string{"zip", "count"},
+		Rows:    [][]string{{"01234", "7"}},
+		Columns: []common.Column{
+			{Name: "zip", Type: common.ColumnTypeString},
+			{Name: "count", Type: common.ColumnTypeInt},
+		},
+	}
+	err := Marshal(cfg, table)
+	assert.NoError(t, err)
+
+	var output []map[string]interface{}
+	err = json.Unmarshal([]byte(buf.String()), &output)
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{{"zip": "01234", "count": float64(7)}}, output)
+}
+
+// TestUnmarshalDocumentKeyedObject tests that object keys name the tables and keep their order
+func TestUnmarshalDocumentKeyedObject(t *testing.T) {
+	input := `{"users": [{"id": 1}], "orders": [{"no": "a"}, {"no": "b"}]}`
+	cfg := &common.Config{
+		Extension: map[string]string{},
+		Reader:    strings.NewReader(input),
+	}
+	doc := &common.Document{}
+	err := UnmarshalDocument(cfg, doc)
+
+	assert.NoError(t, err)
+	assert.Len(t, doc.Tables, 2)
+	assert.Equal(t, "users", doc.Tables[0].Name)
+	assert.Equal(t, [][]string{{"1"}}, doc.Tables[0].Rows)
+	assert.Equal(t, "orders", doc.Tables[1].Name)
+	assert.Equal(t, [][]string{{"a"}, {"b"}}, doc.Tables[1].Rows)
+
+	// Unmarshal reads the first table of a keyed object
+	cfg.Reader = strings.NewReader(input)
+	table := &common.Table{}
+	err = Unmarshal(cfg, table)
+	assert.NoError(t, err)
+	assert.Equal(t, "users", table.Name)
+	assert.Equal(t, []string{"id"}, table.Headers)
+}
+
+func TestMarshalDocument(t *testing.T) {
+	doc := &common.Document{Tables: []*common.Table{
+		{Name: "users", Headers: []string{"id"}, Rows: [][]string{{"1"}}},
+		{Headers: []string{"no"}, Rows: [][]string{{"a"}}},
+	}}
+
+	var buf strings.Builder
+	cfg := &common.Config{Extension: map[string]string{"minify": "true"}, Writer: &buf}
+	err := MarshalDocument(cfg, doc)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"users":[{"id":"1"}],"Table2":[{"no":"a"}]}`, buf.String())
+
+	buf.Reset()
+	cfg.Extension = map[string]string{"format": "2d"}
+	err = MarshalDocument(cfg, doc)
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "users": [
+    [
+      "id"
+    ],
+    [
+      "1"
+    ]
+  ],
+  "Table2": [
+    [
+      "no"
+    ],
+    [
+      "a"
+    ]
+  ]
+}`, buf.String())
+
+	// Tables with the same name get unique keys
+	buf.Reset()
+	doc.Tables[1].Name = "Users"
+	cfg.Extension = map[string]string{"minify": "true"}
+	err = MarshalDocument(cfg, doc)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"users":[{"id":"1"}],"Users (2)":[{"no":"a"}]}`, buf.String())
+}
+
+func TestUnmarshalDetectedLayout(t *testing.T) {
+	// Without the format option the layout is taken from the array
+	tests := map[string]string{
+		"2d":     `[["a", "b"], [1, "x"]]`,
+		"column": `[{"a": [1]}, {"b": ["x"]}]`,
+		"object": `[{"a": 1, "b": "x"}]`,
+	}
+	for name, input := range tests {
+		table := &common.Table{}
+		assert.NoError(t, Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table), name)
+		assert.Equal(t, []string{"a", "b"}, table.Headers, name)
+		assert.Equal(t, [][]string{{"1", "x"}}, table.Rows, name)
+	}
+
+	// Objects sharing a key that holds arrays are rows
+	table := &common.Table{}
+	assert.NoError(t, Unmarshal(&common.Config{Reader: strings.NewReader(`[{"a": [1]}, {"a": [2]}]`)}, table))
+	assert.Equal(t, []string{"a"}, table.Headers)
+	assert.Len(t, table.Rows, 2)
 }
@@ -0,0 +1,58 @@
+package jsonl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "jsonl",
+		Description: "JSON Lines",
+		Aliases:     []string{"jsonlines"},
+		Extensions:  []string{".jsonl", ".jsonlines"},
+		Detect:      detect,
+		DetectOrder: 7,
+		Params: []common.FormatParam{
+			{Name: "parsing-json", DefaultValue: "false", AllowedValues: "true, false", Description: "Parsing JSON"},
+			{Name: "infer-rows", DefaultValue: strconv.Itoa(defaultInferRows), Validate: validateInferRows, Description: "Number of records held in memory when streaming, the rest of the input is copied to a temporary file", Use: common.ParamRead},
+		},
+		Unmarshal:    Unmarshal,
+		Marshal:      Marshal,
+		NewRowReader: NewRowReader,
+		NewRowWriter: NewRowWriter,
+	})
+}
+
+// detect scores content with one JSON object per line
+func detect(sample string) int {
+	lines := common.SampleLines(sample, 20)
+	if len(lines) > 1 && len(sample) >= common.DetectSampleSize {
+		lines = lines[:len(lines)-1] // the last line may be cut off
+	}
+	if len(lines) == 0 {
+		return common.DetectNone
+	}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") || !json.Valid([]byte(line)) {
+			return common.DetectNone
+		}
+	}
+	if len(lines) == 1 {
+		return 50 // a single object is JSON as well
+	}
+	return 96
+}
+
+// validateInferRows accepts a positive number of records
+func validateInferRows(value string) error {
+	if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+		return fmt.Errorf("invalid value %q, expected a positive number of records", value)
+	}
+	return nil
+}
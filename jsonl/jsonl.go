@@ -4,11 +4,18 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/martianzhang/tableconvert/common"
 )
 
+// defaultInferRows is the number of records a streaming reader holds in memory unless --infer-rows is given
+const defaultInferRows = 1000
+
 func Unmarshal(cfg *common.Config, table *common.Table) error {
 	scanner := bufio.NewScanner(cfg.Reader)
 	var records []map[string]interface{}
@@ -22,13 +29,18 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 		}
 
 		var record map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &record); err != nil {
+		decoder := json.NewDecoder(strings.NewReader(line))
+		decoder.UseNumber()
+		if err := decoder.Decode(&record); err != nil {
 			return &common.ParseError{
 				LineNumber: lineNumber,
 				Message:    fmt.Sprintf("invalid JSON: %v", err),
 				Line:       line,
 			}
 		}
+		if err := cfg.CheckRow(len(records), nil); err != nil {
+			return err
+		}
 		records = append(records, record)
 	}
 
@@ -60,56 +72,314 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 	sort.Strings(headers)
 
 	// Convert records to rows
+	columns := common.NewColumns(headers)
 	rows := make([][]string, len(records))
+	table.TrackNulls()
 	for i, record := range records {
 		row := make([]string, len(headers))
 		for j, header := range headers {
 			if val, ok := record[header]; ok {
-				row[j] = fmt.Sprintf("%v", val)
+				row[j] = cellValue(val, &columns[j])
+				if val == nil {
+					table.SetNull(i, j)
+				}
 			} else {
 				row[j] = "" // empty string for missing fields
+				columns[j].Nullable = true
 			}
 		}
+		if err := cfg.CheckRow(i, row); err != nil {
+			return err
+		}
 		rows[i] = row
 	}
+	for i := range columns {
+		if columns[i].Type == common.ColumnTypeUnknown {
+			columns[i].Type = common.ColumnTypeString
+		}
+	}
 
 	table.Headers = headers
 	table.Rows = rows
+	table.Columns = columns
 	return nil
 }
 
-func Marshal(cfg *common.Config, table *common.Table) error {
-	parsing := cfg.GetExtensionBool("parsing-json", false)
+// cellValue converts a decoded JSON value to its cell text and records its type in column
+func cellValue(val interface{}, column *common.Column) string {
+	switch v := val.(type) {
+	case nil:
+		column.Nullable = true
+		return common.DefaultNullString
+	case json.Number:
+		if _, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+			column.Observe(common.ColumnTypeInt, "number")
+		} else {
+			column.Observe(common.ColumnTypeFloat, "number")
+		}
+		return v.String()
+	case bool:
+		column.Observe(common.ColumnTypeBool, "boolean")
+		return strconv.FormatBool(v)
+	case string:
+		column.Observe(common.ColumnTypeString, "string")
+		return v
+	default:
+		column.Observe(common.ColumnTypeString, "object")
+		return fmt.Sprintf("%v", v)
+	}
+}
 
-	writer := bufio.NewWriter(cfg.Writer)
-	defer writer.Flush()
+// rowReader streams JSONL records. The keys and value types of every record
+// give the columns, as with Unmarshal. The first --infer-rows records are kept
+// until Next returns them; when the input goes on, the rest of it is copied to
+// a temporary file while its keys are collected, and Next reads the copy.
+type rowReader struct {
+	scanner    *bufio.Scanner
+	lineNumber int
+	columns    []common.Column
+	pending    []map[string]interface{} // first records, returned before the spooled ones
+	spool      *os.File                 // temporary copy of the input after the pending records
+	nulls      []bool                   // NULL flags of the row last returned by Next
+}
 
-	// Each row becomes a JSON object with headers as keys
-	for _, row := range table.Rows {
-		if len(row) != len(table.Headers) {
-			return fmt.Errorf("row length %d does not match header length %d", len(row), len(table.Headers))
-		}
-		record := make(map[string]interface{})
-		for i, header := range table.Headers {
-			if parsing {
-				record[header] = common.InferType(row[i])
-			} else {
-				record[header] = row[i]
+// NewRowReader returns a streaming JSONL reader. The whole input is read
+// immediately, holding at most --infer-rows records in memory.
+func NewRowReader(cfg *common.Config) (common.RowReader, error) {
+	r := &rowReader{scanner: bufio.NewScanner(cfg.Reader)}
+	if err := r.scan(cfg, cfg.GetExtensionInt("infer-rows", defaultInferRows)); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// scan reads every record into the columns. Up to limit records are kept in
+// pending, the lines after them are copied to the spool file and rewound for Next.
+func (r *rowReader) scan(cfg *common.Config, limit int) error {
+	columns := make(map[string]*common.Column)
+	present := make(map[string]int) // key -> number of records holding it
+	records := 0
+	var spool *bufio.Writer
+	spoolLine := 0 // lines read before the first spooled one
+	for {
+		record, err := r.readRecord()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := cfg.CheckRow(records, nil); err != nil {
+			return err
+		}
+		if len(r.pending) < limit {
+			r.pending = append(r.pending, record)
+		} else {
+			if spool == nil {
+				if r.spool, err = os.CreateTemp("", "tableconvert-*.jsonl"); err != nil {
+					return err
+				}
+				spool = bufio.NewWriter(r.spool)
+				spoolLine = r.lineNumber - 1
+			}
+			if _, err := spool.Write(r.scanner.Bytes()); err != nil {
+				return err
+			}
+			if err := spool.WriteByte('\n'); err != nil {
+				return err
 			}
 		}
+		records++
+		for key, val := range record {
+			if columns[key] == nil {
+				columns[key] = &common.Column{Name: key}
+			}
+			cellValue(val, columns[key])
+			present[key]++
+		}
+	}
+	if records == 0 {
+		return &common.ParseError{
+			LineNumber: 0,
+			Message:    "empty JSONL file",
+			Line:       "",
+		}
+	}
 
-		jsonData, err := json.Marshal(record)
-		if err != nil {
-			return fmt.Errorf("failed to marshal JSON: %w", err)
+	headers := make([]string, 0, len(columns))
+	for key := range columns {
+		headers = append(headers, key)
+	}
+	sort.Strings(headers)
+	r.columns = make([]common.Column, len(headers))
+	for i, header := range headers {
+		r.columns[i] = *columns[header]
+		if present[header] < records {
+			r.columns[i].Nullable = true
+		}
+		if r.columns[i].Type == common.ColumnTypeUnknown {
+			r.columns[i].Type = common.ColumnTypeString
+		}
+	}
+
+	if spool != nil {
+		if err := spool.Flush(); err != nil {
+			return err
+		}
+		if _, err := r.spool.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		r.scanner = bufio.NewScanner(r.spool)
+		r.lineNumber = spoolLine
+	}
+	return nil
+}
+
+// readRecord decodes the next non-empty line
+func (r *rowReader) readRecord() (map[string]interface{}, error) {
+	for r.scanner.Scan() {
+		r.lineNumber++
+		line := r.scanner.Text()
+		if line == "" {
+			continue // skip empty lines
+		}
+
+		var record map[string]interface{}
+		decoder := json.NewDecoder(strings.NewReader(line))
+		decoder.UseNumber()
+		if err := decoder.Decode(&record); err != nil {
+			return nil, &common.ParseError{
+				LineNumber: r.lineNumber,
+				Message:    fmt.Sprintf("invalid JSON: %v", err),
+				Line:       line,
+			}
 		}
+		return record, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read JSONL: %w", err)
+	}
+	return nil, io.EOF
+}
 
-		if _, err := writer.Write(jsonData); err != nil {
-			return fmt.Errorf("failed to write JSON: %w", err)
+// toRow converts a record to a row and its NULL flags
+func (r *rowReader) toRow(record map[string]interface{}) ([]string, []bool) {
+	row := make([]string, len(r.columns))
+	nulls := make([]bool, len(r.columns))
+	for j := range r.columns {
+		if val, ok := record[r.columns[j].Name]; ok {
+			row[j] = cellValue(val, &r.columns[j])
+			nulls[j] = val == nil
+		} else {
+			row[j] = "" // empty string for missing fields
+			r.columns[j].Nullable = true
 		}
-		if _, err := writer.WriteString("\n"); err != nil {
-			return fmt.Errorf("failed to write newline: %w", err)
+	}
+	return row, nulls
+}
+
+func (r *rowReader) Columns() []common.Column {
+	return r.columns
+}
+
+func (r *rowReader) Next() ([]string, error) {
+	var record map[string]interface{}
+	if len(r.pending) > 0 {
+		record, r.pending = r.pending[0], r.pending[1:]
+	} else {
+		var err error
+		if record, err = r.readRecord(); err != nil {
+			return nil, err
 		}
 	}
+	row, nulls := r.toRow(record)
+	r.nulls = nulls
+	return row, nil
+}
+
+func (r *rowReader) Nulls() []bool {
+	return r.nulls
+}
+
+// Close removes the temporary copy of the input
+func (r *rowReader) Close() error {
+	if r.spool == nil {
+		return nil
+	}
+	r.spool.Close()
+	return os.Remove(r.spool.Name())
+}
+
+// rowWriter writes each row as one JSON object
+type rowWriter struct {
+	writer  *bufio.Writer
+	columns []common.Column
+	parsing bool
+}
+
+// NewRowWriter returns a streaming JSONL writer
+func NewRowWriter(cfg *common.Config) (common.RowWriter, error) {
+	return newRowWriter(cfg), nil
+}
+
+func newRowWriter(cfg *common.Config) *rowWriter {
+	return &rowWriter{
+		writer:  bufio.NewWriter(cfg.Writer),
+		parsing: cfg.GetExtensionBool("parsing-json", false),
+	}
+}
+
+func (w *rowWriter) WriteHeader(columns []common.Column) error {
+	w.columns = columns
+	return nil
+}
+
+// WriteRow writes a JSON object with headers as keys
+func (w *rowWriter) WriteRow(row []string) error {
+	return w.WriteNullRow(row, nil)
+}
 
+// WriteNullRow writes a JSON object with headers as keys and null for NULL cells
+func (w *rowWriter) WriteNullRow(row []string, nulls []bool) error {
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("row length %d does not match header length %d", len(row), len(w.columns))
+	}
+	record := make(map[string]interface{})
+	for i, column := range w.columns {
+		record[column.Name] = common.RowValue(row, nulls, i, column.Type, w.parsing)
+	}
+
+	jsonData, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	if _, err := w.writer.Write(jsonData); err != nil {
+		return fmt.Errorf("failed to write JSON: %w", err)
+	}
+	if _, err := w.writer.WriteString("\n"); err != nil {
+		return fmt.Errorf("failed to write newline: %w", err)
+	}
 	return nil
 }
+
+func (w *rowWriter) Close() error {
+	return w.writer.Flush()
+}
+
+func Marshal(cfg *common.Config, table *common.Table) error {
+	writer := newRowWriter(cfg)
+	if err := writer.WriteHeader(table.HeaderColumns()); err != nil {
+		return err
+	}
+
+	// Each row becomes a JSON object with headers as keys
+	for i, row := range table.Rows {
+		if err := writer.WriteNullRow(row, table.RowNulls(i)); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
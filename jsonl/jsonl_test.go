@@ -3,11 +3,15 @@ package jsonl
 import (
 	"bytes"
 	"encoding/json"
+	"io"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/martianzhang/tableconvert/common"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMarshal(t *testing.T) {
@@ -79,3 +83,116 @@ func TestUnmarshalDeterministicHeaders(t *testing.T) {
 	assert.Equal(t, "2", table.Rows[0][0]) // a=2
 	assert.Equal(t, "4", table.Rows[1][2]) // z=4
 }
+
+func TestRowReader(t *testing.T) {
+	input := "{\"name\":\"Alice\",\"age\":30}\n\n{\"name\":\"Bob\"}\n"
+	reader, err := NewRowReader(&common.Config{Reader: strings.NewReader(input)})
+	assert.NoError(t, err)
+
+	row, err := reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"30", "Alice"}, row)
+	row, err = reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "Bob"}, row)
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+
+	columns := reader.Columns()
+	assert.Equal(t, "age", columns[0].Name)
+	assert.Equal(t, common.ColumnTypeInt, columns[0].Type)
+	assert.True(t, columns[0].Nullable)
+}
+
+func TestNulls(t *testing.T) {
+	input := "{\"a\":null,\"b\":\"null\"}\n{\"a\":\"\",\"b\":\"x\"}\n"
+	table := &common.Table{}
+	assert.NoError(t, Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table))
+	assert.Equal(t, [][]string{{"NULL", "null"}, {"", "x"}}, table.Rows)
+	assert.True(t, table.IsNull(0, 0))
+	assert.False(t, table.IsNull(0, 1))
+	assert.False(t, table.IsNull(1, 0))
+
+	// null stays null, the string "null" and the empty string stay strings
+	var buf bytes.Buffer
+	assert.NoError(t, Marshal(&common.Config{Writer: &buf}, table))
+	assert.Equal(t, input, buf.String())
+
+	reader, err := NewRowReader(&common.Config{Reader: strings.NewReader(input)})
+	assert.NoError(t, err)
+	nullReader := reader.(common.NullRowReader)
+	_, err = reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, nullReader.Nulls())
+	_, err = reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{false, false}, nullReader.Nulls())
+}
+
+func TestRowReaderKeysAndTypes(t *testing.T) {
+	// Keys missing from the first record and values of other types give the same
+	// columns and rows as Unmarshal
+	input := "{\"a\":true}\n{\"a\":\"1\",\"b\":2}\n{\"b\":2.5}\n"
+	reader, err := NewRowReader(&common.Config{Reader: strings.NewReader(input)})
+	require.NoError(t, err)
+	table := &common.Table{}
+	require.NoError(t, common.ReadAllRows(&common.Config{}, reader, table))
+
+	expected := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: strings.NewReader(input)}, expected))
+	assert.Equal(t, [][]string{{"true", ""}, {"1", "2"}, {"", "2.5"}}, table.Rows)
+	assert.Equal(t, expected.Rows, table.Rows)
+	assert.Equal(t, expected.Columns, table.Columns)
+	assert.Equal(t, common.ColumnTypeString, table.Columns[0].Type)
+	assert.Equal(t, common.ColumnTypeFloat, table.Columns[1].Type)
+	assert.True(t, table.Columns[1].Nullable)
+}
+
+func TestRowReaderInferRows(t *testing.T) {
+	// Records after the first --infer-rows are read from a temporary copy of the
+	// input, so a key first seen in them is still a column
+	input := "{\"a\":1}\n{\"a\":2,\"b\":\"x\"}\n\n{\"b\":\"y\"}\n{\"a\":3,\"c\":true}\n"
+	cfg := &common.Config{Reader: strings.NewReader(input), Extension: map[string]string{"infer-rows": "2"}}
+	reader, err := NewRowReader(cfg)
+	require.NoError(t, err)
+	spool := reader.(*rowReader).spool.Name()
+	table := &common.Table{}
+	require.NoError(t, common.ReadAllRows(&common.Config{}, reader, table))
+
+	expected := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: strings.NewReader(input)}, expected))
+	assert.Equal(t, []string{"a", "b", "c"}, table.Headers)
+	assert.Equal(t, expected.Rows, table.Rows)
+	assert.Equal(t, expected.Columns, table.Columns)
+	assert.Equal(t, common.ColumnTypeBool, table.Columns[2].Type)
+
+	require.NoError(t, reader.(io.Closer).Close())
+	_, err = os.Stat(spool)
+	assert.True(t, os.IsNotExist(err))
+
+	// An input within --infer-rows is not copied
+	reader, err = NewRowReader(&common.Config{Reader: strings.NewReader(input)})
+	require.NoError(t, err)
+	assert.Nil(t, reader.(*rowReader).spool)
+	assert.Len(t, reader.Columns(), 3)
+
+	// Invalid JSON after the first records is reported before any row is read
+	_, err = NewRowReader(&common.Config{Reader: strings.NewReader(input + "{bad\n"), Extension: map[string]string{"infer-rows": "1"}})
+	var parseErr *common.ParseError
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 6, parseErr.LineNumber)
+
+	assert.NoError(t, validateInferRows("10"))
+	assert.Error(t, validateInferRows("0"))
+	assert.Error(t, validateInferRows("x"))
+}
+
+func TestRowReaderErrors(t *testing.T) {
+	_, err := NewRowReader(&common.Config{Reader: strings.NewReader("\n\n")})
+	assert.ErrorContains(t, err, "empty JSONL file")
+
+	_, err = NewRowReader(&common.Config{Reader: strings.NewReader("{\"a\":1}\n{bad\n")})
+	var parseErr *common.ParseError
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 2, parseErr.LineNumber)
+}
@@ -0,0 +1,44 @@
+package latex
+
+import (
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "latex",
+		Description: "LaTeX table",
+		Extensions:  []string{".tex", ".latex"},
+		Detect:      detect,
+		DetectOrder: 3,
+		Params: []common.FormatParam{
+			{Name: "bold-first-column", DefaultValue: "false", AllowedValues: "true, false", Description: "Bold first column"},
+			{Name: "bold-first-row", DefaultValue: "false", AllowedValues: "true, false", Description: "Bold first row"},
+			{Name: "borders", DefaultValue: "1111,1111", AllowedValues: "1111,1111, 1101,1101, 0000,1101, 1111,0100, 0000,0100, 0000,0000", Description: "Table Border"},
+			{Name: "caption", DefaultValue: "", AllowedValues: "", Description: "Table Caption"},
+			{Name: "escape", DefaultValue: "true", AllowedValues: "true, false", Description: "Escape LaTeX table"},
+			{Name: "ht", DefaultValue: "false", AllowedValues: "true, false", Description: "Place here or top of page"},
+			{Name: "label", DefaultValue: "", AllowedValues: "", Description: "Table Label"},
+			{Name: "location", DefaultValue: "above", AllowedValues: "above, below", Description: "Caption Location"},
+			{Name: "mwe", DefaultValue: "false", AllowedValues: "true, false", Description: "Minimal working example"},
+			{Name: "table-align", DefaultValue: "centering", AllowedValues: "centering, raggedleft, raggedright", Description: "Table Alignment"},
+			{Name: "text-align", DefaultValue: "l", AllowedValues: "l, c, r", Description: "Text Alignment"},
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+	})
+}
+
+// detect scores content holding a LaTeX tabular
+func detect(sample string) int {
+	switch {
+	case strings.Contains(sample, "\\begin{tabular"):
+		return 95
+	case strings.Contains(sample, "\\hline") && strings.Contains(sample, "&"):
+		return 60
+	}
+	return common.DetectNone
+}
@@ -3,34 +3,61 @@ package latex
 import (
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 
 	"github.com/martianzhang/tableconvert/common"
 )
 
-func Unmarshal(cfg *common.Config, table *common.Table) error {
+// parse reads up to limit tabular environments of the input, all of them when
+// limit is 0. A table is named after the \caption of its table float, written
+// above or below the tabular.
+func parse(cfg *common.Config, limit int) ([]*common.Table, error) {
 	if cfg == nil || cfg.Reader == nil {
-		return fmt.Errorf("Unmarshal: config or reader cannot be nil")
+		return nil, fmt.Errorf("Unmarshal: config or reader cannot be nil")
 	}
 
 	content, err := io.ReadAll(cfg.Reader)
 	if err != nil {
-		return fmt.Errorf("Unmarshal: failed to read input: %v", err)
+		return nil, fmt.Errorf("Unmarshal: failed to read input: %v", err)
 	}
 
 	lines := strings.Split(string(content), "\n")
-	table.Headers = []string{}
-	table.Rows = [][]string{}
+	var tables []*common.Table
+	var table *common.Table
 
-	inTabular := false
+	// The caption of the current float and the index of its first table
+	caption, floatStart := "", -1
 	var headersProcessed bool
 	var expectedColumns int
 
-	for _, line := range lines {
+	for i, line := range lines {
 		line = strings.TrimSpace(line)
 
+		if strings.HasPrefix(line, "\\begin{table}") || strings.HasPrefix(line, "\\begin{table*}") {
+			caption, floatStart = "", len(tables)
+			continue
+		}
+		if strings.HasPrefix(line, "\\end{table}") || strings.HasPrefix(line, "\\end{table*}") {
+			caption, floatStart = "", -1
+			continue
+		}
+		if strings.HasPrefix(line, "\\caption") && table == nil {
+			caption = parseCaption(line)
+			// A caption below the tabular names the table above it
+			if floatStart >= 0 && floatStart < len(tables) && tables[len(tables)-1].Name == "" {
+				tables[len(tables)-1].Name = caption
+				caption = ""
+			}
+			continue
+		}
+
 		if strings.HasPrefix(line, "\\begin{tabular}") {
-			inTabular = true
+			if limit > 0 && len(tables) == limit {
+				break
+			}
+			table = &common.Table{Name: caption, Headers: []string{}, Rows: [][]string{}}
+			caption, headersProcessed, expectedColumns = "", false, 0
 			// Parse column specification to determine expected column count
 			start := strings.LastIndex(line, "{") + 1
 			end := strings.LastIndex(line, "}")
@@ -45,30 +72,52 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 		}
 
 		if strings.HasPrefix(line, "\\end{tabular}") {
-			inTabular = false
+			if table != nil {
+				tables = append(tables, table)
+				table = nil
+			}
 			continue
 		}
 
-		if !inTabular {
+		if table == nil {
 			continue
 		}
 
-		if strings.HasPrefix(line, "\\hline") || line == "" {
+		if strings.HasPrefix(line, "\\hline") || strings.HasPrefix(line, "\\cline") || line == "" {
 			continue
 		}
 
+		// Row index of the line, -1 for the header row
+		rowIndex := len(table.Rows)
+		if !headersProcessed {
+			rowIndex = -1
+		}
+
 		// Process table content
 		cells := []string{}
 		// Split the line properly, handling escaped & characters
 		parts := splitLaTeXLine(line)
 		for _, part := range parts {
-			cell := strings.TrimSpace(part)
+			cell, rowSpan, colSpan := parseSpanMacros(strings.TrimSpace(part))
+			if colSpan > common.MaxSpan {
+				return nil, &common.ParseError{
+					LineNumber: i + 1,
+					Message:    fmt.Sprintf("\\multicolumn spans %d columns, more than the limit of %d", colSpan, common.MaxSpan),
+					Line:       line,
+				}
+			}
 			// Handle LaTeX special cases
 			if cell == "~" || cell == "\\textasciitilde{}" {
 				cell = "" // Empty cell
 			}
-			cell = common.LaTeXUnescape(cell)
-			cells = append(cells, cell)
+			if rowSpan > 1 || colSpan > 1 {
+				table.Spans = append(table.Spans, common.Span{Row: rowIndex, Col: len(cells), RowSpan: rowSpan, ColSpan: colSpan})
+			}
+			cells = append(cells, common.LaTeXUnescape(cell))
+			// Cells covered by \multicolumn are not written
+			for i := 1; i < colSpan; i++ {
+				cells = append(cells, "")
+			}
 		}
 
 		// Pad or truncate cells to match expected column count
@@ -82,6 +131,9 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 			}
 		}
 
+		if err := cfg.CheckRow(rowIndex, cells); err != nil {
+			return nil, err
+		}
 		if !headersProcessed && strings.Contains(line, "\\\\") {
 			table.Headers = cells
 			headersProcessed = true
@@ -95,9 +147,119 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 		}
 	}
 
+	// A tabular without \end{tabular} keeps the rows read
+	if table != nil {
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// parseCaption returns the text of a \caption[short]{text} line
+func parseCaption(line string) string {
+	rest := strings.TrimSpace(strings.TrimPrefix(line, "\\caption"))
+	if strings.HasPrefix(rest, "[") {
+		if end := strings.Index(rest, "]"); end >= 0 {
+			rest = strings.TrimSpace(rest[end+1:])
+		}
+	}
+	text, _, ok := braceGroup(rest)
+	if !ok {
+		return ""
+	}
+	return common.LaTeXUnescape(strings.TrimSpace(text))
+}
+
+// Unmarshal reads the first tabular of the input. Without one it leaves table empty.
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	tables, err := parse(cfg, 1)
+	if err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		table.Headers = []string{}
+		table.Rows = [][]string{}
+		return nil
+	}
+	*table = *tables[0]
 	return nil
 }
 
+// UnmarshalDocument reads every tabular of the input
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	tables, err := parse(cfg, 0)
+	if err != nil {
+		return err
+	}
+	doc.Tables = append(doc.Tables, tables...)
+	return nil
+}
+
+// parseSpanMacros unwraps \multicolumn{cols}{spec}{text} and
+// \multirow[pos]{rows}{width}{text}, in any nesting, returning the text and the spans
+func parseSpanMacros(cell string) (string, int, int) {
+	rowSpan, colSpan := 1, 1
+	for {
+		var macro string
+		switch {
+		case strings.HasPrefix(cell, "\\multicolumn"):
+			macro = "\\multicolumn"
+		case strings.HasPrefix(cell, "\\multirow"):
+			macro = "\\multirow"
+		default:
+			return cell, rowSpan, colSpan
+		}
+
+		rest := strings.TrimSpace(strings.TrimPrefix(cell, macro))
+		if macro == "\\multirow" && strings.HasPrefix(rest, "[") {
+			if end := strings.Index(rest, "]"); end >= 0 {
+				rest = strings.TrimSpace(rest[end+1:])
+			}
+		}
+		var args []string
+		for len(args) < 3 {
+			arg, remaining, ok := braceGroup(rest)
+			if !ok {
+				return cell, rowSpan, colSpan
+			}
+			args = append(args, arg)
+			rest = remaining
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(args[0]))
+		if err != nil || n < 1 {
+			return cell, rowSpan, colSpan
+		}
+		if macro == "\\multicolumn" {
+			colSpan = n
+		} else {
+			rowSpan = n
+		}
+		cell = strings.TrimSpace(args[2])
+	}
+}
+
+// braceGroup returns the content of the {...} group at the start of s and the text after it
+func braceGroup(s string) (string, string, bool) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") {
+		return "", s, false
+	}
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++ // skip escaped character
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return s[1:i], s[i+1:], true
+			}
+		}
+	}
+	return "", s, false
+}
+
 // splitLaTeXLine properly splits a LaTeX table line into cells
 func splitLaTeXLine(line string) []string {
 	// Remove the line terminator if present
@@ -159,29 +321,20 @@ func Marshal(cfg *common.Config, table *common.Table) error {
 	writer.Write([]byte("\\begin{tabular}{" + colSpec + "}\n"))
 	writer.Write([]byte("\\hline\n"))
 
+	layout := table.SpanLayout()
+	width := len(table.Headers)
+
 	// Write headers if they exist
 	if len(table.Headers) > 0 {
-		headerLine := ""
-		for i, header := range table.Headers {
-			if i > 0 {
-				headerLine += " & "
-			}
-			headerLine += common.LaTeXEscape(header)
-		}
-		headerLine += " \\\\\n\\hline\n"
+		headerLine := formatRow(layout, -1, table.Headers)
+		headerLine += " \\\\\n" + rule(layout, -1, width) + "\n"
 		writer.Write([]byte(headerLine))
 	}
 
 	// Write data rows
-	for _, row := range table.Rows {
-		rowLine := ""
-		for i, cell := range row {
-			if i > 0 {
-				rowLine += " & "
-			}
-			rowLine += common.LaTeXEscape(cell)
-		}
-		rowLine += " \\\\\n\\hline\n"
+	for i, row := range table.Rows {
+		rowLine := formatRow(layout, i, row)
+		rowLine += " \\\\\n" + rule(layout, i, max(width, len(row))) + "\n"
 		writer.Write([]byte(rowLine))
 	}
 
@@ -190,3 +343,65 @@ func Marshal(cfg *common.Config, table *common.Table) error {
 
 	return nil
 }
+
+// formatRow joins the cells of a row. Merged cells are written with
+// \multicolumn and \multirow (which needs \usepackage{multirow}), the cells
+// they cover in the rows below are left empty.
+func formatRow(layout common.SpanLayout, row int, cells []string) string {
+	var parts []string
+	for col := 0; col < len(cells); {
+		if span, ok := layout.At(row, col); ok {
+			cell := common.LaTeXEscape(cells[col])
+			if span.RowSpan > 1 {
+				cell = fmt.Sprintf("\\multirow{%d}{*}{%s}", span.RowSpan, cell)
+			}
+			if span.ColSpan > 1 {
+				cell = fmt.Sprintf("\\multicolumn{%d}{l}{%s}", span.ColSpan, cell)
+			}
+			parts = append(parts, cell)
+			col += span.ColSpan
+			continue
+		}
+		if span, ok := layout.CoveredBy(row, col); ok && span.Row < row {
+			// Placeholder below a \multirow cell
+			if span.ColSpan > 1 {
+				parts = append(parts, fmt.Sprintf("\\multicolumn{%d}{l}{}", span.ColSpan))
+			} else {
+				parts = append(parts, "")
+			}
+			col += span.ColSpan
+			continue
+		}
+		parts = append(parts, common.LaTeXEscape(cells[col]))
+		col++
+	}
+	return strings.Join(parts, " & ")
+}
+
+// rule returns the line drawn below a row: \hline, or \cline segments that
+// leave out the columns of \multirow cells continuing into the next row
+func rule(layout common.SpanLayout, row, width int) string {
+	if width == 0 {
+		return "\\hline"
+	}
+	var segments []string
+	start := -1
+	for col := 0; col <= width; col++ {
+		continues := false
+		if col < width {
+			span, ok := layout.CoveredBy(row+1, col)
+			continues = ok && span.Row <= row
+		}
+		switch {
+		case col < width && !continues && start < 0:
+			start = col
+		case (col == width || continues) && start >= 0:
+			segments = append(segments, fmt.Sprintf("\\cline{%d-%d}", start+1, col))
+			start = -1
+		}
+	}
+	if len(segments) == 1 && segments[0] == fmt.Sprintf("\\cline{1-%d}", width) {
+		return "\\hline"
+	}
+	return strings.Join(segments, " ")
+}
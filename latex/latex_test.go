@@ -99,3 +99,108 @@ Bob & {\textbf{25} & Los Angeles} \\
 		{"Bob", "{\\textbf{25} & Los Angeles}"}, // \textbf{} is preserved
 	}, table.Rows)
 }
+
+func TestUnmarshalAndMarshalSpans(t *testing.T) {
+	input := `\begin{tabular}{lll}
+\hline
+Region & \multicolumn{2}{c}{Sales} \\
+\hline
+\multirow{2}{*}{North} & 1 & 2 \\
+\cline{2-3}
+ & 3 & 4 \\
+\hline
+\end{tabular}
+`
+
+	var table common.Table
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, &table)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Region", "Sales", ""}, table.Headers)
+	assert.Equal(t, [][]string{{"North", "1", "2"}, {"", "3", "4"}}, table.Rows)
+	assert.Equal(t, []common.Span{
+		{Row: -1, Col: 1, RowSpan: 1, ColSpan: 2},
+		{Row: 0, Col: 0, RowSpan: 2, ColSpan: 1},
+	}, table.Spans)
+
+	var buf bytes.Buffer
+	err = Marshal(&common.Config{Writer: &buf}, &table)
+	assert.NoError(t, err)
+	assert.Equal(t, `\begin{tabular}{lll}
+\hline
+Region & \multicolumn{2}{l}{Sales} \\
+\hline
+\multirow{2}{*}{North} & 1 & 2 \\
+\cline{2-3}
+ & 3 & 4 \\
+\hline
+\end{tabular}
+`, buf.String())
+}
+
+func TestUnmarshalSpanLimit(t *testing.T) {
+	input := "\\begin{tabular}{l}\n\\multicolumn{50000000}{l}{x} \\\\\n\\end{tabular}\n"
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, &common.Table{})
+	var parseErr *common.ParseError
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 2, parseErr.LineNumber)
+}
+
+func TestParseSpanMacros(t *testing.T) {
+	tests := []struct {
+		cell    string
+		value   string
+		rowSpan int
+		colSpan int
+	}{
+		{`plain`, `plain`, 1, 1},
+		{`\multicolumn{3}{|c|}{Total \{x\}}`, `Total \{x\}`, 1, 3},
+		{`\multirow[t]{2}{*}{A}`, `A`, 2, 1},
+		{`\multicolumn{2}{l}{\multirow{3}{2cm}{B}}`, `B`, 3, 2},
+		{`\multicolumn{x}{l}{C}`, `\multicolumn{x}{l}{C}`, 1, 1},
+	}
+	for _, tt := range tests {
+		value, rowSpan, colSpan := parseSpanMacros(tt.cell)
+		assert.Equal(t, tt.value, value, tt.cell)
+		assert.Equal(t, tt.rowSpan, rowSpan, tt.cell)
+		assert.Equal(t, tt.colSpan, colSpan, tt.cell)
+	}
+}
+
+func TestUnmarshalDocument(t *testing.T) {
+	// A document with two table floats, captioned above and below the tabular
+	input := `\begin{table}[ht]
+\caption{Servers}
+\begin{tabular}{ll}
+\hline
+Host & Role \\
+\hline
+web1 & web \\
+\hline
+\end{tabular}
+\end{table}
+
+\begin{table}
+\begin{tabular}{lll}
+Name & Team & Email \\
+Alice & Ops & alice@example.com \\
+\end{tabular}
+\caption[Owners]{Owners of the servers}
+\end{table}
+`
+	var doc common.Document
+	err := UnmarshalDocument(&common.Config{Reader: strings.NewReader(input)}, &doc)
+	assert.NoError(t, err)
+	assert.Len(t, doc.Tables, 2)
+	assert.Equal(t, "Servers", doc.Tables[0].Name)
+	assert.Equal(t, []string{"Host", "Role"}, doc.Tables[0].Headers)
+	assert.Equal(t, [][]string{{"web1", "web"}}, doc.Tables[0].Rows)
+	assert.Equal(t, "Owners of the servers", doc.Tables[1].Name)
+	assert.Equal(t, []string{"Name", "Team", "Email"}, doc.Tables[1].Headers)
+	assert.Equal(t, [][]string{{"Alice", "Ops", "alice@example.com"}}, doc.Tables[1].Rows)
+
+	// Unmarshal reads the first table only
+	var table common.Table
+	err = Unmarshal(&common.Config{Reader: strings.NewReader(input)}, &table)
+	assert.NoError(t, err)
+	assert.Equal(t, doc.Tables[0], &table)
+}
@@ -0,0 +1,53 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "markdown",
+		Description: "Markdown table",
+		Aliases:     []string{"md"},
+		Extensions:  []string{".md", ".markdown"},
+		Detect:      detect,
+		DetectOrder: 2,
+		Params: []common.FormatParam{
+			common.AlignParam,
+			{Name: "bold-header", DefaultValue: "false", AllowedValues: "true, false", Description: "Table Header Bold"},
+			{Name: "bold-first-column", DefaultValue: "false", AllowedValues: "true, false", Description: "Bold first column"},
+			{Name: "escape", DefaultValue: "true", AllowedValues: "true, false", Description: "Escape Markdown table"},
+			{Name: "pretty", DefaultValue: "true", AllowedValues: "true, false", Description: "Pretty-print Markdown"},
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+		MarshalDocument:   MarshalDocument,
+	})
+}
+
+var (
+	// separatorPattern matches the line below the header of a pipe table, e.g. "|---|:--:|"
+	separatorPattern = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?$`)
+	// headingPattern matches a heading or list item at the start of the content
+	headingPattern = regexp.MustCompile(`^#+\s|^-\s|^\*\s`)
+)
+
+// detect scores content holding a pipe table: a row followed by a separator line
+func detect(sample string) int {
+	lines := common.SampleLines(sample, 50)
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if (strings.Contains(line, "---") || strings.Contains(line, ":-")) && separatorPattern.MatchString(line) &&
+			strings.Count(lines[i-1], "|") >= strings.Count(line, "|") && strings.Contains(lines[i-1], "|") {
+			return 90
+		}
+	}
+	if headingPattern.MatchString(sample) {
+		return common.DetectWeak
+	}
+	return common.DetectNone
+}
@@ -3,6 +3,7 @@ package markdown
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/martianzhang/tableconvert/common"
@@ -202,6 +203,9 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 					Line:       line,
 				}
 			}
+			if err := cfg.CheckRow(len(table.Rows), cells); err != nil {
+				return err
+			}
 			// Append the valid row. `append` handles the case where table.Rows is initially nil.
 			table.Rows = append(table.Rows, cells)
 		}
@@ -233,6 +237,111 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 	return nil // Success
 }
 
+// UnmarshalDocument parses every table of a Markdown document. A table ends at
+// the first line that is not a table row, and is named after the closest
+// preceding heading.
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	scanner := bufio.NewScanner(cfg.Reader)
+	lineNumber := 0
+	heading := ""
+	var table *common.Table
+	foundSeparator := false
+
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		trimmedLine := strings.TrimSpace(line)
+
+		if table != nil && !strings.HasPrefix(trimmedLine, "|") {
+			if !foundSeparator {
+				return &common.ParseError{
+					LineNumber: lineNumber,
+					Message:    "expected separator line (e.g., |---|---|) after header",
+					Line:       line,
+				}
+			}
+			// End of the current table
+			doc.Tables = append(doc.Tables, table)
+			table = nil
+		}
+
+		switch {
+		case strings.HasPrefix(trimmedLine, "#"):
+			heading = strings.TrimSpace(strings.TrimLeft(trimmedLine, "#"))
+		case !strings.HasPrefix(trimmedLine, "|"):
+			// Text, blank lines and fences between tables
+		case table == nil:
+			cells, err := parseLine(trimmedLine, lineNumber)
+			if err != nil {
+				return err
+			}
+			table = &common.Table{Name: heading, Headers: cells}
+			heading = ""
+			foundSeparator = false
+		case !foundSeparator:
+			sepCells, _ := parseLine(trimmedLine, lineNumber)
+			if !isSeparatorLine(trimmedLine) || len(sepCells) != len(table.Headers) {
+				return &common.ParseError{
+					LineNumber: lineNumber,
+					Message:    fmt.Sprintf("expected separator line with %d columns after header", len(table.Headers)),
+					Line:       line,
+				}
+			}
+			foundSeparator = true
+		default:
+			cells, err := parseLine(trimmedLine, lineNumber)
+			if err != nil {
+				return err
+			}
+			if len(cells) != len(table.Headers) {
+				return &common.ParseError{
+					LineNumber: lineNumber,
+					Message:    fmt.Sprintf("data row has %d columns, but header has %d", len(cells), len(table.Headers)),
+					Line:       line,
+				}
+			}
+			if err := cfg.CheckRow(len(table.Rows), cells); err != nil {
+				return err
+			}
+			table.Rows = append(table.Rows, cells)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading input: %w", err)
+	}
+
+	if table != nil {
+		if !foundSeparator {
+			return fmt.Errorf("parsing failed: no separator row found after header (line %d)", lineNumber)
+		}
+		doc.Tables = append(doc.Tables, table)
+	}
+	if len(doc.Tables) == 0 {
+		return fmt.Errorf("parsing failed: no valid header row found in input")
+	}
+	return nil
+}
+
+// MarshalDocument writes the tables one after another, each preceded by a
+// heading with its name
+func MarshalDocument(cfg *common.Config, doc *common.Document) error {
+	names := doc.UniqueTableNames(nil)
+	for i, table := range doc.Tables {
+		if i > 0 {
+			if _, err := io.WriteString(cfg.Writer, "\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(cfg.Writer, "## %s\n\n", names[i]); err != nil {
+			return err
+		}
+		if err := Marshal(cfg, table); err != nil {
+			return fmt.Errorf("table %q: %w", names[i], err)
+		}
+	}
+	return nil
+}
+
 func Marshal(cfg *common.Config, table *common.Table) error {
 	if table == nil {
 		return fmt.Errorf("Marshal: input table pointer cannot be nil")
@@ -250,7 +359,6 @@ func Marshal(cfg *common.Config, table *common.Table) error {
 	}
 
 	// Get configuration with defaults
-	align := cfg.GetExtensionString("align", "l")
 	boldHeader := cfg.GetExtensionBool("bold-header", false)
 	boldFirstColumn := cfg.GetExtensionBool("bold-first-column", false)
 	escape := cfg.GetExtensionBool("escape", true)
@@ -259,24 +367,8 @@ func Marshal(cfg *common.Config, table *common.Table) error {
 	writer := cfg.Writer
 	headers := table.Headers
 
-	// deal with align config: trim, default to "l", pad/truncate to headers length
-	aligns := strings.Split(align, ",")
-	for i := range aligns {
-		aligns[i] = strings.ToLower(strings.TrimSpace(aligns[i]))
-		switch aligns[i] {
-		case "l", "c", "r":
-			// valid
-		default:
-			aligns[i] = "l" // default fallback
-		}
-	}
-	if len(aligns) < len(headers) {
-		for len(aligns) < len(headers) {
-			aligns = append(aligns, "l")
-		}
-	} else if len(aligns) > len(headers) {
-		aligns = aligns[:len(headers)]
-	}
+	// one alignment per column, "l" unless given
+	aligns := cfg.ColumnAligns(len(headers))
 
 	// Prepare display copies of headers and rows where escape/bold are applied
 	displayHeaders := make([]string, len(headers))
@@ -945,3 +945,55 @@ func TestRoundTrip(t *testing.T) {
 		})
 	}
 }
+
+func TestUnmarshalDocument(t *testing.T) {
+	input := "# Report\n\n" +
+		"## Users\n\n| A | B |\n|---|---|\n| 1 | 2 |\n\nSome text\n\n" +
+		"| C |\n|---|\n| 3 |\n| 4 |\n"
+
+	cfg := &common.Config{Reader: strings.NewReader(input)}
+	doc := &common.Document{}
+	err := UnmarshalDocument(cfg, doc)
+
+	assert.NoError(t, err)
+	assert.Len(t, doc.Tables, 2)
+	assert.Equal(t, "Users", doc.Tables[0].Name)
+	assert.Equal(t, []string{"A", "B"}, doc.Tables[0].Headers)
+	assert.Equal(t, [][]string{{"1", "2"}}, doc.Tables[0].Rows)
+	assert.Equal(t, "", doc.Tables[1].Name)
+	assert.Equal(t, [][]string{{"3"}, {"4"}}, doc.Tables[1].Rows)
+}
+
+func TestUnmarshalDocumentErrors(t *testing.T) {
+	for name, input := range map[string]string{
+		"no table":          "just text\n",
+		"missing separator": "| A |\n| 1 |\n",
+		"column mismatch":   "| A |\n|---|\n| 1 | 2 |\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := UnmarshalDocument(&common.Config{Reader: strings.NewReader(input)}, &common.Document{})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMarshalDocument(t *testing.T) {
+	doc := &common.Document{Tables: []*common.Table{
+		{Name: "Users", Headers: []string{"A"}, Rows: [][]string{{"1"}}},
+		{Headers: []string{"B"}, Rows: [][]string{{"2"}}},
+	}}
+
+	var buf bytes.Buffer
+	cfg := &common.Config{Writer: &buf, Extension: map[string]string{}}
+	err := MarshalDocument(cfg, doc)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "## Users\n\n| A |\n|---|\n| 1 |\n\n## Table2\n\n| B |\n|---|\n| 2 |\n", buf.String())
+
+	back := &common.Document{}
+	err = UnmarshalDocument(&common.Config{Reader: &buf}, back)
+	assert.NoError(t, err)
+	assert.Equal(t, "Users", back.Tables[0].Name)
+	assert.Equal(t, "Table2", back.Tables[1].Name)
+	assert.Equal(t, [][]string{{"2"}}, back.Tables[1].Rows)
+}
@@ -0,0 +1,39 @@
+package mediawiki
+
+import (
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "mediawiki",
+		Description: "MediaWiki table",
+		Extensions:  []string{".wiki", ".mediawiki"},
+		Detect:      detect,
+		DetectOrder: 4,
+		Params: []common.FormatParam{
+			{Name: "first-row-header", DefaultValue: "false", AllowedValues: "true, false", Description: "Use first row as headers"},
+			{Name: "minify", DefaultValue: "false", AllowedValues: "true, false", Description: "Minify MediaWiki table"},
+			{Name: "sort", DefaultValue: "false", AllowedValues: "true, false", Description: "Make table sortable in Wikipedia"},
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+	})
+}
+
+// detect scores content holding a MediaWiki table, which opens with "{|"
+func detect(sample string) int {
+	lines := common.SampleLines(sample, 50)
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "{|") {
+			return 95
+		}
+	}
+	if strings.Contains(sample, "|}") && strings.Contains(sample, "|-") {
+		return 40
+	}
+	return common.DetectNone
+}
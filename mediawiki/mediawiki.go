@@ -3,32 +3,83 @@ package mediawiki
 import (
 	"fmt"
 	"io"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/martianzhang/tableconvert/common"
 )
 
-func Unmarshal(cfg *common.Config, table *common.Table) error {
+// newTable lays out the rows of a table, the first one being the header, and
+// checks that every row is as wide as the header
+func newTable(name string, rows [][]common.SpanCell, rowLines []int) (*common.Table, error) {
+	grid, spans, widths, err := common.LayoutSpans(rows)
+	if err != nil {
+		return nil, err
+	}
+	headerCount := widths[0]
+	for i, width := range widths {
+		if width != headerCount {
+			return nil, fmt.Errorf("parse error on line %d: row has %d columns, but header has %d",
+				rowLines[i], width, headerCount)
+		}
+	}
+	table := &common.Table{}
+	table.SetGrid(grid, spans)
+	table.Name = name
+	return table, nil
+}
+
+// parse reads up to limit tables of the input, all of them when limit is 0.
+// Each table goes from "{|" to "|}" and is named after its "|+" caption.
+func parse(cfg *common.Config, limit int) ([]*common.Table, error) {
 	if cfg == nil || cfg.Reader == nil {
-		return fmt.Errorf("Unmarshal: config or reader cannot be nil")
+		return nil, fmt.Errorf("Unmarshal: config or reader cannot be nil")
 	}
 
 	// Read all content from reader
 	content, err := io.ReadAll(cfg.Reader)
 	if err != nil {
-		return fmt.Errorf("Unmarshal: failed to read input: %v", err)
+		return nil, fmt.Errorf("Unmarshal: failed to read input: %v", err)
 	}
 
 	lines := strings.Split(string(content), "\n")
-	table.Headers = []string{}
-	table.Rows = [][]string{}
+	var tables []*common.Table
 
-	var currentRow []string
+	// The first row holds the headers, cells may span several rows or columns
+	var name string
+	var rows [][]common.SpanCell
+	var rowLines []int
+	var currentRow []common.SpanCell
 	inTable := false
-	headerCount := 0
 	lineNumber := 0
 
+	commitRow := func() {
+		if len(currentRow) > 0 && err == nil {
+			// The first row is the header
+			err = cfg.CheckSpanRow(len(rows)-1, currentRow)
+			rows = append(rows, currentRow)
+			rowLines = append(rowLines, lineNumber)
+			currentRow = nil
+		}
+	}
+	endTable := func() {
+		if len(rows) > 0 && err == nil {
+			var table *common.Table
+			if table, err = newTable(name, rows, rowLines); err == nil {
+				tables = append(tables, table)
+			}
+		}
+		name, rows, rowLines, currentRow = "", nil, nil, nil
+	}
+
 	for _, line := range lines {
+		if err != nil {
+			return nil, err
+		}
+		if limit > 0 && len(tables) == limit {
+			break
+		}
 		lineNumber++
 		line = strings.TrimSpace(line)
 
@@ -39,15 +90,8 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 		} else if line == "|}" {
 			inTable = false
 			// Add the last row if exists
-			if len(currentRow) > 0 {
-				// Validate column count
-				if len(currentRow) != headerCount {
-					return fmt.Errorf("parse error on line %d: row has %d columns, but header has %d (line: %q)",
-						lineNumber, len(currentRow), headerCount, line)
-				}
-				table.Rows = append(table.Rows, currentRow)
-				currentRow = nil
-			}
+			commitRow()
+			endTable()
 			continue
 		}
 
@@ -58,50 +102,89 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 		// Process table content
 		if strings.HasPrefix(line, "|-") {
 			// Row separator - commit current row
-			if len(currentRow) > 0 {
-				// Validate column count
-				if len(currentRow) != headerCount {
-					return fmt.Errorf("parse error on line %d: row has %d columns, but header has %d (line: %q)",
-						lineNumber, len(currentRow), headerCount, line)
-				}
-				table.Rows = append(table.Rows, currentRow)
-				currentRow = nil
-			}
+			commitRow()
+		} else if strings.HasPrefix(line, "|+") {
+			// Table caption
+			name = strings.TrimSpace(strings.TrimPrefix(line, "|+"))
 		} else if strings.HasPrefix(line, "!") {
 			// Header row - handle both !! and | separators
-			headerLine := strings.TrimPrefix(line, "!")
-			headerCells := strings.Split(headerLine, "!!")
-			if len(headerCells) == 1 {
-				headerCells = strings.Split(headerLine, "|")
-			}
-			for i, cell := range headerCells {
-				cell = strings.TrimSpace(cell)
-				// Handle {{!}} template
-				cell = strings.ReplaceAll(cell, "{{!}}", "|")
-				headerCells[i] = cell
-			}
-			table.Headers = headerCells
-			headerCount = len(headerCells)
+			currentRow = append(currentRow, parseCells(strings.TrimPrefix(line, "!"), "!!")...)
 		} else if strings.HasPrefix(line, "|") {
 			// Data row - handle both || and | separators
-			dataLine := strings.TrimPrefix(line, "|")
-			cells := strings.Split(dataLine, "||")
-			if len(cells) == 1 {
-				cells = strings.Split(dataLine, "|")
-			}
-			for i, cell := range cells {
-				cell = strings.TrimSpace(cell)
-				// Handle {{!}} template
-				cell = strings.ReplaceAll(cell, "{{!}}", "|")
-				cells[i] = cell
-			}
-			currentRow = cells
+			currentRow = append(currentRow, parseCells(strings.TrimPrefix(line, "|"), "||")...)
 		}
 	}
 
+	// A table without "|}" keeps the rows ended by "|-"
+	if limit == 0 || len(tables) < limit {
+		endTable()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// Unmarshal reads the first table of the input. Without a table it leaves
+// table empty.
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	tables, err := parse(cfg, 1)
+	if err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		table.Headers = []string{}
+		table.Rows = [][]string{}
+		return nil
+	}
+	*table = *tables[0]
+	return nil
+}
+
+// UnmarshalDocument reads every table of the input
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	tables, err := parse(cfg, 0)
+	if err != nil {
+		return err
+	}
+	doc.Tables = append(doc.Tables, tables...)
 	return nil
 }
 
+// spanAttrPattern matches the rowspan and colspan cell attributes
+var spanAttrPattern = regexp.MustCompile(`(?i)\b(rowspan|colspan)\s*=\s*["']?(\d+)`)
+
+// attrsPattern matches the attribute part of a cell such as `rowspan="2" | value`
+var attrsPattern = regexp.MustCompile(`^\s*(\w+\s*=\s*("[^"]*"|'[^']*'|[^\s"'|]+)\s*)+$`)
+
+// parseCells splits a cell line at separator and reads the attributes of each cell
+func parseCells(line, separator string) []common.SpanCell {
+	parts := strings.Split(line, separator)
+	if len(parts) == 1 {
+		if attrs, _, found := strings.Cut(line, "|"); !found || !attrsPattern.MatchString(attrs) {
+			parts = strings.Split(line, "|")
+		}
+	}
+
+	cells := make([]common.SpanCell, len(parts))
+	for i, part := range parts {
+		if attrs, value, found := strings.Cut(part, "|"); found && attrsPattern.MatchString(attrs) {
+			for _, m := range spanAttrPattern.FindAllStringSubmatch(attrs, -1) {
+				n, _ := strconv.Atoi(m[2])
+				if strings.EqualFold(m[1], "rowspan") {
+					cells[i].RowSpan = n
+				} else {
+					cells[i].ColSpan = n
+				}
+			}
+			part = value
+		}
+		// Handle {{!}} template
+		cells[i].Value = strings.ReplaceAll(strings.TrimSpace(part), "{{!}}", "|")
+	}
+	return cells
+}
+
 func Marshal(cfg *common.Config, table *common.Table) error {
 	if table == nil {
 		return fmt.Errorf("Marshal: input table pointer cannot be nil")
@@ -126,18 +209,11 @@ func Marshal(cfg *common.Config, table *common.Table) error {
 	// Write table start with default wikitable class
 	writer.Write([]byte("{| class=\"wikitable\"\n"))
 
+	layout := table.SpanLayout()
+
 	// Write headers (MediaWiki uses ! for headers)
 	if len(table.Headers) > 0 {
-		headerLine := "! "
-		for i, header := range table.Headers {
-			if i > 0 {
-				headerLine += " !! "
-			}
-			// Escape pipes in header content using {{!}} template
-			escapedHeader := strings.ReplaceAll(header, "|", "{{!}}")
-			headerLine += escapedHeader
-		}
-		writer.Write([]byte(headerLine + "\n"))
+		writer.Write([]byte("! " + formatCells(layout, -1, table.Headers, " !! ") + "\n"))
 	}
 
 	// Write data rows
@@ -154,16 +230,7 @@ func Marshal(cfg *common.Config, table *common.Table) error {
 		}
 
 		// Write row data (MediaWiki uses | for data cells)
-		rowLine := "| "
-		for j, cell := range row {
-			if j > 0 {
-				rowLine += " || "
-			}
-			// Escape pipes in cell content using {{!}} template
-			escapedCell := strings.ReplaceAll(cell, "|", "{{!}}")
-			rowLine += escapedCell
-		}
-		writer.Write([]byte(rowLine + "\n"))
+		writer.Write([]byte("| " + formatCells(layout, i, row, " || ") + "\n"))
 	}
 
 	// Write table end
@@ -171,3 +238,27 @@ func Marshal(cfg *common.Config, table *common.Table) error {
 
 	return nil
 }
+
+// formatCells joins the cells of a row, leaving out cells covered by a merged cell
+func formatCells(layout common.SpanLayout, row int, cells []string, separator string) string {
+	var parts []string
+	for col, cell := range cells {
+		if layout.Covered(row, col) {
+			continue
+		}
+		// Escape pipes in cell content using {{!}} template
+		part := strings.ReplaceAll(cell, "|", "{{!}}")
+		if span, ok := layout.At(row, col); ok {
+			var attrs []string
+			if span.RowSpan > 1 {
+				attrs = append(attrs, fmt.Sprintf(`rowspan="%d"`, span.RowSpan))
+			}
+			if span.ColSpan > 1 {
+				attrs = append(attrs, fmt.Sprintf(`colspan="%d"`, span.ColSpan))
+			}
+			part = strings.Join(attrs, " ") + " | " + part
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, separator)
+}
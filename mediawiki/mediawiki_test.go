@@ -331,3 +331,89 @@ func TestMarshal(t *testing.T) {
 `
 	assert.Equal(t, expectedPattern, output, "Output format is incorrect")
 }
+
+func TestUnmarshalAndMarshalSpans(t *testing.T) {
+	input := `{| class="wikitable"
+|+ Report
+! Region !! colspan="2" | Sales
+|-
+| rowspan="2" | North || 1 || 2
+|-
+| 3 || 4
+|}
+`
+
+	var table common.Table
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, &table)
+	assert.NoError(t, err)
+	assert.Equal(t, "Report", table.Name)
+	assert.Equal(t, []string{"Region", "Sales", ""}, table.Headers)
+	assert.Equal(t, [][]string{{"North", "1", "2"}, {"", "3", "4"}}, table.Rows)
+	assert.Equal(t, []common.Span{
+		{Row: -1, Col: 1, RowSpan: 1, ColSpan: 2},
+		{Row: 0, Col: 0, RowSpan: 2, ColSpan: 1},
+	}, table.Spans)
+
+	var buf bytes.Buffer
+	err = Marshal(&common.Config{Writer: &buf}, &table)
+	assert.NoError(t, err)
+	assert.Equal(t, `{| class="wikitable"
+! Region !! colspan="2" | Sales
+|-
+| rowspan="2" | North || 1 || 2
+|-
+| 3 || 4
+|}
+`, buf.String())
+}
+
+func TestUnmarshalCellPerLine(t *testing.T) {
+	input := `{| class="wikitable"
+! A
+! B
+|-
+| 1
+| 2
+|}`
+
+	var table common.Table
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, &table)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"A", "B"}, table.Headers)
+	assert.Equal(t, [][]string{{"1", "2"}}, table.Rows)
+}
+
+func TestUnmarshalDocument(t *testing.T) {
+	// A page with two tables of different widths, each named after its caption
+	input := `== Servers ==
+{| class="wikitable"
+|+ Servers
+! Host !! Role
+|-
+| web1 || web
+|}
+
+== Owners ==
+{| class="wikitable"
+! Name !! Team !! Email
+|-
+| Alice || Ops || alice@example.com
+|}
+`
+	var doc common.Document
+	err := UnmarshalDocument(&common.Config{Reader: strings.NewReader(input)}, &doc)
+	assert.NoError(t, err)
+	assert.Len(t, doc.Tables, 2)
+	assert.Equal(t, "Servers", doc.Tables[0].Name)
+	assert.Equal(t, []string{"Host", "Role"}, doc.Tables[0].Headers)
+	assert.Equal(t, [][]string{{"web1", "web"}}, doc.Tables[0].Rows)
+	assert.Equal(t, "", doc.Tables[1].Name)
+	assert.Equal(t, []string{"Name", "Team", "Email"}, doc.Tables[1].Headers)
+	assert.Equal(t, [][]string{{"Alice", "Ops", "alice@example.com"}}, doc.Tables[1].Rows)
+
+	// Unmarshal reads the first table only
+	var table common.Table
+	err = Unmarshal(&common.Config{Reader: strings.NewReader(input)}, &table)
+	assert.NoError(t, err)
+	assert.Equal(t, doc.Tables[0], &table)
+}
@@ -0,0 +1,37 @@
+package mysql
+
+import (
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:            "mysql",
+		Description:     "MySQL query output",
+		OutputExtension: ".txt",
+		Detect:          detect,
+		DetectOrder:     9,
+		Params: []common.FormatParam{
+			{Name: "style", DefaultValue: "box", AllowedValues: "box", Description: "MySQL table style (box format)"},
+		},
+		Unmarshal:    Unmarshal,
+		Marshal:      Marshal,
+		NewRowReader: NewRowReader,
+	})
+}
+
+// detect scores content that looks like MySQL client output: "+---+" borders around "| x |" rows
+func detect(sample string) int {
+	lines := common.SampleLines(sample, 5)
+	if len(lines) < 3 {
+		return common.DetectNone
+	}
+	border := strings.TrimSpace(lines[0])
+	if strings.HasPrefix(border, "+-") && strings.HasSuffix(border, "-+") &&
+		strings.Trim(border, "+-") == "" && strings.HasPrefix(strings.TrimSpace(lines[1]), "|") {
+		return 90
+	}
+	return common.DetectNone
+}
@@ -3,6 +3,7 @@ package mysql
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/martianzhang/tableconvert/common"
@@ -62,40 +63,75 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 		return fmt.Errorf("Unmarshal: target table pointer cannot be nil")
 	}
 
-	scanner := bufio.NewScanner(cfg.Reader)
-	lineNumber := 0
-	var headers []string
-	var rows [][]string
-	var anchors []int
-	parsingState := "start" // states: start, header, header_separator, data, end
-	var expectLineLength int
-	var preline string // Handles lines potentially split across buffer reads
+	reader, err := NewRowReader(cfg)
+	if err != nil {
+		return err
+	}
+	return common.ReadAllRows(cfg, reader, table)
+}
+
+// rowReader parses a MySQL client style table line by line
+type rowReader struct {
+	scanner          *bufio.Scanner
+	lineNumber       int
+	headers          []string
+	columns          []common.Column
+	anchors          []int
+	parsingState     string // states: start, header, header_separator, data, end
+	expectLineLength int
+	preline          string // Handles lines potentially split across buffer reads
+	rowCount         int
+}
+
+// NewRowReader returns a streaming MySQL table reader. Input is read up to the header separator.
+func NewRowReader(cfg *common.Config) (common.RowReader, error) {
+	r := &rowReader{
+		scanner:      bufio.NewScanner(cfg.Reader),
+		parsingState: "start",
+	}
+	if _, err := r.read(true); err != nil && err != io.EOF {
+		return nil, err
+	}
+	r.columns = common.NewColumns(r.headers)
+	return r, nil
+}
+
+func (r *rowReader) Columns() []common.Column {
+	return r.columns
+}
+
+func (r *rowReader) Next() ([]string, error) {
+	return r.read(false)
+}
 
-	for scanner.Scan() {
-		lineNumber++
-		line := scanner.Text()
+// read consumes input lines until a data row is parsed, or until the header
+// is complete when untilHeader is set. It returns io.EOF at the end of the table.
+func (r *rowReader) read(untilHeader bool) ([]string, error) {
+	for r.parsingState != "end" && r.scanner.Scan() {
+		r.lineNumber++
+		line := r.scanner.Text()
 
 		// --- Line Concatenation Logic (Handles lines split by buffer boundaries) ---
 		// If we have a previous partial line...
-		if len(preline) > 0 {
-			line = preline + line // Concatenate
+		if len(r.preline) > 0 {
+			line = r.preline + line // Concatenate
 			// If the combined line is still shorter than expected, store it and continue
-			if expectLineLength > 0 && len(line) < expectLineLength {
-				preline = line
+			if r.expectLineLength > 0 && len(line) < r.expectLineLength {
+				r.preline = line
 				continue
 			} else {
 				// Otherwise, we have the full line (or more), clear preline
-				preline = ""
+				r.preline = ""
 			}
 		} else { // If no previous partial line...
 			// If this line is shorter than expected (and we expect a certain length),
 			// store it as a partial line and continue. Only do this after anchors are found.
-			if expectLineLength > 0 && len(line) < expectLineLength {
+			if r.expectLineLength > 0 && len(line) < r.expectLineLength {
 				// Simple check: Does it look like a plausible start of a border/data line?
 				// Avoid treating completely unrelated short lines as partial table lines.
 				trimmedLookahead := strings.TrimSpace(line)
 				if strings.HasPrefix(trimmedLookahead, "+-") || strings.HasPrefix(trimmedLookahead, "|") {
-					preline = line
+					r.preline = line
 					continue
 				}
 				// If it doesn't look like part of the table, process it as is (might lead to error later)
@@ -104,153 +140,144 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 		}
 		// --- End Line Concatenation Logic ---
 
-		trimmedLine := strings.TrimSpace(line)
-
 		// Skip effectively empty lines after potential concatenation
-		if trimmedLine == "" {
+		if strings.TrimSpace(line) == "" {
 			continue
 		}
 
-		switch parsingState {
-		case "start":
-			if isBorderLine(line) {
-				parsingState = "header"
-				anchors = findAnchors(line)
-				if len(anchors) < 2 { // Need at least two '+' for one column
-					return &common.ParseError{LineNumber: lineNumber, Message: "failed to parse header anchors (need at least two '+')", Line: line}
-				}
-				expectLineLength = anchors[len(anchors)-1] + 1 // Expect lines to reach the last '+'
-			} else {
-				// Allow skipping introductory lines before the first border
-				// return &common.ParseError{LineNumber: lineNumber, Message: "expected top border line (+--+)", Line: line}
-			}
-		case "header":
-			if isDataLine(line) {
-				// Ensure line length matches expectation *before* parsing
-				if len(line) < expectLineLength {
-					// Should have been caught by concatenation logic, but double-check
-					preline = line // Assume it's a partial line
-					continue
-				}
-				headers = parseFields(line)
-				if len(headers) == 0 {
-					// Check if the line structure *looks* right but parsing failed
-					if len(strings.Split(strings.Trim(line, "|"), "|")) >= 1 {
-						// It looks like a data line, maybe parsing logic failed?
-						// Or perhaps anchors were wrong? Let's assume header parse failure.
-						return &common.ParseError{LineNumber: lineNumber, Message: "failed to parse header fields from data line", Line: line}
-					}
-					// If it doesn't even look like a data line (|...|)
-					return &common.ParseError{LineNumber: lineNumber, Message: "expected header data line (| Header |)", Line: line}
-				}
-				parsingState = "header_separator"
-			} else if isBorderLine(line) { // Handle case like +---+ \n +---+ (empty header)
-				return &common.ParseError{LineNumber: lineNumber, Message: "expected header data line (| Header |), got another border", Line: line}
-			} else {
-				return &common.ParseError{LineNumber: lineNumber, Message: "expected header data line (| Header |)", Line: line}
-			}
-		case "header_separator":
-			if isBorderLine(line) {
-				// Optional: Verify separator anchors match header anchors?
-				// sepAnchors := findAnchors(line)
-				// if !reflect.DeepEqual(anchors, sepAnchors) {
-				//     return &common.ParseError{LineNumber: lineNumber, Message: "header separator anchors mismatch header anchors", Line: line}
-				// }
-				parsingState = "data"
-			} else {
-				return &common.ParseError{LineNumber: lineNumber, Message: "expected header separator line (+--+)", Line: line}
+		row, err := r.parseLine(line)
+		if err != nil {
+			return nil, err
+		}
+		if row != nil {
+			r.rowCount++
+			return row, nil
+		}
+		if untilHeader && r.parsingState == "data" {
+			return nil, nil
+		}
+	}
+	// Any non-empty line after the final border is usually a query summary
+	// like "1 row in set (0.00 sec)", the table ends at the bottom border.
+	return nil, r.finish()
+}
+
+// parseLine advances the parser state with one complete line and returns the data row it holds, if any
+func (r *rowReader) parseLine(line string) ([]string, error) {
+	lineNumber := r.lineNumber
+	switch r.parsingState {
+	case "start":
+		if isBorderLine(line) {
+			r.parsingState = "header"
+			r.anchors = findAnchors(line)
+			if len(r.anchors) < 2 { // Need at least two '+' for one column
+				return nil, &common.ParseError{LineNumber: lineNumber, Message: "failed to parse header anchors (need at least two '+')", Line: line}
 			}
-		case "data":
+			r.expectLineLength = r.anchors[len(r.anchors)-1] + 1 // Expect lines to reach the last '+'
+		}
+		// Allow skipping introductory lines before the first border
+	case "header":
+		if isDataLine(line) {
 			// Ensure line length matches expectation *before* parsing
-			if len(line) < expectLineLength {
+			if len(line) < r.expectLineLength {
 				// Should have been caught by concatenation logic, but double-check
-				// Check if it looks like a partial data line before assuming
-				if isDataLine(line) || isBorderLine(line) {
-					preline = line // Assume it's a partial line
-					continue
+				r.preline = line // Assume it's a partial line
+				return nil, nil
+			}
+			r.headers = parseFields(line)
+			if len(r.headers) == 0 {
+				// Check if the line structure *looks* right but parsing failed
+				if len(strings.Split(strings.Trim(line, "|"), "|")) >= 1 {
+					// It looks like a data line, maybe parsing logic failed?
+					// Or perhaps anchors were wrong? Let's assume header parse failure.
+					return nil, &common.ParseError{LineNumber: lineNumber, Message: "failed to parse header fields from data line", Line: line}
 				}
-				// If it's short and doesn't look like table content, treat as error
-				return &common.ParseError{LineNumber: lineNumber, Message: "unexpected short line", Line: line}
+				// If it doesn't even look like a data line (|...|)
+				return nil, &common.ParseError{LineNumber: lineNumber, Message: "expected header data line (| Header |)", Line: line}
+			}
+			r.parsingState = "header_separator"
+		} else if isBorderLine(line) { // Handle case like +---+ \n +---+ (empty header)
+			return nil, &common.ParseError{LineNumber: lineNumber, Message: "expected header data line (| Header |), got another border", Line: line}
+		} else {
+			return nil, &common.ParseError{LineNumber: lineNumber, Message: "expected header data line (| Header |)", Line: line}
+		}
+	case "header_separator":
+		if isBorderLine(line) {
+			// Optional: Verify separator anchors match header anchors?
+			r.parsingState = "data"
+		} else {
+			return nil, &common.ParseError{LineNumber: lineNumber, Message: "expected header separator line (+--+)", Line: line}
+		}
+	case "data":
+		// Ensure line length matches expectation *before* parsing
+		if len(line) < r.expectLineLength {
+			// Should have been caught by concatenation logic, but double-check
+			// Check if it looks like a partial data line before assuming
+			if isDataLine(line) || isBorderLine(line) {
+				r.preline = line // Assume it's a partial line
+				return nil, nil
 			}
+			// If it's short and doesn't look like table content, treat as error
+			return nil, &common.ParseError{LineNumber: lineNumber, Message: "unexpected short line", Line: line}
+		}
 
-			if isDataLine(line) {
-				rowData := parseFields(line)
-				// Check column count consistency (optional, but good practice)
-				if len(rowData) != len(headers) {
-					// Decide how strict to be. Log a warning or return an error.
-					// Using fmt.Sprintf for better error message formatting.
-					return &common.ParseError{
-						LineNumber: lineNumber,
-						Message:    fmt.Sprintf("data column count (%d) does not match header count (%d)", len(rowData), len(headers)),
-						Line:       line,
-					}
+		if isDataLine(line) {
+			rowData := parseFields(line)
+			// Check column count consistency
+			if len(rowData) != len(r.headers) {
+				return nil, &common.ParseError{
+					LineNumber: lineNumber,
+					Message:    fmt.Sprintf("data column count (%d) does not match header count (%d)", len(rowData), len(r.headers)),
+					Line:       line,
 				}
-				rows = append(rows, rowData)
-				// Stay in 'data' state
-			} else if isBorderLine(line) {
-				parsingState = "end" // Found the bottom border
-			} else {
-				return &common.ParseError{LineNumber: lineNumber, Message: "expected data line (| Data |) or bottom border line (+--+)", Line: line}
 			}
-		case "end":
-			// Any non-empty line after the final border is usually an error
-			// (e.g., query summary like "1 row in set (0.00 sec)")
-			// Allow trimming, but if anything remains, it's unexpected content.
-			if trimmedLine != "" {
-				// You might want to be more lenient here and just stop parsing successfully
-				// return &common.ParseError{LineNumber: lineNumber, Message: "unexpected content after bottom border", Line: line}
-				// Option: Break the loop instead of erroring out
-				goto endLoop // Use goto to break out cleanly after state is 'end'
-			}
-			// Otherwise, just ignore trailing empty/whitespace lines
+			return rowData, nil
+		} else if isBorderLine(line) {
+			r.parsingState = "end" // Found the bottom border
+		} else {
+			return nil, &common.ParseError{LineNumber: lineNumber, Message: "expected data line (| Data |) or bottom border line (+--+)", Line: line}
 		}
 	}
-endLoop: // Label for the goto statement
+	return nil, nil
+}
 
-	if err := scanner.Err(); err != nil {
+// finish validates the parser state at the end of input, returning io.EOF for a complete table
+func (r *rowReader) finish() error {
+	if err := r.scanner.Err(); err != nil {
 		// If we have a partial line buffered, include it in the context
-		if len(preline) > 0 {
-			return fmt.Errorf("error reading input (last partial line: %q): %w", preline, err)
+		if len(r.preline) > 0 {
+			return fmt.Errorf("error reading input (last partial line: %q): %w", r.preline, err)
 		}
 		return fmt.Errorf("error reading input: %w", err)
 	}
 
 	// Handle potential partial line at EOF
-	if len(preline) > 0 {
+	if len(r.preline) > 0 {
 		// Treat leftover preline as an error - indicates incomplete input table
 		return &common.ParseError{
-			LineNumber: lineNumber,
-			Message:    fmt.Sprintf("input ended with incomplete line in state '%s'", parsingState),
-			Line:       preline,
+			LineNumber: r.lineNumber,
+			Message:    fmt.Sprintf("input ended with incomplete line in state '%s'", r.parsingState),
+			Line:       r.preline,
 		}
 	}
 
 	// Final state check - Did we reach a valid end state?
-	if parsingState != "end" {
-		// Allow ending in 'data' state (tolerates missing bottom border)
-		if parsingState == "data" {
-			// Successfully parsed headers and possibly data rows, missing final border is acceptable.
-			// fmt.Println("Warning: Input ended without a bottom border.") // Optional warning
-			// Allow ending in 'header_separator' if headers parsed but no rows (empty table)
-		} else if parsingState == "header_separator" && len(headers) > 0 && len(rows) == 0 {
-			// Successfully parsed headers, separator, but no data rows found before EOF.
-			// This represents a valid empty table.
-			// fmt.Println("Warning: Input contained only headers, no data rows or bottom border.") // Optional warning
-		} else {
-			// Any other state means the table is malformed or incomplete.
-			return &common.ParseError{
-				LineNumber: lineNumber,
-				Message:    fmt.Sprintf("input ended unexpectedly in state '%s', table possibly incomplete or malformed", parsingState),
-				Line:       "", // No specific line to point to at EOF
-			}
+	switch {
+	case r.parsingState == "end":
+	case r.parsingState == "data":
+		// Successfully parsed headers and possibly data rows, missing final border is acceptable.
+	case r.parsingState == "header_separator" && len(r.headers) > 0 && r.rowCount == 0:
+		// Successfully parsed headers, separator, but no data rows found before EOF.
+		// This represents a valid empty table.
+	default:
+		// Any other state means the table is malformed or incomplete.
+		return &common.ParseError{
+			LineNumber: r.lineNumber,
+			Message:    fmt.Sprintf("input ended unexpectedly in state '%s', table possibly incomplete or malformed", r.parsingState),
+			Line:       "", // No specific line to point to at EOF
 		}
 	}
-
-	// Populate the provided table struct
-	table.Headers = headers
-	table.Rows = rows
-
-	return nil // Success
+	return io.EOF
 }
 
 func Marshal(cfg *common.Config, table *common.Table) error {
@@ -3,6 +3,7 @@ package mysql
 import (
 	"bytes"
 	"errors"
+	"io"
 	"strings"
 	"testing"
 
@@ -354,3 +355,40 @@ func TestMarshal(t *testing.T) {
 		})
 	}
 }
+
+func TestRowReader(t *testing.T) {
+	input := `+----+-------+
+| id | name  |
++----+-------+
+| 1  | Alice |
+| 2  | Bob   |
++----+-------+
+2 rows in set (0.00 sec)
+`
+	reader, err := NewRowReader(&common.Config{Reader: strings.NewReader(input)})
+	assert.NoError(t, err)
+	assert.Equal(t, common.NewColumns([]string{"id", "name"}), reader.Columns())
+
+	row, err := reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "Alice"}, row)
+	row, err = reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2", "Bob"}, row)
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestRowReaderHeaderOnly(t *testing.T) {
+	input := `+----+
+| id |
++----+
+`
+	reader, err := NewRowReader(&common.Config{Reader: strings.NewReader(input)})
+	assert.NoError(t, err)
+	assert.Equal(t, common.NewColumns([]string{"id"}), reader.Columns())
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
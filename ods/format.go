@@ -0,0 +1,32 @@
+package ods
+
+import (
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "ods",
+		Description: "OpenDocument spreadsheet (LibreOffice Calc)",
+		Extensions:  []string{".ods"},
+		Detect:      detect,
+		Params: []common.FormatParam{
+			{Name: "first-column-header", DefaultValue: "false", AllowedValues: "true, false", Description: "Use first column as headers", Use: common.ParamRead},
+			{Name: "sheet-name", DefaultValue: "Sheet1", AllowedValues: "", Description: "Sheet written, or read instead of the first sheet when given"},
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+		MarshalDocument:   MarshalDocument,
+	})
+}
+
+// detect scores a zip archive starting with the OpenDocument spreadsheet mimetype entry
+func detect(sample string) int {
+	if strings.HasPrefix(sample, "PK\x03\x04") && strings.Contains(sample, "mimetype"+mimeType) {
+		return common.DetectCertain
+	}
+	return common.DetectNone
+}
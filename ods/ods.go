@@ -0,0 +1,683 @@
+package ods
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+// An OpenDocument spreadsheet is a zip package: an uncompressed mimetype entry
+// first, a manifest and content.xml, which holds every sheet as a table:table.
+
+const mimeType = "application/vnd.oasis.opendocument.spreadsheet"
+
+// Largest sheet of the OpenDocument applications, repeated rows and cells
+// are expanded up to it
+const (
+	maxRows    = 1048576
+	maxColumns = 16384
+)
+
+// Namespaces of the content.xml elements and attributes read and written
+const (
+	officeNS = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	tableNS  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	textNS   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+)
+
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	sheets, err := readSheets(cfg)
+	if err != nil {
+		return err
+	}
+
+	// The first sheet, or the one named with --sheet-name
+	s := &sheets[0]
+	if name := cfg.GetExtensionString("sheet-name", ""); name != "" {
+		if s = findSheet(sheets, name); s == nil {
+			names := make([]string, len(sheets))
+			for i := range sheets {
+				names[i] = sheets[i].name
+			}
+			return fmt.Errorf("sheet %q not found, available sheets: %s", name, strings.Join(names, ", "))
+		}
+	}
+	table.Name = s.name
+	return readSheet(cfg, s, table)
+}
+
+// UnmarshalDocument reads every sheet of the spreadsheet as a table named after the sheet
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	sheets, err := readSheets(cfg)
+	if err != nil {
+		return err
+	}
+	for i := range sheets {
+		table := &common.Table{Name: sheets[i].name}
+		if err := readSheet(cfg, &sheets[i], table); err != nil {
+			return fmt.Errorf("sheet %q: %w", sheets[i].name, err)
+		}
+		doc.Tables = append(doc.Tables, table)
+	}
+	return nil
+}
+
+// findSheet returns the sheet called name, ignoring case if no name matches exactly
+func findSheet(sheets []sheet, name string) *sheet {
+	for i := range sheets {
+		if sheets[i].name == name {
+			return &sheets[i]
+		}
+	}
+	for i := range sheets {
+		if strings.EqualFold(sheets[i].name, name) {
+			return &sheets[i]
+		}
+	}
+	return nil
+}
+
+// cell is a parsed table cell
+type cell struct {
+	value     string
+	valueType string // office:value-type, "" for a cell without a value
+	rowSpan   int
+	colSpan   int
+}
+
+func (c cell) empty() bool {
+	return c.value == "" && c.valueType == "" && c.rowSpan <= 1 && c.colSpan <= 1
+}
+
+// sheet is a parsed table:table, without its trailing empty rows and cells
+type sheet struct {
+	name string
+	rows [][]cell
+}
+
+// readSheets reads the sheets from the package in cfg.Reader, or in cfg.File when no reader is given
+func readSheets(cfg *common.Config) ([]sheet, error) {
+	var data []byte
+	var err error
+	if cfg.Reader != nil {
+		data, err = io.ReadAll(cfg.Reader)
+	} else {
+		data, err = os.ReadFile(cfg.File)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ODS file: %w", err)
+	}
+	var content *zip.File
+	for _, f := range archive.File {
+		switch f.Name {
+		case "mimetype":
+			if mime, err := readEntry(f); err != nil {
+				return nil, err
+			} else if mime := strings.TrimSpace(mime); mime != mimeType {
+				return nil, fmt.Errorf("not an OpenDocument spreadsheet: %s", mime)
+			}
+		case "content.xml":
+			content = f
+		}
+	}
+	if content == nil {
+		return nil, fmt.Errorf("invalid ODS file: no content.xml")
+	}
+
+	rc, err := content.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	sheets, err := parseContent(cfg, rc)
+	if err != nil {
+		return nil, err
+	}
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("empty ODS file: no sheets found")
+	}
+	return sheets, nil
+}
+
+func readEntry(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	return string(data), err
+}
+
+// parseContent parses the sheets of content.xml. Repeated rows and cells are
+// expanded, except for the empty ones at the end of a row or sheet, which
+// spreadsheet applications repeat up to the maximum sheet size. Each row is
+// checked against the limits of cfg as it is added.
+func parseContent(cfg *common.Config, r io.Reader) ([]sheet, error) {
+	decoder := xml.NewDecoder(r)
+	var (
+		sheets       []sheet
+		row          []cell
+		rowRepeat    int
+		emptyRows    int // empty rows not yet added, kept only if a row follows
+		current      *cell
+		cellRepeat   int
+		emptyCells   int // empty cells not yet added, kept only if a cell follows
+		paragraphs   []string
+		paragraph    *strings.Builder
+		pendingSpace bool // collapsed white space, written before the next text
+	)
+	flushSpace := func() {
+		if pendingSpace {
+			paragraph.WriteByte(' ')
+			pendingSpace = false
+		}
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return sheets, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid ODS file: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == officeNS && t.Name.Local == "annotation",
+				t.Name.Space == tableNS && t.Name.Local == "table" && current != nil:
+				// Comments and tables nested in cells are not cell text
+				if err := decoder.Skip(); err != nil {
+					return nil, fmt.Errorf("invalid ODS file: %w", err)
+				}
+			case t.Name.Space == tableNS && t.Name.Local == "table":
+				sheets = append(sheets, sheet{name: attr(t, tableNS, "name")})
+				emptyRows = 0
+			case t.Name.Space == tableNS && t.Name.Local == "table-row":
+				row, emptyCells = nil, 0
+				rowRepeat = repeat(t, "number-rows-repeated")
+			case t.Name.Space == tableNS && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				current = &cell{}
+				cellRepeat = repeat(t, "number-columns-repeated")
+				paragraphs = nil
+				if t.Name.Local == "table-cell" {
+					current.valueType = attr(t, officeNS, "value-type")
+					current.value = typedValue(t, current.valueType)
+					current.rowSpan = repeat(t, "number-rows-spanned")
+					current.colSpan = repeat(t, "number-columns-spanned")
+				}
+			case current != nil && t.Name.Space == textNS:
+				switch t.Name.Local {
+				case "p", "h":
+					paragraph, pendingSpace = &strings.Builder{}, false
+				case "s":
+					if paragraph != nil {
+						flushSpace()
+						count, err := strconv.Atoi(attr(t, textNS, "c"))
+						if err != nil || count < 1 {
+							count = 1
+						}
+						paragraph.WriteString(strings.Repeat(" ", count))
+					}
+				case "tab":
+					if paragraph != nil {
+						flushSpace()
+						paragraph.WriteByte('\t')
+					}
+				case "line-break":
+					if paragraph != nil {
+						flushSpace()
+						paragraph.WriteByte('\n')
+					}
+				}
+			}
+
+		case xml.CharData:
+			if paragraph == nil {
+				continue
+			}
+			// White space in the XML collapses to one space, leading and trailing white space is dropped
+			for _, c := range string(t) {
+				if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+					pendingSpace = paragraph.Len() > 0
+					continue
+				}
+				flushSpace()
+				paragraph.WriteRune(c)
+			}
+
+		case xml.EndElement:
+			switch {
+			case current != nil && t.Name.Space == textNS && (t.Name.Local == "p" || t.Name.Local == "h"):
+				if paragraph != nil {
+					paragraphs = append(paragraphs, paragraph.String())
+					paragraph = nil
+				}
+			case current != nil && t.Name.Space == tableNS && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				if t.Name.Local == "covered-table-cell" {
+					// The cell covering it holds the value
+					*current = cell{}
+				} else if current.value == "" {
+					current.value = strings.Join(paragraphs, "\n")
+				}
+				if current.empty() {
+					emptyCells = min(emptyCells+min(cellRepeat, maxColumns), maxColumns)
+				} else {
+					if cellRepeat > maxColumns-len(row)-emptyCells {
+						return nil, fmt.Errorf("row has more than %d columns", maxColumns)
+					}
+					for ; emptyCells > 0; emptyCells-- {
+						row = append(row, cell{})
+					}
+					for range cellRepeat {
+						row = append(row, *current)
+					}
+				}
+				current = nil
+			case t.Name.Space == tableNS && t.Name.Local == "table-row" && len(sheets) > 0:
+				s := &sheets[len(sheets)-1]
+				if len(row) == 0 {
+					emptyRows = min(emptyRows+min(rowRepeat, maxRows), maxRows)
+					continue
+				}
+				if rowRepeat > maxRows-len(s.rows)-emptyRows {
+					return nil, fmt.Errorf("sheet %q has more than %d rows", s.name, maxRows)
+				}
+				for ; emptyRows > 0; emptyRows-- {
+					if err := cfg.CheckRow(rowIndex(cfg, s), nil); err != nil {
+						return nil, err
+					}
+					s.rows = append(s.rows, nil)
+				}
+				values := make([]string, len(row))
+				for i := range row {
+					values[i] = row[i].value
+				}
+				for range rowRepeat {
+					if err := cfg.CheckRow(rowIndex(cfg, s), values); err != nil {
+						return nil, err
+					}
+					s.rows = append(s.rows, row)
+				}
+			}
+		}
+	}
+}
+
+// rowIndex returns the index of the next row of s passed to CheckRow: its data
+// row index, or 0 with first-column-header, where rows become columns
+func rowIndex(cfg *common.Config, s *sheet) int {
+	if cfg.GetExtensionBool("first-column-header", false) {
+		return 0
+	}
+	return len(s.rows) - 1
+}
+
+// attr returns the value of the attribute space:local of an element
+func attr(t xml.StartElement, space, local string) string {
+	for _, a := range t.Attr {
+		if a.Name.Space == space && a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// repeat returns a table:number-* attribute of an element, 1 if it is missing
+func repeat(t xml.StartElement, local string) int {
+	n, err := strconv.Atoi(attr(t, tableNS, local))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// typedValue returns the value of a typed cell from its value attribute. It
+// is "" for text cells and cells without the attribute, which use the cell text.
+func typedValue(t xml.StartElement, valueType string) string {
+	switch valueType {
+	case "float", "percentage", "currency":
+		return attr(t, officeNS, "value")
+	case "date":
+		return attr(t, officeNS, "date-value")
+	case "time":
+		return clockTime(attr(t, officeNS, "time-value"))
+	case "boolean":
+		return attr(t, officeNS, "boolean-value")
+	case "string":
+		return attr(t, officeNS, "string-value")
+	}
+	return ""
+}
+
+// clockTime formats a time-value duration such as PT13H45M00S as 13:45:00
+func clockTime(value string) string {
+	d, err := time.ParseDuration(strings.ToLower(strings.TrimPrefix(value, "PT")))
+	if err != nil {
+		return value
+	}
+	return time.Time{}.Add(d).Format("15:04:05.999999999")
+}
+
+// readSheet fills table from the rows of one sheet
+func readSheet(cfg *common.Config, s *sheet, table *common.Table) error {
+	width := 0
+	for _, row := range s.rows {
+		width = max(width, len(row))
+	}
+	if width > 0 && len(s.rows) > common.MaxGridCells/width {
+		return fmt.Errorf("table of %d rows and %d columns exceeds the limit of %d cells", len(s.rows), width, common.MaxGridCells)
+	}
+	grid := make([][]string, len(s.rows))
+	for r, row := range s.rows {
+		grid[r] = make([]string, width)
+		for c, cell := range row {
+			grid[r][c] = cell.value
+		}
+	}
+
+	if cfg.GetExtensionBool("first-column-header", false) {
+		// Each row holds the header and the values of one column
+		for _, row := range grid {
+			if width == 0 {
+				table.Headers = append(table.Headers, "")
+				continue
+			}
+			table.Headers = append(table.Headers, row[0])
+		}
+		for c := 1; c < width; c++ {
+			values := make([]string, len(grid))
+			for r := range grid {
+				values[r] = grid[r][c]
+			}
+			table.Rows = append(table.Rows, values)
+		}
+		return nil
+	}
+	if len(grid) == 0 {
+		return nil
+	}
+	table.Headers = grid[0]
+	table.Rows = grid[1:]
+
+	// Merged cells become spans, the header row is row -1
+	for r, row := range s.rows {
+		for c, cell := range row {
+			if cell.rowSpan > 1 || cell.colSpan > 1 {
+				table.Spans = append(table.Spans, common.Span{Row: r - 1, Col: c, RowSpan: cell.rowSpan, ColSpan: cell.colSpan})
+			}
+		}
+	}
+
+	// Collect column types from the value types stored in the sheet. Cells
+	// covered by a merged cell hold no value of their own.
+	layout := table.SpanLayout()
+	table.Columns = common.NewColumns(table.Headers)
+	for r, row := range s.rows[1:] {
+		for c := range table.Columns {
+			if layout.Covered(r, c) {
+				continue
+			}
+			if c >= len(row) || row[c].value == "" {
+				table.Columns[c].Nullable = true
+				continue
+			}
+			colType, sourceType := cellType(row[c])
+			table.Columns[c].Observe(colType, sourceType)
+		}
+	}
+	for c := range table.Columns {
+		if table.Columns[c].Type == common.ColumnTypeUnknown {
+			table.Columns[c].Type = common.ColumnTypeString
+		}
+	}
+
+	// Blank cells of number, date or bool columns hold no value, they are NULL.
+	// Blank cells of text columns, and cells covered by a merged cell, stay empty strings.
+	table.TrackNulls()
+	for r, row := range table.Rows {
+		for c, value := range row {
+			if value == "" && table.Columns[c].Type != common.ColumnTypeString && !layout.Covered(r, c) {
+				table.SetNull(r, c)
+				row[c] = common.DefaultNullString
+			}
+		}
+	}
+	return nil
+}
+
+// cellType maps the value type of a cell to a column type
+func cellType(c cell) (common.ColumnType, string) {
+	switch c.valueType {
+	case "float":
+		if _, err := strconv.ParseInt(c.value, 10, 64); err == nil {
+			return common.ColumnTypeInt, c.valueType
+		}
+		return common.ColumnTypeFloat, c.valueType
+	case "percentage", "currency":
+		return common.ColumnTypeFloat, c.valueType
+	case "date":
+		return common.ColumnTypeDate, c.valueType
+	case "boolean":
+		return common.ColumnTypeBool, c.valueType
+	case "":
+		return common.ColumnTypeString, "string"
+	}
+	return common.ColumnTypeString, c.valueType
+}
+
+func Marshal(cfg *common.Config, table *common.Table) error {
+	doc := &common.Document{Tables: []*common.Table{table}}
+	return writePackage(cfg, doc, []string{cfg.GetExtensionString("sheet-name", "Sheet1")})
+}
+
+// MarshalDocument writes each table of the document to its own sheet
+func MarshalDocument(cfg *common.Config, doc *common.Document) error {
+	return writePackage(cfg, doc, doc.UniqueTableNames(sheetNameFor))
+}
+
+// sheetNameFor makes a valid sheet name ending with suffix: none of the
+// characters []*?:/\ and no apostrophe at either end
+func sheetNameFor(name, suffix string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]*?:/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, "'")
+	if name == "" {
+		name = "Sheet"
+	}
+
+	return name + suffix
+}
+
+const manifest = `<?xml version="1.0" encoding="UTF-8"?>
+<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.3">
+ <manifest:file-entry manifest:full-path="/" manifest:version="1.3" manifest:media-type="` + mimeType + `"/>
+ <manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>
+</manifest:manifest>
+`
+
+// writePackage writes the tables of doc as sheets with the given names to
+// cfg.Result, or to cfg.Writer when no file is given
+func writePackage(cfg *common.Config, doc *common.Document, names []string) error {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	// The mimetype entry comes first and uncompressed, so that it can be read at a fixed offset
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, mimeType); err != nil {
+		return err
+	}
+	if w, err = archive.Create("META-INF/manifest.xml"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, manifest); err != nil {
+		return err
+	}
+	if w, err = archive.Create("content.xml"); err != nil {
+		return err
+	}
+	content := bufio.NewWriter(w)
+	content.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	content.WriteString(`<office:document-content xmlns:office="` + officeNS + `" xmlns:table="` + tableNS + `" xmlns:text="` + textNS + `" office:version="1.3">`)
+	content.WriteString(`<office:body><office:spreadsheet>`)
+	for i, table := range doc.Tables {
+		if err := writeTable(content, names[i], table); err != nil {
+			return fmt.Errorf("sheet %q: %w", names[i], err)
+		}
+	}
+	content.WriteString("</office:spreadsheet></office:body></office:document-content>\n")
+	if err := content.Flush(); err != nil {
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	if cfg.Result == "" && cfg.Writer != nil {
+		_, err := cfg.Writer.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(cfg.Result, buf.Bytes(), 0o644)
+}
+
+// writeTable writes table as a table:table with the header row as its header rows
+func writeTable(w *bufio.Writer, name string, table *common.Table) error {
+	w.WriteString(`<table:table table:name="`)
+	xml.EscapeText(w, []byte(name))
+	w.WriteString(`">`)
+	if len(table.Headers) > 0 {
+		fmt.Fprintf(w, `<table:table-column table:number-columns-repeated="%d"/>`, len(table.Headers))
+	}
+
+	layout := table.SpanLayout()
+	w.WriteString("<table:table-header-rows><table:table-row>")
+	for c, header := range table.Headers {
+		writeCell(w, layout, -1, c, header, header, false)
+	}
+	w.WriteString("</table:table-row></table:table-header-rows>")
+
+	for r, row := range table.Rows {
+		if len(row) != len(table.Headers) {
+			return fmt.Errorf("row length %d does not match header length %d", len(row), len(table.Headers))
+		}
+		w.WriteString("<table:table-row>")
+		for c, value := range row {
+			// Typed numeric, boolean and date columns are stored as native cell values
+			t := table.ColumnType(c)
+			typed := common.TypedValue(value, t)
+			if date, ok := dateValue(value); ok && t == common.ColumnTypeDate {
+				typed = dateCell(date)
+			}
+			writeCell(w, layout, r, c, value, typed, table.IsNull(r, c))
+		}
+		w.WriteString("</table:table-row>")
+	}
+	w.WriteString("</table:table>")
+	return nil
+}
+
+// writeCell writes one cell. NULL cells are written without a value.
+func writeCell(w *bufio.Writer, layout common.SpanLayout, r, c int, value string, typed any, null bool) {
+	if layout.Covered(r, c) {
+		w.WriteString("<table:covered-table-cell/>")
+		return
+	}
+	w.WriteString("<table:table-cell")
+	if span, ok := layout.At(r, c); ok {
+		fmt.Fprintf(w, ` table:number-columns-spanned="%d" table:number-rows-spanned="%d"`, span.ColSpan, span.RowSpan)
+	}
+	if null {
+		w.WriteString("/>")
+		return
+	}
+
+	switch v := typed.(type) {
+	case int64:
+		fmt.Fprintf(w, ` office:value-type="float" office:value="%d"`, v)
+	case float64:
+		fmt.Fprintf(w, ` office:value-type="float" office:value="%s"`, strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		fmt.Fprintf(w, ` office:value-type="boolean" office:boolean-value="%t"`, v)
+	case dateCell:
+		fmt.Fprintf(w, ` office:value-type="date" office:date-value="%s"`, v)
+	default:
+		w.WriteString(` office:value-type="string"`)
+	}
+	w.WriteString(">")
+	for _, line := range strings.Split(value, "\n") {
+		w.WriteString("<text:p>")
+		writeText(w, line)
+		w.WriteString("</text:p>")
+	}
+	w.WriteString("</table:table-cell>")
+}
+
+// dateCell is the date-value of a date cell
+type dateCell string
+
+// dateValue returns a date or a date and time without a UTC offset as a date-value
+func dateValue(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if _, err := time.Parse("2006-01-02", value); err == nil {
+		return value, true
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("2006-01-02T15:04:05.999999999"), true
+		}
+	}
+	return "", false
+}
+
+// writeText writes a line of cell text. Spaces that XML white space handling
+// would collapse are written as text:s elements and tabs as text:tab.
+func writeText(w *bufio.Writer, line string) {
+	for len(line) > 0 {
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			xml.EscapeText(w, []byte(line))
+			return
+		}
+		xml.EscapeText(w, []byte(line[:i]))
+		if line[i] == '\t' {
+			w.WriteString("<text:tab/>")
+			line = line[i+1:]
+			continue
+		}
+		spaces := len(line[i:]) - len(strings.TrimLeft(line[i:], " "))
+		literal := i > 0 && i+spaces < len(line)
+		if literal {
+			w.WriteByte(' ')
+			spaces--
+		}
+		if spaces == 1 {
+			w.WriteString("<text:s/>")
+		} else if spaces > 1 {
+			fmt.Fprintf(w, `<text:s text:c="%d"/>`, spaces)
+		}
+		line = strings.TrimLeft(line[i:], " ")
+	}
+}
@@ -0,0 +1,267 @@
+package ods
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPackage zips a content.xml body into a spreadsheet package
+func newPackage(t *testing.T, mime, body string) []byte {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	require.NoError(t, err)
+	_, err = w.Write([]byte(mime))
+	require.NoError(t, err)
+	w, err = archive.Create("content.xml")
+	require.NoError(t, err)
+	_, err = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+  xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+  xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+  xmlns:dc="http://purl.org/dc/elements/1.1/" office:version="1.3">
+<office:body><office:spreadsheet>` + body + `</office:spreadsheet></office:body></office:document-content>`))
+	require.NoError(t, err)
+	require.NoError(t, archive.Close())
+	return buf.Bytes()
+}
+
+func TestUnmarshal(t *testing.T) {
+	// Shaped like a sheet saved by LibreOffice Calc
+	body := `<table:table table:name="Data">
+<table:table-column table:number-columns-repeated="1024"/>
+<table:table-row>
+  <table:table-cell office:value-type="string"><text:p>id</text:p></table:table-cell>
+  <table:table-cell office:value-type="string"><text:p>name</text:p></table:table-cell>
+  <table:table-cell office:value-type="string"><text:p>share</text:p></table:table-cell>
+  <table:table-cell office:value-type="string"><text:p>born</text:p></table:table-cell>
+  <table:table-cell office:value-type="string"><text:p>active</text:p></table:table-cell>
+  <table:table-cell office:value-type="string"><text:p>at</text:p></table:table-cell>
+  <table:table-cell table:number-columns-repeated="1018"/>
+</table:table-row>
+<table:table-row>
+  <table:table-cell office:value-type="float" office:value="1"><text:p>1.00</text:p></table:table-cell>
+  <table:table-cell office:value-type="string"><text:p>Alice <text:span>B.</text:span><text:s text:c="2"/>Smith</text:p>
+    <office:annotation><dc:creator>bob</dc:creator><text:p>a comment</text:p></office:annotation></table:table-cell>
+  <table:table-cell office:value-type="percentage" office:value="0.25"><text:p>25%</text:p></table:table-cell>
+  <table:table-cell office:value-type="date" office:date-value="2020-01-02"><text:p>01/02/20</text:p></table:table-cell>
+  <table:table-cell office:value-type="boolean" office:boolean-value="true"><text:p>TRUE</text:p></table:table-cell>
+  <table:table-cell office:value-type="time" office:time-value="PT13H45M00S"><text:p>01:45 PM</text:p></table:table-cell>
+  <table:table-cell table:number-columns-repeated="1018"/>
+</table:table-row>
+<table:table-row table:number-rows-repeated="2">
+  <table:table-cell office:value-type="float" office:value="2.5"><text:p>2.5</text:p></table:table-cell>
+  <table:table-cell table:number-columns-spanned="2" table:number-rows-spanned="1" office:value-type="string"><text:p>first</text:p><text:p>second</text:p></table:table-cell>
+  <table:covered-table-cell/>
+  <table:table-cell table:number-columns-repeated="1021"/>
+</table:table-row>
+<table:table-row table:number-rows-repeated="1048572"><table:table-cell table:number-columns-repeated="1024"/></table:table-row>
+</table:table>
+<table:table table:name="Other"><table:table-row><table:table-cell office:value-type="string"><text:p>x</text:p></table:table-cell></table:table-row></table:table>`
+	data := newPackage(t, mimeType, body)
+
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: bytes.NewReader(data)}, table))
+	assert.Equal(t, "Data", table.Name)
+	assert.Equal(t, []string{"id", "name", "share", "born", "active", "at"}, table.Headers)
+	assert.Equal(t, [][]string{
+		{"1", "Alice B.  Smith", "0.25", "2020-01-02", "true", "13:45:00"},
+		{"2.5", "first\nsecond", "", "NULL", "NULL", ""},
+		{"2.5", "first\nsecond", "", "NULL", "NULL", ""},
+	}, table.Rows)
+	assert.Equal(t, []common.Span{{Row: 1, Col: 1, RowSpan: 1, ColSpan: 2}, {Row: 2, Col: 1, RowSpan: 1, ColSpan: 2}}, table.Spans)
+
+	types := []common.ColumnType{common.ColumnTypeFloat, common.ColumnTypeString, common.ColumnTypeFloat,
+		common.ColumnTypeDate, common.ColumnTypeBool, common.ColumnTypeString}
+	for i, column := range table.Columns {
+		assert.Equal(t, types[i], column.Type, column.Name)
+	}
+	assert.Equal(t, "percentage", table.Columns[2].SourceType)
+	// The share cells covered by the merged name cells are not NULL
+	assert.False(t, table.IsNull(1, 2))
+	assert.True(t, table.IsNull(1, 3))
+	assert.False(t, table.IsNull(1, 5))
+
+	// A sheet by name
+	table = &common.Table{}
+	cfg := &common.Config{Reader: bytes.NewReader(data), Extension: map[string]string{"sheet-name": "other"}}
+	require.NoError(t, Unmarshal(cfg, table))
+	assert.Equal(t, []string{"x"}, table.Headers)
+
+	cfg = &common.Config{Reader: bytes.NewReader(data), Extension: map[string]string{"sheet-name": "Missing"}}
+	assert.ErrorContains(t, Unmarshal(cfg, &common.Table{}), `sheet "Missing" not found, available sheets: Data, Other`)
+
+	doc := &common.Document{}
+	require.NoError(t, UnmarshalDocument(&common.Config{Reader: bytes.NewReader(data)}, doc))
+	require.Len(t, doc.Tables, 2)
+	assert.Equal(t, "Other", doc.Tables[1].Name)
+}
+
+func TestUnmarshalMergedTypedCells(t *testing.T) {
+	// year A2:A3 is merged, the covered A3 is not a NULL year
+	body := `<table:table table:name="Sales">
+<table:table-row>
+  <table:table-cell office:value-type="string"><text:p>year</text:p></table:table-cell>
+  <table:table-cell office:value-type="string"><text:p>sales</text:p></table:table-cell>
+</table:table-row>
+<table:table-row>
+  <table:table-cell table:number-rows-spanned="2" office:value-type="float" office:value="2024"><text:p>2024</text:p></table:table-cell>
+  <table:table-cell office:value-type="float" office:value="10"><text:p>10</text:p></table:table-cell>
+</table:table-row>
+<table:table-row>
+  <table:covered-table-cell/>
+  <table:table-cell office:value-type="float" office:value="20"><text:p>20</text:p></table:table-cell>
+</table:table-row>
+</table:table>`
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: bytes.NewReader(newPackage(t, mimeType, body))}, table))
+	assert.Equal(t, [][]string{{"2024", "10"}, {"", "20"}}, table.Rows)
+	assert.Equal(t, []common.Span{{Row: 0, Col: 0, RowSpan: 2, ColSpan: 1}}, table.Spans)
+	assert.False(t, table.IsNull(1, 0))
+	assert.Equal(t, common.ColumnTypeInt, table.Columns[0].Type)
+	assert.False(t, table.Columns[0].Nullable)
+
+	common.ExpandSpans(table)
+	assert.Equal(t, [][]string{{"2024", "10"}, {"2024", "20"}}, table.Rows)
+	assert.False(t, table.IsNull(1, 0))
+}
+
+func TestUnmarshalFirstColumnHeader(t *testing.T) {
+	body := `<table:table table:name="Sheet1">
+<table:table-row><table:table-cell><text:p>name</text:p></table:table-cell><table:table-cell><text:p>alice</text:p></table:table-cell><table:table-cell><text:p>bob</text:p></table:table-cell></table:table-row>
+<table:table-row><table:table-cell><text:p>age</text:p></table:table-cell><table:table-cell><text:p>30</text:p></table:table-cell></table:table-row>
+</table:table>`
+	table := &common.Table{}
+	cfg := &common.Config{Reader: bytes.NewReader(newPackage(t, mimeType, body)), Extension: map[string]string{"first-column-header": "true"}}
+	require.NoError(t, Unmarshal(cfg, table))
+	assert.Equal(t, []string{"name", "age"}, table.Headers)
+	assert.Equal(t, [][]string{{"alice", "30"}, {"bob", ""}}, table.Rows)
+}
+
+func TestMarshalAndUnmarshal(t *testing.T) {
+	table := &common.Table{
+		Headers: []string{"id", "score", "born", "active", "note"},
+		Rows: [][]string{
+			{"1", "9.5", "2020-01-02", "true", "  two  spaces "},
+			{"2", "", "2021-03-04 10:00:00", "false", "tab\there\nnext <line> & more"},
+			{"3", "7", "", "NULL", "merged"},
+		},
+		Columns: []common.Column{
+			{Name: "id", Type: common.ColumnTypeInt}, {Name: "score", Type: common.ColumnTypeFloat},
+			{Name: "born", Type: common.ColumnTypeDate}, {Name: "active", Type: common.ColumnTypeBool},
+			{Name: "note", Type: common.ColumnTypeString},
+		},
+		Spans: []common.Span{{Row: 2, Col: 3, RowSpan: 1, ColSpan: 2}},
+	}
+	table.SetNull(2, 3)
+
+	result := filepath.Join(t.TempDir(), "data.ods")
+	require.NoError(t, Marshal(&common.Config{Result: result, Extension: map[string]string{"sheet-name": "Scores"}}, table))
+
+	// The mimetype entry comes first and is stored
+	archive, err := zip.OpenReader(result)
+	require.NoError(t, err)
+	assert.Equal(t, "mimetype", archive.File[0].Name)
+	assert.Equal(t, zip.Store, archive.File[0].Method)
+	archive.Close()
+
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{File: result}, got))
+	assert.Equal(t, "Scores", got.Name)
+	assert.Equal(t, table.Headers, got.Headers)
+	assert.Equal(t, [][]string{
+		{"1", "9.5", "2020-01-02", "true", "  two  spaces "},
+		{"2", "NULL", "2021-03-04T10:00:00", "false", "tab\there\nnext <line> & more"},
+		{"3", "7", "NULL", "NULL", ""},
+	}, got.Rows)
+	assert.Equal(t, table.Spans, got.Spans)
+	types := []common.ColumnType{common.ColumnTypeInt, common.ColumnTypeFloat, common.ColumnTypeDate, common.ColumnTypeBool, common.ColumnTypeString}
+	for i, column := range got.Columns {
+		assert.Equal(t, types[i], column.Type, column.Name)
+	}
+}
+
+func TestMarshalDocument(t *testing.T) {
+	doc := &common.Document{Tables: []*common.Table{
+		{Name: "a/b", Headers: []string{"x"}, Rows: [][]string{{"1"}}},
+		{Name: "A_B", Headers: []string{"y"}, Rows: [][]string{{"2"}}},
+		{Headers: []string{"z"}},
+	}}
+	var buf bytes.Buffer
+	require.NoError(t, MarshalDocument(&common.Config{Writer: &buf}, doc))
+
+	got := &common.Document{}
+	require.NoError(t, UnmarshalDocument(&common.Config{Reader: &buf}, got))
+	require.Len(t, got.Tables, 3)
+	assert.Equal(t, []string{"a_b", "A_B (2)", "Table3"}, []string{got.Tables[0].Name, got.Tables[1].Name, got.Tables[2].Name})
+	assert.Equal(t, [][]string{{"2"}}, got.Tables[1].Rows)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	err := Unmarshal(&common.Config{Reader: bytes.NewReader([]byte("a,b\n1,2\n"))}, &common.Table{})
+	assert.ErrorContains(t, err, "invalid ODS file")
+
+	data := newPackage(t, "application/vnd.oasis.opendocument.text", "")
+	err = Unmarshal(&common.Config{Reader: bytes.NewReader(data)}, &common.Table{})
+	assert.ErrorContains(t, err, "not an OpenDocument spreadsheet")
+
+	data = newPackage(t, mimeType, "")
+	err = Unmarshal(&common.Config{Reader: bytes.NewReader(data)}, &common.Table{})
+	assert.ErrorContains(t, err, "no sheets found")
+
+	err = Unmarshal(&common.Config{File: "does-not-exist.ods"}, &common.Table{})
+	assert.True(t, os.IsNotExist(err))
+
+	table := &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1"}}}
+	assert.Error(t, Marshal(&common.Config{Writer: &bytes.Buffer{}}, table))
+}
+
+func TestUnmarshalRepeated(t *testing.T) {
+	unmarshal := func(cfg *common.Config, body string) error {
+		cfg.Reader = bytes.NewReader(newPackage(t, mimeType, `<table:table table:name="S">`+body+`</table:table>`))
+		return Unmarshal(cfg, &common.Table{})
+	}
+
+	// Repeats beyond the sheet size fail instead of being expanded
+	err := unmarshal(&common.Config{}, `<table:table-row table:number-rows-repeated="1000000000"><table:table-cell><text:p>x</text:p></table:table-cell></table:table-row>`)
+	assert.ErrorContains(t, err, "more than 1048576 rows")
+	err = unmarshal(&common.Config{}, `<table:table-row><table:table-cell table:number-columns-repeated="1000000000"><text:p>x</text:p></table:table-cell></table:table-row>`)
+	assert.ErrorContains(t, err, "more than 16384 columns")
+	err = unmarshal(&common.Config{}, `<table:table-row table:number-rows-repeated="1048576"><table:table-cell table:number-columns-repeated="16384"><text:p>x</text:p></table:table-cell></table:table-row>`)
+	assert.ErrorContains(t, err, "exceeds the limit of")
+
+	// Limits are checked while the rows are expanded
+	var limitErr *common.LimitError
+	err = unmarshal(&common.Config{Limits: common.Limits{MaxRows: 10}}, `<table:table-row table:number-rows-repeated="1048576"><table:table-cell><text:p>x</text:p></table:table-cell></table:table-row>`)
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "max-rows", limitErr.Limit)
+
+	// Empty rows and cells repeated to the end of the sheet are dropped
+	table := &common.Table{}
+	data := newPackage(t, mimeType, `<table:table table:name="S"><table:table-row><table:table-cell><text:p>a</text:p></table:table-cell><table:table-cell table:number-columns-repeated="16383"/></table:table-row><table:table-row><table:table-cell><text:p>1</text:p></table:table-cell></table:table-row><table:table-row table:number-rows-repeated="1048574"><table:table-cell table:number-columns-repeated="16384"/></table:table-row></table:table>`)
+	require.NoError(t, Unmarshal(&common.Config{Reader: bytes.NewReader(data)}, table))
+	assert.Equal(t, []string{"a"}, table.Headers)
+	assert.Equal(t, [][]string{{"1"}}, table.Rows)
+}
+
+func TestFixture(t *testing.T) {
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{File: "../test/mysql.ods"}, table))
+	assert.Equal(t, []string{"FIELD", "TYPE", "NULL", "KEY", "DEFAULT", "EXTRA"}, table.Headers)
+	assert.Equal(t, []string{"user_id", "smallint(5)", "NO", "PRI", "NULL", "auto_increment"}, table.Rows[0])
+}
+
+func TestDetect(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Marshal(&common.Config{Writer: &buf}, &common.Table{Headers: []string{"a"}}))
+	assert.Equal(t, common.DetectCertain, detect(buf.String()))
+	assert.Equal(t, common.DetectNone, detect("PK\x03\x04xl/workbook.xml"))
+}
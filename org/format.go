@@ -0,0 +1,40 @@
+package org
+
+import (
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "org",
+		Description: "Emacs Org-mode table",
+		Aliases:     []string{"orgmode"},
+		Extensions:  []string{".org"},
+		Detect:      detect,
+		DetectOrder: 16,
+		Params: []common.FormatParam{
+			common.AlignParam,
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+		MarshalDocument:   MarshalDocument,
+	})
+}
+
+// detect scores content holding an Org table: a "|---+---|" rule, or a "#+TBLFM:" line after table rows
+func detect(sample string) int {
+	lines := common.SampleLines(sample, 50)
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case hlinePattern.MatchString(line) && strings.Contains(line, "-+-"):
+			return 95
+		case i > 0 && strings.HasPrefix(strings.ToUpper(line), "#+TBLFM:") && strings.HasPrefix(strings.TrimSpace(lines[i-1]), "|"):
+			return 90
+		}
+	}
+	return common.DetectNone
+}
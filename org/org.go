@@ -0,0 +1,233 @@
+package org
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/mattn/go-runewidth"
+)
+
+var (
+	// hlinePattern matches a horizontal rule row such as "|---+---|" or "|-"
+	hlinePattern = regexp.MustCompile(`^\|-[-+|]*$`)
+	// cookiePattern matches an alignment or width cookie such as "<l>", "<r10>" or "<15>"
+	cookiePattern = regexp.MustCompile(`^<[lcr]?\d*>$`)
+	// headingPattern matches an outline heading such as "** TODO Tasks"
+	headingPattern = regexp.MustCompile(`^\*+\s+(\S.*)$`)
+)
+
+// splitRow splits a table row into trimmed cells, with "\vert" read as a literal "|"
+func splitRow(line string) []string {
+	line = strings.TrimPrefix(strings.TrimSpace(line), "|")
+	if strings.HasSuffix(line, "|") {
+		line = line[:len(line)-1]
+	}
+	cells := strings.Split(line, "|")
+	for i, cell := range cells {
+		cell = strings.ReplaceAll(strings.TrimSpace(cell), `\vert{}`, "|")
+		cells[i] = strings.ReplaceAll(cell, `\vert`, "|")
+	}
+	return cells
+}
+
+// isCookieRow reports whether every non-empty cell of a row is an alignment or width cookie
+func isCookieRow(cells []string) bool {
+	found := false
+	for _, cell := range cells {
+		if cell == "" {
+			continue
+		}
+		if !cookiePattern.MatchString(cell) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// newTable builds a table from its rows: the first row is the header whether or
+// not a rule follows it, and short rows are padded to the widest one
+func newTable(name string, rows [][]string) (*common.Table, error) {
+	if err := common.PadRows(rows); err != nil {
+		return nil, err
+	}
+	return &common.Table{Name: name, Headers: rows[0], Rows: rows[1:]}, nil
+}
+
+// parse reads up to limit tables of an Org document, all of them when limit is 0.
+// Rules and cookie rows are left out wherever they are, as are the "#+TBLFM:"
+// formula lines below a table. A table is named after its "#+NAME:" or
+// "#+CAPTION:" line, or the closest preceding heading.
+func parse(cfg *common.Config, limit int) ([]*common.Table, error) {
+	if cfg.Reader == nil {
+		return nil, fmt.Errorf("Unmarshal: Reader in Config cannot be nil")
+	}
+	var tables []*common.Table
+	var rows [][]string
+	heading, name := "", ""
+	var err error
+	endTable := func() {
+		if len(rows) > 0 && err == nil {
+			if name == "" {
+				name = heading
+			}
+			var table *common.Table
+			if table, err = newTable(name, rows); err == nil {
+				tables = append(tables, table)
+			}
+			heading, name = "", ""
+		}
+		rows = nil
+	}
+
+	scanner := bufio.NewScanner(cfg.Reader)
+	for scanner.Scan() && (limit == 0 || len(tables) < limit) && err == nil {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "|") {
+			endTable()
+		}
+		upper := strings.ToUpper(line)
+		switch {
+		case hlinePattern.MatchString(line):
+		case strings.HasPrefix(line, "|"):
+			if cells := splitRow(line); !isCookieRow(cells) {
+				// The first row is the header
+				err = cfg.CheckRow(len(rows)-1, cells)
+				rows = append(rows, cells)
+			}
+		case strings.HasPrefix(upper, "#+NAME:"):
+			name = strings.TrimSpace(line[len("#+NAME:"):])
+		case strings.HasPrefix(upper, "#+CAPTION:"):
+			if name == "" {
+				name = strings.TrimSpace(line[len("#+CAPTION:"):])
+			}
+		case headingPattern.MatchString(line):
+			heading, name = headingPattern.FindStringSubmatch(line)[1], ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading input: %w", err)
+	}
+	if limit == 0 || len(tables) < limit {
+		endTable()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("parsing failed: no Org table found in input")
+	}
+	return tables, nil
+}
+
+// Unmarshal reads the first table of the input
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Unmarshal: target table pointer cannot be nil")
+	}
+	tables, err := parse(cfg, 1)
+	if err != nil {
+		return err
+	}
+	table.Name, table.Headers, table.Rows = tables[0].Name, tables[0].Headers, tables[0].Rows
+	return nil
+}
+
+// UnmarshalDocument reads every table of the input
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	tables, err := parse(cfg, 0)
+	if err != nil {
+		return err
+	}
+	doc.Tables = append(doc.Tables, tables...)
+	return nil
+}
+
+// MarshalDocument writes every table below a "#+NAME:" line with its name
+func MarshalDocument(cfg *common.Config, doc *common.Document) error {
+	names := doc.UniqueTableNames(nil)
+	for i, table := range doc.Tables {
+		prefix := ""
+		if i > 0 {
+			prefix = "\n"
+		}
+		if _, err := fmt.Fprintf(cfg.Writer, "%s#+NAME: %s\n", prefix, names[i]); err != nil {
+			return err
+		}
+		if err := Marshal(cfg, table); err != nil {
+			return fmt.Errorf("table %q: %w", names[i], err)
+		}
+	}
+	return nil
+}
+
+// Marshal writes an aligned Org table with a rule below the header. Alignments other
+// than left are also written as a "<l>/<c>/<r>" cookie row so that Org keeps them.
+// A "|" in a cell is written as "\vert{}" and line breaks as spaces.
+func Marshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Marshal: input table pointer cannot be nil")
+	}
+	if len(table.Headers) == 0 {
+		return fmt.Errorf("Marshal: table must have at least one header")
+	}
+	for i, row := range table.Rows {
+		if len(row) != len(table.Headers) {
+			return fmt.Errorf("Marshal: row %d has %d columns, but table has %d", i, len(row), len(table.Headers))
+		}
+	}
+
+	aligns := cfg.ColumnAligns(len(table.Headers))
+	cookies := false
+	for _, align := range aligns {
+		cookies = cookies || align != "l"
+	}
+
+	replacer := strings.NewReplacer("|", `\vert{}`, "\r\n", " ", "\n", " ")
+	var rows [][]string
+	for _, row := range append([][]string{table.Headers}, table.Rows...) {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = replacer.Replace(cell)
+		}
+		rows = append(rows, cells)
+	}
+	if cookies {
+		cookieRow := make([]string, len(table.Headers))
+		for i := range cookieRow {
+			cookieRow[i] = "<" + aligns[i] + ">"
+		}
+		rows = slices.Insert(rows, 1, cookieRow)
+	}
+
+	widths := make([]int, len(table.Headers))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], runewidth.StringWidth(cell))
+		}
+	}
+
+	var b strings.Builder
+	for r, row := range rows {
+		b.WriteString("|")
+		for i, cell := range row {
+			b.WriteString(" " + common.PadAlign(cell, widths[i], aligns[i]) + " |")
+		}
+		b.WriteString("\n")
+		if r == 0 && len(rows) > 1 {
+			rule := make([]string, len(widths))
+			for i, width := range widths {
+				rule[i] = strings.Repeat("-", width+2)
+			}
+			b.WriteString("|" + strings.Join(rule, "+") + "|\n")
+		}
+	}
+	_, err := io.WriteString(cfg.Writer, b.String())
+	return err
+}
@@ -0,0 +1,120 @@
+package org
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshal(t *testing.T) {
+	input := `* Scores
+Some notes.
+
+|-------+-----|
+| <l>   | <r> |
+| name  | n   |
+|-------+-----|
+| a \vert b | 1 |
+|-------+-----|
+| b     | 2   |
+| total |
+#+TBLFM: @>$2=vsum(@I..@II)
+`
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table))
+	assert.Equal(t, "Scores", table.Name)
+	assert.Equal(t, []string{"name", "n"}, table.Headers)
+	assert.Equal(t, [][]string{{"a | b", "1"}, {"b", "2"}, {"total", ""}}, table.Rows)
+}
+
+func TestMarshal(t *testing.T) {
+	table := &common.Table{
+		Headers: []string{"name", "年齢", "note"},
+		Rows:    [][]string{{"alice", "20", "a|b"}, {"bob", "3", "two\nlines"}},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, Marshal(&common.Config{Writer: &buf}, table))
+	expected := `| name  | 年齢 | note      |
+|-------+------+-----------|
+| alice | 20   | a\vert{}b |
+| bob   | 3    | two lines |
+`
+	assert.Equal(t, expected, buf.String())
+
+	buf.Reset()
+	cfg := &common.Config{Writer: &buf, Extension: map[string]string{"align": "l,r,c"}}
+	require.NoError(t, Marshal(cfg, table))
+	expected = `| name  | 年齢 |   note    |
+|-------+------+-----------|
+| <l>   |  <r> |    <c>    |
+| alice |   20 | a\vert{}b |
+| bob   |    3 | two lines |
+`
+	assert.Equal(t, expected, buf.String())
+
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: &buf}, got))
+	assert.Equal(t, table.Headers, got.Headers)
+	assert.Equal(t, [][]string{{"alice", "20", "a|b"}, {"bob", "3", "two lines"}}, got.Rows)
+
+	buf.Reset()
+	require.NoError(t, Marshal(&common.Config{Writer: &buf}, &common.Table{Headers: []string{"a"}}))
+	assert.Equal(t, "| a |\n", buf.String())
+}
+
+func TestDocument(t *testing.T) {
+	input := `#+NAME: first
+| a |
+|---|
+| 1 |
+
+* Heading
+#+CAPTION: Second table
+| b |
+| 2 |
+
+* Third
+| c |
+`
+	doc := &common.Document{}
+	require.NoError(t, UnmarshalDocument(&common.Config{Reader: strings.NewReader(input)}, doc))
+	require.Len(t, doc.Tables, 3)
+	assert.Equal(t, "first", doc.Tables[0].Name)
+	assert.Equal(t, "Second table", doc.Tables[1].Name)
+	assert.Equal(t, [][]string{{"2"}}, doc.Tables[1].Rows)
+	assert.Equal(t, "Third", doc.Tables[2].Name)
+
+	var buf bytes.Buffer
+	require.NoError(t, MarshalDocument(&common.Config{Writer: &buf}, doc))
+	assert.Equal(t, "#+NAME: first\n| a |\n|---|\n| 1 |\n\n#+NAME: Second table\n| b |\n|---|\n| 2 |\n\n#+NAME: Third\n| c |\n", buf.String())
+}
+
+func TestErrors(t *testing.T) {
+	assert.ErrorContains(t, Unmarshal(&common.Config{Reader: strings.NewReader("* only text\n")}, &common.Table{}), "no Org table")
+	assert.Error(t, Unmarshal(&common.Config{Reader: strings.NewReader("")}, nil))
+	assert.Error(t, Marshal(&common.Config{Writer: &bytes.Buffer{}}, &common.Table{}))
+	table := &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1"}}}
+	assert.ErrorContains(t, Marshal(&common.Config{Writer: &bytes.Buffer{}}, table), "row 0 has 1 columns")
+}
+
+func TestFixture(t *testing.T) {
+	file, err := os.Open("../test/mysql.org")
+	require.NoError(t, err)
+	defer file.Close()
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: file}, table))
+	assert.Equal(t, []string{"FIELD", "TYPE", "NULL", "KEY", "DEFAULT", "EXTRA"}, table.Headers)
+	assert.Len(t, table.Rows, 3)
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, 95, detect("| a | b |\n|---+---|\n| 1 | 2 |\n"))
+	assert.Equal(t, 90, detect("| a | 1 |\n#+TBLFM: $2=$1*2\n"))
+	assert.Equal(t, common.DetectNone, detect("| a | b |\n|---|---|\n| 1 | 2 |\n"))
+}
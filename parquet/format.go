@@ -0,0 +1,40 @@
+package parquet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "parquet",
+		Description: "Apache Parquet columnar file",
+		Extensions:  []string{".parquet"},
+		Detect:      detect,
+		Params: []common.FormatParam{
+			{Name: "compression", DefaultValue: "snappy", Validate: common.OneOf(codecNames...), Description: "Compression codec: snappy, gzip, zstd, brotli, lz4 or none", Use: common.ParamWrite},
+			{Name: "row-group-size", DefaultValue: strconv.Itoa(defaultRowGroupSize), Validate: validateRowGroupSize, Description: "Maximum number of rows per row group", Use: common.ParamWrite},
+		},
+		Unmarshal: Unmarshal,
+		Marshal:   Marshal,
+	})
+}
+
+// detect scores a file starting with the Parquet magic number
+func detect(sample string) int {
+	if strings.HasPrefix(sample, "PAR1") {
+		return common.DetectCertain
+	}
+	return common.DetectNone
+}
+
+// validateRowGroupSize accepts a positive number of rows
+func validateRowGroupSize(value string) error {
+	if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+		return fmt.Errorf("invalid value %q, expected a positive number of rows", value)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package parquet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/arrow"
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+// defaultRowGroupSize is the maximum number of rows per row group unless --row-group-size is given
+const defaultRowGroupSize = 1 << 20
+
+// codecNames are the values of the compression option, codecs maps them to Parquet codecs
+var (
+	codecNames = []string{"snappy", "gzip", "zstd", "brotli", "lz4", "none"}
+	codecs     = map[string]compress.Compression{
+		"snappy": compress.Codecs.Snappy,
+		"gzip":   compress.Codecs.Gzip,
+		"zstd":   compress.Codecs.Zstd,
+		"brotli": compress.Codecs.Brotli,
+		"lz4":    compress.Codecs.Lz4Raw,
+		"none":   compress.Codecs.Uncompressed,
+	}
+)
+
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	src, err := openFile(cfg)
+	if err != nil {
+		return err
+	}
+	reader, err := file.NewParquetReader(src)
+	if err != nil {
+		return fmt.Errorf("invalid Parquet file: %w", err)
+	}
+	defer reader.Close()
+
+	fr, err := pqarrow.NewFileReader(reader, pqarrow.ArrowReadProperties{BatchSize: 64 << 10}, memory.DefaultAllocator)
+	if err != nil {
+		return err
+	}
+	schema, err := fr.Schema()
+	if err != nil {
+		return err
+	}
+
+	arrow.SetColumns(table, schema)
+
+	records, err := fr.GetRecordReader(context.Background(), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer records.Release()
+	for records.Next() {
+		if err := arrow.AppendRecordBatch(cfg, table, records.RecordBatch()); err != nil {
+			return err
+		}
+	}
+	return records.Err()
+}
+
+// openFile opens cfg.InputPath, or reads the whole input from cfg.Reader when
+// the file cannot be opened by name; Parquet keeps its metadata at the end of the file
+func openFile(cfg *common.Config) (parquet.ReaderAtSeeker, error) {
+	path := cfg.InputPath()
+	if path == "" && cfg.Reader != nil {
+		data, err := io.ReadAll(cfg.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(data), nil
+	}
+	return os.Open(path)
+}
+
+func Marshal(cfg *common.Config, table *common.Table) error {
+	mem := memory.DefaultAllocator
+	schema, err := arrow.NewSchema(table)
+	if err != nil {
+		return err
+	}
+	batch, err := arrow.NewRecordBatch(mem, schema, table, 0, len(table.Rows))
+	if err != nil {
+		return err
+	}
+	defer batch.Release()
+
+	name := cfg.GetExtensionString("compression", "snappy")
+	codec, ok := codecs[name]
+	if !ok {
+		return fmt.Errorf("unsupported Parquet compression %q, expected one of %s", name, strings.Join(codecNames, ", "))
+	}
+	props := parquet.NewWriterProperties(
+		parquet.WithCompression(codec),
+		parquet.WithMaxRowGroupLength(int64(cfg.GetExtensionInt("row-group-size", defaultRowGroupSize))),
+		parquet.WithAllocator(mem),
+	)
+	out, err := createFile(cfg)
+	if err != nil {
+		return err
+	}
+	writer, err := pqarrow.NewFileWriter(schema, out, props, pqarrow.DefaultWriterProps())
+	if err != nil {
+		out.Close()
+		return err
+	}
+	if err := writer.Write(batch); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+// createFile creates cfg.Result, or writes to cfg.Writer when no file is given.
+// The Parquet writer closes its output when it writes the footer.
+func createFile(cfg *common.Config) (io.WriteCloser, error) {
+	if cfg.Result == "" && cfg.Writer != nil {
+		return nopCloser{cfg.Writer}, nil
+	}
+	return os.Create(cfg.Result)
+}
+
+// nopCloser keeps the Parquet writer from closing cfg.Writer, e.g. stdout
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
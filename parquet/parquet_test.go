@@ -0,0 +1,135 @@
+package parquet
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalAndUnmarshal(t *testing.T) {
+	table := &common.Table{
+		Headers: []string{"id", "name", "score", "born", "active", "seen"},
+		Rows: [][]string{
+			{"1", "alice", "9.5", "2020-01-02", "true", "2023-01-02T03:04:05Z"},
+			{"2", "", "", "2021-03-04", "false", "2023-01-02T03:04:05+02:00"},
+			{"3", "NULL", "7", "", "true", ""},
+		},
+	}
+	table.SetNull(2, 1)
+
+	result := filepath.Join(t.TempDir(), "data.parquet")
+	require.NoError(t, Marshal(&common.Config{Result: result}, table))
+
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{File: result}, got))
+	assert.Equal(t, table.Headers, got.Headers)
+	assert.Equal(t, [][]string{
+		{"1", "alice", "9.5", "2020-01-02", "true", "2023-01-02T03:04:05Z"},
+		// Empty strings stay strings, empty cells of typed columns are NULL
+		{"2", "", "NULL", "2021-03-04", "false", "2023-01-02T01:04:05Z"},
+		{"3", "NULL", "7", "NULL", "true", "NULL"},
+	}, got.Rows)
+	assert.True(t, got.IsNull(2, 1))
+	assert.False(t, got.IsNull(1, 1))
+	assert.True(t, got.IsNull(1, 2))
+
+	types := []common.ColumnType{common.ColumnTypeInt, common.ColumnTypeString, common.ColumnTypeFloat,
+		common.ColumnTypeDate, common.ColumnTypeBool, common.ColumnTypeDate}
+	for i, column := range got.Columns {
+		assert.Equal(t, types[i], column.Type, column.Name)
+	}
+	assert.Equal(t, "int64", got.Columns[0].SourceType)
+	assert.Equal(t, "date32", got.Columns[3].SourceType)
+	assert.Equal(t, "timestamp[us, tz=UTC]", got.Columns[5].SourceType)
+}
+
+func TestMarshalSchemaFallback(t *testing.T) {
+	// The schema says int, but a value only fits a float or a string
+	table := &common.Table{
+		Headers: []string{"a", "b", "c"},
+		Rows:    [][]string{{"1", "1", "2023-01-02"}, {"1.5", "x", "2023-01-02 10:00:00"}},
+		Columns: []common.Column{{Name: "a", Type: common.ColumnTypeInt}, {Name: "b", Type: common.ColumnTypeInt}, {Name: "c", Type: common.ColumnTypeDate}},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, Marshal(&common.Config{Writer: &buf}, table))
+
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: &buf}, got))
+	assert.Equal(t, "float64", got.Columns[0].SourceType)
+	assert.Equal(t, "utf8", got.Columns[1].SourceType)
+	assert.Equal(t, "timestamp[us]", got.Columns[2].SourceType)
+	assert.Equal(t, [][]string{{"1", "1", "2023-01-02T00:00:00"}, {"1.5", "x", "2023-01-02T10:00:00"}}, got.Rows)
+}
+
+func TestMarshalOptions(t *testing.T) {
+	rows := make([][]string, 5)
+	for i := range rows {
+		rows[i] = []string{"row", "1"}
+	}
+	table := &common.Table{Headers: []string{"a", "b"}, Rows: rows}
+
+	result := filepath.Join(t.TempDir(), "data.parquet")
+	cfg := &common.Config{Result: result, Extension: map[string]string{"compression": "zstd", "row-group-size": "2"}}
+	require.NoError(t, Marshal(cfg, table))
+
+	reader, err := file.OpenParquetFile(result, false)
+	require.NoError(t, err)
+	defer reader.Close()
+	assert.Equal(t, 3, reader.NumRowGroups())
+	column, err := reader.MetaData().RowGroup(0).ColumnChunk(0)
+	require.NoError(t, err)
+	assert.Equal(t, compress.Codecs.Zstd, column.Compression())
+
+	cfg.Extension = map[string]string{"compression": "lzo"}
+	assert.ErrorContains(t, Marshal(cfg, table), `unsupported Parquet compression "lzo"`)
+}
+
+func TestMarshalEmptyTable(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Marshal(&common.Config{Writer: &buf}, &common.Table{Headers: []string{"a"}}))
+
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: &buf}, got))
+	assert.Equal(t, []string{"a"}, got.Headers)
+	assert.Empty(t, got.Rows)
+}
+
+func TestMarshalRaggedRow(t *testing.T) {
+	// The schema is inferred from every row, so a short row after the first one is an error, not a panic
+	table := &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1", "2"}, {"1"}}}
+	err := Marshal(&common.Config{Writer: &bytes.Buffer{}}, table)
+	assert.EqualError(t, err, "row length 1 does not match header length 2")
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	err := Unmarshal(&common.Config{Reader: bytes.NewReader([]byte("a,b\n1,2\n"))}, &common.Table{})
+	assert.ErrorContains(t, err, "invalid Parquet file")
+
+	err = Unmarshal(&common.Config{File: "does-not-exist.parquet"}, &common.Table{})
+	assert.True(t, os.IsNotExist(err))
+
+	table := &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1"}}}
+	assert.Error(t, Marshal(&common.Config{Writer: &bytes.Buffer{}}, table))
+}
+
+func TestFixture(t *testing.T) {
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{File: "../test/mysql.parquet"}, table))
+	assert.Equal(t, []string{"FIELD", "TYPE", "NULL", "KEY", "DEFAULT", "EXTRA"}, table.Headers)
+	assert.Equal(t, []string{"user_id", "smallint(5)", "NO", "PRI", "NULL", "auto_increment"}, table.Rows[0])
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, common.DetectCertain, detect("PAR1\x15\x04"))
+	assert.Equal(t, common.DetectNone, detect("a,b\n"))
+	assert.Error(t, validateRowGroupSize("0"))
+	assert.NoError(t, validateRowGroupSize("1000"))
+}
@@ -0,0 +1,42 @@
+package rst
+
+import (
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "rst",
+		Description: "reStructuredText grid or simple table",
+		Aliases:     []string{"restructuredtext"},
+		Extensions:  []string{".rst", ".rest"},
+		Detect:      detect,
+		DetectOrder: 14,
+		Params: []common.FormatParam{
+			{Name: "style", DefaultValue: "grid", AllowedValues: "grid, simple", Description: "Table style written, both are read", Use: common.ParamWrite},
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+		MarshalDocument:   MarshalDocument,
+	})
+}
+
+// detect scores content with the "+===+" header rule of a grid table, or the
+// "===  ===" column rules of a simple table
+func detect(sample string) int {
+	for _, line := range common.SampleLines(sample, 50) {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "+=") && strings.HasSuffix(line, "=+") && strings.Trim(line, "+=") == "":
+			return 95
+		case strings.HasPrefix(line, ".. table::"):
+			return 90
+		case len(rules(toColumns(line), "=")) > 1:
+			return 80
+		}
+	}
+	return common.DetectNone
+}
@@ -0,0 +1,796 @@
+package rst
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// tabSize is the distance of the tab stops tabs are expanded to
+const tabSize = 8
+
+// punctuation holds the characters of section title underlines
+const punctuation = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// columns is a line split into display columns. A wide character takes two
+// columns, the second one empty, and zero-width characters join the column before them.
+type columns []string
+
+func toColumns(line string) columns {
+	var cols columns
+	for _, r := range line {
+		switch width := runewidth.RuneWidth(r); {
+		case r == '\t':
+			for n := tabSize - len(cols)%tabSize; n > 0; n-- {
+				cols = append(cols, " ")
+			}
+		case width == 0 && len(cols) > 0:
+			cols[len(cols)-1] += string(r)
+		case width == 2:
+			cols = append(cols, string(r), "")
+		default:
+			cols = append(cols, string(r))
+		}
+	}
+	return cols
+}
+
+// at returns column i, a space past the end of the line
+func (cols columns) at(i int) string {
+	if i < len(cols) {
+		return cols[i]
+	}
+	return " "
+}
+
+// text returns the trimmed text of columns from, to, to the end of the line when to is negative
+func (cols columns) text(from, to int) string {
+	if to < 0 || to > len(cols) {
+		to = len(cols)
+	}
+	if from >= to {
+		return ""
+	}
+	return strings.TrimSpace(strings.Join(cols[from:to], ""))
+}
+
+// indent returns the number of leading spaces
+func (cols columns) indent() int {
+	for i, col := range cols {
+		if col != " " {
+			return i
+		}
+	}
+	return len(cols)
+}
+
+// rules returns the runs of ch of a line made of ch and spaces only, e.g. the
+// column rules "===  ===" of a simple table, or nil for any other line
+func rules(cols columns, ch string) [][2]int {
+	var runs [][2]int
+	start := -1
+	for i, col := range cols {
+		switch col {
+		case ch:
+			if start < 0 {
+				start = i
+			}
+		case " ":
+			if start >= 0 {
+				runs = append(runs, [2]int{start, i})
+				start = -1
+			}
+		default:
+			return nil
+		}
+	}
+	if start >= 0 {
+		runs = append(runs, [2]int{start, len(cols)})
+	}
+	return runs
+}
+
+// underline reports whether line repeats one punctuation character, like a section title underline
+func underline(line string) bool {
+	line = strings.TrimRight(line, " ")
+	return line != "" && strings.Count(line, line[:1]) == len(line) && strings.Contains(punctuation, line[:1])
+}
+
+// title returns the section title at line i and the number of lines it takes, 0 if there is none
+func title(lines []string, i int) (string, int) {
+	next := func(n int) string {
+		if i+n < len(lines) {
+			return lines[i+n]
+		}
+		return ""
+	}
+	text := strings.TrimSpace(next(1))
+	if underline(lines[i]) && text != "" && !underline(text) && strings.TrimRight(next(2), " ") == strings.TrimRight(lines[i], " ") {
+		return text, 3
+	}
+	text = strings.TrimSpace(lines[i])
+	if text != "" && lines[i][0] != ' ' && underline(next(1)) && len(strings.TrimRight(next(1), " ")) >= runewidth.StringWidth(text) {
+		return text, 2
+	}
+	return "", 0
+}
+
+// block returns the lines with indent columns removed
+func block(lines []string, indent int) []columns {
+	result := make([]columns, len(lines))
+	for i, line := range lines {
+		cols := toColumns(line)
+		result[i] = cols[min(indent, len(cols)):]
+	}
+	return result
+}
+
+// parse reads up to limit tables of a reStructuredText document, all of them when limit is 0.
+// A table is named after the table directive holding it, or the closest preceding section title.
+func parse(cfg *common.Config, limit int) ([]*common.Table, error) {
+	if cfg.Reader == nil {
+		return nil, fmt.Errorf("Unmarshal: Reader in Config cannot be nil")
+	}
+	content, err := io.ReadAll(cfg.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading input: %w", err)
+	}
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+
+	var tables []*common.Table
+	heading, caption := "", ""
+	for i := 0; i < len(lines) && (limit == 0 || len(tables) < limit); {
+		cols := toColumns(lines[i])
+		indent := cols.indent()
+		trimmed := strings.TrimSpace(lines[i])
+
+		var table *common.Table
+		var end int
+		if strings.HasPrefix(trimmed, ".. table::") {
+			caption = strings.TrimSpace(strings.TrimPrefix(trimmed, ".. table::"))
+			i++
+			continue
+		}
+		if text, n := title(lines, i); n > 0 && !strings.HasPrefix(trimmed, "+") {
+			heading = text
+			i += n
+			continue
+		}
+		switch {
+		case strings.HasPrefix(trimmed, "+-") || strings.HasPrefix(trimmed, "+="):
+			for end = i + 1; end < len(lines); end++ {
+				next := toColumns(lines[end])
+				if next.indent() != indent || (next.at(indent) != "+" && next.at(indent) != "|") {
+					break
+				}
+			}
+			table, err = parseGrid(cfg, block(lines[i:end], indent), i+1)
+		case len(rules(cols[indent:], "=")) > 0:
+			if end, err = simpleEnd(lines, i, indent); err == nil {
+				table, err = parseSimple(cfg, block(lines[i:end], indent), i+1)
+			}
+		default:
+			i++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		table.Name = heading
+		if caption != "" {
+			table.Name = caption
+		}
+		heading, caption = "", ""
+		tables = append(tables, table)
+		i = end
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("parsing failed: no grid or simple table found in input")
+	}
+	return tables, nil
+}
+
+// simpleEnd returns the end of the simple table starting at line start: the
+// line after the column rule that is followed by a blank line or the end of input
+func simpleEnd(lines []string, start, indent int) (int, error) {
+	for end := start + 1; end < len(lines); end++ {
+		cols := toColumns(lines[end])
+		if cols.indent() != indent || len(rules(cols[indent:], "=")) == 0 {
+			continue
+		}
+		if end+1 == len(lines) || strings.TrimSpace(lines[end+1]) == "" {
+			return end + 1, nil
+		}
+	}
+	return 0, &common.ParseError{LineNumber: start + 1, Message: "simple table has no closing column rule", Line: lines[start]}
+}
+
+// gridCell is a cell of a grid table given by the positions of its borders
+type gridCell struct {
+	top, left, bottom, right int
+}
+
+// gridParser finds the cells of a grid table by following their borders from the top left corner
+type gridParser struct {
+	lines         []columns
+	bottom, right int
+	done          []int // done[x] is the last line of the cells found at column x
+}
+
+// parseGrid reads a grid table, firstLine being its line number in the input
+func parseGrid(cfg *common.Config, lines []columns, firstLine int) (*common.Table, error) {
+	width := 0
+	for _, line := range lines {
+		width = max(width, len(line))
+	}
+	if width > common.MaxGridCells/len(lines) {
+		return nil, &common.ParseError{LineNumber: firstLine, Message: fmt.Sprintf("grid table exceeds the limit of %d characters", common.MaxGridCells),
+			Line: strings.Join(lines[0], "")}
+	}
+	p := &gridParser{lines: make([]columns, len(lines)), bottom: len(lines) - 1, right: width - 1, done: make([]int, width)}
+	for y, line := range lines {
+		p.lines[y] = append(append(columns(nil), line...), toColumns(strings.Repeat(" ", width-len(line)))...)
+		// The header rule is a row border
+		if y > 0 && line.at(0) == "+" && line.at(1) == "=" {
+			for x, col := range p.lines[y] {
+				if col == "=" {
+					p.lines[y][x] = "-"
+				}
+			}
+		}
+	}
+	for x := range p.done {
+		p.done[x] = -1
+	}
+
+	cells := p.scan()
+	if len(cells) == 0 || !p.complete() {
+		return nil, &common.ParseError{LineNumber: firstLine, Message: "malformed grid table, cell borders do not line up",
+			Line: strings.Join(lines[0], "")}
+	}
+
+	// Row and column borders of all cells
+	rowIndex, colIndex := make(map[int]int), make(map[int]int)
+	for _, cell := range cells {
+		rowIndex[cell.top], rowIndex[cell.bottom] = 0, 0
+		colIndex[cell.left], colIndex[cell.right] = 0, 0
+	}
+	for _, index := range []map[int]int{rowIndex, colIndex} {
+		positions := make([]int, 0, len(index))
+		for pos := range index {
+			positions = append(positions, pos)
+		}
+		sort.Ints(positions)
+		for i, pos := range positions {
+			index[pos] = i
+		}
+	}
+
+	grid := make([][]string, len(rowIndex)-1)
+	for r := range grid {
+		grid[r] = make([]string, len(colIndex)-1)
+	}
+	var spans []common.Span
+	for _, cell := range cells {
+		r, c := rowIndex[cell.top], colIndex[cell.left]
+		grid[r][c] = p.text(cell)
+		if err := cfg.CheckRow(r-1, grid[r][c:c+1]); err != nil {
+			return nil, err
+		}
+		rowSpan, colSpan := rowIndex[cell.bottom]-r, colIndex[cell.right]-c
+		if rowSpan > 1 || colSpan > 1 {
+			spans = append(spans, common.Span{Row: r, Col: c, RowSpan: rowSpan, ColSpan: colSpan})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].Row < spans[j].Row || spans[i].Row == spans[j].Row && spans[i].Col < spans[j].Col
+	})
+	table := &common.Table{}
+	table.SetGrid(grid, spans)
+	return table, nil
+}
+
+// scan returns the cells of the table, starting from the top left corner
+// and going on from the top right and bottom left corners of every cell found
+func (p *gridParser) scan() []gridCell {
+	var cells []gridCell
+	corners := [][2]int{{0, 0}}
+	for len(corners) > 0 {
+		top, left := corners[0][0], corners[0][1]
+		corners = corners[1:]
+		if top == p.bottom || left == p.right || top <= p.done[left] {
+			continue
+		}
+		cell, ok := p.scanCell(top, left)
+		if !ok {
+			continue
+		}
+		for x := cell.left; x < cell.right; x++ {
+			p.done[x] = cell.bottom - 1
+		}
+		cells = append(cells, cell)
+		corners = append(corners, [2]int{top, cell.right}, [2]int{cell.bottom, left})
+		sort.Slice(corners, func(i, j int) bool {
+			return corners[i][0] < corners[j][0] || corners[i][0] == corners[j][0] && corners[i][1] < corners[j][1]
+		})
+	}
+	return cells
+}
+
+// complete reports whether the cells cover the whole table
+func (p *gridParser) complete() bool {
+	for x := 0; x < p.right; x++ {
+		if p.done[x] != p.bottom-1 {
+			return false
+		}
+	}
+	return true
+}
+
+// scanCell follows the top border of the cell at corner top, left to the right,
+// trying every corner on the way as the top right corner of the cell
+func (p *gridParser) scanCell(top, left int) (gridCell, bool) {
+	if p.lines[top][left] != "+" {
+		return gridCell{}, false
+	}
+	for right := left + 1; right <= p.right; right++ {
+		switch p.lines[top][right] {
+		case "+":
+			if cell, ok := p.scanDown(top, left, right); ok {
+				return cell, true
+			}
+		case "-":
+		default:
+			return gridCell{}, false
+		}
+	}
+	return gridCell{}, false
+}
+
+// scanDown follows the right border down, trying every corner on the way as the bottom right corner
+func (p *gridParser) scanDown(top, left, right int) (gridCell, bool) {
+	for bottom := top + 1; bottom <= p.bottom; bottom++ {
+		switch p.lines[bottom][right] {
+		case "+":
+			if p.closed(top, left, bottom, right) {
+				return gridCell{top, left, bottom, right}, true
+			}
+		case "|":
+		default:
+			return gridCell{}, false
+		}
+	}
+	return gridCell{}, false
+}
+
+// closed reports whether the bottom and left borders of the cell are complete
+func (p *gridParser) closed(top, left, bottom, right int) bool {
+	for x := right - 1; x > left; x-- {
+		if col := p.lines[bottom][x]; col != "+" && col != "-" {
+			return false
+		}
+	}
+	if p.lines[bottom][left] != "+" {
+		return false
+	}
+	for y := bottom - 1; y > top; y-- {
+		if col := p.lines[y][left]; col != "+" && col != "|" {
+			return false
+		}
+	}
+	return true
+}
+
+// text returns the lines of the cell content, each trimmed
+func (p *gridParser) text(cell gridCell) string {
+	var lines []string
+	for y := cell.top + 1; y < cell.bottom; y++ {
+		lines = append(lines, p.lines[y].text(cell.left+1, cell.right))
+	}
+	return joinLines(lines)
+}
+
+// joinLines joins the lines of a cell, leaving out blank lines at the start and end
+func joinLines(lines []string) string {
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// simpleRow is a row of a simple table with the lines it takes. Each group
+// is a range of columns taking one cell, set by a "---" underline.
+type simpleRow struct {
+	lines  []columns
+	groups [][2]int
+}
+
+// parseSimple reads a simple table, firstLine being its line number in the input
+func parseSimple(cfg *common.Config, lines []columns, firstLine int) (*common.Table, error) {
+	cols := rules(lines[0], "=")
+	var borders []int
+	for y, line := range lines {
+		if len(rules(line, "=")) > 0 {
+			borders = append(borders, y)
+		}
+	}
+	var sections [][]columns
+	switch len(borders) {
+	case 2:
+		sections = [][]columns{lines[1 : len(lines)-1]}
+	case 3:
+		sections = [][]columns{lines[1:borders[1]], lines[borders[1]+1 : len(lines)-1]}
+	default:
+		return nil, &common.ParseError{LineNumber: firstLine + borders[2], Message: "simple table has more than one header rule",
+			Line: strings.Join(lines[borders[2]], "")}
+	}
+
+	var rows []*simpleRow
+	for _, section := range sections {
+		rows = append(rows, simpleRows(section, cols)...)
+	}
+	if len(rows) == 0 {
+		return nil, &common.ParseError{LineNumber: firstLine, Message: "simple table has no rows", Line: strings.Join(lines[0], "")}
+	}
+
+	// start returns where column c starts, -1 past the last column
+	start := func(c int) int {
+		if c < len(cols) {
+			return cols[c][0]
+		}
+		return -1
+	}
+	if len(cols) > common.MaxGridCells/len(rows) {
+		return nil, &common.ParseError{LineNumber: firstLine, Message: fmt.Sprintf("simple table exceeds the limit of %d cells", common.MaxGridCells),
+			Line: strings.Join(lines[0], "")}
+	}
+	grid := make([][]string, len(rows))
+	var spans []common.Span
+	for r, row := range rows {
+		grid[r] = make([]string, len(cols))
+		for _, group := range row.groups {
+			var text []string
+			for _, line := range row.lines {
+				text = append(text, line.text(start(group[0]), start(group[1])))
+			}
+			// A backslash starts a row with an empty first cell
+			if group[0] == 0 && len(text) > 0 && text[0] == `\` {
+				text[0] = ""
+			}
+			grid[r][group[0]] = joinLines(text)
+			if group[1]-group[0] > 1 {
+				spans = append(spans, common.Span{Row: r, Col: group[0], RowSpan: 1, ColSpan: group[1] - group[0]})
+			}
+		}
+		// The first row is the header
+		if err := cfg.CheckRow(r-1, grid[r]); err != nil {
+			return nil, err
+		}
+	}
+	table := &common.Table{}
+	table.SetGrid(grid, spans)
+	return table, nil
+}
+
+// simpleRows splits the lines of a section into rows: a row starts with text
+// in the first column, other lines continue the row above
+func simpleRows(lines []columns, cols [][2]int) []*simpleRow {
+	firstEnd := -1
+	if len(cols) > 1 {
+		firstEnd = cols[1][0]
+	}
+	var rows []*simpleRow
+	for _, line := range lines {
+		if dashes := rules(line, "-"); len(dashes) > 0 && len(rows) > 0 {
+			rows[len(rows)-1].groups = groupColumns(dashes, cols)
+			continue
+		}
+		if line.text(cols[0][0], firstEnd) != "" || (len(rows) == 0 && line.text(0, -1) != "") {
+			row := &simpleRow{}
+			for c := range cols {
+				row.groups = append(row.groups, [2]int{c, c + 1})
+			}
+			rows = append(rows, row)
+		}
+		if len(rows) > 0 {
+			rows[len(rows)-1].lines = append(rows[len(rows)-1].lines, line)
+		}
+	}
+	return rows
+}
+
+// groupColumns returns the column ranges under each run of an underline,
+// columns no run starts over keep a cell of their own
+func groupColumns(dashes [][2]int, cols [][2]int) [][2]int {
+	var groups [][2]int
+	for c := 0; c < len(cols); {
+		end := c + 1
+		for _, run := range dashes {
+			if cols[c][0] >= run[0] && cols[c][0] < run[1] {
+				for end < len(cols) && cols[end][0] < run[1] {
+					end++
+				}
+				break
+			}
+		}
+		groups = append(groups, [2]int{c, end})
+		c = end
+	}
+	return groups
+}
+
+// Unmarshal reads the first grid or simple table of the input
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Unmarshal: target table pointer cannot be nil")
+	}
+	tables, err := parse(cfg, 1)
+	if err != nil {
+		return err
+	}
+	table.Name, table.Headers, table.Rows, table.Spans = tables[0].Name, tables[0].Headers, tables[0].Rows, tables[0].Spans
+	return nil
+}
+
+// UnmarshalDocument reads every grid and simple table of the input
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	tables, err := parse(cfg, 0)
+	if err != nil {
+		return err
+	}
+	doc.Tables = append(doc.Tables, tables...)
+	return nil
+}
+
+// Marshal writes a grid table, or a simple table with --style=simple
+func Marshal(cfg *common.Config, table *common.Table) error {
+	lines, err := render(cfg, table)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(cfg.Writer, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// MarshalDocument writes every table in a table directive titled with its name
+func MarshalDocument(cfg *common.Config, doc *common.Document) error {
+	names := doc.UniqueTableNames(nil)
+	for i, table := range doc.Tables {
+		lines, err := render(cfg, table)
+		if err != nil {
+			return fmt.Errorf("table %q: %w", names[i], err)
+		}
+		var b strings.Builder
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, ".. table:: %s\n\n", names[i])
+		for _, line := range lines {
+			b.WriteString("   " + line + "\n")
+		}
+		if _, err := io.WriteString(cfg.Writer, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// render returns the lines of the table in the configured style
+func render(cfg *common.Config, table *common.Table) ([]string, error) {
+	if table == nil {
+		return nil, fmt.Errorf("Marshal: input table pointer cannot be nil")
+	}
+	if len(table.Headers) == 0 {
+		return nil, fmt.Errorf("Marshal: table must have at least one header")
+	}
+	for j, row := range table.Rows {
+		if len(row) != len(table.Headers) {
+			return nil, fmt.Errorf("Marshal: row %d has %d columns, but table header has %d columns", j, len(row), len(table.Headers))
+		}
+	}
+	if cfg.GetExtensionString("style", "grid") == "simple" {
+		return simpleTable(table)
+	}
+	return gridTable(table), nil
+}
+
+// cellLines splits a cell value into lines with tabs expanded and trailing spaces removed
+func cellLines(value string) []string {
+	lines := strings.Split(strings.ReplaceAll(value, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(strings.Join(toColumns(line), ""), " ")
+	}
+	return lines
+}
+
+// linesWidth returns the display width of the widest line
+func linesWidth(lines []string) int {
+	width := 0
+	for _, line := range lines {
+		width = max(width, runewidth.StringWidth(line))
+	}
+	return width
+}
+
+// box is a cell of a grid table covering rows x cols cells of the table
+type box struct {
+	row, col, rows, cols int
+	lines                []string
+}
+
+// gridTable draws the table with "+---+" borders and a "+===+" rule below
+// the header. Merged cells are drawn without the borders they cover, but not
+// across the header rule.
+func gridTable(table *common.Table) []string {
+	rows := append([][]string{table.Headers}, table.Rows...)
+	nCols := len(table.Headers)
+	layout := table.SpanLayout()
+
+	owner := make([][]*box, len(rows))
+	for r := range owner {
+		owner[r] = make([]*box, nCols)
+	}
+	free := func(r, c, cols int) bool {
+		for dc := 0; dc < cols; dc++ {
+			if owner[r][c+dc] != nil {
+				return false
+			}
+		}
+		return true
+	}
+	var boxes []*box
+	for r := range rows {
+		for c := 0; c < nCols; c++ {
+			if owner[r][c] != nil {
+				continue
+			}
+			b := &box{row: r, col: c, rows: 1, cols: 1, lines: cellLines(rows[r][c])}
+			if span, ok := layout.At(r-1, c); ok {
+				b.cols = span.ColSpan
+				if r > 0 {
+					b.rows = span.RowSpan
+				}
+			}
+			for b.cols > 1 && !free(r, c, b.cols) {
+				b.cols--
+			}
+			for b.rows > 1 && !free(r+b.rows-1, c, b.cols) {
+				b.rows--
+			}
+			for dr := 0; dr < b.rows; dr++ {
+				for dc := 0; dc < b.cols; dc++ {
+					owner[r+dr][c+dc] = b
+				}
+			}
+			boxes = append(boxes, b)
+		}
+	}
+
+	// Single cells size their column and row, merged cells widen the last ones if needed
+	widths := make([]int, nCols)
+	heights := make([]int, len(rows))
+	for _, b := range boxes {
+		if b.cols == 1 {
+			widths[b.col] = max(widths[b.col], linesWidth(b.lines))
+		}
+		if b.rows == 1 {
+			heights[b.row] = max(heights[b.row], len(b.lines))
+		}
+	}
+	for _, b := range boxes {
+		width, height := 3*(b.cols-1), b.rows-1
+		for c := b.col; c < b.col+b.cols; c++ {
+			width += widths[c]
+		}
+		for r := b.row; r < b.row+b.rows; r++ {
+			height += heights[r]
+		}
+		widths[b.col+b.cols-1] += max(linesWidth(b.lines)-width, 0)
+		heights[b.row+b.rows-1] += max(len(b.lines)-height, 0)
+	}
+
+	xs := make([]int, nCols+1)
+	for c, width := range widths {
+		xs[c+1] = xs[c] + width + 3
+	}
+	ys := make([]int, len(rows)+1)
+	for r, height := range heights {
+		ys[r+1] = ys[r] + height + 1
+	}
+	canvas := make([]columns, ys[len(rows)]+1)
+	for y := range canvas {
+		canvas[y] = toColumns(strings.Repeat(" ", xs[nCols]+1))
+	}
+	rule := func(y int) string {
+		if y == ys[1] && len(rows) > 1 {
+			return "="
+		}
+		return "-"
+	}
+	for _, b := range boxes {
+		top, bottom, left, right := ys[b.row], ys[b.row+b.rows], xs[b.col], xs[b.col+b.cols]
+		for x := left + 1; x < right; x++ {
+			canvas[top][x], canvas[bottom][x] = rule(top), rule(bottom)
+		}
+		for y := top + 1; y < bottom; y++ {
+			canvas[y][left], canvas[y][right] = "|", "|"
+		}
+		for i, line := range b.lines {
+			copy(canvas[top+1+i][left+2:], toColumns(line))
+		}
+	}
+	for _, b := range boxes {
+		for _, y := range []int{ys[b.row], ys[b.row+b.rows]} {
+			canvas[y][xs[b.col]], canvas[y][xs[b.col+b.cols]] = "+", "+"
+		}
+	}
+
+	lines := make([]string, len(canvas))
+	for y, cols := range canvas {
+		lines[y] = strings.Join(cols, "")
+	}
+	return lines
+}
+
+// simpleTable writes the table between "===  ===" column rules. Merged cells
+// are written as single cells, and an empty first cell as a backslash.
+func simpleTable(table *common.Table) ([]string, error) {
+	rows := append([][]string{table.Headers}, table.Rows...)
+	cells := make([][][]string, len(rows))
+	widths := make([]int, len(table.Headers))
+	for r, row := range rows {
+		cells[r] = make([][]string, len(row))
+		for c, value := range row {
+			lines := cellLines(value)
+			if c == 0 {
+				if len(lines) > 1 {
+					return nil, fmt.Errorf("Marshal: the first column of a simple table cannot hold several lines, use --style=grid")
+				}
+				if lines[0] == "" {
+					lines[0] = `\`
+				}
+			}
+			cells[r][c] = lines
+			widths[c] = max(widths[c], linesWidth(lines), 1)
+		}
+	}
+
+	rule := make([]string, len(widths))
+	for c, width := range widths {
+		rule[c] = strings.Repeat("=", width)
+	}
+	border := strings.Join(rule, "  ")
+	lines := []string{border}
+	for r, row := range cells {
+		height := 0
+		for _, cell := range row {
+			height = max(height, len(cell))
+		}
+		for i := 0; i < height; i++ {
+			parts := make([]string, len(row))
+			for c, cell := range row {
+				if i < len(cell) {
+					parts[c] = runewidth.FillRight(cell[i], widths[c])
+				} else {
+					parts[c] = strings.Repeat(" ", widths[c])
+				}
+			}
+			lines = append(lines, strings.TrimRight(strings.Join(parts, "  "), " "))
+		}
+		if r == 0 {
+			lines = append(lines, border)
+		}
+	}
+	if len(rows) > 1 {
+		lines = append(lines, border)
+	}
+	return lines, nil
+}
@@ -0,0 +1,253 @@
+package rst
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func unmarshal(t *testing.T, input string) *common.Table {
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table))
+	return table
+}
+
+func marshal(t *testing.T, table *common.Table, style string) string {
+	var buf bytes.Buffer
+	cfg := &common.Config{Writer: &buf, Extension: map[string]string{"style": style}}
+	require.NoError(t, Marshal(cfg, table))
+	return buf.String()
+}
+
+func TestUnmarshalGrid(t *testing.T) {
+	input := `
++------------+------------+-----------+
+| Header 1   | Header 2   | Header 3  |
++============+============+===========+
+| body row 1 | column 2   | column 3  |
+| continued  |            |           |
++------------+------------+-----------+
+| body row 2 | Cells may span columns.|
++------------+------------+-----------+
+| body row 3 | Cells may  | - Cells   |
++------------+ span rows. | - contain |
+| body row 4 |            | - blocks. |
++------------+------------+-----------+
+`
+	table := unmarshal(t, input)
+	assert.Equal(t, []string{"Header 1", "Header 2", "Header 3"}, table.Headers)
+	assert.Equal(t, [][]string{
+		{"body row 1\ncontinued", "column 2", "column 3"},
+		{"body row 2", "Cells may span columns.", ""},
+		{"body row 3", "Cells may\nspan rows.", "- Cells\n- contain\n- blocks."},
+		{"body row 4", "", ""},
+	}, table.Rows)
+	assert.Equal(t, []common.Span{
+		{Row: 1, Col: 1, RowSpan: 1, ColSpan: 2},
+		{Row: 2, Col: 1, RowSpan: 2, ColSpan: 1},
+		{Row: 2, Col: 2, RowSpan: 2, ColSpan: 1},
+	}, table.Spans)
+}
+
+func TestUnmarshalSimple(t *testing.T) {
+	input := `
+=====  =====  ======
+   Inputs     Output
+------------  ------
+  A      B    A or B
+=====  =====  ======
+False  False  False
+True   False  True
+       long
+       text
+\      True   True
+=====  =====  ======
+`
+	table := unmarshal(t, input)
+	assert.Equal(t, []string{"Inputs", "", "Output"}, table.Headers)
+	assert.Equal(t, [][]string{
+		{"A", "B", "A or B"},
+		{"False", "False", "False"},
+		{"True", "False\nlong\ntext", "True"},
+		{"", "True", "True"},
+	}, table.Rows)
+	assert.Equal(t, []common.Span{{Row: -1, Col: 0, RowSpan: 1, ColSpan: 2}}, table.Spans)
+
+	// Without a header rule the first row is the header
+	table = unmarshal(t, "=  =\na  b\n1  2\n=  =\n")
+	assert.Equal(t, []string{"a", "b"}, table.Headers)
+	assert.Equal(t, [][]string{{"1", "2"}}, table.Rows)
+}
+
+func TestUnmarshalCJK(t *testing.T) {
+	input := "+------+-----+\n| 名前 | 年  |\n+======+=====+\n| 太郎 | 20  |\n+------+-----+\n"
+	table := unmarshal(t, input)
+	assert.Equal(t, []string{"名前", "年"}, table.Headers)
+	assert.Equal(t, [][]string{{"太郎", "20"}}, table.Rows)
+}
+
+func TestMarshalGrid(t *testing.T) {
+	table := &common.Table{
+		Headers: []string{"name", "note"},
+		Rows:    [][]string{{"中文", "line 1\nline 2"}, {"merged", ""}, {"a", "b"}},
+		Spans:   []common.Span{{Row: 1, Col: 0, RowSpan: 1, ColSpan: 2}},
+	}
+	expected := `+------+--------+
+| name | note   |
++======+========+
+| 中文 | line 1 |
+|      | line 2 |
++------+--------+
+| merged        |
++------+--------+
+| a    | b      |
++------+--------+
+`
+	assert.Equal(t, expected, marshal(t, table, "grid"))
+
+	got := unmarshal(t, expected)
+	assert.Equal(t, table.Headers, got.Headers)
+	assert.Equal(t, table.Rows, got.Rows)
+	assert.Equal(t, table.Spans, got.Spans)
+
+	// Row spans and a merged cell wider than its columns
+	table = &common.Table{
+		Headers: []string{"a", "b"},
+		Rows:    [][]string{{"x", "a long merged value"}, {"", "y"}, {"z", ""}},
+		Spans:   []common.Span{{Row: 0, Col: 0, RowSpan: 2, ColSpan: 1}, {Row: 2, Col: 0, RowSpan: 1, ColSpan: 2}},
+	}
+	got = unmarshal(t, marshal(t, table, "grid"))
+	assert.Equal(t, table.Rows, got.Rows)
+	assert.Equal(t, table.Spans, got.Spans)
+
+	// A header only table has no header rule
+	assert.Equal(t, "+---+\n| a |\n+---+\n", marshal(t, &common.Table{Headers: []string{"a"}}, "grid"))
+}
+
+func TestMarshalSimple(t *testing.T) {
+	table := &common.Table{
+		Headers: []string{"name", "年齢", "note"},
+		Rows:    [][]string{{"alice", "20", "two\nlines"}, {"", "30", ""}},
+	}
+	expected := `=====  ====  =====
+name   年齢  note
+=====  ====  =====
+alice  20    two
+             lines
+\      30
+=====  ====  =====
+`
+	assert.Equal(t, expected, marshal(t, table, "simple"))
+	got := unmarshal(t, expected)
+	assert.Equal(t, table.Headers, got.Headers)
+	assert.Equal(t, table.Rows, got.Rows)
+
+	var buf bytes.Buffer
+	table = &common.Table{Headers: []string{"a"}, Rows: [][]string{{"1\n2"}}}
+	err := Marshal(&common.Config{Writer: &buf, Extension: map[string]string{"style": "simple"}}, table)
+	assert.ErrorContains(t, err, "use --style=grid")
+}
+
+func TestDocument(t *testing.T) {
+	input := `
+=====
+Users
+=====
+
+Intro text.
+
+=====  =====
+id     name
+=====  =====
+1      alice
+=====  =====
+
+Orders
+------
+
+.. table:: Order lines
+
+   +----+
+   | id |
+   +====+
+   | 7  |
+   +----+
+`
+	doc := &common.Document{}
+	require.NoError(t, UnmarshalDocument(&common.Config{Reader: strings.NewReader(input)}, doc))
+	require.Len(t, doc.Tables, 2)
+	assert.Equal(t, "Users", doc.Tables[0].Name)
+	assert.Equal(t, [][]string{{"1", "alice"}}, doc.Tables[0].Rows)
+	assert.Equal(t, "Order lines", doc.Tables[1].Name)
+	assert.Equal(t, [][]string{{"7"}}, doc.Tables[1].Rows)
+
+	var buf bytes.Buffer
+	require.NoError(t, MarshalDocument(&common.Config{Writer: &buf}, doc))
+	assert.Equal(t, `.. table:: Users
+
+   +----+-------+
+   | id | name  |
+   +====+=======+
+   | 1  | alice |
+   +----+-------+
+
+.. table:: Order lines
+
+   +----+
+   | id |
+   +====+
+   | 7  |
+   +----+
+`, buf.String())
+
+	got := &common.Document{}
+	require.NoError(t, UnmarshalDocument(&common.Config{Reader: &buf}, got))
+	require.Len(t, got.Tables, 2)
+	assert.Equal(t, doc.Tables[0].Name, got.Tables[0].Name)
+	assert.Equal(t, doc.Tables[1].Rows, got.Tables[1].Rows)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := map[string]string{
+		"no table":        "just a paragraph\n",
+		"broken border":   "+---+---+\n| a | b |\n+---+--\n",
+		"no closing rule": "===  ===\na    b\n",
+		"two header rule": "=  =\na  b\n=  =\n1  2\n=  =\n3  4\n=  =\n",
+	}
+	for name, input := range tests {
+		err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, &common.Table{})
+		assert.Error(t, err, name)
+	}
+
+	var parseErr *common.ParseError
+	err := Unmarshal(&common.Config{Reader: strings.NewReader("text\n\n+---+---+\n| a | b |\n+---+--\n")}, &common.Table{})
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 3, parseErr.LineNumber)
+
+	assert.Error(t, Unmarshal(&common.Config{Reader: strings.NewReader("")}, nil))
+	assert.Error(t, Marshal(&common.Config{Writer: &bytes.Buffer{}}, &common.Table{}))
+}
+
+func TestFixture(t *testing.T) {
+	file, err := os.Open("../test/mysql.rst")
+	require.NoError(t, err)
+	defer file.Close()
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: file}, table))
+	assert.Equal(t, []string{"FIELD", "TYPE", "NULL", "KEY", "DEFAULT", "EXTRA"}, table.Headers)
+	assert.Equal(t, []string{"user_id", "smallint(5)", "NO", "PRI", "NULL", "auto_increment"}, table.Rows[0])
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, 95, detect("+---+\n| a |\n+===+\n| 1 |\n+---+\n"))
+	assert.Equal(t, 80, detect("===  ===\na    b\n===  ===\n"))
+	assert.Equal(t, 90, detect(".. table:: Users\n"))
+	assert.Equal(t, common.DetectNone, detect("+---+\n| a |\n+---+\n"))
+	assert.Equal(t, common.DetectNone, detect("Title\n=====\n"))
+}
@@ -0,0 +1,38 @@
+package sql
+
+import (
+	"regexp"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "sql",
+		Description: "SQL INSERT statements",
+		Extensions:  []string{".sql"},
+		Detect:      detect,
+		DetectOrder: 5,
+		Params: []common.FormatParam{
+			{Name: "one-insert", DefaultValue: "false", AllowedValues: "true, false", Description: "Insert multiple rows at once"},
+			{Name: "replace", DefaultValue: "false", AllowedValues: "true, false", Description: "Use REPLACE instead of INSERT"},
+			{Name: "dialect", DefaultValue: "mysql", AllowedValues: "none, mysql, oracle, mssql, postgresql, postgres, sqlite", Description: "identity escape SQL Dialect, none for no escape"},
+			{Name: "table", DefaultValue: "", AllowedValues: "", Description: "Table Name", Use: common.ParamWrite},
+		},
+		Unmarshal:    Unmarshal,
+		Marshal:      Marshal,
+		NewRowReader: NewRowReader,
+		NewRowWriter: NewRowWriter,
+	})
+}
+
+// insertPattern matches an INSERT or REPLACE statement at the start of a line
+var insertPattern = regexp.MustCompile(`(?im)^\s*(insert|replace)(\s+ignore)?\s+into\s`)
+
+// detect scores content holding INSERT or REPLACE statements
+func detect(sample string) int {
+	if insertPattern.MatchString(sample) {
+		return 90
+	}
+	return common.DetectNone
+}
@@ -1,6 +1,8 @@
 package sql
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -40,7 +42,7 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 	for _, stmt := range stmts {
 		switch stmt := stmt.(type) {
 		case *sqlparser.Insert:
-			if err := handleInsert(stmt, table); err != nil {
+			if err := handleInsert(cfg, stmt, table); err != nil {
 				return err
 			}
 		default:
@@ -53,7 +55,7 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 
 // handleInsert converts INSERT statement to table rows
 // This is a helper function and should only be called by Unmarshal
-func handleInsert(insert *sqlparser.Insert, table *common.Table) error {
+func handleInsert(cfg *common.Config, insert *sqlparser.Insert, table *common.Table) error {
 	// Check for column order mismatch
 	if len(table.Headers) > 0 {
 		// Verify current INSERT columns match existing headers
@@ -71,6 +73,7 @@ func handleInsert(insert *sqlparser.Insert, table *common.Table) error {
 		for _, col := range insert.Columns {
 			table.Headers = append(table.Headers, col.String())
 		}
+		table.Columns = common.NewColumns(table.Headers)
 	}
 
 	rows, ok := insert.Rows.(sqlparser.Values)
@@ -78,10 +81,14 @@ func handleInsert(insert *sqlparser.Insert, table *common.Table) error {
 		// Unsupported row formats (e.g. INSERT SELECT statements)
 		return fmt.Errorf("unsupported row format")
 	}
+	table.TrackNulls()
 
 	for _, row := range rows {
 		var values []string
-		for _, val := range row {
+		for i, val := range row {
+			if i < len(table.Columns) {
+				observeType(val, &table.Columns[i])
+			}
 			switch v := val.(type) {
 			case *sqlparser.Literal:
 				litStr := string(v.Val)
@@ -96,7 +103,8 @@ func handleInsert(insert *sqlparser.Insert, table *common.Table) error {
 					values = append(values, litStr)
 				}
 			case *sqlparser.NullVal:
-				values = append(values, "NULL")
+				table.SetNull(len(table.Rows), i)
+				values = append(values, common.DefaultNullString)
 			default:
 				values = append(values, sqlparser.String(val)) // fallback: stringify everything else
 			}
@@ -105,106 +113,378 @@ func handleInsert(insert *sqlparser.Insert, table *common.Table) error {
 		if len(values) != len(table.Headers) {
 			return fmt.Errorf("column count mismatch: expected %d values, got %d", len(table.Headers), len(values))
 		}
+		if err := cfg.CheckRow(len(table.Rows), values); err != nil {
+			return err
+		}
 		table.Rows = append(table.Rows, values)
 	}
 	return nil
 }
 
-func Marshal(cfg *common.Config, table *common.Table) error {
-	if table == nil {
-		return fmt.Errorf("Marshal: input table pointer cannot be nil")
+// observeType records the type of a VALUES expression in the column schema
+func observeType(val sqlparser.Expr, column *common.Column) {
+	switch v := val.(type) {
+	case *sqlparser.Literal:
+		switch v.Type {
+		case sqlparser.IntVal:
+			column.Observe(common.ColumnTypeInt, "INT")
+		case sqlparser.DecimalVal:
+			column.Observe(common.ColumnTypeFloat, "DECIMAL")
+		case sqlparser.FloatVal:
+			column.Observe(common.ColumnTypeFloat, "FLOAT")
+		case sqlparser.DateVal:
+			column.Observe(common.ColumnTypeDate, "DATE")
+		case sqlparser.TimestampVal:
+			column.Observe(common.ColumnTypeDate, "TIMESTAMP")
+		case sqlparser.TimeVal:
+			column.Observe(common.ColumnTypeString, "TIME")
+		case sqlparser.HexNum, sqlparser.HexVal, sqlparser.BitNum:
+			column.Observe(common.ColumnTypeString, "BINARY")
+		default:
+			column.Observe(common.ColumnTypeString, "VARCHAR")
+		}
+	case *sqlparser.UnaryExpr:
+		// Negative numbers are parsed as a unary minus applied to a literal
+		if lit, ok := v.Expr.(*sqlparser.Literal); ok && v.Operator == sqlparser.UMinusOp {
+			observeType(lit, column)
+			return
+		}
+		column.Observe(common.ColumnTypeString, "EXPR")
+	case sqlparser.BoolVal:
+		column.Observe(common.ColumnTypeBool, "BOOLEAN")
+	case *sqlparser.NullVal:
+		column.Nullable = true
+	default:
+		column.Observe(common.ColumnTypeString, "EXPR")
 	}
+}
 
-	columnCount := len(table.Headers)
-	if columnCount == 0 {
-		return fmt.Errorf("Marshal: table must have at least one header")
+// statementScanner splits SQL input into statements on semicolons outside quotes and comments
+type statementScanner struct {
+	reader *bufio.Reader
+}
+
+// peek reports whether the next rune is want, without consuming it
+func (s *statementScanner) peek(want rune) bool {
+	r, _, err := s.reader.ReadRune()
+	if err != nil {
+		return false
 	}
+	_ = s.reader.UnreadRune()
+	return r == want
+}
 
-	// Validate all rows have consistent column count upfront
-	for _, row := range table.Rows {
-		if len(row) != columnCount {
-			return fmt.Errorf("Marshal: row has %d columns, but table has %d", len(row), columnCount)
+// next returns the next statement without its trailing semicolon, or io.EOF
+func (s *statementScanner) next() (string, error) {
+	var sb strings.Builder
+	var quote rune
+	for {
+		r, _, err := s.reader.ReadRune()
+		if err == io.EOF {
+			stmt := strings.TrimSpace(sb.String())
+			if stmt == "" {
+				return "", io.EOF
+			}
+			return stmt, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read SQL data: %w", err)
+		}
+		sb.WriteRune(r)
+
+		switch {
+		case quote != 0:
+			if r == '\\' && quote != '`' {
+				// Keep the escaped rune, it can't close the literal
+				if escaped, _, err := s.reader.ReadRune(); err == nil {
+					sb.WriteRune(escaped)
+				}
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '#' || (r == '-' && s.peek('-')):
+			// Line comment, runs to the end of line
+			line, err := s.reader.ReadString('\n')
+			sb.WriteString(line)
+			if err != nil && err != io.EOF {
+				return "", fmt.Errorf("failed to read SQL data: %w", err)
+			}
+		case r == '/' && s.peek('*'):
+			// Block comment, runs to the closing */
+			var prev rune
+			for {
+				c, _, err := s.reader.ReadRune()
+				if err != nil {
+					break
+				}
+				sb.WriteRune(c)
+				if prev == '*' && c == '/' {
+					break
+				}
+				prev = c
+			}
+		case r == ';':
+			stmt := strings.TrimSpace(strings.TrimSuffix(sb.String(), ";"))
+			if stmt == "" {
+				sb.Reset() // empty statement
+				continue
+			}
+			return stmt, nil
 		}
 	}
+}
 
-	writer := cfg.Writer
+// rowReader streams the rows of INSERT statements, one statement at a time
+type rowReader struct {
+	cfg        *common.Config
+	parser     *sqlparser.Parser
+	statements *statementScanner
+	table      common.Table // headers and column schema of the statements read so far
+	pending    [][]string
+	nulls      [][]bool // NULL flags of the pending rows
+	current    []bool   // NULL flags of the row last returned by Next
+}
 
-	// table name
-	tableName := cfg.GetExtensionString("table", "{table_name}")
+// NewRowReader returns a streaming SQL reader. Statements are read up to the first row.
+func NewRowReader(cfg *common.Config) (common.RowReader, error) {
+	if cfg.Reader == nil {
+		return nil, fmt.Errorf("Unmarshal: Reader in Config cannot be nil")
+	}
 
-	// dialect
-	dialect := cfg.GetExtensionString("dialect", "mysql")
+	// Create the parser instance
+	parser, err := sqlparser.New(sqlparser.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize parser: %w", err)
+	}
 
-	// all-in-one
-	allInOne := cfg.GetExtensionBool("one-insert", false)
+	r := &rowReader{
+		cfg:        cfg,
+		parser:     parser,
+		statements: &statementScanner{reader: bufio.NewReader(cfg.Reader)},
+	}
+	if err := r.fill(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return r, nil
+}
 
+// fill parses statements until one of them yields rows
+func (r *rowReader) fill() error {
+	for len(r.pending) == 0 {
+		sql, err := r.statements.next()
+		if err != nil {
+			return err
+		}
+		stmt, err := r.parser.Parse(sql)
+		if errors.Is(err, sqlparser.ErrEmpty) {
+			continue // comment only
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse SQL: %w", err)
+		}
+		insert, ok := stmt.(*sqlparser.Insert)
+		if !ok {
+			return fmt.Errorf("unsupported SQL statement type %T", stmt)
+		}
+		r.table.Rows = nil
+		r.table.Nulls = nil
+		if err := handleInsert(r.cfg, insert, &r.table); err != nil {
+			return err
+		}
+		r.pending = r.table.Rows
+		r.nulls = make([][]bool, len(r.pending))
+		for i := range r.pending {
+			r.nulls[i] = r.table.RowNulls(i)
+		}
+	}
+	return nil
+}
+
+func (r *rowReader) Columns() []common.Column {
+	return r.table.Columns
+}
+
+func (r *rowReader) Next() ([]string, error) {
+	if err := r.fill(); err != nil {
+		return nil, err
+	}
+	row := r.pending[0]
+	r.pending = r.pending[1:]
+	r.current = r.nulls[0]
+	r.nulls = r.nulls[1:]
+	return row, nil
+}
+
+func (r *rowReader) Nulls() []bool {
+	return r.current
+}
+
+// rowWriter writes one INSERT statement per row, or a single multi-row
+// INSERT when one-insert is set
+type rowWriter struct {
+	writer    io.Writer
+	tableName string
+	dialect   string
+	insert    string
+	allInOne  bool
+	columns   []common.Column
+	prefix    string
+	rows      int
+}
+
+// NewRowWriter returns a streaming SQL writer
+func NewRowWriter(cfg *common.Config) (common.RowWriter, error) {
+	return newRowWriter(cfg), nil
+}
+
+func newRowWriter(cfg *common.Config) *rowWriter {
 	// INSERT or REPLACE
 	var insert = "INSERT"
 	if cfg.GetExtensionBool("replace", false) {
 		insert = "REPLACE"
 	}
 
+	return &rowWriter{
+		writer:    cfg.Writer,
+		tableName: cfg.GetExtensionString("table", "{table_name}"),
+		dialect:   cfg.GetExtensionString("dialect", "mysql"),
+		insert:    insert,
+		allInOne:  cfg.GetExtensionBool("one-insert", false),
+	}
+}
+
+func (w *rowWriter) WriteHeader(columns []common.Column) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("Marshal: table must have at least one header")
+	}
+	w.columns = columns
+
 	// SQL Prefix
-	columns := make([]string, columnCount)
-	for i, h := range table.Headers {
-		columns[i] = escapeIdentifier(h, dialect)
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = EscapeIdentifier(column.Name, w.dialect)
 	}
+	w.prefix = fmt.Sprintf("%s INTO %s (%s) VALUES",
+		w.insert,
+		EscapeIdentifier(w.tableName, w.dialect),
+		strings.Join(names, ", "),
+	)
+	return nil
+}
 
-	// concat statements
-	var stmt string
-	if allInOne {
-		stmt = fmt.Sprintf("%s INTO %s (%s) VALUES\n",
-			insert,
-			escapeIdentifier(tableName, dialect),
-			strings.Join(columns, ", "),
-		)
-		if _, err := writer.Write([]byte(stmt)); err != nil {
-			return fmt.Errorf("failed to write SQL: %w", err)
+func (w *rowWriter) WriteRow(row []string) error {
+	return w.WriteNullRow(row, nil)
+}
+
+// WriteNullRow writes NULL cells as NULL. Without NULL flags, cells spelled "NULL" are taken as NULL.
+func (w *rowWriter) WriteNullRow(row []string, nulls []bool) error {
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("Marshal: row has %d columns, but table has %d", len(row), len(w.columns))
+	}
+
+	values := make([]string, len(row))
+	for i, cell := range row {
+		switch {
+		case i < len(nulls) && nulls[i]:
+			values[i] = "NULL"
+		case nulls != nil:
+			values[i] = formatValue(cell, w.columns[i].Type, common.SQLStringEscape)
+		default:
+			values[i] = formatValue(cell, w.columns[i].Type, common.SQLValueEscape)
 		}
 	}
 
-	// Build and write SQL INSERT statements
-	for j, row := range table.Rows {
-		values := make([]string, columnCount)
-		for i, cell := range row {
-			values[i] = common.SQLValueEscape(cell)
-		}
-		if allInOne {
-			if j == 0 {
-				stmt = fmt.Sprintf("(%s)", strings.Join(values, ", "))
-			} else {
-				stmt = fmt.Sprintf(",\n(%s)", strings.Join(values, ", "))
-			}
-			if _, err := writer.Write([]byte(stmt)); err != nil {
-				return fmt.Errorf("failed to write SQL: %w", err)
-			}
+	var stmt string
+	if w.allInOne {
+		// The statement is started by its first row, a table without rows
+		// writes nothing rather than an INSERT without values
+		if w.rows == 0 {
+			stmt = fmt.Sprintf("%s\n(%s)", w.prefix, strings.Join(values, ", "))
 		} else {
-			stmt = fmt.Sprintf("%s INTO %s (%s) VALUES (%s);\n",
-				insert,
-				escapeIdentifier(tableName, dialect),
-				strings.Join(columns, ", "),
-				strings.Join(values, ", "),
-			)
-			if _, err := writer.Write([]byte(stmt)); err != nil {
-				return fmt.Errorf("failed to write SQL: %w", err)
-			}
+			stmt = fmt.Sprintf(",\n(%s)", strings.Join(values, ", "))
 		}
+	} else {
+		stmt = fmt.Sprintf("%s (%s);\n", w.prefix, strings.Join(values, ", "))
 	}
-	if allInOne {
-		if _, err := writer.Write([]byte(";\n")); err != nil {
+	w.rows++
+
+	if _, err := io.WriteString(w.writer, stmt); err != nil {
+		return fmt.Errorf("failed to write SQL: %w", err)
+	}
+	return nil
+}
+
+// Close ends the single INSERT of one-insert. It writes nothing when no row was
+// written, including when the conversion failed before WriteHeader.
+func (w *rowWriter) Close() error {
+	if w.allInOne && w.rows > 0 {
+		if _, err := io.WriteString(w.writer, ";\n"); err != nil {
 			return fmt.Errorf("failed to write SQL: %w", err)
 		}
 	}
-
 	return nil
 }
 
-// Escape identifier (like column and table names)
-func escapeIdentifier(s string, dialect string) string {
+func Marshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Marshal: input table pointer cannot be nil")
+	}
+
+	columnCount := len(table.Headers)
+	if columnCount == 0 {
+		return fmt.Errorf("Marshal: table must have at least one header")
+	}
+
+	// Validate all rows have consistent column count upfront
+	for _, row := range table.Rows {
+		if len(row) != columnCount {
+			return fmt.Errorf("Marshal: row has %d columns, but table has %d", len(row), columnCount)
+		}
+	}
+
+	writer := newRowWriter(cfg)
+	if err := writer.WriteHeader(table.HeaderColumns()); err != nil {
+		return err
+	}
+
+	// Build and write SQL INSERT statements
+	for i, row := range table.Rows {
+		if err := writer.WriteNullRow(row, table.RowNulls(i)); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// formatValue renders a cell as a SQL literal, leaving typed numbers and booleans unquoted.
+// Numbers are written as their source text so DECIMAL and large integer values keep
+// every digit. Strings are quoted with escape.
+func formatValue(cell string, colType common.ColumnType, escape func(string) string) string {
+	switch v := common.TypedValue(cell, colType).(type) {
+	case int64, float64:
+		if common.IsDecimal(cell) {
+			return strings.TrimSpace(cell)
+		}
+		return escape(cell)
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	default:
+		return escape(cell)
+	}
+}
+
+// EscapeIdentifier quotes an identifier (like column and table names) for the given dialect
+func EscapeIdentifier(s string, dialect string) string {
 	switch dialect {
-	case "oracle":
+	case "oracle", "sqlite":
 		return common.OracleIdentifierEscape(s)
-	case "postgres":
+	case "postgresql", "postgres":
 		return common.PostgreSQLIdentifierEscape(s)
 	case "mssql":
 		return common.MssqlIdentifierEscape(s)
@@ -1,7 +1,9 @@
 package sql
 
 import (
+	"bufio"
 	"bytes"
+	"io"
 	"strings"
 	"testing"
 
@@ -36,7 +38,7 @@ func TestEscapeIdentifier(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		result := escapeIdentifier(tc.input, "mysql")
+		result := EscapeIdentifier(tc.input, "mysql")
 		assert.Equal(t, tc.expected, result)
 	}
 }
@@ -192,6 +194,22 @@ func TestUnmarshalInsertWithNullValues(t *testing.T) {
 	assert.Equal(t, 1, len(table.Rows))
 	assert.Equal(t, "1", table.Rows[0][0])
 	assert.Equal(t, "NULL", table.Rows[0][1])
+	assert.True(t, table.IsNull(0, 1))
+}
+
+func TestNullRoundTrip(t *testing.T) {
+	input := "INSERT INTO `t` (`a`, `b`) VALUES (NULL, 'NULL');"
+	table := &common.Table{}
+	assert.NoError(t, Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table))
+	assert.Equal(t, [][]string{{"NULL", "NULL"}}, table.Rows)
+	assert.True(t, table.IsNull(0, 0))
+	assert.False(t, table.IsNull(0, 1))
+
+	// The NULL value stays NULL, the string 'NULL' stays quoted
+	var buf bytes.Buffer
+	cfg := &common.Config{Writer: &buf, Extension: map[string]string{"table": "t"}}
+	assert.NoError(t, Marshal(cfg, table))
+	assert.Contains(t, buf.String(), "(NULL, 'NULL')")
 }
 
 // TestUnmarshalInsertWithNumericLiterals tests different numeric literal formats
@@ -321,6 +339,11 @@ func TestMarshalWithDifferentDialects(t *testing.T) {
 			dialect:  "oracle",
 			expected: `"id"`,
 		},
+		{
+			name:     "postgresql",
+			dialect:  "postgresql",
+			expected: `"id"`,
+		},
 		{
 			name:     "postgres",
 			dialect:  "postgres",
@@ -443,7 +466,7 @@ func TestMarshalWithEmptyString(t *testing.T) {
 	assert.Contains(t, output, "''")
 }
 
-// TestEscapeIdentifierDialects tests escapeIdentifier with all dialects
+// TestEscapeIdentifierDialects tests EscapeIdentifier with all dialects
 func TestEscapeIdentifierDialects(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -453,15 +476,17 @@ func TestEscapeIdentifierDialects(t *testing.T) {
 	}{
 		{"mysql", "mysql", "col-name", "`col-name`"},
 		{"oracle", "oracle", "col-name", `"col-name"`},
+		{"postgresql", "postgresql", "col-name", `"col-name"`},
 		{"postgres", "postgres", "col-name", `"col-name"`},
 		{"mssql", "mssql", "col-name", `[col-name]`},
+		{"sqlite", "sqlite", `col"name`, `"col""name"`},
 		{"none", "none", "col-name", "col-name"},
 		{"default", "", "col-name", "`col-name`"}, // default to mysql
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := escapeIdentifier(tt.input, tt.dialect)
+			result := EscapeIdentifier(tt.input, tt.dialect)
 			assert.Equal(t, tt.expected, result)
 		})
 	}
@@ -500,3 +525,129 @@ func TestUnmarshalInsertWithComplexLiterals(t *testing.T) {
 	assert.Equal(t, "true", table.Rows[0][2])
 	assert.Equal(t, "NULL", table.Rows[0][3])
 }
+
+// TestUnmarshalColumnTypes tests that literal types are recorded in the column schema
+func TestUnmarshalColumnTypes(t *testing.T) {
+	input := "INSERT INTO t (id, price, name, active, note) VALUES (1, 9.5, 'Alice', TRUE, NULL), (-2, 10, 'Bob', FALSE, 'x');"
+	cfg := &common.Config{
+		Reader: strings.NewReader(input),
+	}
+	table := &common.Table{}
+
+	err := Unmarshal(cfg, table)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Column{
+		{Name: "id", Type: common.ColumnTypeInt, SourceType: "INT"},
+		{Name: "price", Type: common.ColumnTypeFloat, SourceType: "mixed"},
+		{Name: "name", Type: common.ColumnTypeString, SourceType: "VARCHAR"},
+		{Name: "active", Type: common.ColumnTypeBool, SourceType: "BOOLEAN"},
+		{Name: "note", Type: common.ColumnTypeString, Nullable: true, SourceType: "VARCHAR"},
+	}, table.Columns)
+}
+
+// TestMarshalTypedColumns tests that typed columns are written as unquoted literals
+func TestMarshalTypedColumns(t *testing.T) {
+	table := &common.Table{
+		Headers: []string{"id", "price", "active", "code"},
+		Rows: [][]string{
+			{"1", "9.50", "true", "007"},
+			{"2", "n/a", "false", "008"},
+			{"12345678901234567890", "12345678901234567.89", "1", "009"},
+			{"0x10", "Inf", "0", "010"},
+		},
+		Columns: []common.Column{
+			{Name: "id", Type: common.ColumnTypeInt},
+			{Name: "price", Type: common.ColumnTypeFloat},
+			{Name: "active", Type: common.ColumnTypeBool},
+			{Name: "code", Type: common.ColumnTypeString},
+		},
+	}
+
+	var buf bytes.Buffer
+	cfg := &common.Config{
+		Writer:    &buf,
+		Extension: map[string]string{"table": "t", "dialect": "none"},
+	}
+	err := Marshal(cfg, table)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO t (id, price, active, code) VALUES (1, 9.50, TRUE, '007');\n"+
+		"INSERT INTO t (id, price, active, code) VALUES (2, 'n/a', FALSE, '008');\n"+
+		"INSERT INTO t (id, price, active, code) VALUES (12345678901234567890, 12345678901234567.89, TRUE, '009');\n"+
+		"INSERT INTO t (id, price, active, code) VALUES ('0x10', 'Inf', FALSE, '010');\n", buf.String())
+}
+
+// TestStatementScanner tests splitting statements outside quotes and comments
+func TestStatementScanner(t *testing.T) {
+	input := "INSERT INTO t (a) VALUES ('x;y');; -- c;\nINSERT INTO t (a) VALUES (\"it\\\"s;\") /* ; */;\nINSERT INTO t (a) VALUES (1)"
+	scanner := &statementScanner{reader: bufio.NewReader(strings.NewReader(input))}
+
+	var stmts []string
+	for {
+		stmt, err := scanner.next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		stmts = append(stmts, stmt)
+	}
+
+	assert.Equal(t, []string{
+		"INSERT INTO t (a) VALUES ('x;y')",
+		"-- c;\nINSERT INTO t (a) VALUES (\"it\\\"s;\") /* ; */",
+		"INSERT INTO t (a) VALUES (1)",
+	}, stmts)
+}
+
+// TestRowReader tests streaming rows from several INSERT statements
+func TestRowReader(t *testing.T) {
+	input := "INSERT INTO t (id, name) VALUES (1, 'a;b'), (2, 'c');\n-- only a comment;\nINSERT INTO t (id, name) VALUES (3, NULL);\n"
+	reader, err := NewRowReader(&common.Config{Reader: strings.NewReader(input)})
+	assert.NoError(t, err)
+	assert.Equal(t, "id", reader.Columns()[0].Name)
+	assert.Equal(t, common.ColumnTypeInt, reader.Columns()[0].Type)
+
+	var rows [][]string
+	for {
+		row, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		rows = append(rows, row)
+	}
+	assert.Equal(t, [][]string{{"1", "a;b"}, {"2", "c"}, {"3", "NULL"}}, rows)
+}
+
+// TestRowReaderUnsupportedStatement tests that non-INSERT statements are rejected
+func TestRowReaderUnsupportedStatement(t *testing.T) {
+	_, err := NewRowReader(&common.Config{Reader: strings.NewReader("SELECT 1;")})
+	assert.ErrorContains(t, err, "unsupported SQL statement type")
+}
+
+// TestRowWriterOneInsertWithoutRows tests that one-insert writes no statement without rows
+func TestRowWriterOneInsertWithoutRows(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := &common.Config{Writer: &buf, Extension: map[string]string{"one-insert": "true"}}
+
+	// Closed before WriteHeader, as when the conversion fails early
+	writer, err := NewRowWriter(cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+	assert.Empty(t, buf.String())
+
+	writer, err = NewRowWriter(cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, writer.WriteHeader(common.NewColumns([]string{"id"})))
+	assert.NoError(t, writer.Close())
+	assert.Empty(t, buf.String())
+
+	writer, err = NewRowWriter(cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, writer.WriteHeader(common.NewColumns([]string{"id"})))
+	assert.NoError(t, writer.WriteRow([]string{"1"}))
+	assert.NoError(t, writer.WriteRow([]string{"2"}))
+	assert.NoError(t, writer.Close())
+	assert.Equal(t, "INSERT INTO `{table_name}` (`id`) VALUES\n('1'),\n('2');\n", buf.String())
+}
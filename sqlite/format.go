@@ -0,0 +1,37 @@
+package sqlite
+
+import (
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "sqlite",
+		Description: "SQLite database file",
+		Extensions:  []string{".sqlite", ".sqlite3", ".db"},
+		Detect:      detect,
+		// Tables are added to an existing --result database, see --if-exists
+		UpdatesResult: true,
+		Params: []common.FormatParam{
+			{Name: "table", DefaultValue: "", AllowedValues: "", Description: "Table to read (default the first one) or to create (default the input table name or data)"},
+			{Name: "input-table", DefaultValue: "", AllowedValues: "", Description: "Table to read instead of --table, when --table names the table written", Use: common.ParamRead},
+			{Name: "query", DefaultValue: "", AllowedValues: "", Description: "SELECT statement read instead of a table", Use: common.ParamRead},
+			{Name: "if-exists", DefaultValue: "fail", AllowedValues: "fail, replace, append", Validate: common.OneOf("fail", "replace", "append"), Description: "What to do when the --result database has the table already", Use: common.ParamWrite},
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		NewRowReader:      NewRowReader,
+		UnmarshalDocument: UnmarshalDocument,
+		MarshalDocument:   MarshalDocument,
+	})
+}
+
+// detect scores a file starting with the SQLite database header
+func detect(sample string) int {
+	if strings.HasPrefix(sample, magic) {
+		return common.DetectCertain
+	}
+	return common.DetectNone
+}
@@ -0,0 +1,589 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/martianzhang/tableconvert/common"
+	sqlformat "github.com/martianzhang/tableconvert/sql"
+
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// magic is the header every SQLite database file starts with
+	magic = "SQLite format 3\x00"
+	// dialect quotes identifiers with the sql package
+	dialect = "sqlite"
+	// defaultTableName names a created table when neither --table nor the input give a name
+	defaultTableName = "data"
+)
+
+// open opens the database file at path, mode is "ro" for reading or "rwc" for writing
+func open(path, mode string) (*sql.DB, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	abs = filepath.ToSlash(abs)
+	if !strings.HasPrefix(abs, "/") {
+		abs = "/" + abs
+	}
+	dsn := &url.URL{Scheme: "file", Path: abs, RawQuery: "mode=" + mode}
+	return sql.Open("sqlite", dsn.String())
+}
+
+// inputPath returns the database file to read: cfg.InputPath, or a temporary
+// copy of cfg.Reader when the file cannot be opened by name, which remove deletes
+func inputPath(cfg *common.Config) (path string, remove func(), err error) {
+	if path := cfg.InputPath(); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", nil, err
+		}
+		return path, func() {}, nil
+	}
+	if cfg.Reader == nil {
+		return "", nil, fmt.Errorf("Unmarshal: Reader in Config cannot be nil")
+	}
+	file, err := os.CreateTemp("", "tableconvert-*.db")
+	if err != nil {
+		return "", nil, err
+	}
+	remove = func() { os.Remove(file.Name()) }
+	_, err = io.Copy(file, cfg.Reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		remove()
+		return "", nil, fmt.Errorf("failed to read SQLite data: %w", err)
+	}
+	return file.Name(), remove, nil
+}
+
+// tableNames returns the names of the tables in creation order, including views when views is set
+func tableNames(db *sql.DB, views bool) ([]string, error) {
+	query := `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite\_%' ESCAPE '\' ORDER BY rowid`
+	if views {
+		query = strings.Replace(query, "type = 'table'", "type IN ('table', 'view')", 1)
+	}
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SQLite file: %w", err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// findTable returns the table or view called name, matched case-insensitively like SQLite does
+func findTable(db *sql.DB, name string) (string, error) {
+	names, err := tableNames(db, true)
+	if err != nil {
+		return "", err
+	}
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return n, nil
+		}
+	}
+	return "", fmt.Errorf("table %q not found, available tables: %s", name, strings.Join(names, ", "))
+}
+
+// rowReader reads the result rows of a query
+type rowReader struct {
+	db      *sql.DB
+	rows    *sql.Rows
+	done    func() // releases the database, nil when the reader does not own it
+	name    string
+	columns []common.Column
+	// untyped marks columns without a declared type, e.g. expressions, which
+	// are typed by their values as rows are read
+	untyped []bool
+	values  []any
+	nulls   []bool
+}
+
+// NewRowReader returns a streaming reader of the --input-table or --table table,
+// the --query result or the first table of the database
+func NewRowReader(cfg *common.Config) (common.RowReader, error) {
+	return newRowReader(cfg)
+}
+
+func newRowReader(cfg *common.Config) (*rowReader, error) {
+	query := cfg.GetExtensionString("query", "")
+	// --input-table tells the table read from the table written, e.g. by the sql format
+	flag := "input-table"
+	name := cfg.GetExtensionString(flag, "")
+	if name == "" {
+		flag = "table"
+		name = cfg.GetExtensionString(flag, "")
+	}
+	if query != "" && name != "" {
+		return nil, fmt.Errorf("--%s and --query cannot be used together", flag)
+	}
+
+	path, remove, err := inputPath(cfg)
+	if err != nil {
+		return nil, err
+	}
+	db, err := open(path, "ro")
+	if err != nil {
+		remove()
+		return nil, err
+	}
+	r := &rowReader{db: db, done: func() {
+		db.Close()
+		remove()
+	}}
+	if err := r.query(query, name); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// query runs the query, or selects all rows of the table called name
+func (r *rowReader) query(query, name string) error {
+	if query == "" {
+		var err error
+		if name != "" {
+			name, err = findTable(r.db, name)
+		} else {
+			var names []string
+			if names, err = tableNames(r.db, false); err == nil && len(names) == 0 {
+				err = fmt.Errorf("empty SQLite file: no tables found")
+			} else if err == nil {
+				name = names[0]
+			}
+		}
+		if err != nil {
+			return err
+		}
+		r.name = name
+		query = "SELECT * FROM " + sqlformat.EscapeIdentifier(name, dialect)
+	}
+	return r.run(query)
+}
+
+func (r *rowReader) run(query string) error {
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return err
+	}
+	r.rows = rows
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	r.columns = make([]common.Column, len(types))
+	r.untyped = make([]bool, len(types))
+	for i, t := range types {
+		decl := t.DatabaseTypeName()
+		r.columns[i] = common.Column{Name: t.Name(), Type: columnType(decl), SourceType: decl}
+		r.columns[i].Nullable, _ = t.Nullable()
+		r.untyped[i] = decl == ""
+	}
+	r.values = make([]any, len(types))
+	r.nulls = make([]bool, len(types))
+	return nil
+}
+
+func (r *rowReader) Columns() []common.Column {
+	return r.columns
+}
+
+func (r *rowReader) Next() ([]string, error) {
+	if !r.rows.Next() {
+		err := r.rows.Err()
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	dest := make([]any, len(r.values))
+	for i := range r.values {
+		dest[i] = &r.values[i]
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	row := make([]string, len(r.values))
+	for i, v := range r.values {
+		r.nulls[i] = v == nil
+		if v == nil {
+			row[i] = common.DefaultNullString
+			r.columns[i].Nullable = true
+			continue
+		}
+		if r.untyped[i] {
+			r.columns[i].Observe(valueType(v), "")
+		}
+		row[i] = text(v, r.columns[i].Type)
+	}
+	return row, nil
+}
+
+func (r *rowReader) Nulls() []bool {
+	return r.nulls
+}
+
+// Close releases the database and deletes a temporary copy of the input, it is safe to call twice
+func (r *rowReader) Close() error {
+	if r.rows != nil {
+		r.rows.Close()
+	}
+	if r.done != nil {
+		r.done()
+		r.done = nil
+	}
+	return nil
+}
+
+// columnType maps a declared column type to a column type following the
+// SQLite type affinity rules, booleans and dates are told apart from numbers
+func columnType(decl string) common.ColumnType {
+	decl = strings.ToUpper(decl)
+	has := func(names ...string) bool {
+		for _, name := range names {
+			if strings.Contains(decl, name) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case decl == "":
+		return common.ColumnTypeUnknown
+	case has("BOOL"):
+		return common.ColumnTypeBool
+	case has("INT"):
+		return common.ColumnTypeInt
+	case has("CHAR", "CLOB", "TEXT", "BLOB"):
+		return common.ColumnTypeString
+	case has("REAL", "FLOA", "DOUB"):
+		return common.ColumnTypeFloat
+	case has("DATE", "TIME"):
+		return common.ColumnTypeDate
+	default:
+		// NUMERIC affinity, e.g. DECIMAL(10,2)
+		return common.ColumnTypeFloat
+	}
+}
+
+// valueType returns the column type of a value read from a column without a declared type
+func valueType(v any) common.ColumnType {
+	switch v.(type) {
+	case int64:
+		return common.ColumnTypeInt
+	case float64:
+		return common.ColumnTypeFloat
+	case time.Time:
+		return common.ColumnTypeDate
+	default:
+		return common.ColumnTypeString
+	}
+}
+
+// text formats a value read from a column of type t
+func text(v any, t common.ColumnType) string {
+	switch v := v.(type) {
+	case int64:
+		if t == common.ColumnTypeBool && (v == 0 || v == 1) {
+			return strconv.FormatBool(v == 1)
+		}
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	case []byte:
+		if utf8.Valid(v) {
+			return string(v)
+		}
+		return base64.StdEncoding.EncodeToString(v)
+	case time.Time:
+		// Text of DATE, DATETIME and TIMESTAMP columns is parsed by the driver
+		switch {
+		case v.Location() != time.UTC:
+			return v.Format(time.RFC3339Nano)
+		case v.Equal(v.Truncate(24 * time.Hour)):
+			return v.Format("2006-01-02")
+		default:
+			return v.Format("2006-01-02 15:04:05.999999999")
+		}
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Unmarshal reads the --input-table or --table table, the --query result or the first table of the database
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Unmarshal: target table pointer cannot be nil")
+	}
+	reader, err := newRowReader(cfg)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return readTable(cfg, reader, table)
+}
+
+// readTable reads all rows of reader into table, with the column types
+// observed while reading
+func readTable(cfg *common.Config, reader *rowReader, table *common.Table) error {
+	if err := common.ReadAllRows(cfg, reader, table); err != nil {
+		return err
+	}
+	table.Name = reader.name
+	table.Columns = nil
+	for _, column := range reader.columns {
+		if column.Type != common.ColumnTypeUnknown {
+			table.Columns = reader.columns
+			break
+		}
+	}
+	return nil
+}
+
+// UnmarshalDocument reads every table of the database
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	path, remove, err := inputPath(cfg)
+	if err != nil {
+		return err
+	}
+	defer remove()
+	db, err := open(path, "ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	names, err := tableNames(db, false)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("empty SQLite file: no tables found")
+	}
+	for _, name := range names {
+		reader := &rowReader{db: db, name: name}
+		err := reader.run("SELECT * FROM " + sqlformat.EscapeIdentifier(name, dialect))
+		table := &common.Table{}
+		if err == nil {
+			err = readTable(cfg, reader, table)
+		}
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("table %q: %w", name, err)
+		}
+		doc.Tables = append(doc.Tables, table)
+	}
+	return nil
+}
+
+// Marshal creates the --table table, named after the input table or "data"
+// by default, and inserts the rows
+func Marshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Marshal: input table pointer cannot be nil")
+	}
+	name := cfg.GetExtensionString("table", "")
+	if name == "" {
+		name = table.Name
+	}
+	if name == "" {
+		name = defaultTableName
+	}
+	return write(cfg, []*common.Table{table}, []string{name})
+}
+
+// MarshalDocument creates one table for every table of doc
+func MarshalDocument(cfg *common.Config, doc *common.Document) error {
+	// Table names are case-insensitive, as UniqueTableNames compares them
+	return write(cfg, doc.Tables, doc.UniqueTableNames(nil))
+}
+
+// write creates the tables in the database file cfg.Result, or in a
+// temporary file copied to cfg.Writer when no file is given
+func write(cfg *common.Config, tables []*common.Table, names []string) error {
+	path := cfg.Result
+	if path == "" {
+		if cfg.Writer == nil {
+			return fmt.Errorf("Marshal: Writer in Config cannot be nil")
+		}
+		file, err := os.CreateTemp("", "tableconvert-*.db")
+		if err != nil {
+			return err
+		}
+		file.Close()
+		path = file.Name()
+		defer os.Remove(path)
+	}
+
+	db, err := open(path, "rwc")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to write SQLite file: %w", err)
+	}
+	ifExists := cfg.GetExtensionString("if-exists", "fail")
+	for i, table := range tables {
+		if err := createTable(tx, names[i], table, ifExists); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("table %q: %w", names[i], err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to write SQLite file: %w", err)
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	if cfg.Result == "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return common.CopyReaderToWriter(file, cfg.Writer)
+	}
+	return nil
+}
+
+// createTable creates the table called name with the inferred column types and inserts its rows.
+// An existing table of that name is an error, dropped first or kept and appended to, as ifExists says.
+func createTable(tx *sql.Tx, name string, table *common.Table, ifExists string) error {
+	if len(table.Headers) == 0 {
+		return fmt.Errorf("table must have at least one header")
+	}
+	for _, row := range table.Rows {
+		if len(row) != len(table.Headers) {
+			return fmt.Errorf("row has %d columns, but table has %d", len(row), len(table.Headers))
+		}
+	}
+
+	columns := table.Schema()
+	var inferred []common.Column
+	types := make([]common.ColumnType, len(table.Headers))
+	definitions := make([]string, len(table.Headers))
+	names := make([]string, len(table.Headers))
+	for i, header := range table.Headers {
+		types[i] = columns[i].Type
+		if types[i] == common.ColumnTypeUnknown {
+			// Columns without a type in a partial schema
+			if inferred == nil {
+				inferred = common.InferColumns(table)
+			}
+			types[i] = inferred[i].Type
+		}
+		names[i] = sqlformat.EscapeIdentifier(header, dialect)
+		definitions[i] = names[i] + " " + declaredType(table, i, types[i])
+	}
+	quoted := sqlformat.EscapeIdentifier(name, dialect)
+	var existing string
+	err := tx.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = ? COLLATE NOCASE`, name).Scan(&existing)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	create := true
+	if err == nil {
+		switch ifExists {
+		case "replace":
+			if _, err := tx.Exec("DROP TABLE " + sqlformat.EscapeIdentifier(existing, dialect)); err != nil {
+				return err
+			}
+		case "append":
+			create = false
+		default:
+			return fmt.Errorf("the table already exists, give another --table or --if-exists=replace or append")
+		}
+	}
+	if create {
+		if _, err := tx.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", quoted, strings.Join(definitions, ", "))); err != nil {
+			return err
+		}
+	}
+
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quoted, strings.Join(names, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	args := make([]any, len(table.Headers))
+	for r := range table.Rows {
+		for i := range args {
+			args[i] = value(table, r, i, types[i])
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return fmt.Errorf("row %d: %w", r+1, err)
+		}
+	}
+	return nil
+}
+
+// declaredType returns the SQLite column type of column i of type t, DATE
+// when every value of a date column is a plain date and DATETIME otherwise
+func declaredType(table *common.Table, i int, t common.ColumnType) string {
+	switch t {
+	case common.ColumnTypeInt:
+		return "INTEGER"
+	case common.ColumnTypeFloat:
+		return "REAL"
+	case common.ColumnTypeBool:
+		return "BOOLEAN"
+	case common.ColumnTypeDate:
+		for r := range table.Rows {
+			if v, ok := value(table, r, i, t).(string); ok {
+				if _, err := time.Parse("2006-01-02", v); err != nil {
+					return "DATETIME"
+				}
+			}
+		}
+		return "DATE"
+	default:
+		return "TEXT"
+	}
+}
+
+// value returns the cell at r, i as the value stored in a column of type t.
+// Blank cells of typed columns are NULL, values that do not fit the type are stored as text.
+func value(table *common.Table, r, i int, t common.ColumnType) any {
+	if table.IsNull(r, i) {
+		return nil
+	}
+	cell := table.Rows[r][i]
+	if t == common.ColumnTypeString || t == common.ColumnTypeUnknown {
+		return cell
+	}
+	trimmed := strings.TrimSpace(cell)
+	if trimmed == "" || common.InferType(trimmed) == nil {
+		return nil
+	}
+	if t == common.ColumnTypeDate {
+		return trimmed
+	}
+	return common.TypedValue(trimmed, t)
+}
@@ -0,0 +1,262 @@
+package sqlite
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDatabase creates a database file from SQL statements
+func newDatabase(t *testing.T, statements ...string) string {
+	path := filepath.Join(t.TempDir(), "app.db")
+	db, err := open(path, "rwc")
+	require.NoError(t, err)
+	defer db.Close()
+	for _, statement := range statements {
+		_, err := db.Exec(statement)
+		require.NoError(t, err)
+	}
+	return path
+}
+
+func TestMarshalAndUnmarshal(t *testing.T) {
+	table := &common.Table{
+		Name:    "users",
+		Headers: []string{"id", "name", "score", "active", "born", "seen", "note"},
+		Rows: [][]string{
+			{"1", "alice", "9.5", "true", "2020-01-02", "2023-01-02 03:04:05", "NULL"},
+			{"2", "o'brien", "", "false", "2021-03-04", "2023-01-02T03:04:05+02:00", ""},
+		},
+		Columns: []common.Column{
+			{Name: "id", Type: common.ColumnTypeInt}, {Name: "name", Type: common.ColumnTypeString},
+			{Name: "score", Type: common.ColumnTypeFloat}, {Name: "active", Type: common.ColumnTypeBool},
+			{Name: "born", Type: common.ColumnTypeDate}, {Name: "seen", Type: common.ColumnTypeDate},
+			{Name: "note", Type: common.ColumnTypeString},
+		},
+	}
+	table.SetNull(0, 6)
+
+	result := filepath.Join(t.TempDir(), "out.db")
+	require.NoError(t, Marshal(&common.Config{Result: result}, table))
+
+	db, err := open(result, "ro")
+	require.NoError(t, err)
+	var schema string
+	require.NoError(t, db.QueryRow(`SELECT sql FROM sqlite_master WHERE name = 'users'`).Scan(&schema))
+	db.Close()
+	assert.Equal(t, `CREATE TABLE "users" ("id" INTEGER, "name" TEXT, "score" REAL, "active" BOOLEAN, "born" DATE, "seen" DATETIME, "note" TEXT)`, schema)
+
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{File: result}, got))
+	assert.Equal(t, "users", got.Name)
+	assert.Equal(t, table.Headers, got.Headers)
+	assert.Equal(t, [][]string{
+		{"1", "alice", "9.5", "true", "2020-01-02", "2023-01-02 03:04:05", "NULL"},
+		{"2", "o'brien", "NULL", "false", "2021-03-04", "2023-01-02T03:04:05+02:00", ""},
+	}, got.Rows)
+	assert.True(t, got.IsNull(0, 6))
+	assert.True(t, got.IsNull(1, 2))
+	assert.False(t, got.IsNull(1, 6))
+	for i, column := range got.Columns {
+		assert.Equal(t, table.Columns[i].Type, column.Type, column.Name)
+	}
+	assert.Equal(t, "BOOLEAN", got.Columns[3].SourceType)
+}
+
+func TestMarshalInferredTypes(t *testing.T) {
+	table := &common.Table{
+		Headers: []string{"a", "b", "c"},
+		Rows:    [][]string{{"1", "x", "2020-01-02 10:00:00"}, {"2.5", "", ""}},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, Marshal(&common.Config{Writer: &buf}, table))
+	assert.True(t, strings.HasPrefix(buf.String(), magic))
+
+	got := &common.Table{}
+	cfg := &common.Config{Reader: &buf, Extension: map[string]string{"query": "SELECT sql FROM sqlite_master"}}
+	require.NoError(t, Unmarshal(cfg, got))
+	assert.Equal(t, `CREATE TABLE "data" ("a" REAL, "b" TEXT, "c" DATETIME)`, got.Rows[0][0])
+}
+
+func TestUnmarshalQuery(t *testing.T) {
+	path := newDatabase(t,
+		`CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(10) NOT NULL, price DECIMAL(10,2), data BLOB)`,
+		`CREATE TABLE orders (id INTEGER)`,
+		`CREATE VIEW names AS SELECT name FROM users`,
+		`INSERT INTO users VALUES (1, 'alice', 2.5, x'00ff'), (2, 'bob', 3, 'text')`,
+	)
+
+	// The first table by default
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{File: path}, table))
+	assert.Equal(t, "users", table.Name)
+	assert.Equal(t, [][]string{{"1", "alice", "2.5", "AP8="}, {"2", "bob", "3", "text"}}, table.Rows)
+	assert.Equal(t, common.ColumnTypeFloat, table.Columns[2].Type)
+	assert.Equal(t, "DECIMAL(10,2)", table.Columns[2].SourceType)
+
+	// Expressions are typed by their values
+	table = &common.Table{}
+	cfg := &common.Config{File: path, Extension: map[string]string{"query": "SELECT count(*) AS n, max(name) AS last FROM users"}}
+	require.NoError(t, Unmarshal(cfg, table))
+	assert.Equal(t, []string{"n", "last"}, table.Headers)
+	assert.Equal(t, [][]string{{"2", "bob"}}, table.Rows)
+	assert.Equal(t, common.ColumnTypeInt, table.Columns[0].Type)
+	assert.Equal(t, common.ColumnTypeString, table.Columns[1].Type)
+
+	// Views by name, case-insensitively
+	table = &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{File: path, Extension: map[string]string{"table": "NAMES"}}, table))
+	assert.Equal(t, "names", table.Name)
+	assert.Equal(t, [][]string{{"alice"}, {"bob"}}, table.Rows)
+
+	err := Unmarshal(&common.Config{File: path, Extension: map[string]string{"table": "nope"}}, &common.Table{})
+	assert.ErrorContains(t, err, `table "nope" not found, available tables: users, orders, names`)
+
+	cfg = &common.Config{File: path, Extension: map[string]string{"table": "users", "query": "SELECT 1"}}
+	assert.ErrorContains(t, Unmarshal(cfg, &common.Table{}), "--table and --query cannot be used together")
+
+	// --input-table wins over a --table naming the table written
+	table = &common.Table{}
+	cfg = &common.Config{File: path, Extension: map[string]string{"input-table": "names", "table": "people"}}
+	require.NoError(t, Unmarshal(cfg, table))
+	assert.Equal(t, "names", table.Name)
+
+	// Read only
+	cfg = &common.Config{File: path, Extension: map[string]string{"query": "DELETE FROM users RETURNING id"}}
+	assert.Error(t, Unmarshal(cfg, &common.Table{}))
+}
+
+func TestDocument(t *testing.T) {
+	doc := &common.Document{Tables: []*common.Table{
+		{Name: "Users", Headers: []string{"id"}, Rows: [][]string{{"1"}}},
+		{Name: "users", Headers: []string{"id"}, Rows: [][]string{{"2"}}},
+		{Headers: []string{"x"}, Rows: [][]string{{"a"}}},
+	}}
+	var buf bytes.Buffer
+	require.NoError(t, MarshalDocument(&common.Config{Writer: &buf}, doc))
+
+	got := &common.Document{}
+	require.NoError(t, UnmarshalDocument(&common.Config{Reader: &buf}, got))
+	require.Len(t, got.Tables, 3)
+	assert.Equal(t, []string{"Users", "users (2)", "Table3"}, []string{got.Tables[0].Name, got.Tables[1].Name, got.Tables[2].Name})
+	assert.Equal(t, [][]string{{"2"}}, got.Tables[1].Rows)
+}
+
+func TestRowReader(t *testing.T) {
+	path := newDatabase(t, `CREATE TABLE t (a INTEGER, b TEXT)`, `INSERT INTO t VALUES (1, NULL), (2, 'x')`)
+
+	reader, err := NewRowReader(&common.Config{File: path})
+	require.NoError(t, err)
+	assert.Equal(t, []common.Column{
+		{Name: "a", Type: common.ColumnTypeInt, SourceType: "INTEGER", Nullable: true},
+		{Name: "b", Type: common.ColumnTypeString, SourceType: "TEXT", Nullable: true},
+	}, reader.Columns())
+	row, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "NULL"}, row)
+	assert.Equal(t, []bool{false, true}, reader.(common.NullRowReader).Nulls())
+	row, err = reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2", "x"}, row)
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestRowReaderClose(t *testing.T) {
+	data, err := os.ReadFile(newDatabase(t, `CREATE TABLE t (a INTEGER)`, `INSERT INTO t VALUES (1), (2)`))
+	require.NoError(t, err)
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	// A stream stopped before the last row still deletes the copy of stdin
+	reader, err := NewRowReader(&common.Config{Reader: bytes.NewReader(data)})
+	require.NoError(t, err)
+	_, err = reader.Next()
+	require.NoError(t, err)
+	copies, _ := filepath.Glob(filepath.Join(tmp, "tableconvert-*.db"))
+	assert.Len(t, copies, 1)
+	require.NoError(t, reader.(io.Closer).Close())
+	require.NoError(t, reader.(io.Closer).Close())
+	copies, _ = filepath.Glob(filepath.Join(tmp, "tableconvert-*.db"))
+	assert.Empty(t, copies)
+}
+
+func TestErrors(t *testing.T) {
+	err := Unmarshal(&common.Config{Reader: strings.NewReader("a,b\n1,2\n")}, &common.Table{})
+	assert.ErrorContains(t, err, "invalid SQLite file")
+
+	err = Unmarshal(&common.Config{File: "does-not-exist.db"}, &common.Table{})
+	assert.True(t, os.IsNotExist(err))
+
+	err = Unmarshal(&common.Config{File: newDatabase(t, "PRAGMA user_version = 1")}, &common.Table{})
+	assert.ErrorContains(t, err, "no tables found")
+
+	table := &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1"}}}
+	assert.ErrorContains(t, Marshal(&common.Config{Writer: &bytes.Buffer{}}, table), "row has 1 columns, but table has 2")
+
+}
+
+func TestIfExists(t *testing.T) {
+	path := newDatabase(t, `CREATE TABLE Data (a TEXT, b TEXT)`, `INSERT INTO Data VALUES ('old', 'row')`)
+	table := &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1", "new"}}}
+	read := func() [][]string {
+		got := &common.Table{}
+		require.NoError(t, Unmarshal(&common.Config{File: path}, got))
+		return got.Rows
+	}
+
+	// By default the existing table is named and kept
+	err := Marshal(&common.Config{Result: path}, table)
+	assert.EqualError(t, err, `table "data": the table already exists, give another --table or --if-exists=replace or append`)
+	assert.Equal(t, [][]string{{"old", "row"}}, read())
+
+	cfg := &common.Config{Result: path, Extension: map[string]string{"if-exists": "append"}}
+	require.NoError(t, Marshal(cfg, table))
+	assert.Equal(t, [][]string{{"old", "row"}, {"1", "new"}}, read())
+
+	// Appended columns must be columns of the table
+	other := &common.Table{Headers: []string{"c"}, Rows: [][]string{{"x"}}}
+	assert.ErrorContains(t, Marshal(cfg, other), "no column named c")
+
+	cfg.Extension["if-exists"] = "replace"
+	require.NoError(t, Marshal(cfg, table))
+	assert.Equal(t, [][]string{{"1", "new"}}, read())
+}
+
+func TestColumnType(t *testing.T) {
+	tests := map[string]common.ColumnType{
+		"":              common.ColumnTypeUnknown,
+		"BIGINT":        common.ColumnTypeInt,
+		"boolean":       common.ColumnTypeBool,
+		"VARCHAR(255)":  common.ColumnTypeString,
+		"BLOB":          common.ColumnTypeString,
+		"DOUBLE":        common.ColumnTypeFloat,
+		"DATETIME":      common.ColumnTypeDate,
+		"DECIMAL(10,2)": common.ColumnTypeFloat,
+	}
+	for decl, want := range tests {
+		assert.Equal(t, want, columnType(decl), decl)
+	}
+}
+
+func TestFixture(t *testing.T) {
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{File: "../test/mysql.sqlite"}, table))
+	assert.Equal(t, []string{"FIELD", "TYPE", "NULL", "KEY", "DEFAULT", "EXTRA"}, table.Headers)
+	assert.Equal(t, []string{"user_id", "smallint(5)", "NO", "PRI", "NULL", "auto_increment"}, table.Rows[0])
+}
+
+func TestDetect(t *testing.T) {
+	data, err := os.ReadFile("../test/mysql.sqlite")
+	require.NoError(t, err)
+	assert.Equal(t, common.DetectCertain, detect(string(data[:100])))
+	assert.Equal(t, common.DetectNone, detect("a,b\n"))
+}
@@ -0,0 +1,279 @@
+// Package tableconvert converts tables between formats from Go code.
+//
+// Convert, Read, Write and Register with the With* options are the stable API
+// of this module, together with the types they take and return: Table, Column,
+// ColumnType, Span, Limits, LimitError, Registry, Format, FormatParam and
+// Config. These are aliases of the common types of the same name, whose
+// exported fields and methods are kept stable too. The rest of common and the
+// format packages are the plumbing behind it and may change between releases.
+// The command line tool is built on the same functions.
+//
+//	err := tableconvert.Convert(ctx, in, out, "csv", "markdown",
+//		tableconvert.WithParam("bold-header", "true"),
+//		tableconvert.WithTransform("deduplicate"))
+package tableconvert
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	// Built-in formats register themselves in init
+	_ "github.com/martianzhang/tableconvert/arrow"
+	_ "github.com/martianzhang/tableconvert/ascii"
+	_ "github.com/martianzhang/tableconvert/asciidoc"
+	_ "github.com/martianzhang/tableconvert/avro"
+	_ "github.com/martianzhang/tableconvert/bbcode"
+	_ "github.com/martianzhang/tableconvert/code"
+	_ "github.com/martianzhang/tableconvert/csv"
+	_ "github.com/martianzhang/tableconvert/dokuwiki"
+	_ "github.com/martianzhang/tableconvert/excel"
+	_ "github.com/martianzhang/tableconvert/html"
+	_ "github.com/martianzhang/tableconvert/jira"
+	_ "github.com/martianzhang/tableconvert/json"
+	_ "github.com/martianzhang/tableconvert/jsonl"
+	_ "github.com/martianzhang/tableconvert/latex"
+	_ "github.com/martianzhang/tableconvert/markdown"
+	_ "github.com/martianzhang/tableconvert/mediawiki"
+	_ "github.com/martianzhang/tableconvert/mysql"
+	_ "github.com/martianzhang/tableconvert/ods"
+	_ "github.com/martianzhang/tableconvert/org"
+	_ "github.com/martianzhang/tableconvert/parquet"
+	_ "github.com/martianzhang/tableconvert/rst"
+	_ "github.com/martianzhang/tableconvert/sql"
+	_ "github.com/martianzhang/tableconvert/sqlite"
+	_ "github.com/martianzhang/tableconvert/textile"
+	_ "github.com/martianzhang/tableconvert/tmpl"
+	_ "github.com/martianzhang/tableconvert/toml"
+	_ "github.com/martianzhang/tableconvert/tracwiki"
+	_ "github.com/martianzhang/tableconvert/twiki"
+	_ "github.com/martianzhang/tableconvert/xml"
+	_ "github.com/martianzhang/tableconvert/yaml"
+)
+
+// Types of the stable API, defined in common where the format packages use them
+type (
+	// Table is a parsed table: headers, rows, and the optional column types,
+	// merged cells and NULL flags of formats that have them
+	Table = common.Table
+	// Column describes the name and type of a table column
+	Column = common.Column
+	// ColumnType is the type of the values held by a column
+	ColumnType = common.ColumnType
+	// Span marks a merged cell of a table
+	Span = common.Span
+	// Limits bound the rows, bytes, cell size and duration of a call
+	Limits = common.Limits
+	// LimitError is returned, wrapped, when the input exceeds one of the Limits
+	LimitError = common.LimitError
+	// Registry maps format names to their readers and writers
+	Registry = common.FormatRegistry
+	// Format describes a format for Register
+	Format = common.Format
+	// FormatParam describes a parameter of a Format
+	FormatParam = common.FormatParam
+	// Config holds the input, output and parameters a Format reads and writes with
+	Config = common.Config
+)
+
+// DefaultRegistry holds all registered formats. It is used unless WithRegistry is given.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry returns a new registry with all registered formats, ready for
+// registering additional formats
+func NewRegistry() *Registry {
+	registry := common.NewFormatRegistry()
+	for _, format := range common.Formats() {
+		registry.Add(format)
+	}
+	return registry
+}
+
+// Register adds a format to DefaultRegistry, help output, format detection
+// and batch file naming. Format packages outside this module call it from
+// init; the built-in formats register themselves with common.Register.
+func Register(format *Format) {
+	common.Register(format)
+	DefaultRegistry.Add(format)
+}
+
+// Option configures a Convert, Read or Write call
+type Option func(*options)
+
+type options struct {
+	registry *Registry
+	cfg      Config
+	err      error
+}
+
+// WithParam sets a format parameter, e.g. WithParam("delimiter", ";"). The
+// parameters of each format are listed by `tableconvert --help-formats`.
+func WithParam(name, value string) Option {
+	return func(o *options) {
+		o.cfg.Extension[name] = value
+	}
+}
+
+// WithParams sets several format parameters
+func WithParams(params map[string]string) Option {
+	return func(o *options) {
+		for name, value := range params {
+			o.cfg.Extension[name] = value
+		}
+	}
+}
+
+// WithTransform enables data transformations by name: transpose,
+// delete-empty, deduplicate, uppercase, lowercase, capitalize or expand-spans
+func WithTransform(names ...string) Option {
+	return func(o *options) {
+		for _, name := range names {
+			switch name {
+			case "expand-spans":
+				o.cfg.ExpandSpans = true
+			case "transpose", "delete-empty", "deduplicate", "uppercase", "lowercase", "capitalize":
+				o.cfg.Extension[name] = "true"
+			default:
+				o.err = fmt.Errorf("unknown transformation: %s", name)
+			}
+		}
+	}
+}
+
+// WithTableIndex reads the N-th table of the input (0-based)
+func WithTableIndex(index int) Option {
+	return func(o *options) {
+		o.cfg.TableIndex = index
+	}
+}
+
+// WithTableName reads the table with this name, e.g. a sheet name or HTML caption
+func WithTableName(name string) Option {
+	return func(o *options) {
+		o.cfg.TableName = name
+	}
+}
+
+// WithAllTables converts every table of the input. The output format must
+// hold several tables unless the input has only one.
+func WithAllTables() Option {
+	return func(o *options) {
+		o.cfg.AllTables = true
+	}
+}
+
+// WithLimits bounds the input rows, bytes and cell size and the duration of
+// the call. An exceeded limit returns an error wrapping *LimitError.
+func WithLimits(limits Limits) Option {
+	return func(o *options) {
+		o.cfg.Limits = limits
+	}
+}
+
+// WithLenient accepts parameters that the formats do not take, which are
+// otherwise rejected like the --lenient command-line flag does
+func WithLenient() Option {
+	return func(o *options) {
+		o.cfg.Lenient = true
+	}
+}
+
+// WithRegistry uses registry instead of DefaultRegistry, e.g. one from NewRegistry with extra formats
+func WithRegistry(registry *Registry) Option {
+	return func(o *options) {
+		o.registry = registry
+	}
+}
+
+// newOptions applies opts to a config for reading format from and writing format to
+func newOptions(from, to string, opts []Option) (*options, error) {
+	o := &options{
+		registry: DefaultRegistry,
+		cfg: Config{
+			From:      strings.ToLower(from),
+			To:        strings.ToLower(to),
+			Extension: make(map[string]string),
+		},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.err != nil {
+		return nil, o.err
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// validate checks the parameters against the formats as the command line
+// does. Formats only known to a registry given by WithRegistry do not
+// describe their parameters, so those are not checked.
+func (o *options) validate() error {
+	if o.cfg.Lenient {
+		return nil
+	}
+	for _, name := range []string{o.cfg.From, o.cfg.To} {
+		if _, ok := common.LookupFormat(name); name != "" && !ok {
+			return nil
+		}
+	}
+	if o.cfg.From == "" && o.cfg.To != "" {
+		return o.cfg.ValidateWriteParams(o.cfg.To)
+	}
+	return o.cfg.ValidateParams(o.cfg.From, o.cfg.To)
+}
+
+// Convert reads a table in format from r and writes it in format to w.
+// Rows are streamed when both formats support it. Reading and writing stop
+// with the context error once ctx is done.
+func Convert(ctx context.Context, r io.Reader, w io.Writer, from, to string, opts ...Option) error {
+	o, err := newOptions(from, to, opts)
+	if err != nil {
+		return err
+	}
+	o.cfg.Reader = r
+	o.cfg.Writer = w
+	return common.PerformConversionContext(ctx, o.registry, &o.cfg)
+}
+
+// Read parses a table in format from r and applies the transformation options.
+// With WithTableIndex or WithTableName it returns the chosen table of the input.
+// NULL cells are marked in Table.Nulls.
+func Read(ctx context.Context, r io.Reader, from string, opts ...Option) (*Table, error) {
+	o, err := newOptions(from, "", opts)
+	if err != nil {
+		return nil, err
+	}
+	o.cfg.AllTables = false
+	o.cfg.Reader = r
+	tables, err := common.ReadTablesContext(ctx, o.registry, &o.cfg)
+	if err != nil {
+		return nil, err
+	}
+	table := tables[0]
+	table.FillNulls(o.cfg.NullString())
+	o.cfg.ApplyTransformations(table)
+	return table, nil
+}
+
+// Write writes table in format to w after applying the transformation
+// options. The table itself is not modified.
+func Write(ctx context.Context, w io.Writer, table *Table, to string, opts ...Option) error {
+	if table == nil {
+		return fmt.Errorf("table cannot be nil")
+	}
+	o, err := newOptions("", to, opts)
+	if err != nil {
+		return err
+	}
+	o.cfg.Writer = w
+	table = table.Clone()
+	table.FillNulls(o.cfg.NullString())
+	o.cfg.ApplyTransformations(table)
+	return common.WriteTablesContext(ctx, o.registry, &o.cfg, []*Table{table})
+}
@@ -0,0 +1,175 @@
+package tableconvert
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvert(t *testing.T) {
+	input := "name,age\nAlice,30\nBob,25\nAlice,30\n"
+	var out bytes.Buffer
+	err := Convert(context.Background(), strings.NewReader(input), &out, "csv", "md",
+		WithParam("bold-header", "true"),
+		WithTransform("deduplicate", "uppercase"))
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "**NAME**")
+	assert.Contains(t, out.String(), "| ALICE ")
+	assert.Equal(t, 1, strings.Count(out.String(), "ALICE"))
+}
+
+func TestConvertStreaming(t *testing.T) {
+	input := "{\"a\":1,\"b\":null}\n{\"a\":2,\"b\":\"x\"}\n"
+	var out bytes.Buffer
+	err := Convert(context.Background(), strings.NewReader(input), &out, "jsonl", "csv", WithParam("null-string", ""))
+	require.NoError(t, err)
+	assert.Equal(t, "a,b\n1,\n2,x\n", out.String())
+}
+
+func TestConvertSQLDialect(t *testing.T) {
+	// The documented dialect name passes validation and quotes identifiers like the short one
+	for _, dialect := range []string{"postgresql", "postgres"} {
+		var out bytes.Buffer
+		err := Convert(context.Background(), strings.NewReader("col\n1\n"), &out, "csv", "sql",
+			WithParam("dialect", dialect), WithParam("table", "t"))
+		require.NoError(t, err)
+		assert.Equal(t, "INSERT INTO \"t\" (\"col\") VALUES ('1');\n", out.String(), dialect)
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	ctx := context.Background()
+	var out bytes.Buffer
+
+	err := Convert(ctx, strings.NewReader("a\n1\n"), &out, "csv", "markdown", WithTransform("shuffle"))
+	assert.EqualError(t, err, "unknown transformation: shuffle")
+
+	err = Convert(ctx, strings.NewReader("a\n1\n"), &out, "nope", "markdown")
+	assert.ErrorContains(t, err, "unsupported `--from` format: nope")
+
+	err = Convert(ctx, strings.NewReader("a\n1\n"), &out, "csv", "markdown", WithParam("bold-heder", "true"))
+	assert.ErrorContains(t, err, "unknown parameter --bold-heder, did you mean --bold-header?")
+	err = Convert(ctx, strings.NewReader("a\n1\n"), &out, "csv", "markdown", WithParams(map[string]string{"escape": "maybe"}))
+	assert.ErrorContains(t, err, `--escape: invalid value "maybe"`)
+	_, err = Read(ctx, strings.NewReader("a\n1\n"), "csv", WithParam("minify", "true"))
+	assert.ErrorContains(t, err, "--minify: it is a parameter of html, json, mediawiki, xml, not of csv")
+	err = Write(ctx, &out, &Table{Headers: []string{"a"}}, "sqlite", WithParam("input-table", "users"))
+	assert.ErrorContains(t, err, "unknown parameter --input-table: sqlite does not take it when writing")
+	out.Reset()
+	err = Convert(ctx, strings.NewReader("a\n1\n"), &out, "csv", "markdown", WithParam("bold-heder", "true"), WithLenient())
+	assert.NoError(t, err)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = Convert(cancelled, strings.NewReader("a\n1\n"), &out, "csv", "markdown")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestConvertLimits(t *testing.T) {
+	var out bytes.Buffer
+	err := Convert(context.Background(), strings.NewReader("a\n1\n2\n3\n"), &out, "csv", "json",
+		WithLimits(Limits{MaxRows: 2}))
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "max-rows", limitErr.Limit)
+
+	_, err = Read(context.Background(), strings.NewReader(`[{"a": "long value"}]`), "json",
+		WithLimits(common.Limits{MaxCellBytes: 4}))
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "max-cell-bytes", limitErr.Limit)
+}
+
+func TestReadWrite(t *testing.T) {
+	ctx := context.Background()
+	input := `{"first": [{"a": 1}], "second": [{"b": null}]}`
+
+	table, err := Read(ctx, strings.NewReader(input), "json", WithTableName("second"))
+	require.NoError(t, err)
+	assert.Equal(t, "second", table.Name)
+	assert.Equal(t, []string{"b"}, table.Headers)
+	assert.True(t, table.IsNull(0, 0))
+
+	var out bytes.Buffer
+	err = Write(ctx, &out, table, "json", WithParam("minify", "true"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"b": null}]`, out.String())
+
+	// Transformations do not modify the given table
+	out.Reset()
+	table = &Table{Headers: []string{"a"}, Rows: [][]string{{"x"}}}
+	err = Write(ctx, &out, table, "csv", WithTransform("uppercase"))
+	require.NoError(t, err)
+	assert.Equal(t, "A\nX\n", out.String())
+	assert.Equal(t, [][]string{{"x"}}, table.Rows)
+
+	err = Write(ctx, &out, nil, "csv")
+	assert.Error(t, err)
+}
+
+func TestExcelStreams(t *testing.T) {
+	// Workbooks are read from and written to streams when no file name is set
+	data, err := os.ReadFile("test/mysql.xlsx")
+	require.NoError(t, err)
+
+	table, err := Read(context.Background(), bytes.NewReader(data), "xlsx")
+	require.NoError(t, err)
+	assert.Equal(t, "FIELD", table.Headers[0])
+
+	var out bytes.Buffer
+	require.NoError(t, Write(context.Background(), &out, table, "excel"))
+
+	again, err := Read(context.Background(), &out, "excel")
+	require.NoError(t, err)
+	assert.Equal(t, table.Headers, again.Headers)
+	assert.Equal(t, table.Rows, again.Rows)
+}
+
+func TestNewRegistry(t *testing.T) {
+	registry := NewRegistry()
+	assert.NotSame(t, DefaultRegistry, registry)
+
+	registry.RegisterWriteOnlyFormat("count", func(cfg *common.Config, table *common.Table) error {
+		_, err := cfg.Writer.Write([]byte(strings.Repeat("#", len(table.Rows))))
+		return err
+	})
+	var out bytes.Buffer
+	err := Convert(context.Background(), strings.NewReader("a\n1\n2\n"), &out, "csv", "count", WithRegistry(registry))
+	require.NoError(t, err)
+	assert.Equal(t, "##", out.String())
+
+	_, ok := DefaultRegistry.GetMarshalFunc("count")
+	assert.False(t, ok)
+}
+
+func TestRegister(t *testing.T) {
+	// An in-house format is usable by name and extension once registered
+	if _, ok := common.LookupFormat("lines"); !ok {
+		Register(&Format{
+			Name:        "lines",
+			Description: "One cell per line",
+			Extensions:  []string{".lines"},
+			Marshal: func(cfg *Config, table *Table) error {
+				for _, row := range table.Rows {
+					if _, err := cfg.Writer.Write([]byte(strings.Join(row, "\n") + "\n")); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		})
+	}
+
+	var out bytes.Buffer
+	err := Convert(context.Background(), strings.NewReader("a,b\n1,2\n"), &out, "csv", "LINES")
+	require.NoError(t, err)
+	assert.Equal(t, "1\n2\n", out.String())
+	assert.Equal(t, "lines", common.DetectTableFormatByExtension("out.lines"))
+	assert.Contains(t, common.GetAllFormats(), "lines")
+}
@@ -0,0 +1,5 @@
+data = [
+    {"FIELD": "user_id", "TYPE": "smallint(5)", "NULL": "NO", "KEY": "PRI", "DEFAULT": None, "EXTRA": "auto_increment"},
+    {"FIELD": "username", "TYPE": "varchar(10)", "NULL": "NO", "KEY": "", "DEFAULT": None, "EXTRA": ""},
+    {"FIELD": "password", "TYPE": "varchar(100)", "NULL": "NO", "KEY": "", "DEFAULT": "", "EXTRA": ""},
+]
@@ -0,0 +1,45 @@
+package textile
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+// spanCellPattern matches a cell starting with a column or row span modifier, such as "|\2. "
+var spanCellPattern = regexp.MustCompile(`\|[\\/]\d+\.\s`)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "textile",
+		Description: "Textile table",
+		Extensions:  []string{".textile"},
+		Detect:      detect,
+		DetectOrder: 20,
+		Params: []common.FormatParam{
+			common.AlignParam,
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+	})
+}
+
+// detect scores content with "|_. Header|" cells, a "table." signature before a row, or span modifiers
+func detect(sample string) int {
+	lines := common.SampleLines(sample, 20)
+	score := common.DetectNone
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "|_.") || strings.Contains(line, "|_. "):
+			return 95
+		case signaturePattern.MatchString(line) && i+1 < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i+1]), "|"):
+			score = max(score, 90)
+		case strings.HasPrefix(line, "|") && spanCellPattern.MatchString(line):
+			score = max(score, 60)
+		}
+	}
+	return score
+}
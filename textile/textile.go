@@ -0,0 +1,330 @@
+package textile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// attributes matches a (class#id), {style} or [lang] attribute of a table, row or cell
+const attributes = `\([^)]*\)|\{[^}]*\}|\[[^\]]*\]`
+
+var (
+	// attributesPattern matches the attributes of a cell, which may hold "/" or digits
+	attributesPattern = regexp.MustCompile(attributes)
+	// signaturePattern matches a "table(class)." line before the rows
+	signaturePattern = regexp.MustCompile(`^table(?:` + attributes + `|<>|[<>=])*\.\s*$`)
+	// captionPattern matches a "|=. Caption" line before the rows
+	captionPattern = regexp.MustCompile(`^\|=(?:` + attributes + `)*\.\s+(.*[^|\s])\s*$`)
+	// sectionPattern matches the "|^.", "|-." and "|~." lines starting the head, body and foot of a table
+	sectionPattern = regexp.MustCompile(`^\|[\^~-](?:` + attributes + `)*\.\s*$`)
+	// rowModifierPattern matches the modifiers written before the first cell of a row, such as "(odd). |"
+	rowModifierPattern = regexp.MustCompile(`^(?:<>|[<>=^~-]|` + attributes + `)+\.\s*\|`)
+	// cellModifierPattern matches the modifiers a cell starts with, such as "_. " or "\2/3>. "
+	cellModifierPattern = regexp.MustCompile(`^((?:_|[\\/]\d+|<>|[<>=^~-]|` + attributes + `)+)\.(?:\s|$)`)
+	// spanModifierPattern matches a column span "\2" or a row span "/3" modifier
+	spanModifierPattern = regexp.MustCompile(`([\\/])(\d+)`)
+	// brPattern matches the <br> tags the writer uses for line breaks
+	brPattern = regexp.MustCompile(`(?i)<br\s*/?>`)
+)
+
+// notextilePairs are the markups whose text is kept as it is
+var notextilePairs = [][2]string{{"==", "=="}, {"<notextile>", "</notextile>"}}
+
+// escaper writes the "|" and line breaks of a cell as an entity and <br /> tags
+var escaper = strings.NewReplacer("|", "&#124;", "\r\n", "<br />", "\n", "<br />")
+
+// literalPattern matches the text of a cell that reads as markup: notextile
+// markers, the "&#124;" entity and <br> tags
+var literalPattern = regexp.MustCompile(`(?i)==|<notextile>|&#124;|<br\s*/?>`)
+
+// notextile returns the text inside the ==notextile== or <notextile> markup s starts
+// with and the length of the whole markup, 0 if it starts with none or it is not closed
+func notextile(s string) (string, int) {
+	for _, pair := range notextilePairs {
+		if !strings.HasPrefix(s, pair[0]) {
+			continue
+		}
+		if end := strings.Index(s[len(pair[0]):], pair[1]); end >= 0 {
+			return s[len(pair[0]) : len(pair[0])+end], len(pair[0]) + end + len(pair[1])
+		}
+	}
+	return "", 0
+}
+
+// splitRow splits a row into the raw text of its cells, a "|" inside notextile
+// markup is part of the cell. Text after the last "|" is a cell when not blank.
+func splitRow(row string) []string {
+	var cells []string
+	var value strings.Builder
+	started := false
+	for i := 0; i < len(row); {
+		if _, n := notextile(row[i:]); n > 0 {
+			value.WriteString(row[i : i+n])
+			i += n
+			continue
+		}
+		if row[i] == '|' {
+			if started {
+				cells = append(cells, value.String())
+			}
+			value.Reset()
+			started = true
+		} else {
+			value.WriteByte(row[i])
+		}
+		i++
+	}
+	if strings.TrimSpace(value.String()) != "" {
+		cells = append(cells, value.String())
+	}
+	return cells
+}
+
+// escape writes the text of a cell that reads as markup as notextile text, then
+// "|" and line breaks with the escaper
+func escape(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range literalPattern.FindAllStringIndex(s, -1) {
+		b.WriteString(escaper.Replace(s[last:m[0]]))
+		if literal := s[m[0]:m[1]]; literal == "==" {
+			b.WriteString("<notextile>==</notextile>")
+		} else {
+			b.WriteString("==" + literal + "==")
+		}
+		last = m[1]
+	}
+	b.WriteString(escaper.Replace(s[last:]))
+	return b.String()
+}
+
+// decode reads <br> tags as line breaks and "&#124;" as "|"
+func decode(s string) string {
+	return strings.ReplaceAll(brPattern.ReplaceAllString(s, "\n"), "&#124;", "|")
+}
+
+// unescape reads the text of a cell, notextile text being kept as it is
+func unescape(s string) string {
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(s); {
+		if text, n := notextile(s[i:]); n > 0 {
+			b.WriteString(decode(s[last:i]) + text)
+			i += n
+			last = i
+			continue
+		}
+		i++
+	}
+	b.WriteString(decode(s[last:]))
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// parseCell reads the span modifiers and text of a cell, other modifiers are left out
+func parseCell(raw string) common.SpanCell {
+	var cell common.SpanCell
+	if m := cellModifierPattern.FindStringSubmatch(raw); m != nil {
+		modifiers := attributesPattern.ReplaceAllString(m[1], "")
+		for _, span := range spanModifierPattern.FindAllStringSubmatch(modifiers, -1) {
+			n, _ := strconv.Atoi(span[2])
+			if span[1] == `\` {
+				cell.ColSpan = n
+			} else {
+				cell.RowSpan = n
+			}
+		}
+		raw = raw[len(m[0]):]
+	}
+	cell.Value = unescape(raw)
+	return cell
+}
+
+// readTable reads the next table of the input, its first row being the header
+// whether or not its cells start with "_.". A row may go on over several lines
+// until it ends with "|", and the "|=. Caption" line names the table.
+func readTable(cfg *common.Config, scanner *bufio.Scanner, lineNumber *int) (*common.Table, error) {
+	var rows [][]common.SpanCell
+	var pending strings.Builder
+	name := ""
+	pendingLine := 0
+	endRow := func() error {
+		if pending.Len() == 0 {
+			return nil
+		}
+		row := pending.String()
+		pending.Reset()
+		var cells []common.SpanCell
+		for _, raw := range splitRow(row) {
+			cells = append(cells, parseCell(raw))
+		}
+		if len(cells) == 0 {
+			return &common.ParseError{LineNumber: pendingLine, Message: "row contains no cells", Line: row}
+		}
+		// The first row is the header
+		if err := cfg.CheckSpanRow(len(rows)-1, cells); err != nil {
+			return err
+		}
+		rows = append(rows, cells)
+		return nil
+	}
+
+scan:
+	for scanner.Scan() {
+		*lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case pending.Len() > 0 && line != "":
+			// A cell going on over several lines
+			pending.WriteString("\n" + line)
+		case len(rows) == 0 && signaturePattern.MatchString(line):
+		case len(rows) == 0 && captionPattern.MatchString(line):
+			name = unescape(captionPattern.FindStringSubmatch(line)[1])
+		case sectionPattern.MatchString(line):
+		case strings.HasPrefix(line, "|") || rowModifierPattern.MatchString(line):
+			pending.WriteString(rowModifierPattern.ReplaceAllString(line, "|"))
+			pendingLine = *lineNumber
+		case len(rows) > 0 || pending.Len() > 0:
+			// A blank line or other text ends the table
+			break scan
+		}
+		if strings.HasSuffix(line, "|") {
+			if err := endRow(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := endRow(); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	grid, spans, _, err := common.LayoutSpans(rows)
+	if err != nil {
+		return nil, err
+	}
+	table := &common.Table{}
+	table.SetGrid(grid, spans)
+	table.Name = name
+	return table, nil
+}
+
+// Unmarshal reads the first table of the input
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Unmarshal: target table pointer cannot be nil")
+	}
+	tables, err := common.ScanTables(cfg, 1, readTable)
+	if err != nil {
+		return err
+	}
+	*table = *tables[0]
+	return nil
+}
+
+// UnmarshalDocument reads every table of the input, tables being separated by
+// blank lines or other text
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	tables, err := common.ScanTables(cfg, 0, readTable)
+	if err != nil {
+		return err
+	}
+	doc.Tables = append(doc.Tables, tables...)
+	return nil
+}
+
+// Marshal writes a "|_. header |" row followed by "| cell |" rows. Alignments other
+// than left are written as "=." and ">." modifiers and merged cells as "\2." column
+// span and "/2." row span modifiers, cells are padded so that columns line up.
+// A "|" in a cell is written as "&#124;" and line breaks as <br /> tags.
+func Marshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Marshal: input table pointer cannot be nil")
+	}
+	if len(table.Headers) == 0 {
+		return fmt.Errorf("Marshal: table must have at least one header")
+	}
+	for i, row := range table.Rows {
+		if len(row) != len(table.Headers) {
+			return fmt.Errorf("Marshal: row %d has %d columns, but table has %d", i, len(row), len(table.Headers))
+		}
+	}
+
+	aligns := cfg.ColumnAligns(len(table.Headers))
+
+	layout := table.SpanLayout()
+	rows := append([][]string{table.Headers}, table.Rows...)
+	leads := make([][]string, len(rows))
+	values := make([][]string, len(rows))
+	widths := make([]int, len(table.Headers))
+	for r, row := range rows {
+		leads[r], values[r] = make([]string, len(row)), make([]string, len(row))
+		for col, value := range row {
+			modifiers := ""
+			if r == 0 {
+				modifiers = "_"
+			}
+			span, anchor := layout.At(r-1, col)
+			if anchor && span.ColSpan > 1 {
+				modifiers += fmt.Sprintf(`\%d`, span.ColSpan)
+			}
+			if anchor && span.RowSpan > 1 {
+				modifiers += fmt.Sprintf("/%d", span.RowSpan)
+			}
+			modifiers += map[string]string{"c": "=", "r": ">"}[aligns[col]]
+
+			lead := " "
+			if modifiers != "" {
+				lead = modifiers + ". "
+				value = escape(value)
+			} else if prefix := cellModifierPattern.FindString(value); prefix != "" {
+				// Text that would be read as modifiers
+				rest := escape(value[len(prefix):])
+				if strings.Contains(prefix, "=") {
+					value = "<notextile>" + prefix + "</notextile>" + rest
+				} else {
+					value = "==" + prefix + "==" + rest
+				}
+			} else {
+				value = escape(value)
+			}
+			leads[r][col], values[r][col] = lead, value
+			if !layout.Covered(r-1, col) && (!anchor || span.ColSpan == 1) {
+				widths[col] = max(widths[col], len(lead)+runewidth.StringWidth(value))
+			}
+		}
+	}
+
+	var b strings.Builder
+	for r := range rows {
+		b.WriteString("|")
+		for col := range rows[r] {
+			if layout.Covered(r-1, col) {
+				continue
+			}
+			width := widths[col]
+			if span, ok := layout.At(r-1, col); ok {
+				// A merged cell takes the width of the columns it covers and their " |"
+				for _, w := range widths[col+1 : col+span.ColSpan] {
+					width += w + 2
+				}
+			}
+			b.WriteString(leads[r][col] + common.PadAlign(values[r][col], width-len(leads[r][col]), aligns[col]) + " |")
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(cfg.Writer, b.String())
+	return err
+}
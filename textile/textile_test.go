@@ -0,0 +1,234 @@
+package textile
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	// The other wiki dialects, scored against this one by TestDetect
+	_ "github.com/martianzhang/tableconvert/bbcode"
+	_ "github.com/martianzhang/tableconvert/dokuwiki"
+	_ "github.com/martianzhang/tableconvert/jira"
+	_ "github.com/martianzhang/tableconvert/markdown"
+	_ "github.com/martianzhang/tableconvert/mediawiki"
+	_ "github.com/martianzhang/tableconvert/tracwiki"
+	_ "github.com/martianzhang/tableconvert/twiki"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalPage(t *testing.T) {
+	// Shaped like a table of a Redmine or Textpattern page: a table signature,
+	// a caption, thead and tbody lines and a row modifier
+	input := `h2. Servers
+
+table(servers).
+|=. Server list
+|^.
+|_. Host |_(wide). Role |_. Notes |
+|-.
+(odd). | web1 |/2=. web | ==a|b== |
+|>. db1 | first line
+second line |
+|\2{color:red}. spans two columns | x&#124;y<br />z |
+
+p. Text below the table
+`
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, "Server list", table.Name)
+	assert.Equal(t, []string{"Host", "Role", "Notes"}, table.Headers)
+	assert.Equal(t, [][]string{
+		{"web1", "web", "a|b"},
+		{"db1", "", "first line\nsecond line"},
+		{"spans two columns", "", "x|y\nz"},
+	}, table.Rows)
+	assert.Equal(t, []common.Span{
+		{Row: 0, Col: 1, RowSpan: 2, ColSpan: 1},
+		{Row: 2, Col: 0, RowSpan: 1, ColSpan: 2},
+	}, table.Spans)
+}
+
+func TestUnmarshalDocument(t *testing.T) {
+	// Each table keeps its own caption
+	input := "table(servers).\n|=. Servers\n|_. Host |_. Role |\n| web1 | web |\n\np. Owners:\n\n|=. Owners\n|_. Name |\n| ops |\n"
+	var doc common.Document
+	err := UnmarshalDocument(&common.Config{Reader: strings.NewReader(input)}, &doc)
+	assert.NoError(t, err)
+	require.Len(t, doc.Tables, 2)
+	assert.Equal(t, "Servers", doc.Tables[0].Name)
+	assert.Equal(t, []string{"Host", "Role"}, doc.Tables[0].Headers)
+	assert.Equal(t, [][]string{{"web1", "web"}}, doc.Tables[0].Rows)
+	assert.Equal(t, "Owners", doc.Tables[1].Name)
+	assert.Equal(t, []string{"Name"}, doc.Tables[1].Headers)
+	assert.Equal(t, [][]string{{"ops"}}, doc.Tables[1].Rows)
+
+	// Unmarshal reads the first table only
+	table := &common.Table{}
+	err = Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, doc.Tables[0].Rows, table.Rows)
+}
+
+func TestUnmarshalWithCellModifiers(t *testing.T) {
+	// Alignment, vertical alignment, class, style and language modifiers are not text,
+	// a "." after other text is
+	input := "|_<>. a |_~. b |_{color:red}. c |\n|[fr](x#y)>. d |^. e | f. g |\n"
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, table.Headers)
+	assert.Equal(t, [][]string{{"d", "e", "f. g"}}, table.Rows)
+}
+
+func TestUnmarshalWithNotextile(t *testing.T) {
+	input := "|_. a |_. b |\n| <notextile>|_. x</notextile> | ==<br>== |\n"
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"|_. x", "<br>"}}, table.Rows)
+}
+
+func TestUnmarshalWithRowSpanPastTheEnd(t *testing.T) {
+	input := "|_. a |_. b |\n|/1000000000. x | y |\n"
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"x", "y"}}, table.Rows)
+	assert.Nil(t, table.Spans)
+}
+
+func TestMarshalWithSpecialCharacters(t *testing.T) {
+	// Pipes are written as an entity, text that reads as markup as notextile text
+	table := &common.Table{
+		Headers: []string{"case", "cell"},
+		Rows: [][]string{
+			{"pipe", "a|b"},
+			{"line break", "two\nlines"},
+			{"entity", "&#124;"},
+			{"br tag", "<br>"},
+			{"notextile marker", "==x=="},
+			{"notextile tag", "<notextile>x</notextile>"},
+			{"header modifier", "_. x"},
+			{"center modifier", "=. x"},
+		},
+	}
+	var buf bytes.Buffer
+	err := Marshal(&common.Config{Writer: &buf}, table)
+	assert.NoError(t, err)
+	expected := `|_. case           |_. cell                                              |
+| pipe             | a&#124;b                                            |
+| line break       | two<br />lines                                      |
+| entity           | ==&#124;==                                          |
+| br tag           | ==<br>==                                            |
+| notextile marker | <notextile>==</notextile>x<notextile>==</notextile> |
+| notextile tag    | ==<notextile>==x</notextile>                        |
+| header modifier  | ==_. ==x                                            |
+| center modifier  | <notextile>=. </notextile>x                         |
+`
+	assert.Equal(t, expected, buf.String())
+
+	// The cells are read back as they were
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: &buf}, got))
+	assert.Equal(t, table.Rows, got.Rows)
+}
+
+func TestMarshalWithSpansAndAlignment(t *testing.T) {
+	// Merged cells and alignments are written as modifiers
+	table := &common.Table{
+		Headers: []string{"name", "n", "note"},
+		Rows:    [][]string{{"merged", "1", "a|b"}, {"", "22", "x"}, {"two\nlines", "3", ""}},
+		Spans:   []common.Span{{Row: 0, Col: 0, RowSpan: 2, ColSpan: 1}, {Row: 2, Col: 1, RowSpan: 1, ColSpan: 2}},
+	}
+	var buf bytes.Buffer
+	cfg := &common.Config{Writer: &buf, Extension: map[string]string{"align": "l,r"}}
+	err := Marshal(cfg, table)
+	assert.NoError(t, err)
+	expected := `|_. name         |_>. n |_. note   |
+|/2. merged      |>.  1 | a&#124;b |
+|>. 22 | x        |
+| two<br />lines |\2>.           3 |
+`
+	assert.Equal(t, expected, buf.String())
+
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: &buf}, got))
+	assert.Equal(t, table.Headers, got.Headers)
+	assert.Equal(t, table.Rows, got.Rows)
+	assert.Equal(t, table.Spans, got.Spans)
+}
+
+func TestUnmarshalErrorCases(t *testing.T) {
+	t.Run("nil table", func(t *testing.T) {
+		err := Unmarshal(&common.Config{Reader: strings.NewReader("|_. a |\n")}, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("no table", func(t *testing.T) {
+		err := Unmarshal(&common.Config{Reader: strings.NewReader("p. no table")}, &common.Table{})
+		assert.ErrorContains(t, err, "no table row")
+	})
+
+	t.Run("column span over the limit", func(t *testing.T) {
+		err := Unmarshal(&common.Config{Reader: strings.NewReader("|_. a |\n|\\1001. x |\n")}, &common.Table{})
+		assert.ErrorContains(t, err, "more than the limit of 1000")
+	})
+}
+
+func TestMarshalErrorCases(t *testing.T) {
+	t.Run("no columns", func(t *testing.T) {
+		err := Marshal(&common.Config{Writer: &bytes.Buffer{}}, &common.Table{})
+		assert.Error(t, err)
+	})
+
+	t.Run("short row", func(t *testing.T) {
+		table := &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1"}}}
+		err := Marshal(&common.Config{Writer: &bytes.Buffer{}}, table)
+		assert.ErrorContains(t, err, "row 0 has 1 columns")
+	})
+}
+
+func TestFixture(t *testing.T) {
+	data, err := os.ReadFile("../test/mysql.textile")
+	require.NoError(t, err)
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: bytes.NewReader(data)}, table))
+	assert.Empty(t, table.Name)
+	assert.Equal(t, []string{"FIELD", "TYPE", "NULL", "KEY", "DEFAULT", "EXTRA"}, table.Headers)
+	assert.Len(t, table.Rows, 3)
+	assert.Equal(t, []string{"username", "varchar(10)", "NO", "", "NULL", ""}, table.Rows[1])
+
+	// Header cells are written with "_.", the fixture has no caption
+	var buf bytes.Buffer
+	require.NoError(t, Marshal(&common.Config{Writer: &buf}, table))
+	assert.Equal(t, string(data), buf.String())
+}
+
+func TestDetect(t *testing.T) {
+	fixture, err := os.ReadFile("../test/mysql.textile")
+	require.NoError(t, err)
+	// Against the other wiki dialects imported above, "_." header cells, a table
+	// signature or span modifiers are Textile
+	inputs := []string{string(fixture), "table(grid).\n| a | b |", "| a | b |\n|\\2. wide |", "| a | b |\n|/2. tall | 1 |"}
+	for _, input := range inputs {
+		scores := common.ScoreFormats([]byte(input))
+		require.NotEmpty(t, scores, input)
+		assert.Equal(t, "textile", scores[0].Format, input)
+	}
+	// A span modifier alone could be cell text of another pipe table
+	assert.Greater(t, detect(inputs[1]), detect(inputs[2]))
+	assert.Equal(t, detect(inputs[2]), detect(inputs[3]))
+
+	// The other wiki dialects also write cells between pipes
+	for _, name := range []string{"mysql.md", "mysql.dokuwiki", "mysql.jira", "mysql.tracwiki", "mysql.twiki"} {
+		other, err := os.ReadFile("../test/" + name)
+		require.NoError(t, err)
+		assert.Equal(t, common.DetectNone, detect(string(other)), name)
+	}
+}
@@ -0,0 +1,18 @@
+package tmpl
+
+import (
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "tmpl",
+		Description: "Custom template (write-only)",
+		Aliases:     []string{"template"},
+		Extensions:  []string{".tmpl", ".template"},
+		Params: []common.FormatParam{
+			{Name: "template", DefaultValue: "", AllowedValues: "", Description: "Template file path"},
+		},
+		Marshal: Marshal,
+	})
+}
@@ -0,0 +1,84 @@
+package toml
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/BurntSushi/toml"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "toml",
+		Description: "TOML array of tables ([[records]] or an array of inline tables)",
+		Extensions:  []string{".toml"},
+		Detect:      detect,
+		DetectOrder: 13,
+		Params: []common.FormatParam{
+			{Name: "key", DefaultValue: defaultKey, AllowedValues: "string", Description: "Key of the array of tables, dotted for nested tables; without it the first array of tables is read"},
+			{Name: "inline", DefaultValue: "false", AllowedValues: "true, false", Description: "Write an array of inline tables instead of [[key]] tables", Use: common.ParamWrite},
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+		MarshalDocument:   MarshalDocument,
+	})
+}
+
+var (
+	// arrayTablePattern matches an array of tables header, e.g. [[records]]
+	arrayTablePattern = regexp.MustCompile(`^\s*\[\[\s*[\w."' -]+\s*\]\]\s*(#.*)?$`)
+	// tablePattern matches a table header, e.g. [server]
+	tablePattern = regexp.MustCompile(`^\s*\[\s*[\w."' -]+\s*\]\s*(#.*)?$`)
+	// pairPattern matches a key/value pair, e.g. name = "web01"
+	pairPattern = regexp.MustCompile(`^\s*[\w."'-]+\s*=\s*\S`)
+	// inlinePattern matches the start of an array of inline tables, e.g. records = [ {
+	inlinePattern = regexp.MustCompile(`^\s*[\w."'-]+\s*=\s*\[\s*(\{|$)`)
+	// inlineTablePattern matches the first pair of an inline table, e.g. { id = 1,
+	// which sets it apart from Python, Ruby and JavaScript literals using : or =>
+	inlineTablePattern = regexp.MustCompile(`\{\s*[\w."'-]+\s*=[^>]`)
+)
+
+// detect scores content made of TOML key/value pairs and table headers that
+// holds an array of tables. An array of inline tables looks like source code
+// literals, so it needs key = value pairs in braces and, when the sample holds
+// the whole input, to decode as TOML.
+func detect(sample string) int {
+	var lines []string
+	for _, line := range common.SampleLines(sample, 20) {
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			lines = append(lines, line)
+		}
+	}
+	for i, line := range lines {
+		next := ""
+		if i+1 < len(lines) {
+			next = lines[i+1]
+		}
+		switch {
+		case arrayTablePattern.MatchString(line):
+			// Wiki pages may also start with [[links]]
+			if pairPattern.MatchString(next) {
+				return 90
+			}
+			return common.DetectWeak
+		case inlinePattern.MatchString(line):
+			if !inlineTablePattern.MatchString(line + "\n" + next) {
+				return common.DetectNone
+			}
+			if len(sample) < common.DetectSampleSize {
+				var data map[string]interface{}
+				if _, err := toml.Decode(sample, &data); err != nil {
+					return common.DetectNone
+				}
+			}
+			return 80
+		case pairPattern.MatchString(line), tablePattern.MatchString(line):
+			continue
+		}
+		return common.DetectNone
+	}
+	return common.DetectNone
+}
@@ -0,0 +1,484 @@
+package toml
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/BurntSushi/toml"
+)
+
+// defaultKey is the key of the array of tables written, and read first if present
+const defaultKey = "records"
+
+// document is a decoded TOML file with the order of its keys
+type document struct {
+	cfg  *common.Config
+	data map[string]interface{}
+	keys []toml.Key
+}
+
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	doc, err := parse(cfg)
+	if err != nil || doc == nil {
+		return err
+	}
+
+	path, ok := keyPath(cfg)
+	if !ok {
+		// Without --key read the default key, or else the first array of tables
+		path = []string{defaultKey}
+		if _, found := doc.records(path); !found {
+			names := doc.arrays()
+			if len(names) == 0 {
+				return fmt.Errorf("no array of tables found in TOML input")
+			}
+			path = names[0]
+		}
+	}
+	records, found := doc.records(path)
+	if !found {
+		return fmt.Errorf("no array of tables named %q in TOML input", strings.Join(path, "."))
+	}
+	return doc.unmarshalTable(path, records, table)
+}
+
+// UnmarshalDocument reads every top-level array of tables as a table named
+// after its key, in document order. With --key only that array is read.
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	if _, ok := keyPath(cfg); ok {
+		table := &common.Table{}
+		if err := Unmarshal(cfg, table); err != nil {
+			return err
+		}
+		doc.Tables = append(doc.Tables, table)
+		return nil
+	}
+
+	parsed, err := parse(cfg)
+	if err != nil || parsed == nil {
+		return err
+	}
+	for _, path := range parsed.arrays() {
+		records, _ := parsed.records(path)
+		table := &common.Table{}
+		if err := parsed.unmarshalTable(path, records, table); err != nil {
+			return err
+		}
+		doc.Tables = append(doc.Tables, table)
+	}
+	return nil
+}
+
+// keyPath returns the dotted key option split into its parts, false if it is not set
+func keyPath(cfg *common.Config) ([]string, bool) {
+	key := strings.TrimSpace(cfg.GetExtensionString("key", ""))
+	if key == "" {
+		return nil, false
+	}
+	path := strings.Split(key, ".")
+	for i := range path {
+		path[i] = strings.Trim(strings.TrimSpace(path[i]), `"'`)
+	}
+	return path, true
+}
+
+// parse decodes cfg.Reader, nil if the input is empty
+func parse(cfg *common.Config) (*document, error) {
+	data, err := io.ReadAll(cfg.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return nil, nil
+	}
+	doc := &document{cfg: cfg}
+	meta, err := toml.Decode(string(data), &doc.data)
+	if err != nil {
+		return nil, err
+	}
+	doc.keys = meta.Keys()
+	return doc, nil
+}
+
+// records returns the tables of the array at path
+func (d *document) records(path []string) ([]map[string]interface{}, bool) {
+	var value interface{} = d.data
+	for _, part := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return tables(value)
+}
+
+// arrays returns the paths of the top-level arrays of tables in document order
+func (d *document) arrays() [][]string {
+	var paths [][]string
+	seen := make(map[string]bool)
+	for _, key := range d.keys {
+		// Each [[key]] header lists the key again
+		if len(key) != 1 || seen[key[0]] {
+			continue
+		}
+		seen[key[0]] = true
+		if _, ok := tables(d.data[key[0]]); ok {
+			paths = append(paths, []string{key[0]})
+		}
+	}
+	return paths
+}
+
+// tables converts an array of tables, [[key]] or inline, to its records.
+// An empty array is an array of tables without records.
+func tables(value interface{}) ([]map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case []map[string]interface{}:
+		return v, true
+	case []interface{}:
+		records := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			record, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			records[i] = record
+		}
+		return records, true
+	}
+	return nil, false
+}
+
+// unmarshalTable converts the records of the array at path to a table.
+// Columns keep the order of their first appearance; keys missing from a record are NULL.
+func (d *document) unmarshalTable(path []string, records []map[string]interface{}, table *common.Table) error {
+	table.Name = strings.Join(path, ".")
+	table.TrackNulls()
+
+	index := make(map[string]int)
+	addHeader := func(name string) {
+		if _, ok := index[name]; !ok {
+			index[name] = len(table.Headers)
+			table.Headers = append(table.Headers, name)
+		}
+	}
+	for _, key := range d.keys {
+		if len(key) == len(path)+1 && slices.Equal([]string(key[:len(path)]), path) {
+			addHeader(key[len(path)])
+		}
+	}
+	// Keys the metadata did not list, sorted for a stable order
+	var extra []string
+	for _, record := range records {
+		for name := range record {
+			if _, ok := index[name]; !ok {
+				extra = append(extra, name)
+			}
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		addHeader(name)
+	}
+
+	if err := d.cfg.CheckRow(0, table.Headers); err != nil {
+		return err
+	}
+	table.Columns = common.NewColumns(table.Headers)
+	for r, record := range records {
+		row := make([]string, len(table.Headers))
+		for i, header := range table.Headers {
+			value, ok := record[header]
+			if !ok {
+				row[i] = common.DefaultNullString
+				table.Columns[i].Nullable = true
+				table.SetNull(r, i)
+				continue
+			}
+			row[i] = cellValue(value, &table.Columns[i])
+		}
+		if err := d.cfg.CheckRow(r, row); err != nil {
+			return err
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	for i := range table.Columns {
+		if table.Columns[i].Type == common.ColumnTypeUnknown {
+			table.Columns[i].Type = common.ColumnTypeString
+		}
+	}
+	return nil
+}
+
+// cellValue converts a decoded TOML value to its cell text and records its type in column
+func cellValue(value interface{}, column *common.Column) string {
+	switch v := value.(type) {
+	case string:
+		column.Observe(common.ColumnTypeString, "string")
+		return v
+	case int64:
+		column.Observe(common.ColumnTypeInt, "integer")
+		return strconv.FormatInt(v, 10)
+	case float64:
+		column.Observe(common.ColumnTypeFloat, "float")
+		return formatFloat(v)
+	case bool:
+		column.Observe(common.ColumnTypeBool, "boolean")
+		return strconv.FormatBool(v)
+	case time.Time:
+		if v.Location().String() == "time-local" {
+			column.Observe(common.ColumnTypeString, "time")
+		} else {
+			column.Observe(common.ColumnTypeDate, "datetime")
+		}
+		return formatTime(v)
+	}
+	column.Observe(common.ColumnTypeString, "collection")
+	var sb strings.Builder
+	writeInline(&sb, value)
+	return sb.String()
+}
+
+// formatTime writes a datetime in the TOML form it was read in, local or with an offset
+func formatTime(t time.Time) string {
+	switch t.Location().String() {
+	case "date-local":
+		return t.Format("2006-01-02")
+	case "time-local":
+		return t.Format("15:04:05.999999999")
+	case "datetime-local":
+		return t.Format("2006-01-02T15:04:05.999999999")
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// formatFloat writes a float that TOML does not read back as an integer, e.g. 2.0
+func formatFloat(f float64) string {
+	var s string
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		s = strconv.FormatFloat(f, 'e', -1, 64)
+	} else {
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// writeInline writes a decoded value of a cell as an inline TOML value
+func writeInline(sb *strings.Builder, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		sb.WriteString("{")
+		for i, name := range names {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(quoteKey(name) + " = ")
+			writeInline(sb, v[name])
+		}
+		sb.WriteString("}")
+	case []map[string]interface{}:
+		items := make([]interface{}, len(v))
+		for i := range v {
+			items[i] = v[i]
+		}
+		writeInline(sb, items)
+	case []interface{}:
+		sb.WriteString("[")
+		for i, item := range v {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			writeInline(sb, item)
+		}
+		sb.WriteString("]")
+	case string:
+		sb.WriteString(quote(v))
+	case int64:
+		sb.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		sb.WriteString(formatFloat(v))
+	case bool:
+		sb.WriteString(strconv.FormatBool(v))
+	case time.Time:
+		sb.WriteString(formatTime(v))
+	default:
+		sb.WriteString(quote(fmt.Sprint(v)))
+	}
+}
+
+func Marshal(cfg *common.Config, table *common.Table) error {
+	path, ok := keyPath(cfg)
+	if !ok {
+		path = []string{defaultKey}
+	}
+	var sb strings.Builder
+	if err := writeTable(cfg, &sb, path, table); err != nil {
+		return err
+	}
+	_, err := io.WriteString(cfg.Writer, sb.String())
+	return err
+}
+
+// MarshalDocument writes one array of tables per table, keyed by the table name
+func MarshalDocument(cfg *common.Config, doc *common.Document) error {
+	names := doc.UniqueTableNames(nil)
+	var sb strings.Builder
+	for i, table := range doc.Tables {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		if err := writeTable(cfg, &sb, []string{names[i]}, table); err != nil {
+			return fmt.Errorf("table %q: %w", names[i], err)
+		}
+	}
+	_, err := io.WriteString(cfg.Writer, sb.String())
+	return err
+}
+
+// writeTable writes the rows of table as [[path]] tables, or as an array of
+// inline tables with the inline option. NULL cells are left out.
+func writeTable(cfg *common.Config, sb *strings.Builder, path []string, table *common.Table) error {
+	for _, row := range table.Rows {
+		if len(row) != len(table.Headers) {
+			return fmt.Errorf("row length %d does not match header length %d", len(row), len(table.Headers))
+		}
+	}
+	key := make([]string, len(path))
+	for i, part := range path {
+		key[i] = quoteKey(part)
+	}
+	name := strings.Join(key, ".")
+
+	if cfg.GetExtensionBool("inline", false) || len(table.Rows) == 0 {
+		sb.WriteString(name + " = [")
+		for r := range table.Rows {
+			sb.WriteString("\n  {")
+			first := true
+			for i, header := range table.Headers {
+				value, ok := valueText(table, r, i)
+				if !ok {
+					continue
+				}
+				if !first {
+					sb.WriteString(",")
+				}
+				first = false
+				sb.WriteString(" " + quoteKey(header) + " = " + value)
+			}
+			sb.WriteString(" },")
+		}
+		if len(table.Rows) > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("]\n")
+		return nil
+	}
+
+	for r := range table.Rows {
+		if r > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("[[" + name + "]]\n")
+		for i, header := range table.Headers {
+			if value, ok := valueText(table, r, i); ok {
+				sb.WriteString(quoteKey(header) + " = " + value + "\n")
+			}
+		}
+	}
+	return nil
+}
+
+// localDatetimePattern matches the dates and times TOML writes unquoted
+var localDatetimePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}([T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})?)?$`)
+
+// valueText returns the TOML value of a cell, typed through the column schema
+// or common.InferType, false for NULL cells
+func valueText(table *common.Table, r, i int) (string, bool) {
+	cell := table.Rows[r][i]
+	switch v := table.Value(r, i, true).(type) {
+	case nil:
+		if table.IsNull(r, i) {
+			return "", false
+		}
+		return quote(cell), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		// TOML reads nan and inf, but a cell spelled NaN or Infinity is more likely text
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return quote(cell), true
+		}
+		return formatFloat(v), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	if table.ColumnType(i) == common.ColumnTypeDate && localDatetimePattern.MatchString(cell) {
+		return cell, true
+	}
+	return quote(cell), true
+}
+
+// bareKeyPattern matches keys that need no quotes
+var bareKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// quoteKey returns key bare if TOML allows it, otherwise as a basic string
+func quoteKey(key string) string {
+	if bareKeyPattern.MatchString(key) {
+		return key
+	}
+	return quote(key)
+}
+
+// quote returns s as a TOML basic string
+func quote(s string) string {
+	s = strings.ToValidUTF8(s, string(utf8.RuneError))
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&sb, `\u%04X`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
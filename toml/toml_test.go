@@ -0,0 +1,186 @@
+package toml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const seed = `# seed data
+title = "fixtures"
+
+[[users]]
+id = 1
+name = "alice"
+score = 9.5
+joined = 2023-01-02
+
+[[users]]
+id = 2
+name = "bob"
+admin = true
+tags = ["a", "b"]
+
+[[roles]]
+role = "admin"
+`
+
+func unmarshal(t *testing.T, input string, params map[string]string) *common.Table {
+	t.Helper()
+	table := &common.Table{}
+	cfg := &common.Config{Reader: strings.NewReader(input), Extension: params}
+	require.NoError(t, Unmarshal(cfg, table))
+	return table
+}
+
+func marshal(t *testing.T, table *common.Table, params map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	cfg := &common.Config{Writer: &buf, Extension: params}
+	require.NoError(t, Marshal(cfg, table))
+	return buf.String()
+}
+
+func TestUnmarshalArrayOfTables(t *testing.T) {
+	// Without --key the first array of tables is read
+	table := unmarshal(t, seed, nil)
+	assert.Equal(t, "users", table.Name)
+	assert.Equal(t, []string{"id", "name", "score", "joined", "admin", "tags"}, table.Headers)
+	assert.Equal(t, []string{"1", "alice", "9.5", "2023-01-02", "NULL", "NULL"}, table.Rows[0])
+	assert.Equal(t, []string{"2", "bob", "NULL", "NULL", "true", `["a", "b"]`}, table.Rows[1])
+	// Missing keys are NULL
+	assert.True(t, table.IsNull(0, 4))
+	assert.False(t, table.IsNull(0, 0))
+
+	assert.Equal(t, common.ColumnTypeInt, table.Columns[0].Type)
+	assert.Equal(t, common.ColumnTypeFloat, table.Columns[2].Type)
+	assert.Equal(t, common.ColumnTypeDate, table.Columns[3].Type)
+	assert.Equal(t, common.ColumnTypeBool, table.Columns[4].Type)
+	assert.Equal(t, "collection", table.Columns[5].SourceType)
+	assert.True(t, table.Columns[2].Nullable)
+
+	table = unmarshal(t, seed, map[string]string{"key": "roles"})
+	assert.Equal(t, []string{"role"}, table.Headers)
+	assert.Equal(t, [][]string{{"admin"}}, table.Rows)
+}
+
+func TestUnmarshalInlineTables(t *testing.T) {
+	input := `records = [
+  { name = "web01", port = 8080 },
+  { name = "db01", weight = 2.0 },
+]
+`
+	table := unmarshal(t, input, nil)
+	assert.Equal(t, []string{"name", "port", "weight"}, table.Headers)
+	assert.Equal(t, []string{"db01", "NULL", "2.0"}, table.Rows[1])
+
+	// A dotted key reads a nested array
+	nested := "[data]\nrecords = [{ id = 1 }]\n"
+	table = unmarshal(t, nested, map[string]string{"key": "data.records"})
+	assert.Equal(t, "data.records", table.Name)
+	assert.Equal(t, [][]string{{"1"}}, table.Rows)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	cfg := &common.Config{Reader: strings.NewReader(seed), Extension: map[string]string{"key": "groups"}}
+	assert.ErrorContains(t, Unmarshal(cfg, &common.Table{}), `no array of tables named "groups"`)
+
+	cfg = &common.Config{Reader: strings.NewReader("title = \"x\"\n")}
+	assert.ErrorContains(t, Unmarshal(cfg, &common.Table{}), "no array of tables found")
+
+	cfg = &common.Config{Reader: strings.NewReader("[[records]\n")}
+	assert.Error(t, Unmarshal(cfg, &common.Table{}))
+
+	assert.Empty(t, unmarshal(t, "", nil).Headers)
+}
+
+func TestMarshal(t *testing.T) {
+	table := &common.Table{
+		Headers: []string{"id", "name", "ratio", "active", "zip code"},
+		Rows: [][]string{
+			{"1", `say "hi"`, "2.0", "TRUE", "NaN"},
+			{"2", "line\nbreak", "0.25", "false", "NULL"},
+		},
+	}
+	table.SetNull(1, 4)
+
+	expected := `[[records]]
+id = 1
+name = "say \"hi\""
+ratio = 2.0
+active = true
+"zip code" = "NaN"
+
+[[records]]
+id = 2
+name = "line\nbreak"
+ratio = 0.25
+active = false
+`
+	assert.Equal(t, expected, marshal(t, table, nil))
+
+	expected = `seed.users = [
+  { id = 1, name = "say \"hi\"", ratio = 2.0, active = true, "zip code" = "NaN" },
+  { id = 2, name = "line\nbreak", ratio = 0.25, active = false },
+]
+`
+	out := marshal(t, table, map[string]string{"key": "seed.users", "inline": "true"})
+	assert.Equal(t, expected, out)
+
+	again := unmarshal(t, out, map[string]string{"key": "seed.users"})
+	assert.Equal(t, table.Rows[1][:4], again.Rows[1][:4])
+	assert.True(t, again.IsNull(1, 4))
+
+	assert.Equal(t, "records = []\n", marshal(t, &common.Table{Headers: []string{"a"}}, nil))
+}
+
+func TestRoundTripTypes(t *testing.T) {
+	table := unmarshal(t, seed, nil)
+	out := marshal(t, table, map[string]string{"key": "users"})
+	assert.Contains(t, out, "joined = 2023-01-02\n")
+	assert.Contains(t, out, "score = 9.5\n")
+	assert.Equal(t, table.Rows, unmarshal(t, out, nil).Rows)
+}
+
+func TestDocument(t *testing.T) {
+	doc := &common.Document{}
+	require.NoError(t, UnmarshalDocument(&common.Config{Reader: strings.NewReader(seed)}, doc))
+	require.Len(t, doc.Tables, 2)
+	assert.Equal(t, "users", doc.Tables[0].Name)
+	assert.Equal(t, "roles", doc.Tables[1].Name)
+
+	var buf bytes.Buffer
+	doc.Tables = doc.Tables[1:]
+	doc.Tables[0].Name = "team roles"
+	require.NoError(t, MarshalDocument(&common.Config{Writer: &buf}, doc))
+	assert.Equal(t, "[[\"team roles\"]]\nrole = \"admin\"\n", buf.String())
+
+	// Repeated [[A]] blocks would be merged into one array, tables with the same name get unique keys
+	doc.Tables = []*common.Table{
+		{Name: "A", Headers: []string{"a"}, Rows: [][]string{{"1"}}},
+		{Name: "A", Headers: []string{"b"}, Rows: [][]string{{"2"}}},
+	}
+	buf.Reset()
+	require.NoError(t, MarshalDocument(&common.Config{Writer: &buf}, doc))
+	back := &common.Document{}
+	require.NoError(t, UnmarshalDocument(&common.Config{Reader: &buf}, back))
+	require.Len(t, back.Tables, 2)
+	assert.Equal(t, []string{"A", "A (2)"}, []string{back.Tables[0].Name, back.Tables[1].Name})
+	assert.Equal(t, []string{"b"}, back.Tables[1].Headers)
+	assert.Equal(t, [][]string{{"2"}}, back.Tables[1].Rows)
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, 90, detect(seed))
+	assert.Equal(t, 80, detect("records = [\n  { id = 1 },\n]\n"))
+	assert.Equal(t, common.DetectWeak, detect("[[Main Page]]\nSome text\n"))
+	assert.Equal(t, common.DetectNone, detect("data = [\n    {\"id\": 1, \"ok\": True},\n]\n"))
+	assert.Equal(t, common.DetectNone, detect("data = [\n  { \"id\" => 1 },\n]\n"))
+	assert.Equal(t, common.DetectNone, detect("records = [\n  { id = 1, id = 2 },\n]\n"))
+	assert.Equal(t, common.DetectNone, detect("name,age\nAlice,30\n"))
+}
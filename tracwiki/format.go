@@ -0,0 +1,42 @@
+package tracwiki
+
+import (
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "tracwiki",
+		Description: "Trac wiki table",
+		Detect:      detect,
+		DetectOrder: 21,
+		Params: []common.FormatParam{
+			{Name: "first-row-header", DefaultValue: "true", AllowedValues: "true, false", Description: "Write the first row as ||= header =|| cells, false writes it as a plain row", Use: common.ParamWrite},
+			common.AlignParam,
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+	})
+}
+
+// detect scores content whose rows all start with "||", more when the first holds "||=Header=||" cells.
+// Jira writes "||Header||" rows too, but its data rows start with a single "|".
+func detect(sample string) int {
+	lines := common.SampleLines(sample, 20)
+	if len(lines) == 0 {
+		return common.DetectNone
+	}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "||") || !(strings.HasSuffix(line, "||") || strings.HasSuffix(line, `\`)) {
+			return common.DetectNone
+		}
+	}
+	if strings.HasPrefix(strings.TrimSpace(lines[0]), "||=") {
+		return 90
+	}
+	return 70
+}
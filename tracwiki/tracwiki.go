@@ -0,0 +1,248 @@
+package tracwiki
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// lineBreak is the macro Trac renders as a line break
+const lineBreak = "[[BR]]"
+
+// escaper writes "||" and "[[BR]]" in a cell after a "!" and line breaks as [[BR]]
+var escaper = strings.NewReplacer("||", "!||", lineBreak, "!"+lineBreak, "\r\n", lineBreak, "\n", lineBreak)
+
+// separator returns the length of the "||" cell separator s starts with and its
+// number of "||" pairs, 0 if none. A "=" before it ends a header cell and a "="
+// after it starts one.
+func separator(s string) (int, int) {
+	n, pairs := 0, 0
+	if strings.HasPrefix(s, "=||") {
+		n = 1
+	}
+	for strings.HasPrefix(s[n:], "||") {
+		n += 2
+		pairs++
+	}
+	if pairs == 0 {
+		return 0, 0
+	}
+	if strings.HasPrefix(s[n:], "=") {
+		n++
+	}
+	return n, pairs
+}
+
+// splitRow splits a row into its cells. A cell after n "||" pairs spans n columns,
+// a "!" before a separator or [[BR]] writes it as text.
+func splitRow(line string) []common.SpanCell {
+	var cells []common.SpanCell
+	var value strings.Builder
+	pairs := 0
+	endCell := func() {
+		if pairs > 0 {
+			lines := strings.Split(value.String(), "\n")
+			for i, line := range lines {
+				lines[i] = strings.TrimSpace(line)
+			}
+			cells = append(cells, common.SpanCell{Value: strings.TrimSpace(strings.Join(lines, "\n")), ColSpan: pairs})
+		}
+		value.Reset()
+	}
+
+	for i := 0; i < len(line); {
+		rest := line[i:]
+		if strings.HasPrefix(rest, "!") {
+			if n, _ := separator(rest[1:]); n > 0 {
+				value.WriteString(rest[1 : n+1])
+				i += n + 1
+				continue
+			}
+			if len(rest) > len(lineBreak) && strings.EqualFold(rest[1:len(lineBreak)+1], lineBreak) {
+				value.WriteString(rest[1 : len(lineBreak)+1])
+				i += len(lineBreak) + 1
+				continue
+			}
+		}
+		if len(rest) >= len(lineBreak) && strings.EqualFold(rest[:len(lineBreak)], lineBreak) {
+			value.WriteString("\n")
+			i += len(lineBreak)
+			continue
+		}
+		if n, p := separator(rest); n > 0 {
+			endCell()
+			pairs = p
+			i += n
+			continue
+		}
+		value.WriteByte(line[i])
+		i++
+	}
+	// Text after the last separator
+	if strings.TrimSpace(value.String()) != "" {
+		endCell()
+	}
+	return cells
+}
+
+// readTable reads the next table of the input, its first row being the header
+// whether or not its cells are "||=Header=||" cells. A row ending with "||\" goes
+// on over the next line. Alignment given by spaces is not kept.
+func readTable(cfg *common.Config, scanner *bufio.Scanner, lineNumber *int) (*common.Table, error) {
+	var rows [][]common.SpanCell
+	pending := ""
+	for scanner.Scan() {
+		*lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "||") {
+			if len(rows) > 0 || pending != "" {
+				break
+			}
+			continue
+		}
+		line = pending + line
+		if head := strings.TrimSpace(strings.TrimSuffix(line, `\`)); head != line && strings.HasSuffix(head, "||") {
+			// The next line starts with the separator of the next cell
+			pending = strings.TrimSuffix(head, "||")
+			continue
+		}
+		pending = ""
+		cells := splitRow(line)
+		if len(cells) == 0 {
+			return nil, &common.ParseError{LineNumber: *lineNumber, Message: "row contains no cells", Line: line}
+		}
+		// The first row is the header
+		if err := cfg.CheckSpanRow(len(rows)-1, cells); err != nil {
+			return nil, err
+		}
+		rows = append(rows, cells)
+	}
+	if pending != "" {
+		if cells := splitRow(pending + "||"); len(cells) > 0 {
+			rows = append(rows, cells)
+		}
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	grid, spans, _, err := common.LayoutSpans(rows)
+	if err != nil {
+		return nil, err
+	}
+	table := &common.Table{}
+	table.SetGrid(grid, spans)
+	return table, nil
+}
+
+// Unmarshal reads the first table of the input
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Unmarshal: target table pointer cannot be nil")
+	}
+	tables, err := common.ScanTables(cfg, 1, readTable)
+	if err != nil {
+		return err
+	}
+	*table = *tables[0]
+	return nil
+}
+
+// UnmarshalDocument reads every table of the input, tables being separated by other lines
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	tables, err := common.ScanTables(cfg, 0, readTable)
+	if err != nil {
+		return err
+	}
+	doc.Tables = append(doc.Tables, tables...)
+	return nil
+}
+
+// pad fills a cell to width display columns. Trac aligns a cell left when it ends
+// with a space only, right when it starts with a space only, and centers it when
+// it starts with two spaces and ends with one. Header cells are written after a "=".
+func pad(s string, width int, align string, header bool) string {
+	if align == "l" && !header {
+		return " " + common.PadAlign(s, width-1, align)
+	}
+	return common.PadAlign(s, width, align)
+}
+
+// Marshal writes a "||=header=||" row followed by "|| cell ||" rows, padding cells to
+// the alignment of their column. A merged cell is written after one "||" for each
+// column it covers, Trac tables have no row spans so cells below it are left empty.
+func Marshal(cfg *common.Config, table *common.Table) error {
+	if table == nil {
+		return fmt.Errorf("Marshal: input table pointer cannot be nil")
+	}
+	if len(table.Headers) == 0 {
+		return fmt.Errorf("Marshal: table must have at least one header")
+	}
+	for i, row := range table.Rows {
+		if len(row) != len(table.Headers) {
+			return fmt.Errorf("Marshal: row %d has %d columns, but table has %d", i, len(row), len(table.Headers))
+		}
+	}
+
+	header := cfg.GetExtensionBool("first-row-header", true)
+	aligns := cfg.ColumnAligns(len(table.Headers))
+
+	layout := table.SpanLayout()
+	rows := append([][]string{table.Headers}, table.Rows...)
+	cells := make([][]string, len(rows))
+	widths := make([]int, len(table.Headers))
+	for r, row := range rows {
+		cells[r] = make([]string, len(row))
+		for col, value := range row {
+			if layout.Covered(r-1, col) {
+				continue
+			}
+			value = escaper.Replace(value)
+			if strings.HasSuffix(value, "!") || strings.HasSuffix(value, "=") || strings.HasSuffix(value, "|") {
+				// Would be read as part of the separator after it
+				value += " "
+			}
+			cells[r][col] = value
+			if span, ok := layout.At(r-1, col); !ok || span.ColSpan == 1 {
+				widths[col] = max(widths[col], runewidth.StringWidth(value))
+			}
+		}
+	}
+	// Header cells take two columns for their "=" signs, centered cells need two spaces on each side
+	slots := make([]int, len(widths))
+	for i, width := range widths {
+		slots[i] = width + map[string]int{"l": 2, "r": 3, "c": 6}[aligns[i]]
+	}
+
+	var b strings.Builder
+	for r, row := range cells {
+		th := r == 0 && header
+		for col := 0; col < len(row); col++ {
+			if span, ok := layout.CoveredBy(r-1, col); ok && span.Row == r-1 {
+				// Written with the separators of the merged cell
+				continue
+			}
+			n := 1
+			if span, ok := layout.At(r-1, col); ok {
+				n = span.ColSpan
+			}
+			width := 0
+			for _, slot := range slots[col : col+n] {
+				width += slot
+			}
+			b.WriteString(strings.Repeat("||", n))
+			if th {
+				b.WriteString("=" + pad(row[col], width-2, aligns[col], true) + "=")
+			} else {
+				b.WriteString(pad(row[col], width, aligns[col], false))
+			}
+		}
+		b.WriteString("||\n")
+	}
+	_, err := io.WriteString(cfg.Writer, b.String())
+	return err
+}
@@ -0,0 +1,233 @@
+package tracwiki
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	// The other wiki dialects, scored against this one by TestDetect
+	_ "github.com/martianzhang/tableconvert/bbcode"
+	_ "github.com/martianzhang/tableconvert/dokuwiki"
+	_ "github.com/martianzhang/tableconvert/jira"
+	_ "github.com/martianzhang/tableconvert/markdown"
+	_ "github.com/martianzhang/tableconvert/mediawiki"
+	_ "github.com/martianzhang/tableconvert/textile"
+	_ "github.com/martianzhang/tableconvert/twiki"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalPage(t *testing.T) {
+	// Shaped like a table of a Trac wiki page: "!" escapes, [[BR]] line breaks,
+	// a row continued with "\" and a leading "||||" column span
+	input := `== Servers ==
+||= Host =||= Role =||= Notes =||
+||web1||  web  ||a !|| b||
+||db1   ||   db||first[[BR]]second ||\
+|| ![[BR]] ||
+||||  spans two columns  || x||
+
+Text below the table
+`
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	// The continued row is wider than the header, which gets an empty column
+	assert.Equal(t, []string{"Host", "Role", "Notes", ""}, table.Headers)
+	assert.Equal(t, [][]string{
+		{"web1", "web", "a || b", ""},
+		{"db1", "db", "first\nsecond", "[[BR]]"},
+		{"spans two columns", "", "x", ""},
+	}, table.Rows)
+	assert.Equal(t, []common.Span{{Row: 2, Col: 0, RowSpan: 1, ColSpan: 2}}, table.Spans)
+}
+
+func TestUnmarshalDocument(t *testing.T) {
+	// Tables are separated by other lines, each is as wide as its own rows
+	input := "== Servers ==\n||= Host =||= Role =||\n|| web1 || web ||\n\n== Owners ==\n||= Name =||\n|| ops ||\n|| dev ||\n"
+	var doc common.Document
+	err := UnmarshalDocument(&common.Config{Reader: strings.NewReader(input)}, &doc)
+	assert.NoError(t, err)
+	require.Len(t, doc.Tables, 2)
+	assert.Equal(t, []string{"Host", "Role"}, doc.Tables[0].Headers)
+	assert.Equal(t, [][]string{{"web1", "web"}}, doc.Tables[0].Rows)
+	assert.Equal(t, []string{"Name"}, doc.Tables[1].Headers)
+	assert.Equal(t, [][]string{{"ops"}, {"dev"}}, doc.Tables[1].Rows)
+
+	// Unmarshal reads the first table only
+	table := &common.Table{}
+	err = Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, doc.Tables[0].Rows, table.Rows)
+}
+
+func TestUnmarshalWithRowHeadersAndAlignment(t *testing.T) {
+	// "=" marks a header cell in any row, the padding that aligns a cell is not text
+	input := "||   ||= Q1 =||= Q2 =||\n||= north =||  right||  center ||\n"
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "Q1", "Q2"}, table.Headers)
+	assert.Equal(t, [][]string{{"north", "right", "center"}}, table.Rows)
+}
+
+func TestUnmarshalWithContinuedRows(t *testing.T) {
+	// A row ending with "\" continues on the next line
+	input := "||= a =||= b =||= c =||\n|| 1 ||\\\n|| 2 ||\\\n|| 3 ||\n"
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, table.Headers)
+	assert.Equal(t, [][]string{{"1", "2", "3"}}, table.Rows)
+}
+
+func TestUnmarshalWithSpanWiderThanHeader(t *testing.T) {
+	input := "||= a =||\n|||| x ||\n"
+	table := &common.Table{}
+	err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", ""}, table.Headers)
+	assert.Equal(t, [][]string{{"x", ""}}, table.Rows)
+	assert.Equal(t, []common.Span{{Row: 0, Col: 0, RowSpan: 1, ColSpan: 2}}, table.Spans)
+}
+
+func TestMarshalWithSpecialCharacters(t *testing.T) {
+	// "!" escapes a separator or a macro, a "!", "=" or "|" elsewhere is text
+	table := &common.Table{
+		Headers: []string{"case", "cell"},
+		Rows: [][]string{
+			{"separator", "a||b"},
+			{"escaped separator", "!||"},
+			{"line break macro", "[[BR]]"},
+			{"line break", "two\nlines"},
+			{"exclamation", "a!"},
+			{"equals", "=x="},
+			{"single pipe", "a|b"},
+		},
+	}
+	var buf bytes.Buffer
+	err := Marshal(&common.Config{Writer: &buf}, table)
+	assert.NoError(t, err)
+	expected := `||=case             =||=cell          =||
+|| separator         || a!||b          ||
+|| escaped separator || !!||           ||
+|| line break macro  || ![[BR]]        ||
+|| line break        || two[[BR]]lines ||
+|| exclamation       || a!             ||
+|| equals            || =x=            ||
+|| single pipe       || a|b            ||
+`
+	assert.Equal(t, expected, buf.String())
+
+	// The cells are read back as they were
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: &buf}, got))
+	assert.Equal(t, table.Rows, got.Rows)
+}
+
+func TestMarshalWithSpansAndAlignment(t *testing.T) {
+	// Column spans are written as leading "||" pairs, row spans are not kept
+	table := &common.Table{
+		Headers: []string{"name", "n", "note"},
+		Rows:    [][]string{{"merged", "1", "a||b"}, {"", "22", "x="}, {"two\nlines", "3", ""}},
+		Spans:   []common.Span{{Row: 0, Col: 0, RowSpan: 2, ColSpan: 1}, {Row: 2, Col: 1, RowSpan: 1, ColSpan: 2}},
+	}
+	var buf bytes.Buffer
+	cfg := &common.Config{Writer: &buf, Extension: map[string]string{"align": "l,r,c"}}
+	err := Marshal(cfg, table)
+	assert.NoError(t, err)
+	expected := `||=name          =||=  n=||=  note   =||
+|| merged         ||    1||   a!||b   ||
+||                ||   22||    x=     ||
+|| two[[BR]]lines ||||               3||
+`
+	assert.Equal(t, expected, buf.String())
+
+	got := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: &buf}, got))
+	assert.Equal(t, table.Headers, got.Headers)
+	assert.Equal(t, table.Rows, got.Rows)
+	assert.Equal(t, []common.Span{{Row: 2, Col: 1, RowSpan: 1, ColSpan: 2}}, got.Spans)
+}
+
+func TestMarshalWithoutHeaderCells(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := &common.Config{Writer: &buf, Extension: map[string]string{"first-row-header": "false"}}
+	err := Marshal(cfg, &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1", "2"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "|| a || b ||\n|| 1 || 2 ||\n", buf.String())
+}
+
+func TestUnmarshalErrorCases(t *testing.T) {
+	t.Run("nil table", func(t *testing.T) {
+		err := Unmarshal(&common.Config{Reader: strings.NewReader("||= a =||\n")}, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("no table", func(t *testing.T) {
+		err := Unmarshal(&common.Config{Reader: strings.NewReader("no table")}, &common.Table{})
+		assert.ErrorContains(t, err, "no table row")
+	})
+
+	t.Run("column span over the limit", func(t *testing.T) {
+		input := "||= a =||\n" + strings.Repeat("||", 1001) + " x ||\n"
+		err := Unmarshal(&common.Config{Reader: strings.NewReader(input)}, &common.Table{})
+		assert.ErrorContains(t, err, "more than the limit of 1000")
+	})
+}
+
+func TestMarshalErrorCases(t *testing.T) {
+	t.Run("no columns", func(t *testing.T) {
+		err := Marshal(&common.Config{Writer: &bytes.Buffer{}}, &common.Table{})
+		assert.Error(t, err)
+	})
+
+	t.Run("short row", func(t *testing.T) {
+		table := &common.Table{Headers: []string{"a", "b"}, Rows: [][]string{{"1"}}}
+		err := Marshal(&common.Config{Writer: &bytes.Buffer{}}, table)
+		assert.ErrorContains(t, err, "row 0 has 1 columns")
+	})
+}
+
+func TestFixture(t *testing.T) {
+	data, err := os.ReadFile("../test/mysql.tracwiki")
+	require.NoError(t, err)
+	table := &common.Table{}
+	require.NoError(t, Unmarshal(&common.Config{Reader: bytes.NewReader(data)}, table))
+	assert.Equal(t, []string{"FIELD", "TYPE", "NULL", "KEY", "DEFAULT", "EXTRA"}, table.Headers)
+	assert.Len(t, table.Rows, 3)
+	assert.Equal(t, []string{"username", "varchar(10)", "NO", "", "NULL", ""}, table.Rows[1])
+
+	// Header cells are written as "||=name=||" cells, padded like the data rows
+	var buf bytes.Buffer
+	require.NoError(t, Marshal(&common.Config{Writer: &buf}, table))
+	assert.Equal(t, string(data), buf.String())
+}
+
+func TestDetect(t *testing.T) {
+	fixture, err := os.ReadFile("../test/mysql.tracwiki")
+	require.NoError(t, err)
+	// Against the other wiki dialects imported above, rows of "||" cells are
+	// TracWiki, continued with "\" or not
+	inputs := []string{string(fixture), "||= a =||= b =||\n|| 1 ||\\\n|| 2 ||", "||a||b||\n||1||2||"}
+	for _, input := range inputs {
+		scores := common.ScoreFormats([]byte(input))
+		require.NotEmpty(t, scores, input)
+		assert.Equal(t, "tracwiki", scores[0].Format, input)
+	}
+	// "=" header cells are surer than plain "||" rows
+	assert.Greater(t, detect(inputs[1]), detect(inputs[2]))
+	// A "||" line followed by text is not a table
+	assert.Equal(t, common.DetectNone, detect("||a||b||\ntext"))
+
+	// The other wiki dialects also write cells between pipes, Jira header rows with "||" too
+	for _, name := range []string{"mysql.jira", "mysql.twiki", "mysql.dokuwiki", "mysql.textile"} {
+		other, err := os.ReadFile("../test/" + name)
+		require.NoError(t, err)
+		assert.Equal(t, common.DetectNone, detect(string(other)), name)
+	}
+}
@@ -0,0 +1,42 @@
+package twiki
+
+import (
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "twiki",
+		Description: "TWiki table",
+		Extensions:  []string{".twiki"},
+		Detect:      detect,
+		DetectOrder: 11,
+		Params: []common.FormatParam{
+			{Name: "first-row-header", DefaultValue: "false", AllowedValues: "true, false", Description: "Use first row as headers"},
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+	})
+}
+
+// detect scores content with "|=Header=|" rows, or rows of "|" separated cells
+func detect(sample string) int {
+	lines := common.SampleLines(sample, 20)
+	if len(lines) == 0 {
+		return common.DetectNone
+	}
+	first := strings.TrimSpace(lines[0])
+	if strings.HasPrefix(first, "|=") {
+		return 85
+	}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "|") || !strings.HasSuffix(line, "|") {
+			return common.DetectNone
+		}
+	}
+	return 40
+}
@@ -18,12 +18,36 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 	table.Headers = nil
 	table.Rows = nil
 
+	tables, err := parse(cfg, 1)
+	if err != nil || len(tables) == 0 {
+		return err
+	}
+	table.Headers, table.Rows = tables[0].Headers, tables[0].Rows
+	return nil
+}
+
+// UnmarshalDocument parses every TWiki table of the input. A table ends at a
+// line of text, the next one starts at the following "|=Header=|" line.
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	tables, err := parse(cfg, 0)
+	if err != nil {
+		return err
+	}
+	doc.Tables = append(doc.Tables, tables...)
+	return nil
+}
+
+// parse reads up to limit tables of the input, all of them when limit is 0.
+// The first "|" line must be a header row, "|" lines after the end of a table
+// that are not header rows are skipped.
+func parse(cfg *common.Config, limit int) ([]*common.Table, error) {
 	scanner := bufio.NewScanner(cfg.Reader)
 	lineNumber := 0
-	foundHeader := false
+	var tables []*common.Table
+	var table *common.Table
 	headerCount := 0
 
-	for scanner.Scan() {
+	for scanner.Scan() && (limit == 0 || len(tables) < limit) {
 		lineNumber++
 		line := scanner.Text()
 		trimmedLine := strings.TrimSpace(line)
@@ -33,64 +57,70 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 			continue
 		}
 
+		// --- State Machine: Header -> Data Rows ---
+		if table != nil {
+			// --- Expecting Data Row ---
+			// Lines that don't start with '|' (potential non-table content) end the table
+			cells, parseErr := parseLine(trimmedLine, lineNumber)
+			if parseErr == nil {
+				if len(cells) != headerCount {
+					return nil, &common.ParseError{
+						LineNumber: lineNumber,
+						Message:    fmt.Sprintf("data row has %d columns, but header has %d", len(cells), headerCount),
+						Line:       line,
+					}
+				}
+				if err := cfg.CheckRow(len(table.Rows), cells); err != nil {
+					return nil, err
+				}
+				table.Rows = append(table.Rows, cells)
+				continue
+			}
+			// If parseLine fails, treat it as end of table data
+			tables = append(tables, table)
+			table = nil
+			continue
+		}
+
 		// Skip lines that don't start with '|' (potential non-table content)
 		if !strings.HasPrefix(trimmedLine, "|") {
-			// If we've already found the header, treat this as end of table
-			if foundHeader {
-				break
-			}
 			continue
 		}
 
-		// --- State Machine: Header -> Data Rows ---
-		if !foundHeader {
-			// --- Expecting Header ---
-			cells, err := parseTWikiHeaderLine(trimmedLine, lineNumber)
-			if err != nil {
-				return err
-			}
-			if len(cells) == 0 {
-				return &common.ParseError{
-					LineNumber: lineNumber,
-					Message:    "header line contains no columns",
-					Line:       line,
-				}
-			}
-			table.Headers = cells
-			headerCount = len(cells)
-			foundHeader = true
-		} else {
-			// --- Expecting Data Row ---
-			cells, parseErr := parseLine(trimmedLine, lineNumber)
-			if parseErr != nil {
-				// If parseLine fails, treat it as end of table data
-				break
+		// --- Expecting Header ---
+		cells, err := parseTWikiHeaderLine(trimmedLine, lineNumber)
+		if err == nil && len(cells) == 0 {
+			err = &common.ParseError{
+				LineNumber: lineNumber,
+				Message:    "header line contains no columns",
+				Line:       line,
 			}
-			if len(cells) != headerCount {
-				return &common.ParseError{
-					LineNumber: lineNumber,
-					Message:    fmt.Sprintf("data row has %d columns, but header has %d", len(cells), headerCount),
-					Line:       line,
-				}
+		}
+		if err != nil {
+			// Rows between tables are not a table
+			if len(tables) > 0 {
+				continue
 			}
-			table.Rows = append(table.Rows, cells)
+			return nil, err
 		}
+		table = &common.Table{Headers: cells}
+		headerCount = len(cells)
 	}
 
 	// Check for scanning errors
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading input: %w", err)
+		return nil, fmt.Errorf("error reading input: %w", err)
+	}
+	if table != nil {
+		tables = append(tables, table)
 	}
 
 	// Final validation
-	if !foundHeader {
-		if lineNumber > 0 {
-			return fmt.Errorf("parsing failed: no valid header row found in input")
-		}
-		return nil
+	if len(tables) == 0 && lineNumber > 0 {
+		return nil, fmt.Errorf("parsing failed: no valid header row found in input")
 	}
 
-	return nil
+	return tables, nil
 }
 
 // parseTWikiHeaderLine parses a TWiki table header line (e.g. "|=Header1|=Header2=|")
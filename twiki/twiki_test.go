@@ -311,3 +311,22 @@ func TestRoundTrip(t *testing.T) {
 	assert.Equal(t, table.Headers, table2.Headers)
 	assert.Equal(t, table.Rows, table2.Rows)
 }
+
+func TestUnmarshalDocument(t *testing.T) {
+	// Tables are separated by text, rows between them that are not a header row are skipped
+	input := "|=Name=|=Age=|\n|Alice|30|\nSome text\n|not|a header|\n|=City=|\n|Paris|\n"
+	var doc common.Document
+	err := UnmarshalDocument(&common.Config{Reader: bytes.NewBufferString(input)}, &doc)
+	assert.NoError(t, err)
+	require.Len(t, doc.Tables, 2)
+	assert.Equal(t, []string{"Name", "Age"}, doc.Tables[0].Headers)
+	assert.Equal(t, [][]string{{"Alice", "30"}}, doc.Tables[0].Rows)
+	assert.Equal(t, []string{"City"}, doc.Tables[1].Headers)
+	assert.Equal(t, [][]string{{"Paris"}}, doc.Tables[1].Rows)
+
+	// Unmarshal reads the first table only
+	table := &common.Table{}
+	err = Unmarshal(&common.Config{Reader: bytes.NewBufferString(input)}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, doc.Tables[0].Rows, table.Rows)
+}
@@ -0,0 +1,37 @@
+package xml
+
+import (
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "xml",
+		Description: "XML",
+		Extensions:  []string{".xml"},
+		Detect:      detect,
+		DetectOrder: 8,
+		Params: []common.FormatParam{
+			{Name: "minify", DefaultValue: "false", AllowedValues: "true, false", Description: "Minify XML"},
+			{Name: "root-element", DefaultValue: "dataset", AllowedValues: "string", Description: "Root Element Tag"},
+			{Name: "row-element", DefaultValue: "record", AllowedValues: "string", Description: "Row Element Tag"},
+			{Name: "declaration", DefaultValue: "true", AllowedValues: "true, false", Description: "Include XML Declaration"},
+		},
+		Unmarshal: Unmarshal,
+		Marshal:   Marshal,
+	})
+}
+
+// detect scores content that looks like XML
+func detect(sample string) int {
+	trimmed := strings.TrimSpace(sample)
+	switch {
+	case strings.HasPrefix(trimmed, "<?xml"):
+		return 90
+	case strings.HasPrefix(trimmed, "<") && strings.Contains(trimmed, "</"):
+		return 50
+	}
+	return common.DetectNone
+}
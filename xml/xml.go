@@ -8,6 +8,33 @@ import (
 	"github.com/martianzhang/tableconvert/common"
 )
 
+const (
+	xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+	xsNamespace  = "http://www.w3.org/2001/XMLSchema"
+)
+
+// xsdTypes maps column types to the XML Schema types written in xsi:type attributes
+var xsdTypes = map[common.ColumnType]string{
+	common.ColumnTypeInt:   "xs:integer",
+	common.ColumnTypeFloat: "xs:double",
+	common.ColumnTypeBool:  "xs:boolean",
+	common.ColumnTypeDate:  "xs:date",
+}
+
+// columnTypes maps xsi:type attribute values back to column types
+var columnTypes = map[string]common.ColumnType{
+	"xs:integer":  common.ColumnTypeInt,
+	"xs:int":      common.ColumnTypeInt,
+	"xs:long":     common.ColumnTypeInt,
+	"xs:double":   common.ColumnTypeFloat,
+	"xs:float":    common.ColumnTypeFloat,
+	"xs:decimal":  common.ColumnTypeFloat,
+	"xs:boolean":  common.ColumnTypeBool,
+	"xs:date":     common.ColumnTypeDate,
+	"xs:dateTime": common.ColumnTypeDate,
+	"xs:string":   common.ColumnTypeString,
+}
+
 // Unmarshal parses XML data from the reader and populates the table
 func Unmarshal(cfg *common.Config, table *common.Table) error {
 	if cfg == nil {
@@ -30,7 +57,8 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 	// Define the structure for dynamic XML parsing
 	type GenericField struct {
 		XMLName xml.Name
-		Value   string `xml:",chardata"`
+		Attrs   []xml.Attr `xml:",any,attr"`
+		Value   string     `xml:",chardata"`
 	}
 
 	type GenericRow struct {
@@ -61,9 +89,15 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 
 	// Extract all row data and validate column counts
 	headerCount := len(table.Headers)
+	table.TrackNulls()
 	for rowIdx, row := range root.Rows {
 		var dataRow []string
-		for _, field := range row.Fields {
+		for i, field := range row.Fields {
+			if isNil(field.Attrs) {
+				table.SetNull(rowIdx, i)
+				dataRow = append(dataRow, common.DefaultNullString)
+				continue
+			}
 			dataRow = append(dataRow, field.Value)
 		}
 
@@ -75,13 +109,56 @@ func Unmarshal(cfg *common.Config, table *common.Table) error {
 				Line:       fmt.Sprintf("row %d", rowIdx+1),
 			}
 		}
+		if err := cfg.CheckRow(rowIdx, dataRow); err != nil {
+			return err
+		}
 
 		table.Rows = append(table.Rows, dataRow)
 	}
 
+	fieldAttrs := make([][]xml.Attr, len(root.Rows[0].Fields))
+	for i, field := range root.Rows[0].Fields {
+		fieldAttrs[i] = field.Attrs
+	}
+	table.Columns = readColumnTypes(table.Headers, fieldAttrs)
+
 	return nil
 }
 
+// isNil reports whether the element is marked xsi:nil="true"
+func isNil(attrs []xml.Attr) bool {
+	for _, attr := range attrs {
+		if attr.Name.Local == "nil" && (attr.Name.Space == xsiNamespace || attr.Name.Space == "xsi") {
+			return attr.Value == "true" || attr.Value == "1"
+		}
+	}
+	return false
+}
+
+// readColumnTypes builds a column schema from xsi:type attributes of the first row.
+// It returns nil when the document carries no type information.
+func readColumnTypes(headers []string, fieldAttrs [][]xml.Attr) []common.Column {
+	columns := common.NewColumns(headers)
+	typed := false
+	for i, attrs := range fieldAttrs {
+		columns[i].Type = common.ColumnTypeString
+		for _, attr := range attrs {
+			if attr.Name.Local != "type" || (attr.Name.Space != xsiNamespace && attr.Name.Space != "xsi") {
+				continue
+			}
+			if t, ok := columnTypes[attr.Value]; ok {
+				columns[i].Type = t
+				columns[i].SourceType = attr.Value
+				typed = true
+			}
+		}
+	}
+	if !typed {
+		return nil
+	}
+	return columns
+}
+
 // Marshal converts table data to XML format
 func Marshal(cfg *common.Config, table *common.Table) error {
 	if cfg == nil {
@@ -113,8 +190,9 @@ func Marshal(cfg *common.Config, table *common.Table) error {
 
 	// Define the element structure
 	type Cell struct {
-		XMLName xml.Name `xml:""`
-		Value   string   `xml:",chardata"`
+		XMLName xml.Name   `xml:""`
+		Attrs   []xml.Attr `xml:",any,attr"`
+		Value   string     `xml:",chardata"`
 	}
 
 	type Row struct {
@@ -123,14 +201,38 @@ func Marshal(cfg *common.Config, table *common.Table) error {
 	}
 
 	type Root struct {
-		XMLName xml.Name `xml:""`
-		Rows    []Row    `xml:""`
+		XMLName xml.Name   `xml:""`
+		Attrs   []xml.Attr `xml:",any,attr"`
+		Rows    []Row      `xml:""`
 	}
 
 	root := Root{
 		XMLName: xml.Name{Local: rootElement},
 	}
 
+	// Typed columns are annotated with xsi:type attributes
+	cellAttrs := make([][]xml.Attr, len(table.Headers))
+	for i := range table.Headers {
+		if xsdType, ok := xsdTypes[table.ColumnType(i)]; ok {
+			cellAttrs[i] = []xml.Attr{{Name: xml.Name{Local: "xsi:type"}, Value: xsdType}}
+		}
+	}
+	for _, attrs := range cellAttrs {
+		if attrs != nil {
+			root.Attrs = []xml.Attr{
+				{Name: xml.Name{Local: "xmlns:xsi"}, Value: xsiNamespace},
+				{Name: xml.Name{Local: "xmlns:xs"}, Value: xsNamespace},
+			}
+			break
+		}
+	}
+
+	// NULL cells are written as empty elements marked xsi:nil="true"
+	nilAttr := xml.Attr{Name: xml.Name{Local: "xsi:nil"}, Value: "true"}
+	if root.Attrs == nil && hasNulls(table) {
+		root.Attrs = []xml.Attr{{Name: xml.Name{Local: "xmlns:xsi"}, Value: xsiNamespace}}
+	}
+
 	// Build the data structure
 	for rowIdx, row := range table.Rows {
 		r := Row{
@@ -154,8 +256,15 @@ func Marshal(cfg *common.Config, table *common.Table) error {
 				return fmt.Errorf("invalid XML element name for header '%s' at index %d", header, i)
 			}
 
+			if table.IsNull(rowIdx, i) {
+				attrs := append(append([]xml.Attr{}, cellAttrs[i]...), nilAttr)
+				r.Cells = append(r.Cells, Cell{XMLName: xml.Name{Local: header}, Attrs: attrs})
+				continue
+			}
+
 			r.Cells = append(r.Cells, Cell{
 				XMLName: xml.Name{Local: header},
+				Attrs:   cellAttrs[i],
 				Value:   cell,
 			})
 		}
@@ -196,6 +305,18 @@ func Marshal(cfg *common.Config, table *common.Table) error {
 	return nil
 }
 
+// hasNulls reports whether any data cell of the table is NULL
+func hasNulls(table *common.Table) bool {
+	for row := range table.Rows {
+		for col := range table.Rows[row] {
+			if table.IsNull(row, col) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // isValidXMLElementName checks if a string is a valid XML element name
 func isValidXMLElementName(name string) bool {
 	if name == "" {
@@ -416,6 +416,69 @@ func TestRoundTrip(t *testing.T) {
 	assert.Equal(t, original.Rows, result.Rows)
 }
 
+func TestTypedRoundTrip(t *testing.T) {
+	// Typed columns are written as xsi:type attributes and read back into the schema
+	original := &common.Table{
+		Headers: []string{"Name", "Age", "Score"},
+		Rows: [][]string{
+			{"Alice", "30", "9.5"},
+		},
+		Columns: []common.Column{
+			{Name: "Name", Type: common.ColumnTypeString},
+			{Name: "Age", Type: common.ColumnTypeInt},
+			{Name: "Score", Type: common.ColumnTypeFloat},
+		},
+	}
+
+	var buf bytes.Buffer
+	cfg := &common.Config{
+		Writer: &buf,
+		Extension: map[string]string{
+			"minify": "true",
+		},
+	}
+	err := Marshal(cfg, original)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `xsi:type="xs:integer"`)
+	assert.Contains(t, buf.String(), `xsi:type="xs:double"`)
+
+	cfg.Reader = &buf
+	result := &common.Table{}
+	err = Unmarshal(cfg, result)
+	require.NoError(t, err)
+
+	assert.Equal(t, original.Rows, result.Rows)
+	require.Len(t, result.Columns, 3)
+	assert.Equal(t, common.ColumnTypeString, result.Columns[0].Type)
+	assert.Equal(t, common.ColumnTypeInt, result.Columns[1].Type)
+	assert.Equal(t, common.ColumnTypeFloat, result.Columns[2].Type)
+}
+
+func TestNullRoundTrip(t *testing.T) {
+	// xsi:nil elements are NULL, the text "NULL" is a string
+	original := &common.Table{
+		Headers: []string{"a", "b"},
+		Rows:    [][]string{{"NULL", "NULL"}},
+	}
+	original.SetNull(0, 0)
+
+	var buf bytes.Buffer
+	cfg := &common.Config{
+		Writer:    &buf,
+		Extension: map[string]string{"minify": "true"},
+	}
+	require.NoError(t, Marshal(cfg, original))
+	assert.Contains(t, buf.String(), `xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"`)
+	assert.Contains(t, buf.String(), `<a xsi:nil="true"></a><b>NULL</b>`)
+
+	cfg.Reader = &buf
+	result := &common.Table{}
+	require.NoError(t, Unmarshal(cfg, result))
+	assert.Equal(t, original.Rows, result.Rows)
+	assert.True(t, result.IsNull(0, 0))
+	assert.False(t, result.IsNull(0, 1))
+}
+
 func TestUTF8Handling(t *testing.T) {
 	// Test UTF-8 characters in data
 	table := &common.Table{
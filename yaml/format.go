@@ -0,0 +1,59 @@
+package yaml
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+)
+
+func init() {
+	common.Register(&common.Format{
+		Name:        "yaml",
+		Description: "YAML (list of maps, 2d array, column-oriented, keyed)",
+		Aliases:     []string{"yml"},
+		Extensions:  []string{".yaml", ".yml"},
+		Detect:      detect,
+		DetectOrder: 12,
+		Params: []common.FormatParam{
+			{Name: "format", DefaultValue: "object", AllowedValues: "object, 2d, column, keyed", Description: "YAML Format, as for JSON"},
+			{Name: "parsing-json", DefaultValue: "false", AllowedValues: "true, false", Description: "Write numbers, booleans and null unquoted"},
+		},
+		Unmarshal:         Unmarshal,
+		Marshal:           Marshal,
+		UnmarshalDocument: UnmarshalDocument,
+		MarshalDocument:   MarshalDocument,
+	})
+}
+
+var (
+	// recordPattern matches the first line of a list of maps, e.g. "- name: web01"
+	recordPattern = regexp.MustCompile(`^-\s+("[^"]*"|'[^']*'|[^\s:#"'][^:#]*):(\s|$)`)
+	// keyPattern matches a top-level key that opens a nested block, e.g. "hosts:"
+	keyPattern = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s:#"'-][^:#]*):\s*$`)
+)
+
+// detect scores content starting with a YAML list of maps or a block under a top-level key
+func detect(sample string) int {
+	var lines []string
+	for _, line := range common.SampleLines(sample, 20) {
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > 0 && lines[0] == "---" {
+		lines = lines[1:]
+		if len(lines) == 0 {
+			return common.DetectWeak
+		}
+	}
+	switch {
+	case len(lines) == 0:
+		return common.DetectNone
+	case recordPattern.MatchString(lines[0]):
+		return 80
+	case len(lines) > 1 && keyPattern.MatchString(lines[0]) && strings.HasPrefix(lines[1], " "):
+		return common.DetectLikely
+	}
+	return common.DetectNone
+}
@@ -0,0 +1,473 @@
+package yaml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"gopkg.in/yaml.v3"
+)
+
+func Unmarshal(cfg *common.Config, table *common.Table) error {
+	root, err := parse(cfg)
+	if err != nil {
+		return err
+	}
+
+	// A mapping of tables holds several tables, read the first one
+	if isDocument(root) {
+		doc := &common.Document{}
+		if err := unmarshalDocument(cfg, root, doc); err != nil {
+			return err
+		}
+		if len(doc.Tables) == 0 {
+			return fmt.Errorf("no table found in YAML mapping")
+		}
+		*table = *doc.Tables[0]
+		return nil
+	}
+	return unmarshalTable(cfg, root, table)
+}
+
+// UnmarshalDocument reads a mapping whose keys are table names and whose
+// values are tables in the configured format. A list is read as a single table.
+func UnmarshalDocument(cfg *common.Config, doc *common.Document) error {
+	root, err := parse(cfg)
+	if err != nil {
+		return err
+	}
+
+	if !isDocument(root) {
+		table := &common.Table{}
+		if err := unmarshalTable(cfg, root, table); err != nil {
+			return err
+		}
+		doc.Tables = append(doc.Tables, table)
+		return nil
+	}
+	return unmarshalDocument(cfg, root, doc)
+}
+
+// parse reads the first YAML document of cfg.Reader, nil if the input is empty
+func parse(cfg *common.Config) (*yaml.Node, error) {
+	data, err := io.ReadAll(cfg.Reader)
+	if err != nil {
+		return nil, err
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+	return resolve(document.Content[0]), nil
+}
+
+// resolve follows aliases to the node they refer to
+func resolve(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// isDocument reports whether root is a mapping of table names to tables
+func isDocument(root *yaml.Node) bool {
+	return root != nil && root.Kind == yaml.MappingNode
+}
+
+// unmarshalDocument decodes a mapping of tables, keeping the order of its keys
+func unmarshalDocument(cfg *common.Config, root *yaml.Node, doc *common.Document) error {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		name := root.Content[i].Value
+		table := &common.Table{Name: name}
+		if err := unmarshalTable(cfg, resolve(root.Content[i+1]), table); err != nil {
+			return fmt.Errorf("table %q: %w", name, err)
+		}
+		doc.Tables = append(doc.Tables, table)
+	}
+	return nil
+}
+
+// unmarshalTable decodes one table in the format given by the format option.
+// Columns keep the order of their first appearance.
+func unmarshalTable(cfg *common.Config, node *yaml.Node, table *common.Table) error {
+	format := cfg.GetExtensionString("format", "")
+	table.TrackNulls()
+	if node == nil || isNull(node) {
+		return nil
+	}
+
+	switch format {
+	case "2d":
+		rows, err := sequence(node)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		headers, err := sequence(rows[0])
+		if err != nil {
+			return err
+		}
+		for _, header := range headers {
+			table.Headers = append(table.Headers, scalarText(header))
+		}
+		if err := cfg.CheckRow(0, table.Headers); err != nil {
+			return err
+		}
+		table.Columns = common.NewColumns(table.Headers)
+		for r, rowNode := range rows[1:] {
+			cells, err := sequence(rowNode)
+			if err != nil {
+				return err
+			}
+			row := make([]string, len(table.Headers))
+			for i := range table.Headers {
+				if i < len(cells) && !isNull(cells[i]) {
+					row[i] = cellValue(cells[i], &table.Columns[i])
+				} else {
+					// Handle null and missing values
+					row[i] = common.DefaultNullString
+					table.Columns[i].Nullable = true
+					table.SetNull(r, i)
+				}
+			}
+			if err := cfg.CheckRow(r, row); err != nil {
+				return err
+			}
+			table.Rows = append(table.Rows, row)
+		}
+
+	case "column":
+		items, err := sequence(node)
+		if err != nil {
+			return err
+		}
+		var values [][]*yaml.Node
+		for _, item := range items {
+			pairs, err := mapping(item)
+			if err != nil {
+				return err
+			}
+			for _, pair := range pairs {
+				column, err := sequence(pair[1])
+				if err != nil {
+					return fmt.Errorf("invalid column format for key %s", pair[0].Value)
+				}
+				table.Headers = append(table.Headers, pair[0].Value)
+				values = append(values, column)
+			}
+		}
+		if err := cfg.CheckRow(0, table.Headers); err != nil {
+			return err
+		}
+		table.Columns = common.NewColumns(table.Headers)
+		numRows := 0
+		for _, column := range values {
+			numRows = max(numRows, len(column))
+		}
+		for r := 0; r < numRows; r++ {
+			row := make([]string, len(table.Headers))
+			for i, column := range values {
+				if r >= len(column) {
+					table.Columns[i].Nullable = true
+					continue
+				}
+				row[i] = cellValue(column[r], &table.Columns[i])
+				if isNull(column[r]) {
+					table.SetNull(r, i)
+				}
+			}
+			if err := cfg.CheckRow(r, row); err != nil {
+				return err
+			}
+			table.Rows = append(table.Rows, row)
+		}
+
+	default: // List of maps, keyed too as json reads it
+		items, err := sequence(node)
+		if err != nil {
+			return err
+		}
+		records := make([][][2]*yaml.Node, len(items))
+		index := make(map[string]int)
+		for r, item := range items {
+			if records[r], err = mapping(item); err != nil {
+				return err
+			}
+			for _, field := range records[r] {
+				if _, ok := index[field[0].Value]; !ok {
+					index[field[0].Value] = len(table.Headers)
+					table.Headers = append(table.Headers, field[0].Value)
+				}
+			}
+		}
+		if err := cfg.CheckRow(0, table.Headers); err != nil {
+			return err
+		}
+		table.Columns = common.NewColumns(table.Headers)
+		for r := range records {
+			row := make([]string, len(table.Headers))
+			present := make([]bool, len(table.Headers))
+			for _, field := range records[r] {
+				i := index[field[0].Value]
+				present[i] = true
+				row[i] = cellValue(field[1], &table.Columns[i])
+				if isNull(field[1]) {
+					table.SetNull(r, i)
+				}
+			}
+			for i := range row {
+				if !present[i] {
+					table.Columns[i].Nullable = true
+				}
+			}
+			if err := cfg.CheckRow(r, row); err != nil {
+				return err
+			}
+			table.Rows = append(table.Rows, row)
+		}
+	}
+
+	finishColumns(table.Columns)
+	return nil
+}
+
+// sequence returns the items of a sequence node
+func sequence(node *yaml.Node) ([]*yaml.Node, error) {
+	node = resolve(node)
+	if node.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("line %d: expected a YAML list", node.Line)
+	}
+	items := make([]*yaml.Node, len(node.Content))
+	for i, item := range node.Content {
+		items[i] = resolve(item)
+	}
+	return items, nil
+}
+
+// mapping returns the key and value nodes of a mapping node, merge keys (<<) included
+func mapping(node *yaml.Node) ([][2]*yaml.Node, error) {
+	node = resolve(node)
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected a YAML mapping", node.Line)
+	}
+	var pairs [][2]*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], resolve(node.Content[i+1])
+		if key.Tag == "!!merge" {
+			merged, err := mapping(value)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, merged...)
+			continue
+		}
+		pairs = append(pairs, [2]*yaml.Node{key, value})
+	}
+	return pairs, nil
+}
+
+// isNull reports whether node is a null scalar such as ~ or null
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null"
+}
+
+// scalarText returns the text of a scalar, or the flow-style YAML of a collection
+func scalarText(node *yaml.Node) string {
+	node = resolve(node)
+	if node.Kind == yaml.ScalarNode {
+		if isNull(node) {
+			return common.DefaultNullString
+		}
+		return node.Value
+	}
+	flow := *node
+	flow.Style = yaml.FlowStyle
+	data, err := yaml.Marshal(&flow)
+	if err != nil {
+		return node.Value
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// cellValue converts a YAML node to its cell text and records its type in column
+func cellValue(node *yaml.Node, column *common.Column) string {
+	node = resolve(node)
+	if node.Kind != yaml.ScalarNode {
+		column.Observe(common.ColumnTypeString, "collection")
+		return scalarText(node)
+	}
+	switch node.ShortTag() {
+	case "!!null":
+		column.Nullable = true
+		return common.DefaultNullString
+	case "!!int":
+		column.Observe(common.ColumnTypeInt, "int")
+	case "!!float":
+		column.Observe(common.ColumnTypeFloat, "float")
+	case "!!bool":
+		var b bool
+		if err := node.Decode(&b); err == nil {
+			column.Observe(common.ColumnTypeBool, "bool")
+			return fmt.Sprint(b)
+		}
+	case "!!timestamp":
+		column.Observe(common.ColumnTypeDate, "timestamp")
+	default:
+		column.Observe(common.ColumnTypeString, "str")
+	}
+	return node.Value
+}
+
+// finishColumns types columns that only held nulls as strings
+func finishColumns(columns []common.Column) {
+	for i := range columns {
+		if columns[i].Type == common.ColumnTypeUnknown {
+			columns[i].Type = common.ColumnTypeString
+		}
+	}
+}
+
+func Marshal(cfg *common.Config, table *common.Table) error {
+	node, err := tableNode(cfg, table)
+	if err != nil {
+		return err
+	}
+	return encode(cfg, node)
+}
+
+// MarshalDocument writes a mapping with one key per table, in document order
+func MarshalDocument(cfg *common.Config, doc *common.Document) error {
+	names := doc.UniqueTableNames(nil)
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for i, table := range doc.Tables {
+		node, err := tableNode(cfg, table)
+		if err != nil {
+			return fmt.Errorf("table %q: %w", names[i], err)
+		}
+		root.Content = append(root.Content, stringNode(names[i]), node)
+	}
+	return encode(cfg, root)
+}
+
+// encode writes node as a YAML document
+func encode(cfg *common.Config, node *yaml.Node) error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	_, err := cfg.Writer.Write(buf.Bytes())
+	return err
+}
+
+// tableNode builds the node encoded for one table in the format given by the format option
+func tableNode(cfg *common.Config, table *common.Table) (*yaml.Node, error) {
+	format := cfg.GetExtensionString("format", "")
+	parsing := cfg.GetExtensionBool("parsing-json", false)
+
+	for _, row := range table.Rows {
+		if len(row) != len(table.Headers) {
+			return nil, fmt.Errorf("row length %d does not match header length %d", len(row), len(table.Headers))
+		}
+	}
+
+	switch format {
+	// 2D Array
+	case "2d":
+		output := &yaml.Node{Kind: yaml.SequenceNode}
+		headers := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, header := range table.Headers {
+			headers.Content = append(headers.Content, stringNode(header))
+		}
+		output.Content = append(output.Content, headers)
+		for r := range table.Rows {
+			record := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+			for i := range table.Headers {
+				value, err := valueNode(table.Value(r, i, parsing))
+				if err != nil {
+					return nil, err
+				}
+				record.Content = append(record.Content, value)
+			}
+			output.Content = append(output.Content, record)
+		}
+		return output, nil
+	// Column Array
+	case "column":
+		output := &yaml.Node{Kind: yaml.SequenceNode}
+		for i, header := range table.Headers {
+			values := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+			for r := range table.Rows {
+				value, err := valueNode(table.Value(r, i, parsing))
+				if err != nil {
+					return nil, err
+				}
+				values.Content = append(values.Content, value)
+			}
+			output.Content = append(output.Content, &yaml.Node{
+				Kind:    yaml.MappingNode,
+				Content: []*yaml.Node{stringNode(header), values},
+			})
+		}
+		return output, nil
+	// List of maps, keyed too as json writes it
+	default:
+		output := &yaml.Node{Kind: yaml.SequenceNode}
+		for r := range table.Rows {
+			record, err := recordNode(table, r, parsing)
+			if err != nil {
+				return nil, err
+			}
+			output.Content = append(output.Content, record)
+		}
+		return output, nil
+	}
+}
+
+// recordNode builds a mapping of the headers to the cells of row r
+func recordNode(table *common.Table, r int, parsing bool) (*yaml.Node, error) {
+	record := &yaml.Node{Kind: yaml.MappingNode}
+	for i := range table.Headers {
+		value, err := valueNode(table.Value(r, i, parsing))
+		if err != nil {
+			return nil, err
+		}
+		record.Content = append(record.Content, stringNode(table.Headers[i]), value)
+	}
+	return record, nil
+}
+
+// valueNode encodes a cell value; strings that read as other types are quoted
+func valueNode(value interface{}) (*yaml.Node, error) {
+	if s, ok := value.(string); ok {
+		return stringNode(s), nil
+	}
+	node := &yaml.Node{}
+	if err := node.Encode(value); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// stringNode returns a string scalar. Words that YAML 1.1 parsers such as
+// Ansible's read as booleans are quoted, e.g. "NO".
+func stringNode(s string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+	switch strings.ToLower(s) {
+	case "y", "n", "yes", "no", "on", "off":
+		node.Style = yaml.DoubleQuotedStyle
+	}
+	return node
+}
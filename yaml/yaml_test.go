@@ -0,0 +1,157 @@
+package yaml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/martianzhang/tableconvert/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func unmarshal(t *testing.T, input string, params map[string]string) *common.Table {
+	t.Helper()
+	table := &common.Table{}
+	cfg := &common.Config{Reader: strings.NewReader(input), Extension: params}
+	require.NoError(t, Unmarshal(cfg, table))
+	return table
+}
+
+func marshal(t *testing.T, table *common.Table, params map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	cfg := &common.Config{Writer: &buf, Extension: params}
+	require.NoError(t, Marshal(cfg, table))
+	return buf.String()
+}
+
+func TestUnmarshalListOfMaps(t *testing.T) {
+	input := `# inventory
+- name: web01
+  port: 8080
+  enabled: true
+- name: db01
+  weight: 0.5
+  enabled: ~
+`
+	table := unmarshal(t, input, nil)
+	// Columns keep the order of their first appearance
+	assert.Equal(t, []string{"name", "port", "enabled", "weight"}, table.Headers)
+	assert.Equal(t, []string{"web01", "8080", "true", ""}, table.Rows[0])
+	assert.Equal(t, []string{"db01", "", "NULL", "0.5"}, table.Rows[1])
+	assert.True(t, table.IsNull(1, 2))
+	assert.False(t, table.IsNull(1, 1))
+
+	assert.Equal(t, common.ColumnTypeString, table.Columns[0].Type)
+	assert.Equal(t, common.ColumnTypeInt, table.Columns[1].Type)
+	assert.Equal(t, common.ColumnTypeBool, table.Columns[2].Type)
+	assert.Equal(t, common.ColumnTypeFloat, table.Columns[3].Type)
+	assert.True(t, table.Columns[1].Nullable)
+}
+
+func TestUnmarshalAnchorsAndCollections(t *testing.T) {
+	input := `
+- &base {role: web, tags: [a, b]}
+- <<: *base
+  role: db
+`
+	table := unmarshal(t, input, nil)
+	assert.Equal(t, []string{"role", "tags"}, table.Headers)
+	assert.Equal(t, []string{"web", "[a, b]"}, table.Rows[0])
+	// The merged role comes first, the later key wins
+	assert.Equal(t, "db", table.Rows[1][0])
+}
+
+func TestLayoutsRoundTrip(t *testing.T) {
+	table := &common.Table{
+		Headers: []string{"host", "port", "note"},
+		Rows:    [][]string{{"web01", "80", "yes"}, {"db01", "5432", "123"}},
+	}
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"object", "- host: web01\n  port: 80\n  note: \"yes\"\n- host: db01\n  port: 5432\n  note: 123\n"},
+		{"2d", "- [host, port, note]\n- [web01, 80, \"yes\"]\n- [db01, 5432, 123]\n"},
+		{"column", "- host: [web01, db01]\n- port: [80, 5432]\n- note: [\"yes\", 123]\n"},
+		// As in json, keyed is written as a list of records
+		{"keyed", "- host: web01\n  port: 80\n  note: \"yes\"\n- host: db01\n  port: 5432\n  note: 123\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			params := map[string]string{"format": tt.format, "parsing-json": "true"}
+			out := marshal(t, table, params)
+			assert.Equal(t, tt.expected, out)
+
+			again := unmarshal(t, out, map[string]string{"format": tt.format})
+			assert.Equal(t, table.Rows, again.Rows)
+			assert.Equal(t, table.Headers, again.Headers)
+		})
+	}
+}
+
+func TestMarshalQuotesStrings(t *testing.T) {
+	table := &common.Table{
+		Headers: []string{"id", "flag", "text"},
+		Rows:    [][]string{{"007", "true", "null"}},
+	}
+	// Without parsing-json every cell stays a string
+	assert.Equal(t, "- id: \"007\"\n  flag: \"true\"\n  text: \"null\"\n", marshal(t, table, nil))
+	assert.Equal(t, "- id: 7\n  flag: true\n  text: null\n", marshal(t, table, map[string]string{"parsing-json": "true"}))
+}
+
+func TestNullRoundTrip(t *testing.T) {
+	input := "- a: null\n  b: \"null\"\n  c: \"\"\n"
+	table := unmarshal(t, input, nil)
+	assert.Equal(t, []string{"NULL", "null", ""}, table.Rows[0])
+	assert.True(t, table.IsNull(0, 0))
+	assert.False(t, table.IsNull(0, 1))
+	assert.Equal(t, input, marshal(t, table, nil))
+}
+
+func TestDocument(t *testing.T) {
+	input := "users:\n  - name: alice\nroles:\n  - role: admin\n"
+	doc := &common.Document{}
+	cfg := &common.Config{Reader: strings.NewReader(input)}
+	require.NoError(t, UnmarshalDocument(cfg, doc))
+	require.Len(t, doc.Tables, 2)
+	assert.Equal(t, "roles", doc.Tables[1].Name)
+	assert.Equal(t, [][]string{{"admin"}}, doc.Tables[1].Rows)
+
+	// A single table reads the first table of the mapping
+	assert.Equal(t, "users", unmarshal(t, input, nil).Name)
+
+	var buf bytes.Buffer
+	require.NoError(t, MarshalDocument(&common.Config{Writer: &buf}, doc))
+	assert.Equal(t, "users:\n  - name: alice\nroles:\n  - role: admin\n", buf.String())
+
+	// Tables with the same name get unique keys, a mapping cannot repeat one
+	doc.Tables[1].Name = "users"
+	buf.Reset()
+	require.NoError(t, MarshalDocument(&common.Config{Writer: &buf}, doc))
+	assert.Equal(t, "users:\n  - name: alice\nusers (2):\n  - role: admin\n", buf.String())
+	back := &common.Document{}
+	require.NoError(t, UnmarshalDocument(&common.Config{Reader: &buf}, back))
+	require.Len(t, back.Tables, 2)
+	assert.Equal(t, [][]string{{"admin"}}, back.Tables[1].Rows)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	cfg := &common.Config{Reader: strings.NewReader("- [1, 2]\n")}
+	assert.ErrorContains(t, Unmarshal(cfg, &common.Table{}), "expected a YAML mapping")
+
+	cfg = &common.Config{Reader: strings.NewReader("a: [\n")}
+	assert.Error(t, Unmarshal(cfg, &common.Table{}))
+
+	table := unmarshal(t, "", nil)
+	assert.Empty(t, table.Headers)
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, 80, detect("---\n# hosts\n- name: web01\n  ip: 10.0.0.1\n"))
+	assert.Equal(t, common.DetectLikely, detect("hosts:\n  - name: web01\n"))
+	assert.Equal(t, common.DetectNone, detect("- item one\n- item two\n"))
+	assert.Equal(t, common.DetectNone, detect("name,age\nAlice,30\n"))
+}